@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/search"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+const minPasswordLength = 8
+
+// passwordFlags adds the two ways of passing a password to a command.
+type passwordFlags struct {
+	password string
+	stdin    bool
+}
+
+func (p *passwordFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.password, "password", "", "new password (visible in shell history, prefer -password-stdin)")
+	fs.BoolVar(&p.stdin, "password-stdin", false, "read the password from the first line of stdin")
+}
+
+// resolve returns the password to set. When none was given it generates
+// one and reports that it did, so the caller can print it once.
+func (p *passwordFlags) resolve() (password string, generated bool, err error) {
+	switch {
+	case p.stdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", false, fmt.Errorf("read password from stdin: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	case p.password != "":
+		password = p.password
+	default:
+		token, err := utils.GenerateRandomToken()
+		if err != nil {
+			return "", false, err
+		}
+		return token[:20], true, nil
+	}
+
+	if len(password) < minPasswordLength {
+		return "", false, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return password, false, nil
+}
+
+func findUserByEmail(email string) (*models.User, error) {
+	if email == "" {
+		return nil, errors.New("-email is required")
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no user with email %s", email)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// auditCLI records an action taken from the CLI. There is no actor, the
+// person at the shell is not a Kandy user.
+func auditCLI(action string, userID uint, details map[string]interface{}) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["source"] = "cli"
+	encoded, _ := json.Marshal(details)
+
+	if err := utils.RecordAudit(database.DB, utils.AuditEntry{
+		Action:     action,
+		EntityType: "user",
+		EntityID:   &userID,
+		Details:    string(encoded),
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: failed to record audit event:", err)
+	}
+}
+
+func createAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the admin (required)")
+	name := fs.String("name", "", "display name (required)")
+	var pw passwordFlags
+	pw.register(fs)
+	fs.Parse(args)
+
+	if *email == "" || *name == "" {
+		fs.Usage()
+		return errors.New("-email and -name are required")
+	}
+
+	var existing int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", *email).Count(&existing)
+	if existing > 0 {
+		return fmt.Errorf("a user with email %s already exists, use set-role to promote them", *email)
+	}
+
+	password, generated, err := pw.resolve()
+	if err != nil {
+		return err
+	}
+
+	user := models.User{
+		Email:         *email,
+		Name:          *name,
+		Role:          models.RoleAdmin,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+	if err := user.HashPassword(password); err != nil {
+		return err
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return fmt.Errorf("create admin: %w", err)
+	}
+
+	auditCLI(models.AuditUserCreated, user.ID, map[string]interface{}{
+		"role": user.Role,
+	})
+
+	fmt.Printf("Created admin %s (id %d)\n", user.Email, user.ID)
+	if generated {
+		fmt.Printf("Generated password: %s\n", password)
+	}
+	return nil
+}
+
+func setRole(args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user (required)")
+	role := fs.String("role", "", "new role: "+roleNames()+" (required)")
+	fs.Parse(args)
+
+	newRole := models.UserRole(*role)
+	if !newRole.IsValid() {
+		fs.Usage()
+		return fmt.Errorf("-role must be one of %s", roleNames())
+	}
+
+	user, err := findUserByEmail(*email)
+	if err != nil {
+		return err
+	}
+
+	previous := user.Role
+	if previous == newRole {
+		fmt.Printf("%s already has role %s\n", user.Email, newRole)
+		return nil
+	}
+
+	// Tokens carry the role, so existing sessions would keep the old one
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", newRole).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Session{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("set role: %w", err)
+	}
+
+	auditCLI(models.AuditRoleChanged, user.ID, map[string]interface{}{
+		"from": previous,
+		"to":   newRole,
+	})
+
+	fmt.Printf("Changed role of %s from %s to %s and signed them out\n", user.Email, previous, newRole)
+	return nil
+}
+
+func resetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user (required)")
+	keepSessions := fs.Bool("keep-sessions", false, "do not sign the user out of existing sessions")
+	var pw passwordFlags
+	pw.register(fs)
+	fs.Parse(args)
+
+	user, err := findUserByEmail(*email)
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := pw.resolve()
+	if err != nil {
+		return err
+	}
+	if err := user.HashPassword(password); err != nil {
+		return err
+	}
+	user.ResetPasswordToken = nil
+	user.ResetPasswordExpiry = nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if *keepSessions {
+			return nil
+		}
+		return tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Session{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	auditCLI(models.AuditPasswordReset, user.ID, nil)
+
+	fmt.Printf("Reset password for %s\n", user.Email)
+	if generated {
+		fmt.Printf("Generated password: %s\n", password)
+	}
+	return nil
+}
+
+func revokeSessions(args []string) error {
+	fs := flag.NewFlagSet("revoke-sessions", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	all := fs.Bool("all", false, "revoke every session of every user")
+	fs.Parse(args)
+
+	if *all == (*email != "") {
+		fs.Usage()
+		return errors.New("pass either -email or -all")
+	}
+
+	if *all {
+		result := database.DB.Unscoped().Where("1 = 1").Delete(&models.Session{})
+		if result.Error != nil {
+			return fmt.Errorf("revoke sessions: %w", result.Error)
+		}
+		fmt.Printf("Revoked %d sessions\n", result.RowsAffected)
+		return nil
+	}
+
+	user, err := findUserByEmail(*email)
+	if err != nil {
+		return err
+	}
+
+	result := database.DB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("revoke sessions: %w", result.Error)
+	}
+
+	auditCLI(models.AuditSessionsRevoked, user.ID, map[string]interface{}{
+		"revoked": result.RowsAffected,
+	})
+
+	fmt.Printf("Revoked %d sessions of %s\n", result.RowsAffected, user.Email)
+	return nil
+}
+
+func listUsers(args []string) error {
+	fs := flag.NewFlagSet("list-users", flag.ExitOnError)
+	role := fs.String("role", "", "only users with this role")
+	inactive := fs.Bool("inactive", false, "only inactive users")
+	deleted := fs.Bool("deleted", false, "include soft deleted users")
+	fs.Parse(args)
+
+	query := database.DB.Order("id")
+	if *deleted {
+		query = query.Unscoped()
+	}
+	if *role != "" {
+		query = query.Where("role = ?", *role)
+	}
+	if *inactive {
+		query = query.Where("is_active = ?", false)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tROLE\tSTATUS\tLAST LOGIN")
+	for _, user := range users {
+		lastLogin := "never"
+		if user.LastLoginAt != nil {
+			lastLogin = user.LastLoginAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			user.ID, user.Email, user.Name, user.Role, userStatus(&user), lastLogin)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d users\n", len(users))
+	return nil
+}
+
+func userStatus(user *models.User) string {
+	switch {
+	case user.DeletedAt.Valid:
+		return "deleted"
+	case user.IsAwaitingApproval():
+		return "awaiting approval"
+	case user.InvitationStatus() == models.InvitationPending:
+		return "invited"
+	case user.InvitationStatus() == models.InvitationExpired:
+		return "invitation expired"
+	case user.IsDeletionPending():
+		return "deletion pending"
+	case !user.IsActive:
+		return "inactive"
+	default:
+		return "active"
+	}
+}
+
+func cleanup(args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	fs.Parse(args)
+
+	utils.CleanupExpiredSessions()
+	utils.CleanupOldLoginAttempts()
+	utils.CleanupExpiredMagicLinks()
+	utils.ProcessScheduledDeletions()
+	utils.ProcessInvitationLifecycle()
+	utils.CleanupSearchIndexEvents()
+
+	fmt.Println("Cleanup finished")
+	return nil
+}
+
+func verifyAudit(args []string) error {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	checkpoint := fs.Bool("checkpoint", false, "sign a new checkpoint after a successful verification")
+	fs.Parse(args)
+
+	result, err := utils.VerifyAuditChain()
+	if err != nil {
+		return fmt.Errorf("verify audit log: %w", err)
+	}
+
+	fmt.Printf("Entries checked:     %d\n", result.EntriesChecked)
+	fmt.Printf("Checkpoints checked: %d\n", result.CheckpointsChecked)
+	fmt.Printf("Head sequence:       %d\n", result.HeadSequence)
+
+	if !result.Valid {
+		return fmt.Errorf("audit log is broken at sequence %d: %s", *result.BrokenAtSequence, result.Reason)
+	}
+	fmt.Println("Audit log is intact")
+
+	if *checkpoint {
+		cp, err := utils.CreateAuditCheckpoint()
+		if err != nil {
+			return fmt.Errorf("create checkpoint: %w", err)
+		}
+		if cp != nil {
+			fmt.Printf("Signed checkpoint at sequence %d\n", cp.Sequence)
+		}
+	}
+	return nil
+}
+
+func reindex(args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	fs.Parse(args)
+
+	// The servers hold their embedded indexes open, so they are asked to
+	// rebuild them rather than having them rebuilt from here
+	if err := utils.QueueSearchRebuild(); err != nil {
+		return fmt.Errorf("queue search rebuild: %w", err)
+	}
+
+	if search.Driver() == "postgres" {
+		fmt.Println("Queued a search index rebuild. SEARCH_DRIVER is postgres here, which needs no index; servers with an embedded index rebuild theirs")
+		return nil
+	}
+	fmt.Println("Queued a search index rebuild; servers rebuild their index within SEARCH_SYNC_INTERVAL, or when they next start")
+	return nil
+}
+
+// findDuplicates compares every candidate with everyone else, for the
+// candidates added before duplicate detection or while it failed.
+func findDuplicates(args []string) error {
+	fs := flag.NewFlagSet("find-duplicates", flag.ExitOnError)
+	fs.Parse(args)
+
+	var ids []uint
+	if err := database.DB.Model(&models.Candidate{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("list candidates: %w", err)
+	}
+	for _, id := range ids {
+		if _, err := utils.DetectCandidateDuplicates(database.DB, id); err != nil {
+			return fmt.Errorf("candidate %d: %w", id, err)
+		}
+	}
+
+	var pending int64
+	if err := database.DB.Model(&models.CandidateDuplicate{}).
+		Where("status = ?", models.DuplicatePending).
+		Count(&pending).Error; err != nil {
+		return fmt.Errorf("count duplicates: %w", err)
+	}
+	fmt.Printf("Compared %d candidates, %d possible duplicates are waiting for review\n", len(ids), pending)
+	return nil
+}
+
+func roleNames() string {
+	names := make([]string, len(models.ValidRoles))
+	for i, role := range models.ValidRoles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
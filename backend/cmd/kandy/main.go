@@ -0,0 +1,77 @@
+// Command kandy manages a Kandy installation from the shell: bootstrapping
+// the first admin, fixing up accounts and running maintenance jobs.
+//
+// It reads the same .env / environment variables as the server.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/joho/godotenv"
+	"github.com/sebastian/kandy/backend/database"
+	"gorm.io/gorm/logger"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"create-admin":    {"Create an admin account", createAdmin},
+	"set-role":        {"Change a user's role", setRole},
+	"reset-password":  {"Set a new password for a user", resetPassword},
+	"revoke-sessions": {"Sign a user, or everyone, out of all sessions", revokeSessions},
+	"list-users":      {"List user accounts", listUsers},
+	"cleanup":         {"Run the periodic cleanup jobs once", cleanup},
+	"verify-audit":    {"Verify the audit log hash chain", verifyAudit},
+	"reindex":         {"Rebuild the search index from the database", reindex},
+	"find-duplicates": {"Look for duplicate candidates among everyone", findDuplicates},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage()
+		return
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables or defaults")
+	}
+	database.ConnectWithLogLevel(logger.Warn)
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: kandy <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'kandy <command> -h' for the flags of a command.")
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// backfillApplicationActivities gives applications from before the
+// activity timeline the entries that can be reconstructed from their
+// columns: applied into the first stage, a move to the current stage and
+// a rejection or withdrawal. The entries are marked as backfilled.
+func backfillApplicationActivities(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var applications []models.Application
+		if err := tx.Unscoped().
+			Where("id NOT IN (?)", tx.Model(&models.ApplicationActivity{}).Select("application_id")).
+			Find(&applications).Error; err != nil {
+			return err
+		}
+		if len(applications) == 0 {
+			return nil
+		}
+
+		var stages []models.JobStage
+		if err := tx.Order("position").Find(&stages).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.JobStage, len(stages))
+		first := make(map[uint]*models.JobStage)
+		for i := range stages {
+			stage := &stages[i]
+			byID[stage.ID] = stage
+			if first[stage.JobID] == nil {
+				first[stage.JobID] = stage
+			}
+		}
+
+		activities := []models.ApplicationActivity{}
+		for _, application := range applications {
+			current, start := byID[application.StageID], first[application.JobID]
+			if current == nil || start == nil {
+				continue
+			}
+			entry := func(activityType models.ActivityType) models.ApplicationActivity {
+				return models.ApplicationActivity{
+					ApplicationID: application.ID,
+					CandidateID:   application.CandidateID,
+					JobID:         application.JobID,
+					Type:          activityType,
+					Details:       map[string]interface{}{"backfilled": true},
+				}
+			}
+
+			applied := entry(models.ActivityApplied)
+			applied.ActorID = application.CreatedBy
+			applied.ToStageID, applied.ToStage = &start.ID, start.Name
+			applied.ToStatus = models.ApplicationActive
+			applied.OccurredAt = application.AppliedAt
+			activities = append(activities, applied)
+
+			if current.ID != start.ID {
+				moved := entry(models.ActivityStageChanged)
+				moved.FromStageID, moved.FromStage = &start.ID, start.Name
+				moved.ToStageID, moved.ToStage = &current.ID, current.Name
+				moved.OccurredAt = application.StageChangedAt
+				activities = append(activities, moved)
+			}
+
+			closed := entry(models.ActivityStatusChanged)
+			closed.FromStatus, closed.ToStatus = models.ApplicationActive, application.Status
+			switch {
+			case application.Status == models.ApplicationRejected && application.RejectedAt != nil:
+				closed.OccurredAt = *application.RejectedAt
+				closed.Body = application.RejectionNote
+				closed.Details["rejection_reason"] = application.RejectionReason
+				activities = append(activities, closed)
+			case application.Status == models.ApplicationWithdrawn && application.WithdrawnAt != nil:
+				closed.OccurredAt = *application.WithdrawnAt
+				activities = append(activities, closed)
+			}
+		}
+		if len(activities) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&activities, 500).Error
+	})
+}
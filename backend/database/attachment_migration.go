@@ -0,0 +1,139 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+	"gorm.io/gorm"
+)
+
+// legacyFile is a file kept in columns of its record before attachments
+type legacyFile struct {
+	ID          uint
+	Key         string
+	Name        string
+	ContentType string
+}
+
+// migrateApplicationFiles turns the resumes and answer files kept in
+// columns of applications and answers into attachments. The content stays
+// where it is; it is only hashed. It does nothing once the old columns
+// are gone.
+func migrateApplicationFiles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&models.Application{}, "resume_key") {
+			var resumes []legacyFile
+			if err := tx.Table("applications").
+				Select("id, resume_key AS key, resume_name AS name, resume_content_type AS content_type").
+				Where("resume_key IS NOT NULL").
+				Scan(&resumes).Error; err != nil {
+				return err
+			}
+			for _, resume := range resumes {
+				attachment, err := attachLegacyFile(tx, resume, resume.ID, models.AttachmentResume)
+				if err != nil {
+					return err
+				}
+				if attachment == nil {
+					continue
+				}
+				if err := tx.Table("applications").Where("id = ?", resume.ID).
+					Update("resume_id", attachment.ID).Error; err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"resume_key", "resume_name", "resume_content_type", "resume_size"} {
+				if err := tx.Migrator().DropColumn(&models.Application{}, column); err != nil {
+					return err
+				}
+			}
+		}
+
+		if tx.Migrator().HasColumn(&models.ApplicationAnswer{}, "file_key") {
+			var files []struct {
+				legacyFile
+				ApplicationID uint
+			}
+			if err := tx.Table("application_answers").
+				Select("id, application_id, file_key AS key, file_name AS name, file_content_type AS content_type").
+				Where("file_key IS NOT NULL").
+				Scan(&files).Error; err != nil {
+				return err
+			}
+			for _, file := range files {
+				attachment, err := attachLegacyFile(tx, file.legacyFile, file.ApplicationID, models.AttachmentAnswer)
+				if err != nil {
+					return err
+				}
+				if attachment == nil {
+					continue
+				}
+				if err := tx.Table("application_answers").Where("id = ?", file.ID).
+					Update("attachment_id", attachment.ID).Error; err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"file_key", "file_name", "file_content_type", "file_size"} {
+				if err := tx.Migrator().DropColumn(&models.ApplicationAnswer{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// attachLegacyFile attaches a file to an application. Files missing from
+// storage are skipped and give nil; content that is already stored is not
+// kept twice.
+func attachLegacyFile(tx *gorm.DB, legacy legacyFile, applicationID uint, kind models.AttachmentKind) (*models.Attachment, error) {
+	reader, err := storage.Get().Open(legacy.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Skipping missing file %s of application %d", legacy.Key, applicationID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	reader.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	file := models.StoredFile{
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Key:         legacy.Key,
+		Size:        size,
+		ContentType: legacy.ContentType,
+	}
+	var existing models.StoredFile
+	err = tx.Where("sha256 = ?", file.SHA256).First(&existing).Error
+	switch {
+	case err == nil:
+		if existing.Key != legacy.Key {
+			storage.Get().Delete(legacy.Key)
+		}
+		file = existing
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Create(&file).Error; err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	attachment := models.Attachment{
+		ApplicationID: applicationID,
+		FileID:        file.ID,
+		Kind:          kind,
+		Name:          legacy.Name,
+	}
+	return &attachment, tx.Create(&attachment).Error
+}
@@ -14,6 +14,12 @@ import (
 var DB *gorm.DB
 
 func Connect() {
+	ConnectWithLogLevel(logger.Info)
+}
+
+// ConnectWithLogLevel connects and migrates like Connect, with a quieter
+// SQL logger for tools such as the kandy CLI.
+func ConnectWithLogLevel(level logger.LogLevel) {
 	host := getEnv("DB_HOST", "localhost")
 	port := getEnv("DB_PORT", "5432")
 	user := getEnv("DB_USER", "kandy")
@@ -25,7 +31,7 @@ func Connect() {
 
 	var err error
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logger.Default.LogMode(level),
 	})
 
 	if err != nil {
@@ -39,10 +45,59 @@ func Connect() {
 		&models.User{},
 		&models.Session{},
 		&models.LoginAttempt{},
+		&models.UserDevice{},
+		&models.UserLoginCountry{},
+		&models.MagicLinkToken{},
+		&models.MagicLinkRequestLog{},
+		&models.Setting{},
+		&models.EmailOutbox{},
+		&models.InvitationEvent{},
+		&models.Department{},
+		&models.Location{},
+		&models.Job{},
+		&models.JobTransition{},
+		&models.JobApproval{},
+		&models.JobTeamMember{},
+		&models.PipelineTemplate{},
+		&models.JobStage{},
+		&models.LibraryQuestion{},
+		&models.JobQuestion{},
+		&models.CustomField{},
+		&models.Candidate{},
+		&models.CandidateDuplicate{},
+		&models.CandidateMerge{},
+		&models.StoredFile{},
+		&models.Attachment{},
+		&models.ResumeParse{},
+		&models.Application{},
+		&models.ApplicationActivity{},
+		&models.PublicSubmission{},
+		&models.ApplicationAnswer{},
+		&models.AuditLog{},
+		&models.AuditCheckpoint{},
+		&models.SearchIndexEvent{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := migrateJobPipelines(DB); err != nil {
+		log.Fatal("Failed to migrate job pipelines:", err)
+	}
+	if err := backfillApplicationActivities(DB); err != nil {
+		log.Fatal("Failed to backfill application activity:", err)
+	}
+	if err := migrateApplicationFiles(DB); err != nil {
+		log.Fatal("Failed to migrate application files:", err)
+	}
+	if err := queueResumeParses(DB); err != nil {
+		log.Fatal("Failed to queue resume parsing:", err)
+	}
+	if err := migrateSearchColumns(DB); err != nil {
+		log.Fatal("Failed to migrate search columns:", err)
+	}
+	if err := backfillCandidateMatchKeys(DB); err != nil {
+		log.Fatal("Failed to backfill candidate duplicate keys:", err)
+	}
 
 	log.Println("Database migration completed")
 }
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// backfillCandidateMatchKeys computes the duplicate keys of candidates
+// saved before duplicate detection. Saving keeps them up to date after.
+func backfillCandidateMatchKeys(db *gorm.DB) error {
+	var batch []models.Candidate
+	return db.Unscoped().
+		Select("id", "name", "email", "phone").
+		Where("name_tokens IS NULL").
+		FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+			for i := range batch {
+				batch[i].SetMatchKeys()
+				if err := db.Model(&batch[i]).
+					Select("email_key", "phone_key", "name_tokens").
+					UpdateColumns(&batch[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
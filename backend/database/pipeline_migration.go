@@ -0,0 +1,55 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// migrateJobPipelines gives jobs from before per-job pipelines the default
+// stages and moves their applications from the old stage names to them.
+// It does nothing once every job has stages and the old column is gone.
+func migrateJobPipelines(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var jobIDs []uint
+		if err := tx.Model(&models.Job{}).Unscoped().
+			Where("id NOT IN (?)", tx.Model(&models.JobStage{}).Select("job_id")).
+			Pluck("id", &jobIDs).Error; err != nil {
+			return err
+		}
+		for _, jobID := range jobIDs {
+			stages := make([]models.JobStage, len(models.DefaultPipeline))
+			for i, stage := range models.DefaultPipeline {
+				stages[i] = models.JobStage{JobID: jobID, Name: stage.Name, Type: stage.Type, Position: i + 1}
+			}
+			if err := tx.Create(&stages).Error; err != nil {
+				return err
+			}
+		}
+
+		if !tx.Migrator().HasColumn(&models.Application{}, "stage") {
+			return nil
+		}
+
+		// The old fixed stages map one to one onto the default pipeline
+		oldStages := []string{"new", "screening", "interview", "offer", "hired"}
+		for i, old := range oldStages {
+			if err := tx.Exec(`UPDATE applications SET stage_id = job_stages.id
+				FROM job_stages
+				WHERE job_stages.job_id = applications.job_id AND job_stages.position = ?
+				AND applications.stage = ? AND applications.stage_id IS NULL`, i+1, old).Error; err != nil {
+				return err
+			}
+		}
+
+		var unmapped int64
+		if err := tx.Model(&models.Application{}).Unscoped().Where("stage_id IS NULL").Count(&unmapped).Error; err != nil {
+			return err
+		}
+		if unmapped > 0 {
+			return fmt.Errorf("%d applications could not be mapped to a pipeline stage", unmapped)
+		}
+		return tx.Migrator().DropColumn(&models.Application{}, "stage")
+	})
+}
@@ -0,0 +1,16 @@
+package database
+
+import (
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// queueResumeParses queues the resumes attached before resume parsing for
+// the resume parser, so their candidates get filled in too. Resumes that
+// were queued already are left alone.
+func queueResumeParses(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO resume_parses (attachment_id, status, attempts, created_at, updated_at)
+		SELECT id, ?, 0, NOW(), NOW() FROM attachments
+		WHERE kind = ? AND id NOT IN (SELECT attachment_id FROM resume_parses)`,
+		models.ResumeParsePending, models.AttachmentResume).Error
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// SearchConfig is the text search configuration of the search columns.
+// Queries against them have to use the same one.
+const SearchConfig = "english"
+
+// searchColumns are the generated tsvector columns full-text search runs
+// on, by table. They are not part of the models, so saving a model never
+// writes them. Each column's comment holds a hash of its expression; when
+// an expression changes the column is dropped and generated again.
+var searchColumns = map[string]string{
+	// Names and email addresses weigh most; email addresses are also split
+	// into words, so a search for the domain finds them
+	"candidates": `setweight(to_tsvector('english', coalesce(name, '') || ' ' || coalesce(email, '')), 'A') ||
+		setweight(to_tsvector('simple', translate(coalesce(email, ''), '@.', '  ')), 'A') ||
+		setweight(to_tsvector('english', coalesce(current_title, '') || ' ' || coalesce(current_company, '')), 'B') ||
+		setweight(jsonb_to_tsvector('english', coalesce(skills, '[]'::jsonb), '["string"]'), 'B') ||
+		setweight(jsonb_to_tsvector('english', coalesce(tags, '[]'::jsonb), '["string"]'), 'B') ||
+		setweight(to_tsvector('english', coalesce(location, '')), 'C') ||
+		setweight(jsonb_to_tsvector('english', coalesce(custom_fields, '{}'::jsonb), '["string"]'), 'C')`,
+	"resume_parses": `to_tsvector('english', coalesce(text, ''))`,
+	// Only notes are searched; other entries get no vector
+	"application_activities": `CASE WHEN type = 'note' THEN to_tsvector('english', coalesce(body, '')) END`,
+}
+
+// migrateSearchColumns adds the search columns and their GIN indexes, and
+// generates the ones whose expression changed again.
+func migrateSearchColumns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for table, expression := range searchColumns {
+			sum := sha256.Sum256([]byte(expression))
+			version := hex.EncodeToString(sum[:8])
+
+			var current string
+			err := tx.Raw(`SELECT coalesce(col_description(attrelid, attnum), '') FROM pg_attribute
+				WHERE attrelid = ?::regclass AND attname = 'search_vector' AND NOT attisdropped`, table).
+				Row().Scan(&current)
+			exists := err == nil
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			if exists && current != version {
+				// The index goes with the column
+				if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN search_vector", table)).Error; err != nil {
+					return err
+				}
+				exists = false
+			}
+			if !exists {
+				if err := tx.Exec(fmt.Sprintf(
+					"ALTER TABLE %s ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (%s) STORED",
+					table, expression)).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(fmt.Sprintf(
+					"COMMENT ON COLUMN %s.search_vector IS '%s'", table, version)).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec(fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector)",
+				table, table)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
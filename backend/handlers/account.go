@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+)
+
+type AccountDeletionRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ExportAccountData returns everything Kandy stores about the current user
+// as a downloadable JSON archive.
+func ExportAccountData(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var sessions []models.Session
+	var devices []models.UserDevice
+	var countries []models.UserLoginCountry
+	var loginAttempts []models.LoginAttempt
+	var invitations []models.User
+	var auditEvents []models.AuditLog
+
+	queries := []error{
+		database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&sessions).Error,
+		database.DB.Where("user_id = ?", user.ID).Order("first_seen_at").Find(&devices).Error,
+		database.DB.Where("user_id = ?", user.ID).Order("first_seen_at").Find(&countries).Error,
+		database.DB.Where("LOWER(email) IN ?", user.EmailAddresses()).Order("created_at DESC").Find(&loginAttempts).Error,
+		database.DB.Unscoped().Where("invited_by = ?", user.ID).Order("created_at DESC").Find(&invitations).Error,
+		database.DB.Where("actor_id = ?", user.ID).Order("sequence").Find(&auditEvents).Error,
+	}
+	for _, err := range queries {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account data"})
+			return
+		}
+	}
+
+	invitationList := make([]gin.H, len(invitations))
+	for i, invited := range invitations {
+		invitationList[i] = gin.H{
+			"id":                     invited.ID,
+			"email":                  invited.Email,
+			"name":                   invited.Name,
+			"role":                   invited.Role,
+			"invitation_sent_at":     invited.InvitationSentAt,
+			"invitation_accepted_at": invited.InvitationAcceptedAt,
+		}
+	}
+
+	recordAudit(c, database.DB, models.AuditDataExported, "user", user.ID, nil)
+
+	filename := fmt.Sprintf("kandy-export-%d-%s.json", user.ID, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, gin.H{
+		"export_version":   1,
+		"generated_at":     time.Now(),
+		"profile":          user,
+		"previous_emails":  user.PreviousEmails,
+		"notifications":    user.Notifications(),
+		"sessions":         sessions,
+		"devices":          devices,
+		"login_countries":  countries,
+		"login_attempts":   loginAttempts,
+		"invitations_sent": invitationList,
+		"audit_events":     auditEvents,
+	})
+}
+
+// RequestAccountDeletion schedules the current user's account for
+// anonymization once the grace period has passed.
+func RequestAccountDeletion(c *gin.Context) {
+	var req AccountDeletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if user.IsDeletionPending() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                 "Account deletion has already been requested",
+			"deletion_scheduled_at": user.DeletionScheduledAt,
+		})
+		return
+	}
+
+	now := time.Now()
+	scheduledAt := now.Add(utils.AccountDeletionGracePeriod())
+	user.DeletionRequestedAt = &now
+	user.DeletionScheduledAt = &scheduledAt
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditDeletionRequested, "user", user.ID, gin.H{
+		"scheduled_at": scheduledAt,
+	})
+
+	utils.SendEmailAsync(utils.Email{
+		To:      user.Email,
+		Subject: "Your Kandy account is scheduled for deletion",
+		Body: fmt.Sprintf(`Hi %s,
+
+We received a request to delete your Kandy account. Your personal data will be
+permanently anonymized on %s.
+
+If you did not ask for this, or changed your mind, sign in and cancel the
+deletion from your profile before then:
+%s
+`, user.Name, scheduledAt.Format("January 2, 2006"), utils.AppURL("/settings")),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":               "Account deletion scheduled",
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+func CancelAccountDeletion(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.IsDeletionPending() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No account deletion is pending"})
+		return
+	}
+
+	user.DeletionRequestedAt = nil
+	user.DeletionScheduledAt = nil
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditDeletionCanceled, "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account deletion cancelled",
+	})
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+var scorecardRecommendations = []string{"strong_yes", "yes", "no", "strong_no"}
+
+// ActivityRequest logs a note, an email, an interview or a scorecard on an
+// application's timeline. occurred_at defaults to now and may be in the
+// past for things that happened elsewhere.
+type ActivityRequest struct {
+	Type       models.ActivityType `json:"type" binding:"required"`
+	Body       string              `json:"body"`
+	OccurredAt *time.Time          `json:"occurred_at"`
+
+	// Emails
+	Subject   string `json:"subject" binding:"max=200"`
+	Direction string `json:"direction"`
+
+	// Interviews
+	Title           string `json:"title" binding:"max=200"`
+	DurationMinutes int    `json:"duration_minutes" binding:"min=0,max=1440"`
+
+	// Scorecards
+	Rating         int    `json:"rating"`
+	Recommendation string `json:"recommendation"`
+}
+
+// normalize validates the request and returns the details stored with the
+// activity.
+func (r *ActivityRequest) normalize() (map[string]interface{}, error) {
+	r.Body = strings.TrimSpace(r.Body)
+	r.Subject = strings.TrimSpace(r.Subject)
+	r.Title = strings.TrimSpace(r.Title)
+
+	if !r.Type.IsLogged() {
+		return nil, errors.New("type must be note, email, interview or scorecard")
+	}
+	if r.OccurredAt != nil && r.OccurredAt.After(time.Now().Add(time.Minute)) && r.Type != models.ActivityInterview {
+		return nil, errors.New("occurred_at cannot be in the future")
+	}
+
+	details := map[string]interface{}{}
+	switch r.Type {
+	case models.ActivityNote:
+		if r.Body == "" {
+			return nil, errors.New("a note needs a body")
+		}
+	case models.ActivityEmail:
+		if r.Subject == "" {
+			return nil, errors.New("an email needs a subject")
+		}
+		if r.Direction == "" {
+			r.Direction = "outbound"
+		}
+		if r.Direction != "outbound" && r.Direction != "inbound" {
+			return nil, errors.New("direction must be outbound or inbound")
+		}
+		details["subject"] = r.Subject
+		details["direction"] = r.Direction
+	case models.ActivityInterview:
+		if r.Title == "" {
+			return nil, errors.New("an interview needs a title")
+		}
+		details["title"] = r.Title
+		if r.DurationMinutes > 0 {
+			details["duration_minutes"] = r.DurationMinutes
+		}
+	case models.ActivityScorecard:
+		if r.Rating < 1 || r.Rating > 5 {
+			return nil, errors.New("rating must be between 1 and 5")
+		}
+		if !isScorecardRecommendation(r.Recommendation) {
+			return nil, errors.New("recommendation must be strong_yes, yes, no or strong_no")
+		}
+		details["rating"] = r.Rating
+		details["recommendation"] = r.Recommendation
+	}
+	return details, nil
+}
+
+func isScorecardRecommendation(recommendation string) bool {
+	for _, valid := range scorecardRecommendations {
+		if recommendation == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// activitiesJSON adds the actor's name and, for timelines spanning several
+// jobs, the job title to each activity.
+func activitiesJSON(activities []models.ApplicationActivity, withJobTitle bool) []gin.H {
+	actorIDs := []uint{}
+	jobIDs := []uint{}
+	for _, activity := range activities {
+		if activity.ActorID != nil {
+			actorIDs = append(actorIDs, *activity.ActorID)
+		}
+		jobIDs = append(jobIDs, activity.JobID)
+	}
+	names := userNames(actorIDs)
+
+	titles := make(map[uint]string)
+	if withJobTitle && len(jobIDs) > 0 {
+		var jobs []models.Job
+		database.DB.Unscoped().Select("id", "title").Where("id IN ?", jobIDs).Find(&jobs)
+		for _, job := range jobs {
+			titles[job.ID] = job.Title
+		}
+	}
+
+	entries := make([]gin.H, len(activities))
+	for i, activity := range activities {
+		entry := gin.H{
+			"id":             activity.ID,
+			"application_id": activity.ApplicationID,
+			"candidate_id":   activity.CandidateID,
+			"job_id":         activity.JobID,
+			"type":           activity.Type,
+			"actor_id":       activity.ActorID,
+			"actor_name":     "System",
+			"from_stage_id":  activity.FromStageID,
+			"from_stage":     activity.FromStage,
+			"to_stage_id":    activity.ToStageID,
+			"to_stage":       activity.ToStage,
+			"from_status":    activity.FromStatus,
+			"to_status":      activity.ToStatus,
+			"body":           activity.Body,
+			"details":        activity.Details,
+			"occurred_at":    activity.OccurredAt,
+			"created_at":     activity.CreatedAt,
+		}
+		if activity.ActorID != nil {
+			entry["actor_name"] = names[*activity.ActorID]
+		}
+		if withJobTitle {
+			entry["job_title"] = titles[activity.JobID]
+		}
+		entries[i] = entry
+	}
+	return entries
+}
+
+// listActivities paginates query newest first. Filter with ?type= (comma
+// separated).
+func listActivities(c *gin.Context, query *gorm.DB, withJobTitle bool) {
+	if activityType := c.Query("type"); activityType != "" {
+		query = query.Where("type IN ?", strings.Split(activityType, ","))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve activity"})
+		return
+	}
+
+	page := parsePagination(c)
+	activities := []models.ApplicationActivity{}
+	if err := page.apply(query).
+		Order("occurred_at DESC, id DESC").
+		Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"activities": activitiesJSON(activities, withJobTitle),
+		"pagination": page.JSON(total),
+	})
+}
+
+// GetApplicationActivities returns the timeline of an application, newest
+// first.
+func GetApplicationActivities(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	listActivities(c, database.DB.Model(&models.ApplicationActivity{}).
+		Where("application_id = ?", application.ID), false)
+}
+
+// GetCandidateActivities returns the timelines of a candidate's
+// applications on the jobs the current user can see, merged and newest
+// first.
+func GetCandidateActivities(c *gin.Context) {
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+
+	listActivities(c, database.DB.Model(&models.ApplicationActivity{}).
+		Scopes(scopeToVisibleJobs(c, "application_activities.job_id")).
+		Where("candidate_id = ?", candidate.ID).
+		Where("application_id IN (?)", database.DB.Model(&models.Application{}).Select("id")), true)
+}
+
+// GetApplicationTimeInStage returns how long an application spent in each
+// stage, computed from its timeline.
+func GetApplicationTimeInStage(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	var activities []models.ApplicationActivity
+	if err := database.DB.Where("application_id = ? AND type IN ?", application.ID, []models.ActivityType{
+		models.ActivityApplied, models.ActivityStageChanged, models.ActivityStatusChanged,
+	}).Order("occurred_at, id").Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stages": utils.TimeInStage(activities, time.Now()),
+	})
+}
+
+// LogApplicationActivity adds a note, email, interview or scorecard to an
+// application's timeline. Everyone on the job's hiring team may, so
+// interviewers can hand in scorecards.
+func LogApplicationActivity(c *gin.Context) {
+	var req ActivityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	details, err := req.normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if _, onTeam := jobTeamRole(c, application.JobID); !onTeam && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the hiring team can add to the timeline"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	activity, err := utils.LogApplicationActivity(database.DB, application, req.Type, &actorID, req.Body, details, req.OccurredAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add activity"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Activity added",
+		"activity": activitiesJSON([]models.ApplicationActivity{*activity}, false)[0],
+	})
+}
@@ -0,0 +1,604 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplicationRequest adds a candidate to a job's pipeline: an existing
+// candidate by candidate_id, or a new one described in candidate. Without
+// stage_id the application starts in the job's applied stage. Tags and
+// custom fields are the application's own, the candidate's go in
+// candidate.
+type ApplicationRequest struct {
+	JobID       uint              `json:"job_id" binding:"required"`
+	CandidateID *uint             `json:"candidate_id"`
+	Candidate   *CandidateRequest `json:"candidate"`
+	StageID     *uint             `json:"stage_id"`
+	AppliedAt   *time.Time        `json:"applied_at"`
+	CoverLetter string            `json:"cover_letter"`
+
+	Tags         []string                   `json:"tags"`
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+}
+
+// ApplicationUpdateRequest changes the details of an application; omitted
+// fields, and custom fields missing from custom_fields, are left as they
+// are. Stage and status have their own endpoints.
+type ApplicationUpdateRequest struct {
+	AppliedAt   *time.Time `json:"applied_at"`
+	CoverLetter *string    `json:"cover_letter"`
+
+	Tags         []string                   `json:"tags"`
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+}
+
+type MoveApplicationRequest struct {
+	StageID uint `json:"stage_id" binding:"required"`
+}
+
+// ApplicationStatusRequest rejects, withdraws or reopens an application.
+// Hiring is a move to the hired stage.
+type ApplicationStatusRequest struct {
+	Status          models.ApplicationStatus `json:"status" binding:"required"`
+	RejectionReason models.RejectionReason   `json:"rejection_reason"`
+	Note            string                   `json:"note"`
+}
+
+var applicationSortColumns = map[string]string{
+	"applied_at":       "applied_at",
+	"stage_changed_at": "stage_changed_at",
+	"updated_at":       "updated_at",
+}
+
+func applicationJSON(application *models.Application) gin.H {
+	entry := gin.H{
+		"id":               application.ID,
+		"candidate_id":     application.CandidateID,
+		"job_id":           application.JobID,
+		"stage_id":         application.StageID,
+		"status":           application.Status,
+		"applied_at":       application.AppliedAt,
+		"stage_changed_at": application.StageChangedAt,
+		"cover_letter":     application.CoverLetter,
+		"tags":             application.Tags,
+		"custom_fields":    application.CustomFields,
+		"resume_id":        application.ResumeID,
+		"rejection_reason": application.RejectionReason,
+		"rejection_note":   application.RejectionNote,
+		"rejected_at":      application.RejectedAt,
+		"withdrawn_at":     application.WithdrawnAt,
+		"hired_at":         application.HiredAt,
+		"created_by":       application.CreatedBy,
+		"created_at":       application.CreatedAt,
+		"updated_at":       application.UpdatedAt,
+	}
+	if application.Stage != nil {
+		entry["stage"] = application.Stage
+	}
+	if application.Candidate != nil {
+		entry["candidate"] = application.Candidate
+	}
+	if application.Job != nil {
+		entry["job_title"] = application.Job.Title
+	}
+	return entry
+}
+
+// canManageApplications reports whether the current user may add
+// candidates to the job and move them through its pipeline: admins, and
+// the recruiters, hiring managers and coordinators staffed on it.
+func canManageApplications(c *gin.Context, jobID uint) bool {
+	if isAdmin(c) {
+		return true
+	}
+	role, ok := jobTeamRole(c, jobID)
+	return ok && role.CanManageApplications()
+}
+
+// findApplication loads the application in the URL with its candidate.
+// Applications on jobs the current user may not see are reported as not
+// found.
+func findApplication(c *gin.Context) (*models.Application, bool) {
+	var application models.Application
+	if err := database.DB.Scopes(scopeToVisibleJobs(c, "applications.job_id")).
+		Preload("Candidate").
+		Preload("Stage").
+		First(&application, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve application"})
+		}
+		return nil, false
+	}
+	return &application, true
+}
+
+// respondApplicationError maps errors from changing an application.
+func respondApplicationError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, utils.ErrApplicationNotActive), errors.Is(err, utils.ErrApplicationHired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrStageNotInPipeline):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// GetApplications lists the applications on jobs the current user can
+// see. Filters: job_id, candidate_id, stage_id, stage_type and status
+// (comma separated), q (candidate name or email), tag and custom fields
+// (see filterByTagsAndFields). Sort with ?sort=-applied_at or
+// ?sort=stage_changed_at.
+func GetApplications(c *gin.Context) {
+	query, err := applicationListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to retrieve applications")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve applications"})
+		return
+	}
+
+	page := parsePagination(c)
+	applications := []models.Application{}
+	if err := page.apply(query).
+		Preload("Candidate").
+		Preload("Job").
+		Preload("Stage").
+		Order(parseSort(c, applicationSortColumns, "applied_at DESC")).
+		Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve applications"})
+		return
+	}
+
+	entries := make([]gin.H, len(applications))
+	for i := range applications {
+		entries[i] = applicationJSON(&applications[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applications": entries,
+		"pagination":   page.JSON(total),
+	})
+}
+
+// applicationListQuery applies the filters of the applications list.
+func applicationListQuery(c *gin.Context) (*gorm.DB, error) {
+	query := database.DB.Model(&models.Application{}).Scopes(scopeToVisibleJobs(c, "applications.job_id"))
+
+	if jobID := c.Query("job_id"); jobID != "" {
+		query = query.Where("job_id = ?", jobID)
+	}
+	if candidateID := c.Query("candidate_id"); candidateID != "" {
+		query = query.Where("candidate_id = ?", candidateID)
+	}
+	if stageID := c.Query("stage_id"); stageID != "" {
+		query = query.Where("stage_id IN ?", strings.Split(stageID, ","))
+	}
+	if stageType := c.Query("stage_type"); stageType != "" {
+		query = query.Where("stage_id IN (?)", database.DB.Model(&models.JobStage{}).
+			Select("id").
+			Where("type IN ?", strings.Split(stageType, ",")))
+	}
+	if status := c.Query("status"); status != "" && status != "all" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("candidate_id IN (?)", database.DB.Model(&models.Candidate{}).
+			Select("id").
+			Where("name ILIKE ? OR email ILIKE ?", pattern, pattern))
+	}
+	return filterByTagsAndFields(c, query, models.CustomFieldApplication, "applications")
+}
+
+// GetJobPipeline returns a job's applications as kanban columns: one per
+// stage of its pipeline with the active and hired applications in it, and
+// a rejected column with the rejected and withdrawn ones.
+func GetJobPipeline(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	stages, err := utils.JobStages(database.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stages"})
+		return
+	}
+
+	var applications []models.Application
+	if err := database.DB.Preload("Candidate").
+		Where("job_id = ?", job.ID).
+		Order("stage_changed_at DESC, id DESC").
+		Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve applications"})
+		return
+	}
+
+	byStage := make(map[uint][]gin.H)
+	closed := []gin.H{}
+	for i := range applications {
+		application := &applications[i]
+		switch application.Status {
+		case models.ApplicationRejected, models.ApplicationWithdrawn:
+			closed = append(closed, applicationJSON(application))
+		default:
+			byStage[application.StageID] = append(byStage[application.StageID], applicationJSON(application))
+		}
+	}
+
+	columns := make([]gin.H, 0, len(stages))
+	for _, stage := range stages {
+		entries := byStage[stage.ID]
+		if entries == nil {
+			entries = []gin.H{}
+		}
+		columns = append(columns, gin.H{
+			"stage":        stage,
+			"count":        len(entries),
+			"applications": entries,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":     job,
+		"columns": columns,
+		"rejected": gin.H{
+			"count":        len(closed),
+			"applications": closed,
+		},
+		"can_manage": canManageApplications(c, job.ID),
+	})
+}
+
+func GetApplication(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"application": applicationJSON(application),
+		"can_manage":  canManageApplications(c, application.JobID),
+	})
+}
+
+func CreateApplication(c *gin.Context) {
+	var req ApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.CandidateID == nil) == (req.Candidate == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Send either candidate_id or candidate"})
+		return
+	}
+	if req.Candidate != nil {
+		if err := req.Candidate.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.AppliedAt != nil && req.AppliedAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "applied_at cannot be in the future"})
+		return
+	}
+	tags, err := utils.NormalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var job models.Job
+	if err := database.DB.Scopes(scopeToVisibleJobs(c, "jobs.id")).First(&job, req.JobID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Job not found"})
+		return
+	}
+	if !canManageApplications(c, job.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if job.Status == models.JobClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is closed, reopen it to add candidates"})
+		return
+	}
+
+	var stage models.JobStage
+	if req.StageID != nil {
+		if err := database.DB.Where("job_id = ?", job.ID).First(&stage, *req.StageID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Stage not found in the job's pipeline"})
+			return
+		}
+		if stage.Type == models.StageHired {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Add the application to an earlier stage, then move it to hired"})
+			return
+		}
+	} else {
+		first, err := utils.FirstJobStage(database.DB, job.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create application"})
+			return
+		}
+		stage = *first
+	}
+
+	actorID := c.GetUint("user_id")
+	candidate := models.Candidate{CreatedBy: &actorID}
+	if req.CandidateID != nil {
+		if err := database.DB.Scopes(scopeToVisibleCandidates(c)).First(&candidate, *req.CandidateID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Candidate not found"})
+			return
+		}
+		var existing int64
+		database.DB.Model(&models.Application{}).Where("candidate_id = ? AND job_id = ?", candidate.ID, job.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Candidate has already applied to this job"})
+			return
+		}
+	} else {
+		req.Candidate.applyTo(&candidate)
+		if err := setCustomFields(models.CustomFieldCandidate, &candidate.CustomFields, req.Candidate.CustomFields, true); err != nil {
+			respondCustomFieldError(c, err, "Failed to create application")
+			return
+		}
+	}
+
+	now := time.Now()
+	application := models.Application{
+		JobID:          job.ID,
+		Job:            &job,
+		StageID:        stage.ID,
+		Stage:          &stage,
+		Status:         models.ApplicationActive,
+		AppliedAt:      now,
+		StageChangedAt: now,
+		CoverLetter:    req.CoverLetter,
+		Tags:           tags,
+		CreatedBy:      &actorID,
+	}
+	if req.AppliedAt != nil {
+		application.AppliedAt = *req.AppliedAt
+	}
+	if err := setCustomFields(models.CustomFieldApplication, &application.CustomFields, req.CustomFields, true); err != nil {
+		respondCustomFieldError(c, err, "Failed to create application")
+		return
+	}
+
+	created := candidate.ID == 0
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if created {
+			if err := tx.Create(&candidate).Error; err != nil {
+				return err
+			}
+		}
+		application.CandidateID = candidate.ID
+		if err := tx.Omit(clause.Associations).Create(&application).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordApplied(tx, &application, &stage, &actorID); err != nil {
+			return err
+		}
+		return utils.RefreshApplicantCount(tx, job.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create application"})
+		return
+	}
+	application.Candidate = &candidate
+	if created {
+		detectDuplicates(candidate.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Application created",
+		"application": applicationJSON(&application),
+	})
+}
+
+func UpdateApplication(c *gin.Context) {
+	var req ApplicationUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AppliedAt != nil && req.AppliedAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "applied_at cannot be in the future"})
+		return
+	}
+	if req.Tags != nil {
+		tags, err := utils.NormalizeTags(req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Tags = tags
+	}
+
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	if req.AppliedAt != nil {
+		application.AppliedAt = *req.AppliedAt
+	}
+	if req.CoverLetter != nil {
+		application.CoverLetter = *req.CoverLetter
+	}
+	if req.Tags != nil {
+		application.Tags = req.Tags
+	}
+	if err := setCustomFields(models.CustomFieldApplication, &application.CustomFields, req.CustomFields, false); err != nil {
+		respondCustomFieldError(c, err, "Failed to update application")
+		return
+	}
+	if err := database.DB.Omit(clause.Associations).Save(application).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update application"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application updated",
+		"application": applicationJSON(application),
+	})
+}
+
+// MoveApplication moves an application to another stage of its job's
+// pipeline. Moving it to the hired stage hires the candidate.
+func MoveApplication(c *gin.Context) {
+	var req MoveApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	candidate := application.Candidate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockApplication(tx, application); err != nil {
+			return err
+		}
+		// The shared lock keeps the stage from being deleted until the
+		// move is committed, so the application cannot be left behind in it
+		var stage models.JobStage
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&stage, req.StageID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrStageNotInPipeline
+			}
+			return err
+		}
+		return utils.MoveApplication(tx, application, &stage, &actorID)
+	})
+	if err != nil {
+		respondApplicationError(c, err, "Failed to move application")
+		return
+	}
+	application.Candidate = candidate
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application moved",
+		"application": applicationJSON(application),
+	})
+}
+
+// SetApplicationStatus rejects (with a reason), withdraws or reopens an
+// application.
+func SetApplicationStatus(c *gin.Context) {
+	var req ApplicationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Status {
+	case models.ApplicationRejected:
+		if !req.RejectionReason.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rejection_reason must be unqualified, better_candidate, position_filled, compensation, no_show, unresponsive or other"})
+			return
+		}
+	case models.ApplicationWithdrawn, models.ApplicationActive:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be rejected, withdrawn or active, move the application to hired to hire"})
+		return
+	}
+
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	candidate := application.Candidate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockApplication(tx, application); err != nil {
+			return err
+		}
+		switch req.Status {
+		case models.ApplicationRejected:
+			return utils.RejectApplication(tx, application, req.RejectionReason, strings.TrimSpace(req.Note), &actorID)
+		case models.ApplicationWithdrawn:
+			return utils.WithdrawApplication(tx, application, &actorID)
+		default:
+			return utils.ReopenApplication(tx, application, &actorID)
+		}
+	})
+	if err != nil {
+		respondApplicationError(c, err, "Failed to change application status")
+		return
+	}
+	application.Candidate = candidate
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application " + applicationStatusLabel(application.Status),
+		"application": applicationJSON(application),
+	})
+}
+
+func applicationStatusLabel(status models.ApplicationStatus) string {
+	if status == models.ApplicationActive {
+		return "reopened"
+	}
+	return string(status)
+}
+
+// DeleteApplication removes an application entered by mistake. Rejecting
+// keeps the record and is what a decision against a candidate should use.
+func DeleteApplication(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(application).Error; err != nil {
+			return err
+		}
+		return utils.RefreshApplicantCount(tx, application.JobID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete application"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Application deleted",
+	})
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// JobQuestionRequest is one question of a job's form. Questions with an id
+// change that question. A question with only a library_question_id copies
+// the library question.
+type JobQuestionRequest struct {
+	ID                uint  `json:"id"`
+	LibraryQuestionID *uint `json:"library_question_id"`
+	models.QuestionDefinition
+}
+
+// JobFormRequest replaces a job's application form
+type JobFormRequest struct {
+	Questions []JobQuestionRequest `json:"questions" binding:"required"`
+}
+
+// respondFormError maps errors from changing questions and forms.
+func respondFormError(c *gin.Context, err error, fallback string) {
+	var invalid utils.FormError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// publicQuestionJSON is the part of a question candidates see. Knockout
+// rules stay private so they cannot be answered around.
+func publicQuestionJSON(question *models.JobQuestion) gin.H {
+	return gin.H{
+		"id":        question.ID,
+		"label":     question.Label,
+		"help_text": question.HelpText,
+		"type":      question.Type,
+		"options":   question.Options,
+		"required":  question.Required,
+	}
+}
+
+// GetLibraryQuestions lists the question library by label. Filters: type
+// and q (label).
+func GetLibraryQuestions(c *gin.Context) {
+	query := database.DB.Model(&models.LibraryQuestion{})
+	if questionType := c.Query("type"); questionType != "" {
+		query = query.Where("type = ?", questionType)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("label ILIKE ?", "%"+escapeLike(search)+"%")
+	}
+
+	questions := []models.LibraryQuestion{}
+	if err := query.Order("label").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve questions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"questions": questions,
+	})
+}
+
+func CreateLibraryQuestion(c *gin.Context) {
+	var req models.QuestionDefinition
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	question := models.LibraryQuestion{QuestionDefinition: req, CreatedBy: &actorID}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.NormalizeQuestion(&question.QuestionDefinition); err != nil {
+			return err
+		}
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditQuestionChanged, "library_question", question.ID, gin.H{
+			"operation": "created",
+			"label":     question.Label,
+		})
+		return nil
+	})
+	if err != nil {
+		respondFormError(c, err, "Failed to create question")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Question created",
+		"question": question,
+	})
+}
+
+// UpdateLibraryQuestion replaces a library question. Forms that copied it
+// keep their copy.
+func UpdateLibraryQuestion(c *gin.Context) {
+	var req models.QuestionDefinition
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, ok := findLibraryQuestion(c)
+	if !ok {
+		return
+	}
+	question.QuestionDefinition = req
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.NormalizeQuestion(&question.QuestionDefinition); err != nil {
+			return err
+		}
+		if err := tx.Save(question).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditQuestionChanged, "library_question", question.ID, gin.H{
+			"operation": "updated",
+			"label":     question.Label,
+		})
+		return nil
+	})
+	if err != nil {
+		respondFormError(c, err, "Failed to update question")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Question updated",
+		"question": question,
+	})
+}
+
+func DeleteLibraryQuestion(c *gin.Context) {
+	question, ok := findLibraryQuestion(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(question).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditQuestionChanged, "library_question", question.ID, gin.H{
+			"operation": "deleted",
+			"label":     question.Label,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete question"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Question deleted",
+	})
+}
+
+func findLibraryQuestion(c *gin.Context) (*models.LibraryQuestion, bool) {
+	var question models.LibraryQuestion
+	if err := database.DB.First(&question, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve question"})
+		}
+		return nil, false
+	}
+	return &question, true
+}
+
+// GetJobForm lists the questions of a job's application form in order,
+// with their knockout rules.
+func GetJobForm(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	questions, err := utils.JobQuestions(database.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve questions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"questions":  questions,
+		"can_manage": canManageJob(c, job),
+	})
+}
+
+// UpdateJobForm replaces a job's application form. Applications already
+// received keep their answers, including answers to removed questions.
+func UpdateJobForm(c *gin.Context) {
+	var req JobFormRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	questions, err := req.questions()
+	if err != nil {
+		respondFormError(c, err, "Failed to update form")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		var err error
+		questions, err = utils.SaveJobForm(tx, job.ID, questions)
+		if err != nil {
+			return err
+		}
+
+		knockouts := 0
+		for _, question := range questions {
+			if question.Knockout != nil {
+				knockouts++
+			}
+		}
+		recordAudit(c, tx, models.AuditJobFormChanged, "job", job.ID, gin.H{
+			"questions": len(questions),
+			"knockouts": knockouts,
+		})
+		return nil
+	})
+	if err != nil {
+		respondFormError(c, err, "Failed to update form")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Form updated",
+		"questions": questions,
+	})
+}
+
+// questions turns the request into job questions, copying the library
+// questions it refers to.
+func (r *JobFormRequest) questions() ([]models.JobQuestion, error) {
+	ids := []uint{}
+	for _, question := range r.Questions {
+		if question.LibraryQuestionID != nil {
+			ids = append(ids, *question.LibraryQuestionID)
+		}
+	}
+	library := make(map[uint]models.LibraryQuestion, len(ids))
+	if len(ids) > 0 {
+		var rows []models.LibraryQuestion
+		if err := database.DB.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, question := range rows {
+			library[question.ID] = question
+		}
+	}
+
+	questions := make([]models.JobQuestion, len(r.Questions))
+	for i, question := range r.Questions {
+		questions[i] = models.JobQuestion{
+			ID:                 question.ID,
+			LibraryQuestionID:  question.LibraryQuestionID,
+			QuestionDefinition: question.QuestionDefinition,
+		}
+		if question.LibraryQuestionID == nil {
+			continue
+		}
+		source, ok := library[*question.LibraryQuestionID]
+		if !ok {
+			return nil, utils.FormError("library question not found")
+		}
+		if strings.TrimSpace(question.Label) == "" {
+			questions[i].QuestionDefinition = source.QuestionDefinition
+		}
+	}
+	return questions, nil
+}
+
+// GetApplicationAnswers lists the answers sent with an application in the
+// order of the job's form. Answers to questions since removed come last.
+func GetApplicationAnswers(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	answers := []models.ApplicationAnswer{}
+	if err := database.DB.Preload("Attachment.File").
+		Joins("LEFT JOIN job_questions ON job_questions.id = application_answers.question_id").
+		Where("application_answers.application_id = ?", application.ID).
+		Order("job_questions.position NULLS LAST, application_answers.id").
+		Find(&answers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve answers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"answers": answers,
+	})
+}
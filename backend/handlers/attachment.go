@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// GetApplicationAttachments lists the files attached to an application,
+// oldest first.
+func GetApplicationAttachments(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	attachments := []models.Attachment{}
+	if err := database.DB.Preload("File").
+		Where("application_id = ?", application.ID).
+		Order("created_at, id").
+		Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attachments": attachments,
+		"resume_id":   application.ResumeID,
+		"can_manage":  canManageApplications(c, application.JobID),
+	})
+}
+
+// UploadApplicationAttachment attaches a document to an application as
+// multipart form data: file and kind (resume, cover_letter, offer or
+// other). A new resume replaces the application's resume; the old one
+// stays attached.
+func UploadApplicationAttachment(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxDocumentSize+1<<20)
+
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	kind := models.AttachmentKind(c.DefaultPostForm("kind", string(models.AttachmentOther)))
+	if !kind.IsValid() || kind == models.AttachmentAnswer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be resume, cover_letter, offer or other"})
+		return
+	}
+	_, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send the document as the multipart field file, at most %d MB", utils.MaxDocumentSize>>20)})
+		return
+	}
+
+	stored, err := storeUpload(header)
+	if err != nil {
+		if isUploadError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file " + err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		}
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	var attachment *models.Attachment
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockApplication(tx, application); err != nil {
+			return err
+		}
+		var err error
+		attachment, err = stored.attach(tx, application.ID, kind, &actorID)
+		if err != nil {
+			return err
+		}
+		if kind == models.AttachmentResume {
+			if err := tx.Model(application).UpdateColumn("resume_id", attachment.ID).Error; err != nil {
+				return err
+			}
+			if err := utils.QueueResumeParse(tx, attachment.ID); err != nil {
+				return err
+			}
+		}
+		recordAudit(c, tx, models.AuditAttachmentChanged, "application", application.ID, gin.H{
+			"operation":     "added",
+			"attachment_id": attachment.ID,
+			"kind":          kind,
+			"name":          attachment.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach file"})
+		return
+	}
+	attachment.File = stored.file
+	if kind == models.AttachmentResume {
+		// Someone else may have sent the same resume
+		detectDuplicates(application.CandidateID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "File attached",
+		"attachment": attachment,
+	})
+}
+
+// DeleteApplicationAttachment detaches a file. Files sent with answers
+// belong to the answer and stay.
+func DeleteApplicationAttachment(c *gin.Context) {
+	application, attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if attachment.Kind == models.AttachmentAnswer {
+		c.JSON(http.StatusConflict, gin.H{"error": "Files sent with answers cannot be removed"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockApplication(tx, application); err != nil {
+			return err
+		}
+		if application.ResumeID != nil && *application.ResumeID == attachment.ID {
+			if err := tx.Model(application).UpdateColumn("resume_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(attachment).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditAttachmentChanged, "application", application.ID, gin.H{
+			"operation":     "removed",
+			"attachment_id": attachment.ID,
+			"kind":          attachment.Kind,
+			"name":          attachment.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove attachment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Attachment removed",
+	})
+}
+
+// GetApplicationAttachment downloads an attached file.
+func GetApplicationAttachment(c *gin.Context) {
+	_, attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	serveAttachment(c, attachment)
+}
+
+// GetAttachmentLink returns a short-lived URL for an attached file that
+// works without the Authorization header, for links and previews in the
+// browser.
+func GetAttachmentLink(c *gin.Context) {
+	_, attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+
+	token, expiresAt := utils.IssueDownloadToken(attachment.ID, time.Now())
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"url":        "/api/files/" + token,
+		"expires_at": expiresAt,
+	})
+}
+
+// DownloadFile serves the file behind a link from GetAttachmentLink.
+func DownloadFile(c *gin.Context) {
+	attachmentID, err := utils.VerifyDownloadToken(c.Param("token"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := database.DB.Preload("File").First(&attachment, attachmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	serveAttachment(c, &attachment)
+}
+
+// GetApplicationResume downloads the application's resume.
+func GetApplicationResume(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+	if application.ResumeID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application has no resume"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := database.DB.Preload("File").First(&attachment, *application.ResumeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application has no resume"})
+		return
+	}
+	serveAttachment(c, &attachment)
+}
+
+// GetApplicationAnswerFile downloads the file sent for a file question.
+func GetApplicationAnswerFile(c *gin.Context) {
+	application, ok := findApplication(c)
+	if !ok {
+		return
+	}
+
+	var answer models.ApplicationAnswer
+	if err := database.DB.Preload("Attachment.File").
+		Where("application_id = ?", application.ID).
+		First(&answer, c.Param("answerId")).Error; err != nil || answer.Attachment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	serveAttachment(c, answer.Attachment)
+}
+
+// findAttachment loads the application in the URL and its attachment
+// :attachmentId with the stored file.
+func findAttachment(c *gin.Context) (*models.Application, *models.Attachment, bool) {
+	application, ok := findApplication(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var attachment models.Attachment
+	if err := database.DB.Preload("File").
+		Where("application_id = ?", application.ID).
+		First(&attachment, c.Param("attachmentId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachment"})
+		}
+		return nil, nil, false
+	}
+	return application, &attachment, true
+}
+
+// serveAttachment streams an attached file as a download. Uploaded files
+// are only served this way, so browsers never render them inline.
+func serveAttachment(c *gin.Context, attachment *models.Attachment) {
+	file := attachment.File
+	reader, err := storage.Get().Open(file.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load file"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+	})
+}
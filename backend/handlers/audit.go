@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// recordAudit appends an event to the audit chain on behalf of the current
+// request. Failures are logged rather than surfaced, the action itself has
+// already succeeded by the time it is audited.
+func recordAudit(c *gin.Context, db *gorm.DB, action, entityType string, entityID uint, details gin.H) {
+	entry := utils.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		IPAddress:  c.ClientIP(),
+	}
+
+	if userID, exists := c.Get("user_id"); exists {
+		actorID := userID.(uint)
+		entry.ActorID = &actorID
+	}
+	if entityID != 0 {
+		entry.EntityID = &entityID
+	}
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err == nil {
+			entry.Details = string(encoded)
+		}
+	}
+
+	if err := utils.RecordAudit(db, entry); err != nil {
+		log.Printf("Failed to record audit event %s: %v", action, err)
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	query := database.DB.Order("sequence DESC").Limit(limit)
+	if before, err := strconv.ParseUint(c.Query("before"), 10, 64); err == nil {
+		query = query.Where("sequence < ?", before)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+func VerifyAuditLog(c *gin.Context) {
+	result, err := utils.VerifyAuditChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Valid {
+		status = http.StatusConflict
+	}
+	c.JSON(status, result)
+}
+
+func CreateAuditCheckpoint(c *gin.Context) {
+	checkpoint, err := utils.CreateAuditCheckpoint()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create audit checkpoint"})
+		return
+	}
+
+	if checkpoint == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Audit log has no new entries since the last checkpoint",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Audit checkpoint signed",
+		"checkpoint": checkpoint,
+		"public_key": utils.AuditPublicKey(),
+	})
+}
@@ -37,6 +37,12 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	policy := utils.GetRegistrationPolicy()
+	if allowed, reason := policy.Allows(req.Email); !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
+		return
+	}
+
 	var existingUser models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
@@ -50,6 +56,11 @@ func Register(c *gin.Context) {
 		IsActive: true,
 	}
 
+	if policy.RequireApproval {
+		user.IsActive = false
+		user.ApprovalStatus = models.ApprovalPending
+	}
+
 	if err := user.HashPassword(req.Password); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
@@ -60,34 +71,40 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if user.IsAwaitingApproval() {
+		recordAudit(c, database.DB, models.AuditUserRegistered, "user", user.ID, gin.H{
+			"role":            user.Role,
+			"approval_status": user.ApprovalStatus,
+		})
+		notifyAdminsOfRegistration(&user)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Registration received. An administrator needs to approve your account before you can sign in.",
+			"user": gin.H{
+				"id":              user.ID,
+				"email":           user.Email,
+				"name":            user.Name,
+				"role":            user.Role,
+				"approval_status": user.ApprovalStatus,
+			},
+		})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	session, err := startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	session := models.Session{
-		UserID:       user.ID,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IPAddress:    c.ClientIP(),
-		UserAgent:    c.GetHeader("User-Agent"),
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour), // 30 days
-		LastUsedAt:   time.Now(),
-	}
-
-	database.DB.Create(&session)
+	recordAudit(c, database.DB, models.AuditUserRegistered, "user", user.ID, gin.H{
+		"role": user.Role,
+	})
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":       "User registered successfully",
-		"token":         token,
-		"refresh_token": refreshToken,
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
 		"user": gin.H{
 			"id":         user.ID,
 			"email":      user.Email,
@@ -111,6 +128,11 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if user.IsAwaitingApproval() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is awaiting approval"})
+		return
+	}
+
 	if !user.IsActive {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
 		return
@@ -127,33 +149,16 @@ func Login(c *gin.Context) {
 	user.LastLoginAt = &now
 	database.DB.Save(&user)
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	session, err := startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	session := models.Session{
-		UserID:       user.ID,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IPAddress:    c.ClientIP(),
-		UserAgent:    c.GetHeader("User-Agent"),
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour), // 30 days
-		LastUsedAt:   time.Now(),
-	}
-	database.DB.Create(&session)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Login successful",
-		"token":         token,
-		"refresh_token": refreshToken,
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
 		"user": gin.H{
 			"id":            user.ID,
 			"email":         user.Email,
@@ -230,6 +235,8 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, database.DB, models.AuditPasswordReset, "user", user.ID, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Password reset successfully",
 	})
@@ -245,13 +252,7 @@ func GetProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": gin.H{
-			"id":        user.ID,
-			"email":     user.Email,
-			"name":      user.Name,
-			"role":      user.Role,
-			"is_active": user.IsActive,
-		},
+		"user": profileJSON(&user),
 	})
 }
 
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+const maxBulkInvitations = 1000
+
+// Bulk invitation modes
+const (
+	// BulkModeAtomic creates every invitation in one transaction, or none
+	// if any row is invalid
+	BulkModeAtomic = "atomic"
+	// BulkModePerRow creates the valid rows and reports the invalid ones
+	BulkModePerRow = "per_row"
+)
+
+// Per-row statuses in a bulk invitation report
+const (
+	BulkRowCreated = "created"
+	BulkRowValid   = "valid"
+	BulkRowInvalid = "invalid"
+	BulkRowSkipped = "skipped"
+	BulkRowFailed  = "failed"
+)
+
+type BulkInvitationRow struct {
+	Email string          `json:"email"`
+	Name  string          `json:"name"`
+	Role  models.UserRole `json:"role"`
+}
+
+type BulkInvitationRequest struct {
+	Invitations []BulkInvitationRow `json:"invitations" binding:"required"`
+	Mode        string              `json:"mode"`
+	DryRun      bool                `json:"dry_run"`
+}
+
+type BulkInvitationResult struct {
+	Row    int             `json:"row"`
+	Email  string          `json:"email"`
+	Name   string          `json:"name"`
+	Role   models.UserRole `json:"role"`
+	Status string          `json:"status"`
+	UserID uint            `json:"user_id,omitempty"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// BulkInviteUsers invites many users at once from a CSV upload (multipart
+// "file" field or a text/csv body with an email,name,role header) or a JSON
+// body. Every row is validated first and the response reports each row's
+// outcome. Set dry_run to validate without creating anything.
+func BulkInviteUsers(c *gin.Context) {
+	req, rowNumbers, err := parseBulkInvitationRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = BulkModeAtomic
+	}
+	if req.Mode != BulkModeAtomic && req.Mode != BulkModePerRow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be atomic or per_row"})
+		return
+	}
+	if len(req.Invitations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No invitations provided"})
+		return
+	}
+	if len(req.Invitations) > maxBulkInvitations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d invitations per request", maxBulkInvitations)})
+		return
+	}
+
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin user not found"})
+		return
+	}
+
+	results, err := validateBulkInvitations(req.Invitations, rowNumbers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate invitations"})
+		return
+	}
+
+	settings := utils.GetInvitationSettings()
+
+	invalid := 0
+	for _, result := range results {
+		if result.Status == BulkRowInvalid {
+			invalid++
+		}
+	}
+
+	switch {
+	case req.DryRun:
+		// Nothing to write
+	case req.Mode == BulkModeAtomic && invalid > 0:
+		for i := range results {
+			if results[i].Status == BulkRowValid {
+				results[i].Status = BulkRowSkipped
+			}
+		}
+	case req.Mode == BulkModeAtomic:
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			for i := range results {
+				if err := createBulkInvitation(c, tx, adminID.(uint), settings, &results[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			for i := range results {
+				results[i].Status = BulkRowFailed
+				results[i].UserID = 0
+				results[i].Errors = append(results[i].Errors, "Failed to create invitations, nothing was saved")
+			}
+		}
+	default:
+		for i := range results {
+			if results[i].Status != BulkRowValid {
+				continue
+			}
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				return createBulkInvitation(c, tx, adminID.(uint), settings, &results[i])
+			})
+			if err != nil {
+				results[i].Status = BulkRowFailed
+				results[i].UserID = 0
+				results[i].Errors = append(results[i].Errors, "Failed to create invitation")
+			}
+		}
+	}
+
+	summary := gin.H{"total": len(results)}
+	for _, status := range []string{BulkRowCreated, BulkRowValid, BulkRowInvalid, BulkRowSkipped, BulkRowFailed} {
+		summary[status] = 0
+	}
+	for _, result := range results {
+		summary[result.Status] = summary[result.Status].(int) + 1
+	}
+
+	status := http.StatusOK
+	switch {
+	case req.DryRun:
+	case summary[BulkRowCreated].(int) > 0:
+		status = http.StatusCreated
+	case invalid > 0 || summary[BulkRowFailed].(int) > 0:
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.JSON(status, gin.H{
+		"mode":    req.Mode,
+		"dry_run": req.DryRun,
+		"summary": summary,
+		"results": results,
+	})
+}
+
+func createBulkInvitation(c *gin.Context, tx *gorm.DB, adminID uint, settings utils.InvitationSettings, result *BulkInvitationResult) error {
+	user, token, err := newInvitation(adminID, result.Email, result.Name, result.Role, settings)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return err
+	}
+	if err := utils.RecordInvitationEvent(tx, &user, models.InvitationEventSent, &adminID); err != nil {
+		return err
+	}
+	if err := utils.QueueEmail(tx, utils.InvitationEmail(&user, token, settings)); err != nil {
+		return err
+	}
+
+	recordAudit(c, tx, models.AuditInvitationSent, "user", user.ID, gin.H{
+		"role": user.Role,
+		"bulk": true,
+	})
+
+	result.Status = BulkRowCreated
+	result.UserID = user.ID
+	return nil
+}
+
+// validateBulkInvitations checks every row, including for duplicates within
+// the batch and against existing accounts.
+func validateBulkInvitations(rows []BulkInvitationRow, rowNumbers []int) ([]BulkInvitationResult, error) {
+	results := make([]BulkInvitationResult, len(rows))
+	emails := make([]string, 0, len(rows))
+	firstRow := make(map[string]int, len(rows))
+
+	for i, row := range rows {
+		result := BulkInvitationResult{
+			Row:   rowNumbers[i],
+			Email: strings.TrimSpace(row.Email),
+			Name:  strings.TrimSpace(row.Name),
+			Role:  models.UserRole(strings.TrimSpace(string(row.Role))),
+		}
+
+		if result.Email == "" {
+			result.Errors = append(result.Errors, "email is required")
+		} else if addr, err := mail.ParseAddress(result.Email); err != nil || addr.Address != result.Email {
+			result.Errors = append(result.Errors, "email is not a valid address")
+		}
+		if result.Name == "" {
+			result.Errors = append(result.Errors, "name is required")
+		}
+		if !result.Role.IsValid() {
+			result.Errors = append(result.Errors, "role must be one of "+joinRoles(models.ValidRoles))
+		}
+
+		if result.Email != "" {
+			key := strings.ToLower(result.Email)
+			if first, seen := firstRow[key]; seen {
+				result.Errors = append(result.Errors, fmt.Sprintf("duplicate of row %d", first))
+			} else {
+				firstRow[key] = result.Row
+				emails = append(emails, key)
+			}
+		}
+
+		results[i] = result
+	}
+
+	existing := make(map[string]bool)
+	if len(emails) > 0 {
+		var users []models.User
+		// Unscoped: soft deleted accounts still hold their email
+		if err := database.DB.Unscoped().Select("email").Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			existing[strings.ToLower(user.Email)] = true
+		}
+	}
+
+	for i := range results {
+		if existing[strings.ToLower(results[i].Email)] {
+			results[i].Errors = append(results[i].Errors, "a user with this email already exists")
+		}
+		if len(results[i].Errors) > 0 {
+			results[i].Status = BulkRowInvalid
+		} else {
+			results[i].Status = BulkRowValid
+		}
+	}
+
+	return results, nil
+}
+
+// parseBulkInvitationRequest reads the rows from whichever format was sent
+// and returns the row number to report for each of them.
+func parseBulkInvitationRequest(c *gin.Context) (*BulkInvitationRequest, []int, error) {
+	contentType := c.ContentType()
+
+	if contentType != "multipart/form-data" && contentType != "text/csv" {
+		var req BulkInvitationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, nil, err
+		}
+		rowNumbers := make([]int, len(req.Invitations))
+		for i := range rowNumbers {
+			rowNumbers[i] = i + 1
+		}
+		return &req, rowNumbers, nil
+	}
+
+	req := &BulkInvitationRequest{
+		Mode: c.Query("mode"),
+	}
+	req.DryRun, _ = strconv.ParseBool(c.Query("dry_run"))
+
+	if contentType == "multipart/form-data" {
+		if mode := c.PostForm("mode"); mode != "" {
+			req.Mode = mode
+		}
+		if dryRun := c.PostForm("dry_run"); dryRun != "" {
+			req.DryRun, _ = strconv.ParseBool(dryRun)
+		}
+	}
+
+	body, closeBody, err := csvBody(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeBody()
+
+	rows, rowNumbers, err := parseInvitationCSV(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Invitations = rows
+	return req, rowNumbers, nil
+}
+
+// parseInvitationCSV reads rows with an email, name and role header in any
+// column order. Row numbers match what a spreadsheet shows, the header is row 1.
+func parseInvitationCSV(r io.Reader) ([]BulkInvitationRow, []int, error) {
+	records, err := readCSV(r, []string{"email", "name", "role"}, maxBulkInvitations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]BulkInvitationRow, len(records))
+	rowNumbers := make([]int, len(records))
+	for i, record := range records {
+		rows[i] = BulkInvitationRow{
+			Email: record.fields["email"],
+			Name:  record.fields["name"],
+			Role:  models.UserRole(record.fields["role"]),
+		}
+		rowNumbers[i] = record.Line
+	}
+	return rows, rowNumbers, nil
+}
+
+func joinRoles(roles []models.UserRole) string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCandidateLinks = 10
+	// Positions and schools per candidate
+	maxCandidateEntries = 50
+	maxCandidateSkills  = 100
+)
+
+// Dates in work history and education are a year or a month
+var candidateDatePattern = regexp.MustCompile(`^(?:19|20)\d{2}(?:-(?:0[1-9]|1[0-2]))?$`)
+
+// CandidateRequest is used for both creating and replacing a candidate.
+// A candidate needs a name and an email or phone number. Work history,
+// education, skills and tags are kept as they are when left out, and so
+// are custom fields missing from custom_fields; null clears one.
+type CandidateRequest struct {
+	Name           string                  `json:"name" binding:"required,max=200"`
+	Email          string                  `json:"email" binding:"max=255"`
+	Phone          string                  `json:"phone" binding:"max=30"`
+	Location       string                  `json:"location" binding:"max=100"`
+	CurrentTitle   string                  `json:"current_title" binding:"max=200"`
+	CurrentCompany string                  `json:"current_company" binding:"max=200"`
+	Links          []models.CandidateLink  `json:"links"`
+	WorkHistory    []models.WorkExperience `json:"work_history"`
+	Education      []models.Education      `json:"education"`
+	Skills         []string                `json:"skills"`
+	Source         models.CandidateSource  `json:"source"`
+	SourceDetail   string                  `json:"source_detail" binding:"max=100"`
+
+	Tags         []string                   `json:"tags"`
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+}
+
+var candidateSortColumns = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// normalize fills in defaults and validates the request.
+func (r *CandidateRequest) normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Location = strings.TrimSpace(r.Location)
+	r.CurrentTitle = strings.TrimSpace(r.CurrentTitle)
+	r.CurrentCompany = strings.TrimSpace(r.CurrentCompany)
+	r.SourceDetail = strings.TrimSpace(r.SourceDetail)
+
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Email != "" {
+		if addr, err := mail.ParseAddress(r.Email); err != nil || addr.Address != r.Email {
+			return errors.New("email is not a valid address")
+		}
+	}
+	phone, err := utils.NormalizePhone(r.Phone)
+	if err != nil {
+		return err
+	}
+	r.Phone = phone
+	if r.Email == "" && r.Phone == "" {
+		return errors.New("email or phone is required")
+	}
+
+	if r.Source == "" {
+		r.Source = models.SourceOther
+	}
+	if !r.Source.IsValid() {
+		return errors.New("source must be career_site, job_board, referral, sourced, agency, internal or other")
+	}
+
+	if len(r.Links) > maxCandidateLinks {
+		return errors.New("at most 10 links per candidate")
+	}
+	links := []models.CandidateLink{}
+	for _, link := range r.Links {
+		link.Type = strings.ToLower(strings.TrimSpace(link.Type))
+		link.URL = strings.TrimSpace(link.URL)
+		if link.URL == "" {
+			continue
+		}
+		if link.Type == "" {
+			link.Type = models.LinkOther
+		}
+		if !isCandidateLinkType(link.Type) {
+			return errors.New("link type must be linkedin, github, portfolio or other")
+		}
+		parsed, err := url.Parse(link.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("links must be http or https URLs")
+		}
+		links = append(links, link)
+	}
+	r.Links = links
+
+	if r.Tags != nil {
+		tags, err := utils.NormalizeTags(r.Tags)
+		if err != nil {
+			return err
+		}
+		r.Tags = tags
+	}
+
+	return r.normalizeBackground()
+}
+
+// normalizeBackground trims work history, education and skills and drops
+// empty entries.
+func (r *CandidateRequest) normalizeBackground() error {
+	if len(r.WorkHistory) > maxCandidateEntries || len(r.Education) > maxCandidateEntries {
+		return errors.New("at most 50 positions and 50 schools per candidate")
+	}
+	if len(r.Skills) > maxCandidateSkills {
+		return errors.New("at most 100 skills per candidate")
+	}
+	validDates := func(start, end string) bool {
+		return (start == "" || candidateDatePattern.MatchString(start)) && (end == "" || candidateDatePattern.MatchString(end))
+	}
+
+	if r.WorkHistory != nil {
+		positions := []models.WorkExperience{}
+		for _, position := range r.WorkHistory {
+			position.Title = strings.TrimSpace(position.Title)
+			position.Company = strings.TrimSpace(position.Company)
+			if position.Title == "" && position.Company == "" {
+				continue
+			}
+			if len(position.Title) > 200 || len(position.Company) > 200 {
+				return errors.New("titles and companies must be at most 200 characters")
+			}
+			if !validDates(position.StartDate, position.EndDate) {
+				return errors.New("dates must be like 2006 or 2006-01")
+			}
+			positions = append(positions, position)
+		}
+		r.WorkHistory = positions
+	}
+
+	if r.Education != nil {
+		schools := []models.Education{}
+		for _, school := range r.Education {
+			school.Institution = strings.TrimSpace(school.Institution)
+			school.Degree = strings.TrimSpace(school.Degree)
+			if school.Institution == "" && school.Degree == "" {
+				continue
+			}
+			if len(school.Institution) > 200 || len(school.Degree) > 200 {
+				return errors.New("institutions and degrees must be at most 200 characters")
+			}
+			if !validDates(school.StartDate, school.EndDate) {
+				return errors.New("dates must be like 2006 or 2006-01")
+			}
+			schools = append(schools, school)
+		}
+		r.Education = schools
+	}
+
+	if r.Skills != nil {
+		skills := []string{}
+		seen := make(map[string]bool, len(r.Skills))
+		for _, skill := range r.Skills {
+			skill = strings.TrimSpace(skill)
+			if skill == "" || seen[strings.ToLower(skill)] {
+				continue
+			}
+			if len(skill) > 100 {
+				return errors.New("skills must be at most 100 characters")
+			}
+			seen[strings.ToLower(skill)] = true
+			skills = append(skills, skill)
+		}
+		r.Skills = skills
+	}
+	return nil
+}
+
+func isCandidateLinkType(linkType string) bool {
+	for _, valid := range models.CandidateLinkTypes {
+		if linkType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CandidateRequest) applyTo(candidate *models.Candidate) {
+	candidate.Name = r.Name
+	candidate.Email = r.Email
+	candidate.Phone = r.Phone
+	candidate.Location = r.Location
+	candidate.CurrentTitle = r.CurrentTitle
+	candidate.CurrentCompany = r.CurrentCompany
+	candidate.Links = r.Links
+	candidate.Source = r.Source
+	candidate.SourceDetail = r.SourceDetail
+	if r.WorkHistory != nil {
+		candidate.WorkHistory = r.WorkHistory
+	}
+	if r.Education != nil {
+		candidate.Education = r.Education
+	}
+	if r.Skills != nil {
+		candidate.Skills = r.Skills
+	}
+	if r.Tags != nil {
+		candidate.Tags = r.Tags
+	}
+}
+
+// candidateParsedValues are the fields of a candidate that can be filled
+// in from a resume, keyed like ParsedFields.
+func candidateParsedValues(candidate *models.Candidate) map[string]interface{} {
+	return map[string]interface{}{
+		"email":           candidate.Email,
+		"phone":           candidate.Phone,
+		"location":        candidate.Location,
+		"current_title":   candidate.CurrentTitle,
+		"current_company": candidate.CurrentCompany,
+		"links":           candidate.Links,
+		"work_history":    candidate.WorkHistory,
+		"education":       candidate.Education,
+		"skills":          candidate.Skills,
+	}
+}
+
+// scopeToVisibleCandidates limits a query on candidates to the ones the
+// current user may see: candidates with an application on a job they can
+// see, and candidates they added themselves. Admins see everyone.
+func scopeToVisibleCandidates(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if isAdmin(c) {
+			return db
+		}
+
+		applications := database.DB.Model(&models.Application{}).
+			Select("candidate_id").
+			Scopes(scopeToVisibleJobs(c, "applications.job_id"))
+		return db.Where("(candidates.id IN (?) OR candidates.created_by = ?)", applications, c.GetUint("user_id"))
+	}
+}
+
+// canEditCandidate reports whether the current user may change candidate:
+// admins, whoever added them, and team members who manage applications on
+// a job the candidate applied to.
+func canEditCandidate(c *gin.Context, candidate *models.Candidate) bool {
+	if isAdmin(c) {
+		return true
+	}
+	userID := c.GetUint("user_id")
+	if candidate.CreatedBy != nil && *candidate.CreatedBy == userID {
+		return true
+	}
+
+	var count int64
+	database.DB.Model(&models.Application{}).
+		Joins("JOIN job_team_members ON job_team_members.job_id = applications.job_id").
+		Where("applications.candidate_id = ? AND job_team_members.user_id = ? AND job_team_members.role IN ?",
+			candidate.ID, userID, applicationManagerRoles()).
+		Count(&count)
+	return count > 0
+}
+
+func applicationManagerRoles() []models.HiringTeamRole {
+	roles := []models.HiringTeamRole{}
+	for _, role := range models.HiringTeamRoles {
+		if role.CanManageApplications() {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// findCandidate loads the candidate in the URL. Candidates the current user
+// may not see are reported as not found.
+func findCandidate(c *gin.Context) (*models.Candidate, bool) {
+	var candidate models.Candidate
+	if err := database.DB.Scopes(scopeToVisibleCandidates(c)).First(&candidate, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve candidate"})
+		}
+		return nil, false
+	}
+	return &candidate, true
+}
+
+// GetCandidates lists the candidates the current user can see. Filters: q
+// (name, email or phone), source, job_id (candidates who applied to that
+// job), tag and custom fields (see filterByTagsAndFields). Sort with
+// ?sort=name or ?sort=-created_at.
+func GetCandidates(c *gin.Context) {
+	query, err := candidateListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to retrieve candidates")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve candidates"})
+		return
+	}
+
+	page := parsePagination(c)
+	candidates := []models.Candidate{}
+	if err := page.apply(query).
+		Order(parseSort(c, candidateSortColumns, "created_at DESC")).
+		Find(&candidates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve candidates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"candidates": candidates,
+		"pagination": page.JSON(total),
+	})
+}
+
+// candidateListQuery applies the filters of the candidates list.
+func candidateListQuery(c *gin.Context) (*gorm.DB, error) {
+	query := database.DB.Model(&models.Candidate{}).Scopes(scopeToVisibleCandidates(c))
+
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ? OR phone ILIKE ?", pattern, pattern, pattern)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if jobID := c.Query("job_id"); jobID != "" {
+		query = query.Where("candidates.id IN (?)", database.DB.Model(&models.Application{}).
+			Select("candidate_id").
+			Where("job_id = ?", jobID).
+			Scopes(scopeToVisibleJobs(c, "applications.job_id")))
+	}
+	return filterByTagsAndFields(c, query, models.CustomFieldCandidate, "candidates")
+}
+
+// GetCandidate returns a candidate with their applications on the jobs the
+// current user can see.
+func GetCandidate(c *gin.Context) {
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+
+	applications := []models.Application{}
+	if err := database.DB.Scopes(scopeToVisibleJobs(c, "applications.job_id")).
+		Preload("Job").
+		Where("candidate_id = ?", candidate.ID).
+		Order("applied_at DESC").
+		Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve applications"})
+		return
+	}
+
+	entries := make([]gin.H, len(applications))
+	for i := range applications {
+		entries[i] = applicationJSON(&applications[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"candidate":    candidate,
+		"applications": entries,
+		"can_edit":     canEditCandidate(c, candidate),
+	})
+}
+
+// CreateCandidate adds a candidate and lists who they may be a duplicate
+// of, so the team can merge them right away.
+func CreateCandidate(c *gin.Context) {
+	var req CandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	candidate := models.Candidate{CreatedBy: &actorID}
+	req.applyTo(&candidate)
+	if err := setCustomFields(models.CustomFieldCandidate, &candidate.CustomFields, req.CustomFields, true); err != nil {
+		respondCustomFieldError(c, err, "Failed to create candidate")
+		return
+	}
+
+	if err := database.DB.Create(&candidate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create candidate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":             "Candidate created",
+		"candidate":           candidate,
+		"possible_duplicates": duplicateEntries(c, detectDuplicates(candidate.ID), candidate.ID),
+	})
+}
+
+func UpdateCandidate(c *gin.Context) {
+	var req CandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+	if !canEditCandidate(c, candidate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	// Fields someone changed are theirs now, not the parser's
+	before := candidateParsedValues(candidate)
+	req.applyTo(candidate)
+	for field, value := range candidateParsedValues(candidate) {
+		if !reflect.DeepEqual(before[field], value) {
+			delete(candidate.ParsedFields, field)
+		}
+	}
+	if err := setCustomFields(models.CustomFieldCandidate, &candidate.CustomFields, req.CustomFields, false); err != nil {
+		respondCustomFieldError(c, err, "Failed to update candidate")
+		return
+	}
+	if err := database.DB.Save(candidate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update candidate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Candidate updated",
+		"candidate": candidate,
+	})
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type MergeCandidateRequest struct {
+	// CandidateID is the duplicate, merged into the candidate in the URL
+	CandidateID uint `json:"candidate_id" binding:"required"`
+}
+
+// detectDuplicates looks for duplicates of a candidate that was just
+// created or got new details. It runs after the change committed, so a
+// failure only means the pair is not queued.
+func detectDuplicates(candidateID uint) []models.CandidateDuplicate {
+	duplicates, err := utils.DetectCandidateDuplicates(database.DB, candidateID)
+	if err != nil {
+		log.Printf("Failed to look for duplicates of candidate %d: %v", candidateID, err)
+	}
+	return duplicates
+}
+
+// duplicateEntries describes pairs from the side of candidateID: the other
+// candidate, why they match and how strongly. Candidates the current user
+// may not see are left out.
+func duplicateEntries(c *gin.Context, duplicates []models.CandidateDuplicate, candidateID uint) []gin.H {
+	ids := []uint{}
+	for _, pair := range duplicates {
+		ids = append(ids, pair.CandidateID, pair.MatchID)
+	}
+	visible := map[uint]bool{}
+	if len(ids) > 0 {
+		var visibleIDs []uint
+		database.DB.Model(&models.Candidate{}).Scopes(scopeToVisibleCandidates(c)).
+			Where("id IN ?", ids).
+			Pluck("id", &visibleIDs)
+		for _, id := range visibleIDs {
+			visible[id] = true
+		}
+	}
+
+	entries := []gin.H{}
+	for _, pair := range duplicates {
+		other := pair.Match
+		if pair.MatchID == candidateID {
+			other = pair.Candidate
+		}
+		if other == nil || !visible[other.ID] {
+			continue
+		}
+		entries = append(entries, gin.H{
+			"id":         pair.ID,
+			"candidate":  other,
+			"score":      pair.Score,
+			"reasons":    pair.Reasons,
+			"status":     pair.Status,
+			"created_at": pair.CreatedAt,
+		})
+	}
+	return entries
+}
+
+// visibleDuplicatePairs limits a query on candidate_duplicates to pairs
+// where the current user can see both candidates, neither deleted.
+func visibleDuplicatePairs(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		visible := database.DB.Model(&models.Candidate{}).Select("id").Scopes(scopeToVisibleCandidates(c))
+		return db.Where("candidate_duplicates.candidate_id IN (?) AND candidate_duplicates.match_id IN (?)", visible, visible)
+	}
+}
+
+// GetCandidateDuplicates is the review queue: pairs of candidates that may
+// be the same person, most likely first. Filter with status (pending by
+// default, merged or dismissed).
+func GetCandidateDuplicates(c *gin.Context) {
+	status := models.DuplicateStatus(c.DefaultQuery("status", string(models.DuplicatePending)))
+	if !status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, merged or dismissed"})
+		return
+	}
+	query := database.DB.Model(&models.CandidateDuplicate{}).
+		Scopes(visibleDuplicatePairs(c)).
+		Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicates"})
+		return
+	}
+
+	page := parsePagination(c)
+	duplicates := []models.CandidateDuplicate{}
+	if err := page.apply(query).
+		Preload("Candidate").
+		Preload("Match").
+		Order("score DESC, updated_at DESC").
+		Find(&duplicates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"duplicates": duplicates,
+		"pagination": page.JSON(total),
+	})
+}
+
+// GetCandidateDuplicatesOf lists the pending possible duplicates of the
+// candidate in the URL.
+func GetCandidateDuplicatesOf(c *gin.Context) {
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+
+	duplicates := []models.CandidateDuplicate{}
+	if err := database.DB.Scopes(visibleDuplicatePairs(c)).
+		Preload("Candidate").
+		Preload("Match").
+		Where("status = ? AND (candidate_id = ? OR match_id = ?)", models.DuplicatePending, candidate.ID, candidate.ID).
+		Order("score DESC").
+		Find(&duplicates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"duplicates": duplicateEntries(c, duplicates, candidate.ID),
+	})
+}
+
+// DismissCandidateDuplicate takes a pair out of the review queue as two
+// different people. Detection does not queue it again.
+func DismissCandidateDuplicate(c *gin.Context) {
+	var pair models.CandidateDuplicate
+	if err := database.DB.Scopes(visibleDuplicatePairs(c)).
+		Preload("Candidate").
+		Preload("Match").
+		First(&pair, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Duplicate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve duplicate"})
+		}
+		return
+	}
+	if !canEditCandidate(c, pair.Candidate) || !canEditCandidate(c, pair.Match) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if pair.Status != models.DuplicatePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Duplicate was reviewed already"})
+		return
+	}
+
+	now := time.Now()
+	actorID := c.GetUint("user_id")
+	pair.Status = models.DuplicateDismissed
+	pair.ReviewedBy = &actorID
+	pair.ReviewedAt = &now
+	if err := database.DB.Model(&pair).Select("status", "reviewed_by", "reviewed_at").Updates(&pair).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss duplicate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Duplicate dismissed",
+		"duplicate": pair,
+	})
+}
+
+// MergeCandidate merges the candidate in candidate_id into the one in the
+// URL, which survives. Both have to be editable by the current user. The
+// merge can be undone for CANDIDATE_MERGE_UNDO_WINDOW.
+func MergeCandidate(c *gin.Context) {
+	var req MergeCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	survivor, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+	var duplicate models.Candidate
+	if err := database.DB.Scopes(scopeToVisibleCandidates(c)).First(&duplicate, req.CandidateID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Candidate to merge not found"})
+		return
+	}
+	if !canEditCandidate(c, survivor) || !canEditCandidate(c, &duplicate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	var merge *models.CandidateMerge
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		merge, err = utils.MergeCandidates(tx, survivor, &duplicate, &actorID)
+		if err != nil {
+			return err
+		}
+		fields := make([]string, 0, len(merge.Changes.Fields))
+		for field := range merge.Changes.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		recordAudit(c, tx, models.AuditCandidateMerged, "candidate", survivor.ID, gin.H{
+			"merge_id":            merge.ID,
+			"merged_id":           duplicate.ID,
+			"merged_name":         duplicate.Name,
+			"moved_applications":  merge.Changes.MovedApplications,
+			"folded_applications": len(merge.Changes.FoldedApplications),
+			"fields":              fields,
+		})
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrMergeSameCandidate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge candidates"})
+		}
+		return
+	}
+
+	// The merged details may match someone else
+	detectDuplicates(survivor.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Candidates merged",
+		"candidate": survivor,
+		"merge":     merge,
+	})
+}
+
+// GetCandidateMerges lists the candidates merged into the candidate in the
+// URL, newest first, and whether each merge can still be undone.
+func GetCandidateMerges(c *gin.Context) {
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+
+	merges := []models.CandidateMerge{}
+	if err := database.DB.Where("candidate_id = ?", candidate.ID).Order("created_at DESC").Find(&merges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merges"})
+		return
+	}
+
+	ids := make([]uint, len(merges))
+	for i, merge := range merges {
+		ids[i] = merge.MergedID
+	}
+	var merged []models.Candidate
+	database.DB.Unscoped().Select("id", "name", "email").Where("id IN ?", ids).Find(&merged)
+	names := make(map[uint]models.Candidate, len(merged))
+	for _, other := range merged {
+		names[other.ID] = other
+	}
+
+	canEdit := canEditCandidate(c, candidate)
+	window := utils.MergeUndoWindow()
+	entries := make([]gin.H, len(merges))
+	for i, merge := range merges {
+		entries[i] = gin.H{
+			"id":           merge.ID,
+			"merged_id":    merge.MergedID,
+			"merged_name":  names[merge.MergedID].Name,
+			"merged_email": names[merge.MergedID].Email,
+			"actor_id":     merge.ActorID,
+			"changes":      merge.Changes,
+			"created_at":   merge.CreatedAt,
+			"undone_at":    merge.UndoneAt,
+			"can_undo":     canEdit && merge.UndoneAt == nil && time.Since(merge.CreatedAt) <= window,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"merges": entries,
+	})
+}
+
+// UndoCandidateMerge takes a merge back and restores the merged candidate.
+func UndoCandidateMerge(c *gin.Context) {
+	var merge models.CandidateMerge
+	if err := database.DB.First(&merge, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merge"})
+		}
+		return
+	}
+	// The merged candidate's applications are the survivor's now, so
+	// being able to edit the survivor is what counts
+	var survivor models.Candidate
+	if err := database.DB.Scopes(scopeToVisibleCandidates(c)).First(&survivor, merge.CandidateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		return
+	}
+	if !canEditCandidate(c, &survivor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	var restored *models.Candidate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, err = utils.UndoCandidateMerge(tx, &merge, &actorID)
+		if err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditMergeUndone, "candidate", merge.CandidateID, gin.H{
+			"merge_id":  merge.ID,
+			"merged_id": merge.MergedID,
+		})
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrMergeUndone), errors.Is(err, utils.ErrMergeUndoExpired), errors.Is(err, utils.ErrMergeCandidateGone):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Merge undone",
+		"candidate": restored,
+		"merge":     merge,
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvRecord is one data row of a CSV upload. Line matches what a
+// spreadsheet shows, the header is line 1.
+type csvRecord struct {
+	Line   int
+	fields map[string]string
+}
+
+// Get returns the trimmed value of column, or "" if the row or the header
+// does not have it.
+func (r csvRecord) Get(column string) string {
+	return strings.TrimSpace(r.fields[column])
+}
+
+// csvBody returns the CSV sent as a multipart upload in the "file" field
+// or as a text/csv body. Call close when done reading.
+func csvBody(c *gin.Context) (body io.Reader, close func(), err error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return c.Request.Body, func() {}, nil
+	case "multipart/form-data":
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, nil, errors.New("CSV file is required in the \"file\" field")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, func() { file.Close() }, nil
+	default:
+		return nil, nil, errors.New("send the CSV as text/csv or as a multipart upload in the \"file\" field")
+	}
+}
+
+// readCSV reads rows with a header in any column order. Header names are
+// matched case-insensitively. Blank lines are skipped and at most maxRows
+// data rows are accepted.
+func readCSV(r io.Reader, required []string, maxRows int) ([]csvRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	present := make(map[string]bool)
+	for i, name := range header {
+		columns[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		present[columns[i]] = true
+	}
+	for _, column := range required {
+		if !present[column] {
+			return nil, fmt.Errorf("CSV header must contain %s columns (missing %s)", joinColumns(required), column)
+		}
+	}
+
+	var records []csvRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		if len(records) >= maxRows {
+			return nil, fmt.Errorf("at most %d rows per request", maxRows)
+		}
+
+		record := csvRecord{Line: line, fields: make(map[string]string, len(columns))}
+		for i, value := range row {
+			if i < len(columns) {
+				record.fields[columns[i]] = value
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// joinColumns lists column names the way error messages read them:
+// "email, name and role".
+func joinColumns(columns []string) string {
+	if len(columns) <= 1 {
+		return strings.Join(columns, "")
+	}
+	return strings.Join(columns[:len(columns)-1], ", ") + " and " + columns[len(columns)-1]
+}
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+const maxTagSuggestions = 20
+
+// CustomFieldRequest defines a custom field. Entity, key and type are set
+// when the field is created; on update they may be left out but not
+// changed.
+type CustomFieldRequest struct {
+	Entity   models.CustomFieldEntity `json:"entity"`
+	Key      string                   `json:"key"`
+	Label    string                   `json:"label" binding:"required"`
+	Type     models.CustomFieldType   `json:"type"`
+	Options  []string                 `json:"options"`
+	HelpText string                   `json:"help_text"`
+	Required bool                     `json:"required"`
+	Position int                      `json:"position"`
+}
+
+func (r *CustomFieldRequest) applyTo(field *models.CustomField) error {
+	if field.ID != 0 {
+		if (r.Entity != "" && r.Entity != field.Entity) ||
+			(r.Key != "" && r.Key != field.Key) ||
+			(r.Type != "" && r.Type != field.Type) {
+			return utils.CustomFieldError("entity, key and type cannot be changed, add a new field instead")
+		}
+	} else {
+		field.Entity = r.Entity
+		field.Key = r.Key
+		field.Type = r.Type
+	}
+	field.Label = r.Label
+	field.Options = r.Options
+	field.HelpText = r.HelpText
+	field.Required = r.Required
+	field.Position = r.Position
+	return utils.NormalizeCustomField(field)
+}
+
+// respondCustomFieldError maps errors from tags, custom fields and their
+// values, and from filtering by them.
+func respondCustomFieldError(c *gin.Context, err error, fallback string) {
+	var invalid utils.CustomFieldError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// GetCustomFields lists the custom fields in order. Filter with
+// ?entity=candidate, application or job.
+func GetCustomFields(c *gin.Context) {
+	query := database.DB.Order("entity, position, id")
+	if entity := c.Query("entity"); entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+
+	fields := []models.CustomField{}
+	if err := query.Find(&fields).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve custom fields"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"custom_fields": fields,
+	})
+}
+
+func CreateCustomField(c *gin.Context) {
+	var req CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var field models.CustomField
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := req.applyTo(&field); err != nil {
+			return err
+		}
+		var taken int64
+		if err := tx.Model(&models.CustomField{}).
+			Where("entity = ? AND key = ?", field.Entity, field.Key).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return utils.CustomFieldError(fmt.Sprintf("a %s field with key %q already exists", field.Entity, field.Key))
+		}
+		if err := tx.Create(&field).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditCustomFieldChanged, "custom_field", field.ID, gin.H{
+			"operation": "created",
+			"entity":    field.Entity,
+			"key":       field.Key,
+		})
+		return nil
+	})
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to create custom field")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Custom field created",
+		"custom_field": field,
+	})
+}
+
+// UpdateCustomField changes the label, options, help text, required flag
+// and position of a field. Options still picked on a record cannot be
+// removed.
+func UpdateCustomField(c *gin.Context) {
+	var req CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	field, ok := findCustomField(c)
+	if !ok {
+		return
+	}
+	before := append([]string(nil), field.Options...)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := req.applyTo(field); err != nil {
+			return err
+		}
+		if err := checkRemovedOptions(tx, field, before); err != nil {
+			return err
+		}
+		if err := tx.Save(field).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditCustomFieldChanged, "custom_field", field.ID, gin.H{
+			"operation": "updated",
+			"entity":    field.Entity,
+			"key":       field.Key,
+		})
+		return nil
+	})
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to update custom field")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Custom field updated",
+		"custom_field": field,
+	})
+}
+
+// checkRemovedOptions fails when an option field no longer has is still
+// the value of a record.
+func checkRemovedOptions(tx *gorm.DB, field *models.CustomField, before []string) error {
+	kept := make(map[string]bool, len(field.Options))
+	for _, option := range field.Options {
+		kept[option] = true
+	}
+	for _, option := range before {
+		if kept[option] {
+			continue
+		}
+		value := interface{}(option)
+		if field.Type == models.CustomFieldMultiSelect {
+			value = []string{option}
+		}
+		var used int64
+		if err := tx.Model(utils.CustomFieldModel(field.Entity)).
+			Where("custom_fields @> ?::jsonb", customFieldJSON(field.Key, value)).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used > 0 {
+			return utils.CustomFieldError(fmt.Sprintf("option %q is used by %d records, change them first", option, used))
+		}
+	}
+	return nil
+}
+
+// DeleteCustomField removes a field and its values from every record.
+func DeleteCustomField(c *gin.Context) {
+	field, ok := findCustomField(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.RemoveCustomFieldValues(tx, field); err != nil {
+			return err
+		}
+		if err := tx.Delete(field).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditCustomFieldChanged, "custom_field", field.ID, gin.H{
+			"operation": "deleted",
+			"entity":    field.Entity,
+			"key":       field.Key,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete custom field"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Custom field deleted",
+	})
+}
+
+func findCustomField(c *gin.Context) (*models.CustomField, bool) {
+	var field models.CustomField
+	if err := database.DB.First(&field, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Custom field not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve custom field"})
+		}
+		return nil, false
+	}
+	return &field, true
+}
+
+// GetTags suggests the tags used on the candidates, applications or jobs
+// (?entity=, candidate by default) the current user can see, most used
+// first. Filter with ?q= (start of the tag).
+func GetTags(c *gin.Context) {
+	entity := models.CustomFieldEntity(c.DefaultQuery("entity", string(models.CustomFieldCandidate)))
+	if !entity.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity must be candidate, application or job"})
+		return
+	}
+
+	query, table := visibleRecords(c, entity)
+	query = query.
+		Joins("CROSS JOIN jsonb_array_elements_text(" + table + ".tags) AS tag(name)").
+		Select("tag.name AS name, COUNT(*) AS count").
+		Group("tag.name")
+	if prefix := strings.ToLower(strings.TrimSpace(c.Query("q"))); prefix != "" {
+		query = query.Where("tag.name LIKE ?", escapeLike(prefix)+"%")
+	}
+
+	tags := []struct {
+		Name  string `json:"name"`
+		Count int64  `json:"count"`
+	}{}
+	if err := query.Order("count DESC, name").Limit(maxTagSuggestions).Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags": tags,
+	})
+}
+
+// visibleRecords returns a query on the candidates, applications or jobs
+// the current user can see, and its table.
+func visibleRecords(c *gin.Context, entity models.CustomFieldEntity) (*gorm.DB, string) {
+	switch entity {
+	case models.CustomFieldApplication:
+		return database.DB.Model(&models.Application{}).Scopes(scopeToVisibleJobs(c, "applications.job_id")), "applications"
+	case models.CustomFieldJob:
+		return database.DB.Model(&models.Job{}).Scopes(scopeToVisibleJobs(c, "jobs.id")), "jobs"
+	default:
+		return database.DB.Model(&models.Candidate{}).Scopes(scopeToVisibleCandidates(c)), "candidates"
+	}
+}
+
+// setCustomFields validates the custom field values sent for a record of
+// entity and merges them into values. Required fields are checked when
+// creating.
+func setCustomFields(entity models.CustomFieldEntity, values *map[string]interface{}, changes map[string]json.RawMessage, creating bool) error {
+	if len(changes) == 0 && !creating {
+		return nil
+	}
+	fields, err := utils.CustomFieldsFor(database.DB, entity)
+	if err != nil {
+		return err
+	}
+	merged, err := utils.ApplyCustomFields(fields, *values, changes, creating)
+	if err != nil {
+		return err
+	}
+	*values = merged
+	return nil
+}
+
+// filterByTagsAndFields narrows a list of records of entity in table.
+// ?tag=a,b keeps the records with all of the tags and ?cf.<key>= the ones
+// with that value of a custom field: part of the text of text fields, any
+// of a comma separated list of options of select fields, and the exact
+// number or date. ?cf.<key>.min= and ?cf.<key>.max= give an inclusive
+// range of numbers or dates.
+func filterByTagsAndFields(c *gin.Context, query *gorm.DB, entity models.CustomFieldEntity, table string) (*gorm.DB, error) {
+	if tag := c.Query("tag"); tag != "" {
+		tags, err := utils.NormalizeTags(strings.Split(tag, ","))
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) > 0 {
+			encoded, _ := json.Marshal(tags)
+			query = query.Where(table+".tags @> ?::jsonb", string(encoded))
+		}
+	}
+
+	params := c.Request.URL.Query()
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if strings.HasPrefix(name, "cf.") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return query, nil
+	}
+	sort.Strings(names)
+
+	fields, err := utils.CustomFieldsFor(database.DB, entity)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+
+	column := table + ".custom_fields"
+	for _, name := range names {
+		value := strings.TrimSpace(params.Get(name))
+		if value == "" {
+			continue
+		}
+		key, bound, _ := strings.Cut(strings.TrimPrefix(name, "cf."), ".")
+		field, ok := byKey[key]
+		if !ok {
+			return nil, utils.CustomFieldError(fmt.Sprintf("unknown custom field %q", key))
+		}
+
+		switch bound {
+		case "":
+			if query, err = filterByCustomValue(query, column, field, value); err != nil {
+				return nil, err
+			}
+		case "min", "max":
+			operator := ">="
+			if bound == "max" {
+				operator = "<="
+			}
+			switch field.Type {
+			case models.CustomFieldNumber:
+				number, err := utils.ParseCustomFieldValue(field, json.RawMessage(value))
+				if err != nil {
+					return nil, err
+				}
+				query = query.Where("("+column+" ->> ?)::numeric "+operator+" ?", key, number)
+			case models.CustomFieldDate:
+				date, err := parseCustomFilterText(field, value)
+				if err != nil {
+					return nil, err
+				}
+				query = query.Where(column+" ->> ? "+operator+" ?", key, date)
+			default:
+				return nil, utils.CustomFieldError(fmt.Sprintf("%s has no range, only numbers and dates do", field.Label))
+			}
+		default:
+			return nil, utils.CustomFieldError(fmt.Sprintf("unknown filter %q", name))
+		}
+	}
+	return query, nil
+}
+
+// filterByCustomValue keeps the records whose field matches value.
+func filterByCustomValue(query *gorm.DB, column string, field *models.CustomField, value string) (*gorm.DB, error) {
+	switch field.Type {
+	case models.CustomFieldText:
+		return query.Where(column+" ->> ? ILIKE ?", field.Key, "%"+escapeLike(value)+"%"), nil
+
+	case models.CustomFieldNumber:
+		number, err := utils.ParseCustomFieldValue(field, json.RawMessage(value))
+		if err != nil {
+			return nil, err
+		}
+		return query.Where(column+" @> ?::jsonb", customFieldJSON(field.Key, number)), nil
+
+	case models.CustomFieldDate:
+		date, err := parseCustomFilterText(field, value)
+		if err != nil {
+			return nil, err
+		}
+		return query.Where(column+" @> ?::jsonb", customFieldJSON(field.Key, date)), nil
+	}
+
+	// Select fields match any of the options
+	conditions := []string{}
+	args := []interface{}{}
+	for _, choice := range strings.Split(value, ",") {
+		if strings.TrimSpace(choice) == "" {
+			continue
+		}
+		if field.Type == models.CustomFieldMultiSelect {
+			encoded, _ := json.Marshal([]string{choice})
+			picked, err := utils.ParseCustomFieldValue(field, encoded)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, customFieldJSON(field.Key, picked))
+		} else {
+			option, err := parseCustomFilterText(field, choice)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, customFieldJSON(field.Key, option))
+		}
+		conditions = append(conditions, column+" @> ?::jsonb")
+	}
+	if len(conditions) == 0 {
+		return query, nil
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...), nil
+}
+
+// parseCustomFilterText checks a date or option given in a filter.
+func parseCustomFilterText(field *models.CustomField, text string) (interface{}, error) {
+	encoded, _ := json.Marshal(text)
+	return utils.ParseCustomFieldValue(field, encoded)
+}
+
+// customFieldJSON is a jsonb document matching records whose field key
+// has value.
+func customFieldJSON(key string, value interface{}) string {
+	encoded, _ := json.Marshal(map[string]interface{}{key: value})
+	return string(encoded)
+}
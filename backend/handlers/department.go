@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type DepartmentRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Code        string `json:"code" binding:"max=30"`
+	ParentID    *uint  `json:"parent_id"`
+	Description string `json:"description"`
+}
+
+func departmentJSON(tree *utils.DepartmentTree, department *models.Department) gin.H {
+	return gin.H{
+		"id":          department.ID,
+		"name":        department.Name,
+		"code":        department.Code,
+		"parent_id":   department.ParentID,
+		"description": department.Description,
+		"path":        tree.Path(department.ID),
+		"depth":       tree.Depth(department.ID),
+		"created_at":  department.CreatedAt,
+		"updated_at":  department.UpdatedAt,
+	}
+}
+
+// GetDepartments lists every department, parents before their children,
+// with the number of jobs in each.
+func GetDepartments(c *gin.Context) {
+	tree, err := utils.LoadDepartmentTree(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve departments"})
+		return
+	}
+
+	jobCounts, err := jobCountsBy(c, "department_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve departments"})
+		return
+	}
+
+	departments := []gin.H{}
+	tree.Walk(func(department *models.Department, depth int) {
+		entry := departmentJSON(tree, department)
+		entry["jobs"] = jobCounts[department.ID]
+		departments = append(departments, entry)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"departments": departments,
+	})
+}
+
+// jobCountsBy counts the jobs the current user can see per department_id
+// or location_id.
+func jobCountsBy(c *gin.Context, column string) (map[uint]int64, error) {
+	var rows []struct {
+		ID    uint
+		Count int64
+	}
+	err := database.DB.Model(&models.Job{}).
+		Select(column + " AS id, COUNT(*) AS count").
+		Where(column + " IS NOT NULL").
+		Scopes(scopeToVisibleJobs(c, "jobs.id")).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.Count
+	}
+	return counts, nil
+}
+
+func CreateDepartment(c *gin.Context) {
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	department := models.Department{
+		Name:        req.Name,
+		Code:        req.Code,
+		ParentID:    req.ParentID,
+		Description: req.Description,
+	}
+
+	var tree *utils.DepartmentTree
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveDepartment(tx, &department); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditDepartmentChanged, "department", department.ID, gin.H{
+			"operation": "created",
+			"name":      department.Name,
+		})
+		var err error
+		tree, err = utils.LoadDepartmentTree(tx)
+		return err
+	})
+	if err != nil {
+		respondMasterDataError(c, err, "Failed to create department")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Department created",
+		"department": departmentJSON(tree, &department),
+	})
+}
+
+func UpdateDepartment(c *gin.Context) {
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	department, ok := findDepartment(c)
+	if !ok {
+		return
+	}
+
+	department.Name = req.Name
+	department.Code = req.Code
+	department.ParentID = req.ParentID
+	department.Description = req.Description
+
+	var tree *utils.DepartmentTree
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveDepartment(tx, department); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditDepartmentChanged, "department", department.ID, gin.H{
+			"operation": "updated",
+			"name":      department.Name,
+			"parent_id": department.ParentID,
+		})
+		var err error
+		tree, err = utils.LoadDepartmentTree(tx)
+		return err
+	})
+	if err != nil {
+		respondMasterDataError(c, err, "Failed to update department")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Department updated",
+		"department": departmentJSON(tree, department),
+	})
+}
+
+// DeleteDepartment removes a department nothing refers to anymore: no
+// sub-departments, jobs or approval chain.
+func DeleteDepartment(c *gin.Context) {
+	department, ok := findDepartment(c)
+	if !ok {
+		return
+	}
+
+	var children, jobs int64
+	database.DB.Model(&models.Department{}).Where("parent_id = ?", department.ID).Count(&children)
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Move or delete the sub-departments first"})
+		return
+	}
+	database.DB.Model(&models.Job{}).Where("department_id = ?", department.ID).Count(&jobs)
+	if jobs > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Department is used by %d jobs, move them first", jobs)})
+		return
+	}
+	for _, id := range utils.GetJobApprovalSettings().DepartmentIDs() {
+		if id == department.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Department has an approval chain, remove it first"})
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Deleted jobs keep the name only
+		if err := tx.Model(&models.Job{}).Unscoped().
+			Where("department_id = ?", department.ID).
+			UpdateColumn("department_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(department).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditDepartmentChanged, "department", department.ID, gin.H{
+			"operation": "deleted",
+			"name":      department.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete department"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Department deleted",
+	})
+}
+
+// ImportDepartments creates and updates departments from a CSV with a
+// name column and optional code, parent and description columns. parent is
+// the code, full path ("Engineering / Platform") or name of a department
+// that exists or comes earlier in the file. Rows update the department
+// with the same code, or without a code the one with the same name under
+// the same parent.
+func ImportDepartments(c *gin.Context) {
+	results, saved := runImport(c, []string{"name"}, importDepartmentRow)
+	if !saved {
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditDepartmentChanged, "department", 0, gin.H{
+		"operation": "imported",
+		"rows":      len(results),
+	})
+}
+
+func importDepartmentRow(tx *gorm.DB, record csvRecord) (ImportRowResult, error) {
+	name := record.Get("name")
+
+	tree, err := utils.LoadDepartmentTree(tx)
+	if err != nil {
+		return ImportRowResult{}, err
+	}
+
+	var parentID *uint
+	if parent := record.Get("parent"); parent != "" {
+		id, err := findDepartmentRef(tree, parent)
+		if err != nil {
+			return importRowResult(name, 0, "", err)
+		}
+		parentID = &id
+	}
+
+	department := models.Department{}
+	code := record.Get("code")
+	tree.Walk(func(existing *models.Department, _ int) {
+		if department.ID != 0 {
+			return
+		}
+		if code != "" && strings.EqualFold(existing.Code, code) ||
+			code == "" && strings.EqualFold(existing.Name, name) && equalUintPtr(existing.ParentID, parentID) {
+			department = *existing
+		}
+	})
+
+	before := department
+	department.Name = name
+	department.Code = code
+	department.ParentID = parentID
+	department.Description = record.Get("description")
+
+	status := ImportRowCreated
+	if before.ID != 0 {
+		if before.Name == department.Name && before.Code == department.Code &&
+			equalUintPtr(before.ParentID, department.ParentID) && before.Description == department.Description {
+			return importRowResult(name, before.ID, ImportRowUnchanged, nil)
+		}
+		status = ImportRowUpdated
+	}
+
+	err = saveDepartment(tx, &department)
+	return importRowResult(name, department.ID, status, err)
+}
+
+// findDepartmentRef resolves a department by code, full path or name, in
+// that order. Names shared by several departments have to be given as a
+// path or code.
+func findDepartmentRef(tree *utils.DepartmentTree, ref string) (uint, error) {
+	var byCode, byPath uint
+	byName := []uint{}
+	tree.Walk(func(department *models.Department, _ int) {
+		switch {
+		case department.Code != "" && strings.EqualFold(department.Code, ref):
+			byCode = department.ID
+		case strings.EqualFold(tree.Path(department.ID), ref):
+			byPath = department.ID
+		case strings.EqualFold(department.Name, ref):
+			byName = append(byName, department.ID)
+		}
+	})
+
+	switch {
+	case byCode != 0:
+		return byCode, nil
+	case byPath != 0:
+		return byPath, nil
+	case len(byName) == 1:
+		return byName[0], nil
+	case len(byName) > 1:
+		return 0, masterDataError(fmt.Sprintf("more than one department is named %q, use its code or full path", ref))
+	default:
+		return 0, masterDataError(fmt.Sprintf("department %q not found", ref))
+	}
+}
+
+// saveDepartment validates department against the others and saves it,
+// then brings the jobs that reference it up to date.
+func saveDepartment(tx *gorm.DB, department *models.Department) error {
+	department.Name = strings.TrimSpace(department.Name)
+	department.Code = strings.TrimSpace(department.Code)
+	department.Description = strings.TrimSpace(department.Description)
+
+	switch {
+	case department.Name == "":
+		return masterDataError("name is required")
+	case len(department.Name) > 100:
+		return masterDataError("name must be at most 100 characters")
+	case len(department.Code) > 30:
+		return masterDataError("code must be at most 30 characters")
+	}
+
+	tree, err := utils.LoadDepartmentTree(tx)
+	if err != nil {
+		return err
+	}
+
+	if department.ParentID != nil {
+		if _, ok := tree.Get(*department.ParentID); !ok {
+			return masterDataError("parent department not found")
+		}
+		if department.ID != 0 {
+			for _, id := range tree.Descendants(department.ID) {
+				if id == *department.ParentID {
+					return masterDataError("a department cannot be moved below itself")
+				}
+			}
+		}
+	}
+
+	var conflict error
+	tree.Walk(func(other *models.Department, _ int) {
+		switch {
+		case conflict != nil || other.ID == department.ID:
+		case department.Code != "" && strings.EqualFold(other.Code, department.Code):
+			conflict = masterDataError(fmt.Sprintf("code %q is already used by %s", department.Code, tree.Path(other.ID)))
+		case strings.EqualFold(other.Name, department.Name) && equalUintPtr(other.ParentID, department.ParentID):
+			conflict = masterDataError(fmt.Sprintf("a department named %q already exists there", department.Name))
+		}
+	})
+	if conflict != nil {
+		return conflict
+	}
+
+	created := department.ID == 0
+	previousName := ""
+	if existing, ok := tree.Get(department.ID); ok {
+		previousName = existing.Name
+	}
+
+	if err := tx.Save(department).Error; err != nil {
+		return err
+	}
+	return syncJobReferences(tx, "department", department.ID, department.Name, previousName, created)
+}
+
+func findDepartment(c *gin.Context) (*models.Department, bool) {
+	var department models.Department
+	if err := database.DB.First(&department, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve department"})
+		}
+		return nil, false
+	}
+	return &department, true
+}
+
+func equalUintPtr(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// Exports are built in memory, so they are capped
+const maxExportRows = 10000
+
+// ExportCandidates downloads the candidates list as CSV, with the same
+// filters and sort as GET /api/candidates. Each custom field gets a column.
+func ExportCandidates(c *gin.Context) {
+	query, err := candidateListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to export candidates")
+		return
+	}
+	fields, ok := exportFields(c, query, models.CustomFieldCandidate)
+	if !ok {
+		return
+	}
+
+	candidates := []models.Candidate{}
+	if err := query.Order(parseSort(c, candidateSortColumns, "created_at DESC")).Find(&candidates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export candidates"})
+		return
+	}
+
+	fieldHeaders, fieldCells := customFieldColumns(fields)
+	rows := make([][]string, len(candidates))
+	for i, candidate := range candidates {
+		rows[i] = append([]string{
+			strconv.FormatUint(uint64(candidate.ID), 10),
+			candidate.Name,
+			candidate.Email,
+			candidate.Phone,
+			candidate.Location,
+			candidate.CurrentTitle,
+			candidate.CurrentCompany,
+			string(candidate.Source),
+			strings.Join(candidate.Tags, "; "),
+			exportTime(&candidate.CreatedAt),
+		}, fieldCells(candidate.CustomFields)...)
+	}
+
+	header := append([]string{"ID", "Name", "Email", "Phone", "Location", "Current title", "Current company",
+		"Source", "Tags", "Created at"}, fieldHeaders...)
+	writeExport(c, models.CustomFieldCandidate, "candidates", header, rows)
+}
+
+// ExportJobs downloads the jobs list as CSV, with the same filters and sort
+// as GET /api/jobs. Each custom field gets a column.
+func ExportJobs(c *gin.Context) {
+	query, err := jobListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to export jobs")
+		return
+	}
+	fields, ok := exportFields(c, query, models.CustomFieldJob)
+	if !ok {
+		return
+	}
+
+	jobs := []models.Job{}
+	if err := query.Order(parseSort(c, jobSortColumns, "created_at DESC")).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export jobs"})
+		return
+	}
+
+	fieldHeaders, fieldCells := customFieldColumns(fields)
+	rows := make([][]string, len(jobs))
+	for i, job := range jobs {
+		rows[i] = append([]string{
+			strconv.FormatUint(uint64(job.ID), 10),
+			job.Title,
+			job.Department,
+			job.Location,
+			string(job.EmploymentType),
+			string(job.Status),
+			strconv.Itoa(job.ApplicantCount),
+			strings.Join(job.Tags, "; "),
+			exportTime(&job.CreatedAt),
+		}, fieldCells(job.CustomFields)...)
+	}
+
+	header := append([]string{"ID", "Title", "Department", "Location", "Type", "Status", "Applicants",
+		"Tags", "Created at"}, fieldHeaders...)
+	writeExport(c, models.CustomFieldJob, "jobs", header, rows)
+}
+
+// ExportApplications downloads the applications list as CSV, with the
+// same filters and sort as GET /api/applications. Each custom field of
+// applications gets a column.
+func ExportApplications(c *gin.Context) {
+	query, err := applicationListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to export applications")
+		return
+	}
+	fields, ok := exportFields(c, query, models.CustomFieldApplication)
+	if !ok {
+		return
+	}
+
+	applications := []models.Application{}
+	if err := query.
+		Preload("Candidate").
+		Preload("Job").
+		Preload("Stage").
+		Order(parseSort(c, applicationSortColumns, "applied_at DESC")).
+		Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export applications"})
+		return
+	}
+
+	fieldHeaders, fieldCells := customFieldColumns(fields)
+	rows := make([][]string, len(applications))
+	for i, application := range applications {
+		var candidateName, candidateEmail, jobTitle, stage string
+		if application.Candidate != nil {
+			candidateName, candidateEmail = application.Candidate.Name, application.Candidate.Email
+		}
+		if application.Job != nil {
+			jobTitle = application.Job.Title
+		}
+		if application.Stage != nil {
+			stage = application.Stage.Name
+		}
+		rows[i] = append([]string{
+			strconv.FormatUint(uint64(application.ID), 10),
+			candidateName,
+			candidateEmail,
+			jobTitle,
+			stage,
+			string(application.Status),
+			string(application.RejectionReason),
+			strings.Join(application.Tags, "; "),
+			exportTime(&application.AppliedAt),
+			exportTime(application.HiredAt),
+		}, fieldCells(application.CustomFields)...)
+	}
+
+	header := append([]string{"ID", "Candidate", "Email", "Job", "Stage", "Status", "Rejection reason",
+		"Tags", "Applied at", "Hired at"}, fieldHeaders...)
+	writeExport(c, models.CustomFieldApplication, "applications", header, rows)
+}
+
+// exportFields checks that the filtered list is small enough to export and
+// loads the custom fields that become its columns.
+func exportFields(c *gin.Context, query *gorm.DB, entity models.CustomFieldEntity) ([]models.CustomField, bool) {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export"})
+		return nil, false
+	}
+	if total > maxExportRows {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("At most %d rows can be exported, this would be %d; narrow the filters", maxExportRows, total),
+		})
+		return nil, false
+	}
+
+	fields, err := utils.CustomFieldsFor(database.DB, entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export"})
+		return nil, false
+	}
+	return fields, true
+}
+
+// writeExport sends rows as a CSV download and records who exported what.
+func writeExport(c *gin.Context, entity models.CustomFieldEntity, name string, header []string, rows [][]string) {
+	recordAudit(c, database.DB, models.AuditRecordsExported, string(entity), 0, gin.H{
+		"rows":    len(rows),
+		"filters": c.Request.URL.RawQuery,
+	})
+
+	filename := fmt.Sprintf("kandy-%s-%s.csv", name, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(header)
+	for _, row := range rows {
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+}
+
+// csvCell keeps spreadsheets from running a value as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// customFieldColumns returns the column headers of fields and a function
+// giving the cells of a record.
+func customFieldColumns(fields []models.CustomField) ([]string, func(values map[string]interface{}) []string) {
+	headers := make([]string, len(fields))
+	for i, field := range fields {
+		headers[i] = field.Label
+	}
+	return headers, func(values map[string]interface{}) []string {
+		cells := make([]string, len(fields))
+		for i, field := range fields {
+			cells[i] = utils.CustomFieldText(values[field.Key])
+		}
+		return cells
+	}
+}
+
+// exportTime formats times in exports, blank when unset.
+func exportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
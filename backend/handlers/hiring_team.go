@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type TeamMemberRequest struct {
+	UserID uint                  `json:"user_id" binding:"required"`
+	Role   models.HiringTeamRole `json:"role" binding:"required"`
+}
+
+type TeamMemberUpdateRequest struct {
+	Role models.HiringTeamRole `json:"role" binding:"required"`
+}
+
+func isAdmin(c *gin.Context) bool {
+	return models.UserRole(c.GetString("user_role")) == models.RoleAdmin
+}
+
+// scopeToVisibleJobs limits a query to rows whose jobColumn references a
+// job the current user may see: jobs they are staffed on, and jobs waiting
+// for their approval. Admins see everything. Use it on every query that
+// returns jobs or data belonging to jobs:
+//
+//	database.DB.Scopes(scopeToVisibleJobs(c, "jobs.id")).Find(&jobs)
+func scopeToVisibleJobs(c *gin.Context, jobColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if isAdmin(c) {
+			return db
+		}
+
+		userID := c.GetUint("user_id")
+		return db.Where(
+			fmt.Sprintf("(%[1]s IN (SELECT job_id FROM job_team_members WHERE user_id = ?)"+
+				" OR %[1]s IN (SELECT job_id FROM job_approvals WHERE status = ? AND approver_ids @> ?::jsonb))", jobColumn),
+			userID, models.JobApprovalPending, fmt.Sprintf("[%d]", userID),
+		)
+	}
+}
+
+// jobTeamRole returns the current user's role on the job's hiring team.
+func jobTeamRole(c *gin.Context, jobID uint) (models.HiringTeamRole, bool) {
+	var member models.JobTeamMember
+	err := database.DB.Where("job_id = ? AND user_id = ?", jobID, c.GetUint("user_id")).First(&member).Error
+	if err != nil {
+		return "", false
+	}
+	return member.Role, true
+}
+
+// canManageJob reports whether the current user may change job: admins,
+// and the recruiters and hiring managers staffed on it.
+func canManageJob(c *gin.Context, job *models.Job) bool {
+	if isAdmin(c) {
+		return true
+	}
+	role, ok := jobTeamRole(c, job.ID)
+	return ok && role.CanManageJob()
+}
+
+// creatorTeamRole is the team role a job's creator starts with, based on
+// their account role. Admins see every job and are not staffed.
+func creatorTeamRole(role models.UserRole) (models.HiringTeamRole, bool) {
+	switch role {
+	case models.RoleRecruiter:
+		return models.TeamRecruiter, true
+	case models.RoleHiringManager:
+		return models.TeamHiringManager, true
+	default:
+		return "", false
+	}
+}
+
+func teamMemberJSON(member *models.JobTeamMember) gin.H {
+	entry := gin.H{
+		"user_id":    member.UserID,
+		"role":       member.Role,
+		"added_by":   member.AddedBy,
+		"created_at": member.CreatedAt,
+	}
+	if member.User != nil {
+		entry["name"] = member.User.Name
+		entry["email"] = member.User.Email
+		entry["job_title"] = member.User.JobTitle
+		entry["avatar_thumbnail_url"] = avatarURL(member.User, "thumbnail")
+	}
+	return entry
+}
+
+func GetJobTeam(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	var members []models.JobTeamMember
+	if err := database.DB.Preload("User").
+		Where("job_id = ?", job.ID).
+		Order("created_at").
+		Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve hiring team"})
+		return
+	}
+
+	team := make([]gin.H, len(members))
+	for i := range members {
+		team[i] = teamMemberJSON(&members[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team":       team,
+		"can_manage": canManageJob(c, job),
+	})
+}
+
+func AddJobTeamMember(c *gin.Context) {
+	var req TeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be recruiter, hiring_manager, coordinator or interviewer"})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_active = ?", req.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found or inactive"})
+		return
+	}
+	if user.Role == models.RoleReadOnly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Read-only users cannot join a hiring team"})
+		return
+	}
+
+	var existing int64
+	database.DB.Model(&models.JobTeamMember{}).Where("job_id = ? AND user_id = ?", job.ID, user.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already on the hiring team"})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	member := models.JobTeamMember{
+		JobID:   job.ID,
+		UserID:  user.ID,
+		User:    &user,
+		Role:    req.Role,
+		AddedBy: &adminID,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Create(&member).Error; err != nil {
+			return err
+		}
+		return utils.QueueEmail(tx, utils.Email{
+			To:      user.Email,
+			Subject: "You were added to the hiring team for " + job.Title,
+			Body: fmt.Sprintf(`Hi %s,
+
+You were added to the hiring team for "%s" as %s.
+
+Open the job here:
+%s
+`, user.Name, job.Title, teamRoleLabel(req.Role), utils.AppURL(fmt.Sprintf("/jobs/%d/applicants", job.ID))),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add team member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Team member added",
+		"member":  teamMemberJSON(&member),
+	})
+}
+
+func UpdateJobTeamMember(c *gin.Context) {
+	var req TeamMemberUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be recruiter, hiring_manager, coordinator or interviewer"})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	member, ok := findTeamMember(c, job)
+	if !ok {
+		return
+	}
+	if member.UserID == c.GetUint("user_id") && !req.Role.CanManageJob() && !isAdmin(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own permission to manage this job"})
+		return
+	}
+
+	member.Role = req.Role
+	if err := database.DB.Omit("User").Save(member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team member updated",
+		"member":  teamMemberJSON(member),
+	})
+}
+
+func RemoveJobTeamMember(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	member, ok := findTeamMember(c, job)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove team member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team member removed",
+	})
+}
+
+func findTeamMember(c *gin.Context, job *models.Job) (*models.JobTeamMember, bool) {
+	var member models.JobTeamMember
+	err := database.DB.Preload("User").Where("job_id = ? AND user_id = ?", job.ID, c.Param("userId")).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team member"})
+		}
+		return nil, false
+	}
+	return &member, true
+}
+
+func teamRoleLabel(role models.HiringTeamRole) string {
+	switch role {
+	case models.TeamHiringManager:
+		return "hiring manager"
+	default:
+		return string(role)
+	}
+}
+
+// GetUserDirectory lists active colleagues so they can be staffed on
+// hiring teams. ?q= searches name and email.
+func GetUserDirectory(c *gin.Context) {
+	query := database.DB.Where("is_active = ? AND role <> ?", true, models.RoleReadOnly).Order("name").Limit(50)
+	if search := c.Query("q"); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		log.Printf("Failed to load user directory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	directory := make([]gin.H, len(users))
+	for i := range users {
+		directory[i] = gin.H{
+			"id":                   users[i].ID,
+			"name":                 users[i].Name,
+			"email":                users[i].Email,
+			"role":                 users[i].Role,
+			"job_title":            users[i].JobTitle,
+			"avatar_thumbnail_url": avatarURL(&users[i], "thumbnail"),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": directory,
+	})
+}
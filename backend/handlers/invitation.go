@@ -8,6 +8,7 @@ import (
 	"github.com/sebastian/kandy/backend/database"
 	"github.com/sebastian/kandy/backend/models"
 	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
 )
 
 // InviteUserRequest represents the invitation request payload
@@ -38,6 +39,11 @@ func InviteUser(c *gin.Context) {
 		return
 	}
 
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + string(req.Role)})
+		return
+	}
+
 	// Check if user with email already exists
 	var existingUser models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
@@ -45,34 +51,33 @@ func InviteUser(c *gin.Context) {
 		return
 	}
 
-	// Generate invitation token
-	token, err := utils.GenerateRandomToken()
+	settings := utils.GetInvitationSettings()
+	adminIDUint := adminID.(uint)
+
+	user, token, err := newInvitation(adminIDUint, req.Email, req.Name, req.Role, settings)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
 		return
 	}
 
-	// Create user with invitation
-	now := time.Now()
-	adminIDUint := adminID.(uint)
-	user := models.User{
-		Email:            req.Email,
-		Name:             req.Name,
-		Role:             req.Role,
-		IsActive:         false, // User is inactive until they accept invitation
-		EmailVerified:    false, // Will be verified when they accept invitation
-		InvitedBy:        &adminIDUint,
-		InvitationToken:  &token,
-		InvitationSentAt: &now,
-		PasswordHash:     "PENDING", // Placeholder - will be set when invitation is accepted
-	}
-
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordInvitationEvent(tx, &user, models.InvitationEventSent, &adminIDUint); err != nil {
+			return err
+		}
+		return utils.QueueEmail(tx, utils.InvitationEmail(&user, token, settings))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
 		return
 	}
 
-	// TODO: Send email with invitation link
+	recordAudit(c, database.DB, models.AuditInvitationSent, "user", user.ID, gin.H{
+		"role": user.Role,
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Invitation sent successfully",
 		"user": gin.H{
@@ -81,10 +86,35 @@ func InviteUser(c *gin.Context) {
 			"name":  user.Name,
 			"role":  user.Role,
 		},
-		"invitation_token": token, // REMOVE THIS IN PRODUCTION - only for testing
+		"invitation_expires_at": user.InvitationExpiresAt,
 	})
 }
 
+// newInvitation builds an inactive user holding a fresh invitation token.
+// The caller saves it.
+func newInvitation(adminID uint, email, name string, role models.UserRole, settings utils.InvitationSettings) (models.User, string, error) {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(settings.Expiry())
+	user := models.User{
+		Email:            email,
+		Name:             name,
+		Role:             role,
+		IsActive:         false, // User is inactive until they accept invitation
+		EmailVerified:    false, // Will be verified when they accept invitation
+		InvitedBy:        &adminID,
+		InvitationToken:  &token,
+		InvitationSentAt: &now,
+		PasswordHash:     "PENDING", // Placeholder - will be set when invitation is accepted
+	}
+	user.InvitationExpiresAt = &expiresAt
+	return user, token, nil
+}
+
 func AcceptInvitation(c *gin.Context) {
 	var req AcceptInvitationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -103,12 +133,15 @@ func AcceptInvitation(c *gin.Context) {
 		return
 	}
 
-	if user.InvitationSentAt != nil {
-		expiryDuration := 7 * 24 * time.Hour // 7 days
-		if time.Since(*user.InvitationSentAt) > expiryDuration {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation has expired"})
-			return
-		}
+	// Invitations sent before expiry was stored use the configured expiry
+	if user.InvitationExpiresAt == nil && user.InvitationSentAt != nil {
+		expiresAt := user.InvitationSentAt.Add(utils.GetInvitationSettings().Expiry())
+		user.InvitationExpiresAt = &expiresAt
+	}
+
+	if user.IsInvitationExpired() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation has expired"})
+		return
 	}
 
 	if err := user.HashPassword(req.Password); err != nil {
@@ -122,40 +155,29 @@ func AcceptInvitation(c *gin.Context) {
 	user.IsActive = true
 	user.EmailVerified = true
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return utils.RecordInvitationEvent(tx, &user, models.InvitationEventAccepted, &user.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
 		return
 	}
 
-	// Generate JWT tokens
-	token, err := utils.GenerateJWT(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
+	recordAudit(c, database.DB, models.AuditInvitationAccepted, "user", user.ID, nil)
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	session, err := startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	// Create session
-	session := models.Session{
-		UserID:       user.ID,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IPAddress:    c.ClientIP(),
-		UserAgent:    c.GetHeader("User-Agent"),
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour), // 30 days
-		LastUsedAt:   time.Now(),
-	}
-	database.DB.Create(&session)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Invitation accepted successfully",
-		"token":         token,
-		"refresh_token": refreshToken,
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
 		"user": gin.H{
 			"id":    user.ID,
 			"email": user.Email,
@@ -165,12 +187,24 @@ func AcceptInvitation(c *gin.Context) {
 	})
 }
 
+// GetPendingInvitations lists invitations that have not been accepted,
+// including expired ones. Filter with ?status=pending or ?status=expired.
 func GetPendingInvitations(c *gin.Context) {
 	var users []models.User
 
-	if err := database.DB.Where("invitation_token IS NOT NULL AND invitation_accepted_at IS NULL").
-		Order("invitation_sent_at DESC").
-		Find(&users).Error; err != nil {
+	query := database.DB.Where("invitation_token IS NOT NULL AND invitation_accepted_at IS NULL")
+	switch models.InvitationStatus(c.Query("status")) {
+	case "":
+	case models.InvitationPending:
+		query = query.Where("invitation_expired_at IS NULL AND (invitation_expires_at IS NULL OR invitation_expires_at > ?)", time.Now())
+	case models.InvitationExpired:
+		query = query.Where("invitation_expired_at IS NOT NULL OR invitation_expires_at <= ?", time.Now())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending or expired"})
+		return
+	}
+
+	if err := query.Order("invitation_sent_at DESC").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations"})
 		return
 	}
@@ -178,12 +212,15 @@ func GetPendingInvitations(c *gin.Context) {
 	invitations := make([]gin.H, len(users))
 	for i, user := range users {
 		invitations[i] = gin.H{
-			"id":                 user.ID,
-			"email":              user.Email,
-			"name":               user.Name,
-			"role":               user.Role,
-			"invitation_sent_at": user.InvitationSentAt,
-			"invited_by":         user.InvitedBy,
+			"id":                    user.ID,
+			"email":                 user.Email,
+			"name":                  user.Name,
+			"role":                  user.Role,
+			"status":                user.InvitationStatus(),
+			"invitation_sent_at":    user.InvitationSentAt,
+			"invitation_expires_at": user.InvitationExpiresAt,
+			"reminders_sent":        user.InvitationReminders,
+			"invited_by":            user.InvitedBy,
 		}
 	}
 
@@ -207,26 +244,49 @@ func ResendInvitation(c *gin.Context) {
 		return
 	}
 
+	if user.InvitationSentAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User was not invited"})
+		return
+	}
+
 	token, err := utils.GenerateRandomToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
 		return
 	}
 
+	settings := utils.GetInvitationSettings()
+	adminID := c.GetUint("user_id")
+
+	// Resending restarts the lifecycle, including for expired invitations
 	now := time.Now()
+	expiresAt := now.Add(settings.Expiry())
 	user.InvitationToken = &token
 	user.InvitationSentAt = &now
+	user.InvitationExpiresAt = &expiresAt
+	user.InvitationExpiredAt = nil
+	user.InvitationReminders = 0
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := utils.RecordInvitationEvent(tx, &user, models.InvitationEventResent, &adminID); err != nil {
+			return err
+		}
+		return utils.QueueEmail(tx, utils.InvitationEmail(&user, token, settings))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend invitation"})
 		return
 	}
 
-	// TODO: Send email with new invitation link
+	recordAudit(c, database.DB, models.AuditInvitationResent, "user", user.ID, nil)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":          "Invitation resent successfully",
-		"invitation_token": token, // REMOVE THIS IN PRODUCTION
-		"invitation_link":  "http://localhost:3000/accept-invitation?token=" + token,
+		"message":               "Invitation resent successfully",
+		"invitation_link":       settings.AcceptURL(token),
+		"invitation_expires_at": user.InvitationExpiresAt,
 	})
 }
 
@@ -244,13 +304,80 @@ func CancelInvitation(c *gin.Context) {
 		return
 	}
 
-	// Permanently delete the user (hard delete) since they never activated their account
-	if err := database.DB.Unscoped().Delete(&user).Error; err != nil {
+	adminID := c.GetUint("user_id")
+
+	// Permanently delete the user (hard delete) since they never activated their account.
+	// The invitation history is kept.
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.RecordInvitationEvent(tx, &user, models.InvitationEventCancelled, &adminID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&user).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel invitation"})
 		return
 	}
 
+	recordAudit(c, database.DB, models.AuditInvitationCanceled, "user", user.ID, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Invitation cancelled successfully",
 	})
 }
+
+func GetInvitationEvents(c *gin.Context) {
+	userID := c.Param("id")
+
+	var events []models.InvitationEvent
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitation history"})
+		return
+	}
+
+	if len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+func GetInvitationSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"settings":         utils.GetInvitationSettings(),
+		"default_base_url": utils.AppURL(""),
+	})
+}
+
+func UpdateInvitationSettings(c *gin.Context) {
+	var settings utils.InvitationSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := settings.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	if err := utils.SaveSetting(models.SettingInvitations, settings, &adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditSettingsChanged, "setting", 0, gin.H{
+		"key":   models.SettingInvitations,
+		"value": settings,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Invitation settings updated",
+		"settings": settings,
+	})
+}
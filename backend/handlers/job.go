@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// JobRequest is used for both creating and replacing a job. Drafts only
+// need a title; the rest is required once the job is published. Status
+// may be "draft" or "published"; other status changes go through
+// POST /api/jobs/:id/transitions.
+//
+// Department and location are picked by department_id and location_id.
+// Names are still accepted and resolved to the managed entry of that name.
+//
+// Tags are kept as they are when left out, and so are custom fields
+// missing from custom_fields; null clears one. Neither needs approving
+// again.
+//
+// PipelineTemplateID picks the template a new job copies its pipeline from,
+// the default template if omitted. It is ignored on update; the stages of
+// an existing job are changed through /api/jobs/:id/stages.
+type JobRequest struct {
+	Title          string                `json:"title" binding:"required,max=200"`
+	DepartmentID   *uint                 `json:"department_id"`
+	Department     string                `json:"department" binding:"max=100"`
+	LocationID     *uint                 `json:"location_id"`
+	Location       string                `json:"location" binding:"max=100"`
+	Type           models.EmploymentType `json:"type"`
+	Status         models.JobStatus      `json:"status"`
+	Description    string                `json:"description"`
+	Requirements   string                `json:"requirements"`
+	SalaryMin      *int                  `json:"salary_min" binding:"omitempty,min=0"`
+	SalaryMax      *int                  `json:"salary_max" binding:"omitempty,min=0"`
+	SalaryCurrency string                `json:"salary_currency" binding:"omitempty,len=3,alpha"`
+	SalaryPeriod   models.SalaryPeriod   `json:"salary_period"`
+	PublishedTo    []string              `json:"published_to"`
+	PublishAt      *time.Time            `json:"publish_at"`
+	CloseAt        *time.Time            `json:"close_at"`
+
+	Tags         []string                   `json:"tags"`
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+
+	PipelineTemplateID *uint `json:"pipeline_template_id"`
+}
+
+var jobSortColumns = map[string]string{
+	"title":      "title",
+	"department": "department",
+	"location":   "location",
+	"status":     "status",
+	"applicants": "applicant_count",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// normalize fills in defaults and validates the request.
+func (r *JobRequest) normalize() error {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Department = strings.TrimSpace(r.Department)
+	r.Location = strings.TrimSpace(r.Location)
+	r.SalaryCurrency = strings.ToUpper(r.SalaryCurrency)
+
+	if r.Title == "" {
+		return errors.New("title is required")
+	}
+	if r.Type == "" {
+		r.Type = models.EmploymentFullTime
+	}
+	if !r.Type.IsValid() {
+		return errors.New("type must be full-time, part-time, contract or internship")
+	}
+	if r.Status != "" && r.Status != models.JobDraft && r.Status != models.JobPublished {
+		return errors.New("status must be draft or published, use the transitions endpoint for other changes")
+	}
+	if r.CloseAt != nil {
+		if r.CloseAt.Before(time.Now()) {
+			return errors.New("close_at must be in the future")
+		}
+		if r.PublishAt != nil && !r.CloseAt.After(*r.PublishAt) {
+			return errors.New("close_at must be after publish_at")
+		}
+	}
+
+	if r.SalaryMin != nil && r.SalaryMax != nil && *r.SalaryMin > *r.SalaryMax {
+		return errors.New("salary_min cannot be greater than salary_max")
+	}
+	hasSalary := r.SalaryMin != nil || r.SalaryMax != nil
+	switch r.SalaryPeriod {
+	case "":
+		if hasSalary {
+			r.SalaryPeriod = models.SalaryPerYear
+		}
+	case models.SalaryPerYear, models.SalaryPerMonth, models.SalaryPerHour:
+	default:
+		return errors.New("salary_period must be year, month or hour")
+	}
+	if hasSalary && r.SalaryCurrency == "" {
+		return errors.New("salary_currency is required with a salary range")
+	}
+
+	channels := []string{}
+	seen := make(map[string]bool)
+	for _, channel := range r.PublishedTo {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if seen[channel] {
+			continue
+		}
+		if !isJobChannel(channel) {
+			return errors.New("unknown publishing channel: " + channel)
+		}
+		seen[channel] = true
+		channels = append(channels, channel)
+	}
+	r.PublishedTo = channels
+
+	if r.Tags != nil {
+		tags, err := utils.NormalizeTags(r.Tags)
+		if err != nil {
+			return err
+		}
+		r.Tags = tags
+	}
+
+	return nil
+}
+
+// resolveReferences looks up the department and location the request
+// points to and fills in both their IDs and names. current is the job being
+// replaced, nil on create: its unmanaged department or location name, from
+// before they were managed, may be kept as it is.
+func (r *JobRequest) resolveReferences(current *models.Job) error {
+	if r.DepartmentID != nil {
+		var department models.Department
+		if err := database.DB.First(&department, *r.DepartmentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return jobValidationError("department not found")
+			}
+			return err
+		}
+		r.Department = department.Name
+	} else if r.Department != "" {
+		var departments []models.Department
+		if err := database.DB.Where("LOWER(name) = LOWER(?)", r.Department).Find(&departments).Error; err != nil {
+			return err
+		}
+		switch {
+		case len(departments) == 1:
+			r.DepartmentID = &departments[0].ID
+			r.Department = departments[0].Name
+		case len(departments) > 1:
+			return jobValidationError("more than one department is named " + r.Department + ", send department_id instead")
+		case current == nil || current.DepartmentID != nil || current.Department != r.Department:
+			return jobValidationError("unknown department: " + r.Department)
+		}
+	}
+
+	if r.LocationID != nil {
+		var location models.Location
+		if err := database.DB.First(&location, *r.LocationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return jobValidationError("location not found")
+			}
+			return err
+		}
+		r.Location = location.Name
+	} else if r.Location != "" {
+		var location models.Location
+		err := database.DB.Where("LOWER(name) = LOWER(?)", r.Location).First(&location).Error
+		switch {
+		case err == nil:
+			r.LocationID = &location.ID
+			r.Location = location.Name
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		case current == nil || current.LocationID != nil || current.Location != r.Location:
+			return jobValidationError("unknown location: " + r.Location)
+		}
+	}
+
+	return nil
+}
+
+func isJobChannel(channel string) bool {
+	for _, valid := range models.JobChannels {
+		if channel == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTo copies the request onto job and reports whether anything that
+// approvers signed off on changed.
+func (r *JobRequest) applyTo(job *models.Job) bool {
+	before := *job
+
+	job.Title = r.Title
+	job.DepartmentID = r.DepartmentID
+	job.Department = r.Department
+	job.LocationID = r.LocationID
+	job.Location = r.Location
+	job.EmploymentType = r.Type
+	job.Description = r.Description
+	job.Requirements = r.Requirements
+	job.SalaryMin = r.SalaryMin
+	job.SalaryMax = r.SalaryMax
+	job.SalaryCurrency = r.SalaryCurrency
+	job.SalaryPeriod = r.SalaryPeriod
+	job.PublishedTo = r.PublishedTo
+	job.PublishAt = r.PublishAt
+	job.CloseAt = r.CloseAt
+	if r.Tags != nil {
+		job.Tags = r.Tags
+	}
+
+	return before.Title != job.Title ||
+		!equalUintPtr(before.DepartmentID, job.DepartmentID) ||
+		before.Department != job.Department ||
+		!equalUintPtr(before.LocationID, job.LocationID) ||
+		before.Location != job.Location ||
+		before.EmploymentType != job.EmploymentType ||
+		before.Description != job.Description ||
+		before.Requirements != job.Requirements ||
+		!equalIntPtr(before.SalaryMin, job.SalaryMin) ||
+		!equalIntPtr(before.SalaryMax, job.SalaryMax) ||
+		before.SalaryCurrency != job.SalaryCurrency ||
+		before.SalaryPeriod != job.SalaryPeriod
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// findJob loads the job in the URL. Jobs the current user may not see are
+// reported as not found.
+func findJob(c *gin.Context) (*models.Job, bool) {
+	var job models.Job
+	if err := database.DB.Scopes(scopeToVisibleJobs(c, "jobs.id")).First(&job, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		}
+		return nil, false
+	}
+	return &job, true
+}
+
+// GetJobs lists the jobs the current user can see. Filters: status (comma
+// separated), department_id (including its sub-departments), location_id,
+// workplace_type, country, department and location names, type, q (title
+// search), mine=true for jobs the current user is staffed on, tag and
+// custom fields (see filterByTagsAndFields). Sort with ?sort=title or
+// ?sort=-created_at.
+func GetJobs(c *gin.Context) {
+	query, err := jobListQuery(c)
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to retrieve jobs")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	page := parsePagination(c)
+	jobs := []models.Job{}
+	if err := page.apply(query).
+		Order(parseSort(c, jobSortColumns, "created_at DESC")).
+		Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":       jobs,
+		"pagination": page.JSON(total),
+	})
+}
+
+// jobListQuery applies the filters of the jobs list.
+func jobListQuery(c *gin.Context) (*gorm.DB, error) {
+	query := database.DB.Model(&models.Job{}).Scopes(scopeToVisibleJobs(c, "jobs.id"))
+
+	if status := c.Query("status"); status != "" && status != "all" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+	if departmentID, err := strconv.ParseUint(c.Query("department_id"), 10, 64); err == nil {
+		tree, err := utils.LoadDepartmentTree(database.DB)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("department_id IN ?", tree.Descendants(uint(departmentID)))
+	}
+	if locationID := c.Query("location_id"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if workplaceType := c.Query("workplace_type"); workplaceType != "" {
+		query = query.Where("location_id IN (SELECT id FROM locations WHERE workplace_type = ?)", workplaceType)
+	}
+	if country := c.Query("country"); country != "" {
+		query = query.Where("location_id IN (SELECT id FROM locations WHERE country_code = ?)", strings.ToUpper(country))
+	}
+	if department := c.Query("department"); department != "" {
+		query = query.Where("department = ?", department)
+	}
+	if location := c.Query("location"); location != "" {
+		query = query.Where("location = ?", location)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("employment_type = ?", jobType)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("title ILIKE ?", "%"+escapeLike(search)+"%")
+	}
+	if c.Query("mine") == "true" {
+		query = query.Where("jobs.id IN (SELECT job_id FROM job_team_members WHERE user_id = ?)", c.GetUint("user_id"))
+	}
+	return filterByTagsAndFields(c, query, models.CustomFieldJob, "jobs")
+}
+
+// GetJobStats returns the counters shown above the jobs list.
+func GetJobStats(c *gin.Context) {
+	var stats struct {
+		Total           int64
+		Published       int64
+		Drafts          int64
+		PendingApproval int64
+		Paused          int64
+		Closed          int64
+		Applicants      int64
+	}
+
+	err := database.DB.Model(&models.Job{}).Scopes(scopeToVisibleJobs(c, "jobs.id")).Select(
+		"COUNT(*) AS total, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS published, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS drafts, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS pending_approval, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS paused, "+
+			"COUNT(*) FILTER (WHERE status = ?) AS closed, "+
+			"COALESCE(SUM(applicant_count), 0) AS applicants",
+		models.JobPublished, models.JobDraft, models.JobPendingApproval, models.JobPaused, models.JobClosed,
+	).Scan(&stats).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":            stats.Total,
+		"published":        stats.Published,
+		"drafts":           stats.Drafts,
+		"pending_approval": stats.PendingApproval,
+		"paused":           stats.Paused,
+		"closed":           stats.Closed,
+		"applicants":       stats.Applicants,
+	})
+}
+
+func GetJob(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	teamRole, _ := jobTeamRole(c, job.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":                 job,
+		"team_role":           teamRole,
+		"can_manage":          canManageJob(c, job),
+		"allowed_transitions": allowedJobTransitions(job.Status),
+	})
+}
+
+func CreateJob(c *gin.Context) {
+	var req JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.resolveReferences(nil); err != nil {
+		respondJobWorkflowError(c, err, "Failed to create job")
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	job := models.Job{
+		Status:    models.JobDraft,
+		CreatedBy: actorID,
+	}
+	req.applyTo(&job)
+	if err := setCustomFields(models.CustomFieldJob, &job.CustomFields, req.CustomFields, true); err != nil {
+		respondCustomFieldError(c, err, "Failed to create job")
+		return
+	}
+
+	if req.Status == models.JobPublished {
+		if missing := job.MissingForPublish(); len(missing) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing fields to publish: " + strings.Join(missing, ", ")})
+			return
+		}
+	}
+
+	stages, err := utils.PipelineStagesFor(database.DB, req.PipelineTemplateID)
+	if err != nil {
+		respondPipelineError(c, err, "Failed to create job")
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		if err := utils.CreateJobPipeline(tx, job.ID, stages); err != nil {
+			return err
+		}
+		if role, ok := creatorTeamRole(models.UserRole(c.GetString("user_role"))); ok {
+			if err := tx.Create(&models.JobTeamMember{
+				JobID:   job.ID,
+				UserID:  actorID,
+				Role:    role,
+				AddedBy: &actorID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if req.Status == models.JobPublished {
+			return utils.RequestJobPublish(tx, &job, &actorID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": jobStatusMessage(&job, "Job created"),
+		"job":     job,
+	})
+}
+
+// UpdateJob replaces the editable fields of a job. Changing the content of
+// an approved draft means it has to be approved again.
+func UpdateJob(c *gin.Context) {
+	var req JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if err := req.resolveReferences(job); err != nil {
+		respondJobWorkflowError(c, err, "Failed to update job")
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		if job.Status == models.JobPendingApproval {
+			return errJobAwaitingApproval
+		}
+		if req.Status != "" && req.Status != job.Status &&
+			!(req.Status == models.JobPublished && job.Status == models.JobDraft) {
+			return &utils.JobTransitionError{From: job.Status, To: req.Status}
+		}
+
+		chainBefore, err := utils.ApprovalChainForJob(tx, job)
+		if err != nil {
+			return err
+		}
+		if req.applyTo(job) {
+			switch job.Status {
+			case models.JobDraft:
+				job.ApprovedAt = nil
+			case models.JobPublished, models.JobPaused:
+				// What the approvers saw is what stays live; without a
+				// chain nothing was approved
+				chainAfter, err := utils.ApprovalChainForJob(tx, job)
+				if err != nil {
+					return err
+				}
+				if chainBefore != nil || chainAfter != nil {
+					return errJobContentApproved
+				}
+			}
+		}
+		if err := setCustomFields(models.CustomFieldJob, &job.CustomFields, req.CustomFields, false); err != nil {
+			return err
+		}
+
+		if req.Status == models.JobPublished && job.Status == models.JobDraft {
+			if missing := job.MissingForPublish(); len(missing) > 0 {
+				return jobValidationError("Missing fields to publish: " + strings.Join(missing, ", "))
+			}
+			return utils.RequestJobPublish(tx, job, &actorID)
+		}
+		return tx.Save(job).Error
+	})
+	if err != nil {
+		respondJobWorkflowError(c, err, "Failed to update job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": jobStatusMessage(job, "Job updated"),
+		"job":     job,
+	})
+}
+
+func DeleteJob(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var applications int64
+	database.DB.Model(&models.Application{}).Where("job_id = ?", job.ID).Count(&applications)
+	if applications > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has applications, close it instead"})
+		return
+	}
+
+	if err := database.DB.Delete(job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Job deleted",
+	})
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type JobTransitionRequest struct {
+	Status        models.JobStatus     `json:"status" binding:"required"`
+	Reason        string               `json:"reason" binding:"max=1000"`
+	ClosingReason models.ClosingReason `json:"closing_reason"`
+}
+
+type JobApprovalDecisionRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Comment  string `json:"comment" binding:"max=1000"`
+}
+
+var errJobAwaitingApproval = errors.New("job is waiting for approval, withdraw it before editing")
+
+var errJobContentApproved = errors.New("approved content of a live job cannot be changed")
+
+// jobValidationError is a problem with the job itself, reported as 400
+type jobValidationError string
+
+func (e jobValidationError) Error() string {
+	return string(e)
+}
+
+// respondJobWorkflowError maps workflow errors to responses; anything
+// unexpected becomes a 500 with fallback as the message.
+func respondJobWorkflowError(c *gin.Context, err error, fallback string) {
+	var transitionErr *utils.JobTransitionError
+	var validationErr jobValidationError
+	var customFieldErr utils.CustomFieldError
+
+	switch {
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      transitionErr.Error(),
+			"status":     transitionErr.From,
+			"allowed_to": allowedJobTransitions(transitionErr.From),
+		})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+	case errors.As(err, &customFieldErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": customFieldErr.Error()})
+	case errors.Is(err, errJobAwaitingApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is waiting for approval, withdraw it before editing"})
+	case errors.Is(err, errJobContentApproved):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The title, department, location, type, description, requirements and salary were approved " +
+				"and cannot change while the job is live; close and reopen it to change them",
+		})
+	case errors.Is(err, utils.ErrNotApprover):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not an approver for the current step"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func allowedJobTransitions(from models.JobStatus) []models.JobStatus {
+	allowed := []models.JobStatus{}
+	for _, status := range models.JobStatuses {
+		if from.CanTransitionTo(status) {
+			allowed = append(allowed, status)
+		}
+	}
+	return allowed
+}
+
+// jobStatusMessage explains where a publish request ended up.
+func jobStatusMessage(job *models.Job, fallback string) string {
+	switch {
+	case job.Status == models.JobPendingApproval:
+		return "Job submitted for approval"
+	case job.Status == models.JobDraft && job.ApprovedAt != nil && job.IsPublishScheduled():
+		return "Job scheduled for publishing"
+	case job.Status == models.JobPublished:
+		return "Job published"
+	default:
+		return fallback
+	}
+}
+
+// TransitionJob changes a job's status. Publishing a draft may instead
+// submit it for approval or schedule it, see utils.RequestJobPublish.
+// Closing needs a closing_reason.
+func TransitionJob(c *gin.Context) {
+	var req JobTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if req.Status == models.JobClosed && !req.ClosingReason.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "closing_reason must be one of filled, cancelled, on_hold, budget, duplicate, scheduled or other"})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+
+		switch {
+		case job.Status == models.JobDraft && (req.Status == models.JobPublished || req.Status == models.JobPendingApproval):
+			if missing := job.MissingForPublish(); len(missing) > 0 {
+				return jobValidationError("Missing fields to publish: " + strings.Join(missing, ", "))
+			}
+			if req.Status == models.JobPendingApproval {
+				chain, err := utils.ApprovalChainForJob(tx, job)
+				if err != nil {
+					return err
+				}
+				if chain == nil {
+					return jobValidationError("This job does not need approval, publish it instead")
+				}
+			}
+			return utils.RequestJobPublish(tx, job, &actorID)
+		case job.Status == models.JobPendingApproval && req.Status == models.JobDraft:
+			return utils.WithdrawJobApproval(tx, job, &actorID)
+		case job.Status == models.JobPendingApproval && req.Status == models.JobPublished:
+			return jobValidationError("Job is waiting for approval")
+		case req.Status == models.JobClosed:
+			if job.Status.CanTransitionTo(models.JobClosed) {
+				job.ClosingReason = req.ClosingReason
+			}
+		}
+		return utils.TransitionJob(tx, job, req.Status, &actorID, req.Reason)
+	})
+	if err != nil {
+		respondJobWorkflowError(c, err, "Failed to change job status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": jobStatusMessage(job, "Job status changed"),
+		"job":     job,
+	})
+}
+
+// GetJobTransitions returns a job's status history, oldest first.
+func GetJobTransitions(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	var transitions []models.JobTransition
+	if err := database.DB.Where("job_id = ?", job.ID).Order("created_at, id").Find(&transitions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job history"})
+		return
+	}
+
+	actorIDs := []uint{}
+	for _, transition := range transitions {
+		if transition.ActorID != nil {
+			actorIDs = append(actorIDs, *transition.ActorID)
+		}
+	}
+	names := userNames(actorIDs)
+
+	history := make([]gin.H, len(transitions))
+	for i, transition := range transitions {
+		entry := gin.H{
+			"id":          transition.ID,
+			"from_status": transition.FromStatus,
+			"to_status":   transition.ToStatus,
+			"reason":      transition.Reason,
+			"actor_id":    transition.ActorID,
+			"actor_name":  "System",
+			"created_at":  transition.CreatedAt,
+		}
+		if transition.ActorID != nil {
+			entry["actor_name"] = names[*transition.ActorID]
+		}
+		history[i] = entry
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transitions": history,
+	})
+}
+
+// GetJobApprovals returns the steps of a job's approval round, the
+// current one unless ?round= is given.
+func GetJobApprovals(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	round := job.ApprovalRound
+	if requested := c.Query("round"); requested != "" {
+		if _, err := fmt.Sscan(requested, &round); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid round"})
+			return
+		}
+	}
+
+	var approvals []models.JobApproval
+	if err := database.DB.Where("job_id = ? AND round = ?", job.ID, round).Order("step").Find(&approvals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve approvals"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	canDecide := false
+	if job.Status == models.JobPendingApproval && round == job.ApprovalRound {
+		for _, approval := range approvals {
+			if approval.Status == models.JobApprovalPending {
+				canDecide = approval.CanDecide(userID)
+				break
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"round":      round,
+		"approvals":  approvals,
+		"can_decide": canDecide,
+	})
+}
+
+// DecideJobApproval approves or rejects the current approval step of a job.
+func DecideJobApproval(c *gin.Context) {
+	var req JobApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	approverID := c.GetUint("user_id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		if job.Status != models.JobPendingApproval {
+			return jobValidationError("Job is not waiting for approval")
+		}
+		return utils.DecideJobApproval(tx, job, approverID, req.Decision == "approve", req.Comment)
+	})
+	if err != nil {
+		respondJobWorkflowError(c, err, "Failed to record approval")
+		return
+	}
+
+	message := "Approval recorded"
+	switch {
+	case req.Decision == "reject":
+		message = "Job rejected and returned to draft"
+	case job.Status != models.JobPendingApproval:
+		message = jobStatusMessage(job, "Job approved")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"job":     job,
+	})
+}
+
+// GetMyPendingApprovals lists the jobs whose current approval step the
+// current user can decide.
+func GetMyPendingApprovals(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var approvals []models.JobApproval
+	err := database.DB.Table("job_approvals AS ja").
+		Select("ja.*").
+		Joins("JOIN jobs ON jobs.id = ja.job_id AND jobs.approval_round = ja.round AND jobs.status = ? AND jobs.deleted_at IS NULL", models.JobPendingApproval).
+		Where("ja.status = ? AND ja.approver_ids @> ?::jsonb", models.JobApprovalPending, fmt.Sprintf("[%d]", userID)).
+		Where("ja.step = (SELECT MIN(p.step) FROM job_approvals p WHERE p.job_id = ja.job_id AND p.round = ja.round AND p.status = ?)", models.JobApprovalPending).
+		Order("ja.created_at").
+		Find(&approvals).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve approvals"})
+		return
+	}
+
+	jobIDs := make([]uint, len(approvals))
+	for i, approval := range approvals {
+		jobIDs[i] = approval.JobID
+	}
+	var jobs []models.Job
+	if err := database.DB.Where("id IN ?", jobIDs).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve approvals"})
+		return
+	}
+	jobsByID := make(map[uint]models.Job, len(jobs))
+	for _, job := range jobs {
+		jobsByID[job.ID] = job
+	}
+
+	pending := make([]gin.H, 0, len(approvals))
+	for _, approval := range approvals {
+		pending = append(pending, gin.H{
+			"approval": approval,
+			"job":      jobsByID[approval.JobID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pending": pending,
+		"count":   len(pending),
+	})
+}
+
+func GetJobApprovalSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"settings": utils.GetJobApprovalSettings(),
+	})
+}
+
+func UpdateJobApprovalSettings(c *gin.Context) {
+	var settings utils.JobApprovalSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := settings.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	approverIDs := settings.ApproverIDs()
+	if len(approverIDs) > 0 {
+		var found int64
+		if err := database.DB.Model(&models.User{}).
+			Where("id IN ? AND is_active = ?", approverIDs, true).
+			Count(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approvers"})
+			return
+		}
+		if int(found) != len(approverIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Every approver must be an active user"})
+			return
+		}
+	}
+
+	departmentIDs := settings.DepartmentIDs()
+	if len(departmentIDs) > 0 {
+		var found int64
+		if err := database.DB.Model(&models.Department{}).
+			Where("id IN ?", departmentIDs).
+			Count(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check departments"})
+			return
+		}
+		if int(found) != len(departmentIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Every chain must reference an existing department"})
+			return
+		}
+	}
+
+	adminID := c.GetUint("user_id")
+	if err := utils.SaveSetting(models.SettingJobApprovals, settings, &adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditSettingsChanged, "setting", 0, gin.H{
+		"key":   models.SettingJobApprovals,
+		"value": settings,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Job approval settings updated",
+		"settings": settings,
+	})
+}
+
+// userNames maps user IDs to display names, including deleted users.
+func userNames(ids []uint) map[uint]string {
+	names := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return names
+	}
+
+	var users []models.User
+	if err := database.DB.Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return names
+	}
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	return names
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type LocationRequest struct {
+	Name          string               `json:"name" binding:"required,max=100"`
+	WorkplaceType models.WorkplaceType `json:"workplace_type"`
+	AddressLine1  string               `json:"address_line1" binding:"max=200"`
+	AddressLine2  string               `json:"address_line2" binding:"max=200"`
+	City          string               `json:"city" binding:"max=100"`
+	Region        string               `json:"region" binding:"max=100"`
+	PostalCode    string               `json:"postal_code" binding:"max=20"`
+	CountryCode   string               `json:"country_code"`
+	Timezone      string               `json:"timezone"`
+}
+
+func (r *LocationRequest) applyTo(location *models.Location) {
+	location.Name = r.Name
+	location.WorkplaceType = r.WorkplaceType
+	location.AddressLine1 = r.AddressLine1
+	location.AddressLine2 = r.AddressLine2
+	location.City = r.City
+	location.Region = r.Region
+	location.PostalCode = r.PostalCode
+	location.CountryCode = r.CountryCode
+	location.Timezone = r.Timezone
+}
+
+// GetLocations lists every location by name with the number of jobs in
+// each. Filter with ?workplace_type=remote or ?country=DE.
+func GetLocations(c *gin.Context) {
+	query := database.DB.Order("name")
+	if workplaceType := c.Query("workplace_type"); workplaceType != "" {
+		query = query.Where("workplace_type = ?", workplaceType)
+	}
+	if country := c.Query("country"); country != "" {
+		query = query.Where("country_code = ?", strings.ToUpper(country))
+	}
+
+	var locations []models.Location
+	if err := query.Find(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locations"})
+		return
+	}
+
+	jobCounts, err := jobCountsBy(c, "location_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locations"})
+		return
+	}
+
+	entries := make([]gin.H, len(locations))
+	for i := range locations {
+		entries[i] = gin.H{
+			"location": locations[i],
+			"jobs":     jobCounts[locations[i].ID],
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locations": entries,
+	})
+}
+
+func CreateLocation(c *gin.Context) {
+	var req LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var location models.Location
+	req.applyTo(&location)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveLocation(tx, &location); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditLocationChanged, "location", location.ID, gin.H{
+			"operation": "created",
+			"name":      location.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		respondMasterDataError(c, err, "Failed to create location")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Location created",
+		"location": location,
+	})
+}
+
+func UpdateLocation(c *gin.Context) {
+	var req LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, ok := findLocation(c)
+	if !ok {
+		return
+	}
+	req.applyTo(location)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveLocation(tx, location); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditLocationChanged, "location", location.ID, gin.H{
+			"operation": "updated",
+			"name":      location.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		respondMasterDataError(c, err, "Failed to update location")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Location updated",
+		"location": location,
+	})
+}
+
+// DeleteLocation removes a location no job is based in.
+func DeleteLocation(c *gin.Context) {
+	location, ok := findLocation(c)
+	if !ok {
+		return
+	}
+
+	var jobs int64
+	database.DB.Model(&models.Job{}).Where("location_id = ?", location.ID).Count(&jobs)
+	if jobs > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Location is used by %d jobs, move them first", jobs)})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Deleted jobs keep the name only
+		if err := tx.Model(&models.Job{}).Unscoped().
+			Where("location_id = ?", location.ID).
+			UpdateColumn("location_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(location).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditLocationChanged, "location", location.ID, gin.H{
+			"operation": "deleted",
+			"name":      location.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Location deleted",
+	})
+}
+
+// ImportLocations creates and updates locations from a CSV with a name
+// column and optional workplace_type, address_line1, address_line2, city,
+// region, postal_code, country and timezone columns. Rows update the
+// location with the same name.
+func ImportLocations(c *gin.Context) {
+	results, saved := runImport(c, []string{"name"}, importLocationRow)
+	if !saved {
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditLocationChanged, "location", 0, gin.H{
+		"operation": "imported",
+		"rows":      len(results),
+	})
+}
+
+func importLocationRow(tx *gorm.DB, record csvRecord) (ImportRowResult, error) {
+	req := LocationRequest{
+		Name:          record.Get("name"),
+		WorkplaceType: models.WorkplaceType(strings.ToLower(record.Get("workplace_type"))),
+		AddressLine1:  record.Get("address_line1"),
+		AddressLine2:  record.Get("address_line2"),
+		City:          record.Get("city"),
+		Region:        record.Get("region"),
+		PostalCode:    record.Get("postal_code"),
+		CountryCode:   record.Get("country"),
+		Timezone:      record.Get("timezone"),
+	}
+
+	var location models.Location
+	err := tx.Where("LOWER(name) = LOWER(?)", req.Name).First(&location).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return ImportRowResult{}, err
+	}
+
+	before := location
+	req.applyTo(&location)
+
+	status := ImportRowCreated
+	if before.ID != 0 {
+		status = ImportRowUpdated
+	}
+
+	err = saveLocation(tx, &location)
+	if err == nil && status == ImportRowUpdated && sameLocation(&before, &location) {
+		status = ImportRowUnchanged
+	}
+	return importRowResult(req.Name, location.ID, status, err)
+}
+
+func sameLocation(a, b *models.Location) bool {
+	return a.Name == b.Name && a.WorkplaceType == b.WorkplaceType &&
+		a.AddressLine1 == b.AddressLine1 && a.AddressLine2 == b.AddressLine2 &&
+		a.City == b.City && a.Region == b.Region && a.PostalCode == b.PostalCode &&
+		a.CountryCode == b.CountryCode && a.Timezone == b.Timezone
+}
+
+// saveLocation validates location and saves it, then brings the jobs that
+// reference it up to date. Offices need a country; remote locations may
+// span several and leave it empty.
+func saveLocation(tx *gorm.DB, location *models.Location) error {
+	location.Name = strings.TrimSpace(location.Name)
+	location.AddressLine1 = strings.TrimSpace(location.AddressLine1)
+	location.AddressLine2 = strings.TrimSpace(location.AddressLine2)
+	location.City = strings.TrimSpace(location.City)
+	location.Region = strings.TrimSpace(location.Region)
+	location.PostalCode = strings.TrimSpace(location.PostalCode)
+
+	switch {
+	case location.Name == "":
+		return masterDataError("name is required")
+	case len(location.Name) > 100:
+		return masterDataError("name must be at most 100 characters")
+	case len(location.AddressLine1) > 200 || len(location.AddressLine2) > 200:
+		return masterDataError("address lines must be at most 200 characters")
+	case len(location.City) > 100 || len(location.Region) > 100:
+		return masterDataError("city and region must be at most 100 characters")
+	case len(location.PostalCode) > 20:
+		return masterDataError("postal_code must be at most 20 characters")
+	}
+
+	if location.WorkplaceType == "" {
+		location.WorkplaceType = models.WorkplaceOnsite
+	}
+	if !location.WorkplaceType.IsValid() {
+		return masterDataError("workplace_type must be onsite, hybrid or remote")
+	}
+
+	if strings.TrimSpace(location.CountryCode) == "" {
+		location.CountryCode = ""
+		if location.WorkplaceType != models.WorkplaceRemote {
+			return masterDataError("country is required for onsite and hybrid locations")
+		}
+	} else {
+		country, err := utils.NormalizeCountryCode(location.CountryCode)
+		if err != nil {
+			return masterDataError(err.Error())
+		}
+		location.CountryCode = country
+	}
+
+	if strings.TrimSpace(location.Timezone) == "" {
+		location.Timezone = ""
+	} else {
+		timezone, err := utils.NormalizeTimezone(location.Timezone)
+		if err != nil {
+			return masterDataError(err.Error())
+		}
+		location.Timezone = timezone
+	}
+
+	var existing models.Location
+	err := tx.Where("LOWER(name) = LOWER(?) AND id <> ?", location.Name, location.ID).First(&existing).Error
+	if err == nil {
+		return masterDataError(fmt.Sprintf("a location named %q already exists", location.Name))
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	created := location.ID == 0
+	previousName := ""
+	if !created {
+		var previous models.Location
+		if err := tx.Select("name").First(&previous, location.ID).Error; err != nil {
+			return err
+		}
+		previousName = previous.Name
+	}
+
+	if err := tx.Save(location).Error; err != nil {
+		return err
+	}
+	return syncJobReferences(tx, "location", location.ID, location.Name, previousName, created)
+}
+
+func findLocation(c *gin.Context) (*models.Location, bool) {
+	var location models.Location
+	if err := database.DB.First(&location, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve location"})
+		}
+		return nil, false
+	}
+	return &location, true
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+)
+
+const magicLinkRequestLimit = 5
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkConsumeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type MagicLinkSettingsRequest struct {
+	Roles []models.UserRole `json:"roles"`
+}
+
+// magicLinkRoles returns the roles allowed to sign in by magic link.
+func magicLinkRoles() ([]models.UserRole, error) {
+	roles := []models.UserRole{}
+	err := utils.GetSetting(models.SettingMagicLinkRoles, &roles)
+	return roles, err
+}
+
+func magicLinkEnabledFor(role models.UserRole) bool {
+	roles, err := magicLinkRoles()
+	if err != nil {
+		return false
+	}
+	for _, allowed := range roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+func RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same answer whether or not the account exists, may use magic links or
+	// has asked for too many of them
+	response := gin.H{"message": "If magic link sign-in is enabled for this account, a link has been sent"}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if !user.IsActive || !magicLinkEnabledFor(user.Role) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	fifteenMinutesAgo := time.Now().Add(-15 * time.Minute)
+	var recent int64
+	database.DB.Model(&models.MagicLinkToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, fifteenMinutesAgo).
+		Count(&recent)
+	if recent >= magicLinkRequestLimit {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate sign-in link"})
+		return
+	}
+
+	ttl := utils.MagicLinkTTL()
+	link := models.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		IPAddress: c.ClientIP(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	// Only the newest link works
+	database.DB.Model(&models.MagicLinkToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("expires_at", time.Now())
+
+	if err := database.DB.Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate sign-in link"})
+		return
+	}
+
+	utils.SendEmailAsync(utils.Email{
+		To:      user.Email,
+		Subject: "Your Kandy sign-in link",
+		Body: fmt.Sprintf(`Hi %s,
+
+Use the link below to sign in to Kandy. It works once and expires in %d minutes.
+
+%s
+
+If you did not request this, you can ignore this email.
+`, user.Name, int(ttl.Minutes()), utils.AppURL("/auth/magic-link?token="+token)),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ConsumeMagicLink(c *gin.Context) {
+	var req MagicLinkConsumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var link models.MagicLinkToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(req.Token)).First(&link).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	if !link.IsUsable() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	// Claim the link atomically so two concurrent requests cannot both use it
+	now := time.Now()
+	claim := database.DB.Model(&models.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", link.ID).
+		Update("used_at", now)
+	if claim.Error != nil || claim.RowsAffected != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, link.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	if !magicLinkEnabledFor(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Magic link sign-in is not enabled for this account"})
+		return
+	}
+
+	user.LastLoginAt = &now
+	database.DB.Save(&user)
+
+	session, err := startSession(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditMagicLinkLogin, "user", user.ID, gin.H{
+		"session_id": session.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
+		"user": gin.H{
+			"id":            user.ID,
+			"email":         user.Email,
+			"name":          user.Name,
+			"role":          user.Role,
+			"last_login_at": user.LastLoginAt,
+		},
+	})
+}
+
+func GetMagicLinkSettings(c *gin.Context) {
+	roles, err := magicLinkRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+func UpdateMagicLinkSettings(c *gin.Context) {
+	var req MagicLinkSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := []models.UserRole{}
+	for _, role := range req.Roles {
+		if !role.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + string(role)})
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	adminID := c.GetUint("user_id")
+	if err := utils.SaveSetting(models.SettingMagicLinkRoles, roles, &adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditSettingsChanged, "setting", 0, gin.H{
+		"key":   models.SettingMagicLinkRoles,
+		"value": roles,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Magic link settings updated",
+		"roles":   roles,
+	})
+}
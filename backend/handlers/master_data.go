@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+const maxImportRows = 1000
+
+// Per-row statuses in a department or location import report
+const (
+	ImportRowCreated   = "created"
+	ImportRowUpdated   = "updated"
+	ImportRowUnchanged = "unchanged"
+	ImportRowInvalid   = "invalid"
+)
+
+type ImportRowResult struct {
+	Row    int      `json:"row"`
+	Name   string   `json:"name"`
+	Status string   `json:"status"`
+	ID     uint     `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// masterDataError is a problem with a department or location the admin
+// sent, reported as 400 or as an invalid import row.
+type masterDataError string
+
+func (e masterDataError) Error() string {
+	return string(e)
+}
+
+// errImportRolledBack ends an import transaction that must not be kept:
+// a dry run, or an import with invalid rows.
+var errImportRolledBack = errors.New("import rolled back")
+
+// runImport reads a CSV upload and hands its rows to importRow one by one,
+// in one transaction so later rows can refer to earlier ones. Imports are
+// all or nothing: if any row is invalid nothing is saved. With ?dry_run=true
+// (or a dry_run form field) the rows are checked and the transaction is
+// rolled back. It returns false if nothing was saved.
+func runImport(c *gin.Context, required []string, importRow func(tx *gorm.DB, record csvRecord) (ImportRowResult, error)) ([]ImportRowResult, bool) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	if value := c.PostForm("dry_run"); value != "" {
+		dryRun, _ = strconv.ParseBool(value)
+	}
+
+	body, closeBody, err := csvBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	defer closeBody()
+
+	records, err := readCSV(body, required, maxImportRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file has no rows"})
+		return nil, false
+	}
+
+	results := make([]ImportRowResult, 0, len(records))
+	invalid := 0
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, record := range records {
+			result, err := importRow(tx, record)
+			if err != nil {
+				return err
+			}
+			result.Row = record.Line
+			if result.Status == ImportRowInvalid {
+				invalid++
+			}
+			results = append(results, result)
+		}
+		if dryRun || invalid > 0 {
+			return errImportRolledBack
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import, nothing was saved"})
+		return nil, false
+	}
+
+	if err != nil {
+		// Rows created in a rolled back transaction do not exist
+		for i := range results {
+			if results[i].Status == ImportRowCreated {
+				results[i].ID = 0
+			}
+		}
+	}
+
+	summary := gin.H{"total": len(results)}
+	for _, status := range []string{ImportRowCreated, ImportRowUpdated, ImportRowUnchanged, ImportRowInvalid} {
+		summary[status] = 0
+	}
+	for _, result := range results {
+		summary[result.Status] = summary[result.Status].(int) + 1
+	}
+
+	status := http.StatusOK
+	if invalid > 0 && !dryRun {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{
+		"dry_run": dryRun,
+		"saved":   err == nil,
+		"summary": summary,
+		"results": results,
+	})
+	return results, err == nil
+}
+
+// importRowResult turns the outcome of saving one import row into its
+// report entry. Validation problems mark the row invalid; anything else is
+// returned and aborts the import.
+func importRowResult(name string, id uint, status string, err error) (ImportRowResult, error) {
+	result := ImportRowResult{Name: name, ID: id, Status: status}
+	var invalid masterDataError
+	if errors.As(err, &invalid) {
+		result.ID = 0
+		result.Status = ImportRowInvalid
+		result.Errors = []string{invalid.Error()}
+		return result, nil
+	}
+	return result, err
+}
+
+// syncJobReferences keeps jobs in line with a saved department or location.
+// field is "department" or "location". A new entry is linked to the jobs
+// that only carry its name, jobs created before it was managed; a renamed
+// one passes its new name on to the jobs that reference it.
+func syncJobReferences(tx *gorm.DB, field string, id uint, name, previousName string, created bool) error {
+	if created {
+		return tx.Model(&models.Job{}).
+			Where(field+"_id IS NULL AND LOWER("+field+") = LOWER(?)", name).
+			UpdateColumn(field+"_id", id).Error
+	}
+	if name == previousName {
+		return nil
+	}
+	if err := tx.Model(&models.Job{}).Unscoped().
+		Where(field+"_id = ?", id).
+		UpdateColumn(field, name).Error; err != nil {
+		return err
+	}
+	return utils.QueueSearchIndex(tx, models.SearchJob, tx.Model(&models.Job{}).Select("id").Where(field+"_id = ?", id))
+}
+
+// respondMasterDataError maps errors from saving a department or location.
+func respondMasterDataError(c *gin.Context, err error, fallback string) {
+	var invalid masterDataError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type pagination struct {
+	Page    int
+	PerPage int
+}
+
+// parsePagination reads ?page= (1-based) and ?per_page=, falling back to
+// sane values instead of rejecting the request.
+func parsePagination(c *gin.Context) pagination {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+	return pagination{Page: page, PerPage: perPage}
+}
+
+func (p pagination) apply(query *gorm.DB) *gorm.DB {
+	return query.Offset((p.Page - 1) * p.PerPage).Limit(p.PerPage)
+}
+
+func (p pagination) JSON(total int64) gin.H {
+	totalPages := (total + int64(p.PerPage) - 1) / int64(p.PerPage)
+	return gin.H{
+		"page":        p.Page,
+		"per_page":    p.PerPage,
+		"total":       total,
+		"total_pages": totalPages,
+	}
+}
+
+// parseSort maps ?sort= onto an ORDER BY clause. Prefix a key with "-" to
+// sort descending. Only keys in columns are accepted; anything else uses
+// fallback. The id is appended so pages are stable when values tie.
+func parseSort(c *gin.Context, columns map[string]string, fallback string) string {
+	key := c.Query("sort")
+	direction := "ASC"
+	if strings.HasPrefix(key, "-") {
+		key = key[1:]
+		direction = "DESC"
+	}
+
+	column, ok := columns[key]
+	if !ok {
+		return fallback + ", id DESC"
+	}
+	return column + " " + direction + ", id " + direction
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+type PipelineTemplateRequest struct {
+	Name        string                 `json:"name" binding:"required,max=100"`
+	Description string                 `json:"description"`
+	Stages      []models.PipelineStage `json:"stages" binding:"required"`
+	IsDefault   bool                   `json:"is_default"`
+}
+
+// JobStageRequest adds or changes a stage of a job's pipeline. Position is
+// only used when adding; 0 adds the stage just before the hired stage.
+type JobStageRequest struct {
+	Name     string           `json:"name" binding:"required,max=100"`
+	Type     models.StageType `json:"type" binding:"required"`
+	Position int              `json:"position" binding:"min=0"`
+}
+
+type ReorderJobStagesRequest struct {
+	StageIDs []uint `json:"stage_ids" binding:"required"`
+}
+
+// respondPipelineError maps errors from changing templates and stages.
+func respondPipelineError(c *gin.Context, err error, fallback string) {
+	var invalid utils.PipelineError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stage not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// GetPipelineTemplates lists the pipeline templates by name, and the stages
+// a job gets when it is created without picking one.
+func GetPipelineTemplates(c *gin.Context) {
+	templates := []models.PipelineTemplate{}
+	if err := database.DB.Order("name").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pipeline templates"})
+		return
+	}
+
+	defaultStages, err := utils.PipelineStagesFor(database.DB, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pipeline templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates":      templates,
+		"default_stages": defaultStages,
+	})
+}
+
+func CreatePipelineTemplate(c *gin.Context) {
+	var req PipelineTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	template := models.PipelineTemplate{CreatedBy: &actorID}
+	req.applyTo(&template)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := savePipelineTemplate(tx, &template); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditTemplateChanged, "pipeline_template", template.ID, gin.H{
+			"operation":  "created",
+			"name":       template.Name,
+			"is_default": template.IsDefault,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to create pipeline template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Pipeline template created",
+		"template": template,
+	})
+}
+
+// UpdatePipelineTemplate replaces a template. Jobs created from it keep
+// their pipeline.
+func UpdatePipelineTemplate(c *gin.Context) {
+	var req PipelineTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, ok := findPipelineTemplate(c)
+	if !ok {
+		return
+	}
+	req.applyTo(template)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := savePipelineTemplate(tx, template); err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditTemplateChanged, "pipeline_template", template.ID, gin.H{
+			"operation":  "updated",
+			"name":       template.Name,
+			"is_default": template.IsDefault,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to update pipeline template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Pipeline template updated",
+		"template": template,
+	})
+}
+
+func DeletePipelineTemplate(c *gin.Context) {
+	template, ok := findPipelineTemplate(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(template).Error; err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditTemplateChanged, "pipeline_template", template.ID, gin.H{
+			"operation": "deleted",
+			"name":      template.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pipeline template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pipeline template deleted",
+	})
+}
+
+func (r *PipelineTemplateRequest) applyTo(template *models.PipelineTemplate) {
+	template.Name = strings.TrimSpace(r.Name)
+	template.Description = strings.TrimSpace(r.Description)
+	template.Stages = r.Stages
+	template.IsDefault = r.IsDefault
+}
+
+// savePipelineTemplate validates template and saves it. Making it the
+// default takes that over from the previous default.
+func savePipelineTemplate(tx *gorm.DB, template *models.PipelineTemplate) error {
+	if template.Name == "" {
+		return utils.PipelineError("name is required")
+	}
+	stages, err := utils.NormalizePipeline(template.Stages)
+	if err != nil {
+		return err
+	}
+	template.Stages = stages
+
+	var existing int64
+	if err := tx.Model(&models.PipelineTemplate{}).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", template.Name, template.ID).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return utils.PipelineError(fmt.Sprintf("a pipeline template named %q already exists", template.Name))
+	}
+
+	if template.IsDefault {
+		if err := tx.Model(&models.PipelineTemplate{}).
+			Where("is_default = ? AND id <> ?", true, template.ID).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Save(template).Error
+}
+
+func findPipelineTemplate(c *gin.Context) (*models.PipelineTemplate, bool) {
+	var template models.PipelineTemplate
+	if err := database.DB.First(&template, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline template not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pipeline template"})
+		}
+		return nil, false
+	}
+	return &template, true
+}
+
+// GetJobStages lists the stages of a job's pipeline in order, with the
+// number of applications in each.
+func GetJobStages(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	stages, err := utils.JobStages(database.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stages"})
+		return
+	}
+
+	var rows []struct {
+		StageID uint
+		Count   int64
+	}
+	if err := database.DB.Model(&models.Application{}).
+		Select("stage_id, COUNT(*) AS count").
+		Where("job_id = ?", job.ID).
+		Group("stage_id").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stages"})
+		return
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.StageID] = row.Count
+	}
+
+	entries := make([]gin.H, len(stages))
+	for i, stage := range stages {
+		entries[i] = gin.H{
+			"stage":        stage,
+			"applications": counts[stage.ID],
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stages":     entries,
+		"can_manage": canManageJob(c, job),
+	})
+}
+
+func CreateJobStage(c *gin.Context) {
+	var req JobStageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var stage *models.JobStage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		var err error
+		stage, err = utils.AddJobStage(tx, job.ID, models.PipelineStage{Name: req.Name, Type: req.Type}, req.Position)
+		if err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditJobPipelineChanged, "job", job.ID, gin.H{
+			"operation": "stage_added",
+			"stage_id":  stage.ID,
+			"name":      stage.Name,
+			"position":  stage.Position,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to add stage")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Stage added",
+		"stage":   stage,
+	})
+}
+
+// UpdateJobStage renames or retypes a stage. Its position is changed
+// through ReorderJobStages.
+func UpdateJobStage(c *gin.Context) {
+	var req JobStageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, stageID, ok := findJobForStageChange(c)
+	if !ok {
+		return
+	}
+
+	var stage *models.JobStage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		var err error
+		stage, err = utils.UpdateJobStage(tx, job.ID, stageID, models.PipelineStage{Name: req.Name, Type: req.Type})
+		if err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditJobPipelineChanged, "job", job.ID, gin.H{
+			"operation": "stage_updated",
+			"stage_id":  stage.ID,
+			"name":      stage.Name,
+			"type":      stage.Type,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to update stage")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stage updated",
+		"stage":   stage,
+	})
+}
+
+// ReorderJobStages puts a job's stages in the order of stage_ids in one
+// transaction; either every position changes or none does.
+func ReorderJobStages(c *gin.Context) {
+	var req ReorderJobStagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var stages []models.JobStage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		var err error
+		stages, err = utils.ReorderJobStages(tx, job.ID, req.StageIDs)
+		if err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditJobPipelineChanged, "job", job.ID, gin.H{
+			"operation": "stages_reordered",
+			"stage_ids": req.StageIDs,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to reorder stages")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stages reordered",
+		"stages":  stages,
+	})
+}
+
+// DeleteJobStage removes a stage and moves its applications to the stage
+// in ?move_to=, by default the previous one.
+func DeleteJobStage(c *gin.Context) {
+	var moveTo uint
+	if value := c.Query("move_to"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "move_to must be a stage id"})
+			return
+		}
+		moveTo = uint(id)
+	}
+
+	job, stageID, ok := findJobForStageChange(c)
+	if !ok {
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	var moved int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.LockJob(tx, job); err != nil {
+			return err
+		}
+		var err error
+		moved, err = utils.DeleteJobStage(tx, job.ID, stageID, moveTo, &actorID)
+		if err != nil {
+			return err
+		}
+		recordAudit(c, tx, models.AuditJobPipelineChanged, "job", job.ID, gin.H{
+			"operation":          "stage_deleted",
+			"stage_id":           stageID,
+			"move_to":            moveTo,
+			"applications_moved": moved,
+		})
+		return nil
+	})
+	if err != nil {
+		respondPipelineError(c, err, "Failed to delete stage")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Stage deleted",
+		"applications_moved": moved,
+	})
+}
+
+// findJobForStageChange loads the job in the URL and parses :stageId,
+// checking that the current user may change the job's pipeline.
+func findJobForStageChange(c *gin.Context) (*models.Job, uint, bool) {
+	stageID, err := strconv.ParseUint(c.Param("stageId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stage not found"})
+		return nil, 0, false
+	}
+
+	job, ok := findJob(c)
+	if !ok {
+		return nil, 0, false
+	}
+	if !canManageJob(c, job) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, 0, false
+	}
+	return job, uint(stageID), true
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+)
+
+// UpdateProfileRequest only changes the fields that are present.
+type UpdateProfileRequest struct {
+	Name                    *string                         `json:"name" binding:"omitempty,min=1,max=100"`
+	JobTitle                *string                         `json:"job_title" binding:"omitempty,max=100"`
+	Phone                   *string                         `json:"phone"`
+	Timezone                *string                         `json:"timezone"`
+	Locale                  *string                         `json:"locale"`
+	NotificationPreferences *models.NotificationPreferences `json:"notification_preferences"`
+}
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type EmailChangeConfirm struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// profileJSON is the current user's own view of their account.
+func profileJSON(user *models.User) gin.H {
+	return gin.H{
+		"id":                       user.ID,
+		"email":                    user.Email,
+		"pending_email":            user.PendingEmail,
+		"name":                     user.Name,
+		"role":                     user.Role,
+		"is_active":                user.IsActive,
+		"job_title":                user.JobTitle,
+		"phone":                    user.Phone,
+		"timezone":                 user.Timezone,
+		"locale":                   user.Locale,
+		"notification_preferences": user.Notifications(),
+		"avatar_url":               avatarURL(user, ""),
+		"avatar_thumbnail_url":     avatarURL(user, "thumbnail"),
+		"deletion_scheduled_at":    user.DeletionScheduledAt,
+	}
+}
+
+// avatarURL is versioned by upload time so browsers can cache avatars.
+func avatarURL(user *models.User, size string) *string {
+	if !user.HasAvatar() || user.AvatarUpdatedAt == nil {
+		return nil
+	}
+	url := fmt.Sprintf("/api/users/%d/avatar?v=%d", user.ID, user.AvatarUpdatedAt.Unix())
+	if size != "" {
+		url += "&size=" + size
+	}
+	return &url
+}
+
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	changed := []string{}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+			return
+		}
+		user.Name = name
+		changed = append(changed, "name")
+	}
+	if req.JobTitle != nil {
+		user.JobTitle = strings.TrimSpace(*req.JobTitle)
+		changed = append(changed, "job_title")
+	}
+	if req.Phone != nil {
+		phone, err := utils.NormalizePhone(*req.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Phone = phone
+		changed = append(changed, "phone")
+	}
+	if req.Timezone != nil {
+		timezone, err := utils.NormalizeTimezone(*req.Timezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Timezone = timezone
+		changed = append(changed, "timezone")
+	}
+	if req.Locale != nil {
+		locale, err := utils.NormalizeLocale(*req.Locale)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Locale = locale
+		changed = append(changed, "locale")
+	}
+	if req.NotificationPreferences != nil {
+		user.NotificationPreferences = req.NotificationPreferences
+		changed = append(changed, "notification_preferences")
+	}
+
+	if len(changed) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No profile fields to update"})
+		return
+	}
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditProfileUpdated, "user", user.ID, gin.H{
+		"fields": changed,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated",
+		"user":    profileJSON(&user),
+	})
+}
+
+// RequestEmailChange starts moving the account to a new address. The
+// change only takes effect once the link sent to the new address is followed.
+func RequestEmailChange(c *gin.Context) {
+	var req EmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your email address"})
+		return
+	}
+
+	var existing int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", newEmail).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
+	}
+
+	tokenHash := utils.HashToken(token)
+	expiry := time.Now().Add(utils.EmailChangeTTL())
+	user.PendingEmail = &newEmail
+	user.EmailChangeTokenHash = &tokenHash
+	user.EmailChangeExpiry = &expiry
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := utils.QueueEmail(tx, utils.Email{
+			To:      newEmail,
+			Subject: "Confirm your new Kandy email address",
+			Body: fmt.Sprintf(`Hi %s,
+
+Confirm that you want to use this address for your Kandy account:
+%s
+
+The link expires on %s. If you did not ask for this, ignore this email.
+`, user.Name, utils.AppURL("/auth/confirm-email-change?token="+token), expiry.Format("January 2, 2006 at 15:04 MST")),
+		}); err != nil {
+			return err
+		}
+		return utils.QueueEmail(tx, utils.Email{
+			To:      user.Email,
+			Subject: "Your Kandy email address is about to change",
+			Body: fmt.Sprintf(`Hi %s,
+
+Someone signed in to your Kandy account asked to change its email address
+to %s. The change only happens once the new address is confirmed.
+
+If this was not you, change your password and sign out all sessions:
+%s
+`, user.Name, newEmail, utils.AppURL("/settings")),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start email change"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditEmailChangeStarted, "user", user.ID, gin.H{
+		"new_email_hash": utils.AuditEmailHash(newEmail),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Check your new email address for a confirmation link",
+		"pending_email": newEmail,
+		"expires_at":    expiry,
+	})
+}
+
+func CancelEmailChange(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.PendingEmail == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No email change is pending"})
+		return
+	}
+
+	user.PendingEmail = nil
+	user.EmailChangeTokenHash = nil
+	user.EmailChangeExpiry = nil
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email change cancelled",
+	})
+}
+
+// ConfirmEmailChange is public: the link may be opened on a device where
+// the user is not signed in. All sessions end because their tokens carry
+// the old address.
+func ConfirmEmailChange(c *gin.Context) {
+	var req EmailChangeConfirm
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email_change_token_hash = ?", utils.HashToken(req.Token)).First(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+		return
+	}
+
+	if user.PendingEmail == nil || user.EmailChangeExpiry == nil || time.Now().After(*user.EmailChangeExpiry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+		return
+	}
+
+	previousEmail := user.Email
+	newEmail := *user.PendingEmail
+
+	var existing int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", newEmail, user.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		return
+	}
+
+	user.Email = newEmail
+	user.PreviousEmails = append(user.PreviousEmails, previousEmail)
+	user.EmailVerified = true
+	user.PendingEmail = nil
+	user.EmailChangeTokenHash = nil
+	user.EmailChangeExpiry = nil
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return utils.QueueEmail(tx, utils.Email{
+			To:      previousEmail,
+			Subject: "Your Kandy email address was changed",
+			Body: fmt.Sprintf(`Hi %s,
+
+The email address of your Kandy account was changed to %s and you were
+signed out everywhere. If this was not you, contact your Kandy administrator.
+`, user.Name, newEmail),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	recordAudit(c, database.DB, models.AuditEmailChanged, "user", user.ID, gin.H{
+		"from_hash": utils.AuditEmailHash(previousEmail),
+		"to_hash":   utils.AuditEmailHash(newEmail),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email address changed, please sign in again",
+		"email":   newEmail,
+	})
+}
+
+// UploadAvatar takes a multipart "avatar" file and stores a square avatar
+// and a thumbnail rendered from it.
+func UploadAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxAvatarUploadSize+1<<20)
+
+	file, _, err := c.Request.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An avatar file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxAvatarUploadSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read avatar"})
+		return
+	}
+
+	processed, err := utils.ProcessAvatar(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	now := time.Now()
+	avatarKey := fmt.Sprintf("avatars/%d/%d.jpg", user.ID, now.UnixNano())
+	thumbnailKey := fmt.Sprintf("avatars/%d/%d-thumb.jpg", user.ID, now.UnixNano())
+
+	files := storage.Get()
+	if err := files.Put(avatarKey, bytes.NewReader(processed.Image), "image/jpeg"); err != nil {
+		log.Printf("Failed to store avatar for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store avatar"})
+		return
+	}
+	if err := files.Put(thumbnailKey, bytes.NewReader(processed.Thumbnail), "image/jpeg"); err != nil {
+		log.Printf("Failed to store avatar thumbnail for user %d: %v", user.ID, err)
+		files.Delete(avatarKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store avatar"})
+		return
+	}
+
+	previous := []*string{user.AvatarKey, user.AvatarThumbnailKey}
+	user.AvatarKey = &avatarKey
+	user.AvatarThumbnailKey = &thumbnailKey
+	user.AvatarUpdatedAt = &now
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		files.Delete(avatarKey)
+		files.Delete(thumbnailKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update avatar"})
+		return
+	}
+	deleteStoredFiles(previous...)
+
+	recordAudit(c, database.DB, models.AuditAvatarUpdated, "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "Avatar updated",
+		"avatar_url":           avatarURL(&user, ""),
+		"avatar_thumbnail_url": avatarURL(&user, "thumbnail"),
+	})
+}
+
+func DeleteAvatar(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.HasAvatar() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No avatar to remove"})
+		return
+	}
+
+	previous := []*string{user.AvatarKey, user.AvatarThumbnailKey}
+	user.AvatarKey = nil
+	user.AvatarThumbnailKey = nil
+	user.AvatarUpdatedAt = nil
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove avatar"})
+		return
+	}
+	deleteStoredFiles(previous...)
+
+	recordAudit(c, database.DB, models.AuditAvatarUpdated, "user", user.ID, gin.H{
+		"removed": true,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Avatar removed",
+	})
+}
+
+// GetUserAvatar serves any user's avatar to signed-in users, so hiring
+// teams can recognise each other. ?size=thumbnail returns the small version.
+func GetUserAvatar(c *gin.Context) {
+	var user models.User
+	if err := database.DB.Select("id", "avatar_key", "avatar_thumbnail_key").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	key := user.AvatarKey
+	if c.Query("size") == "thumbnail" {
+		key = user.AvatarThumbnailKey
+	}
+	if key == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no avatar"})
+		return
+	}
+
+	reader, err := storage.Get().Open(*key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no avatar"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load avatar"})
+		return
+	}
+	defer reader.Close()
+
+	// The URL changes with every upload, so the content behind it never does
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.DataFromReader(http.StatusOK, -1, "image/jpeg", reader, nil)
+}
+
+func deleteStoredFiles(keys ...*string) {
+	for _, key := range keys {
+		if key == nil {
+			continue
+		}
+		if err := storage.Get().Delete(*key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", *key, err)
+		}
+	}
+}
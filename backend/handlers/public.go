@@ -0,0 +1,579 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxPublicCoverLetter = 10000
+	// Accepted applications one email address may send per day
+	maxApplicationsPerEmail = 5
+)
+
+// Spam and duplicates get the same answer as a real application, so bots
+// and people probing for candidates learn nothing from it
+const applicationReceived = "Thank you, your application has been received"
+
+var errAlreadyApplied = errors.New("candidate has already applied to this job")
+
+var publicJobSortColumns = map[string]string{
+	"title":        "title",
+	"published_at": "published_at",
+}
+
+// publicJobs limits a query to the jobs shown on the careers site.
+func publicJobs(db *gorm.DB) *gorm.DB {
+	return db.Where("jobs.status = ?", models.JobPublished)
+}
+
+// publicJobJSON is the part of a job candidates may see. Locations are the
+// managed locations of the jobs, by id.
+func publicJobJSON(job *models.Job, locations map[uint]models.Location, full bool) gin.H {
+	entry := gin.H{
+		"id":            job.ID,
+		"title":         job.Title,
+		"department_id": job.DepartmentID,
+		"department":    job.Department,
+		"location_id":   job.LocationID,
+		"location":      job.Location,
+		"type":          job.EmploymentType,
+		"published_at":  job.PublishedAt,
+		"close_at":      job.CloseAt,
+	}
+	if job.LocationID != nil {
+		if location, ok := locations[*job.LocationID]; ok {
+			entry["workplace_type"] = location.WorkplaceType
+			entry["city"] = location.City
+			entry["country_code"] = location.CountryCode
+		}
+	}
+	if job.SalaryMin != nil || job.SalaryMax != nil {
+		entry["salary"] = gin.H{
+			"min":      job.SalaryMin,
+			"max":      job.SalaryMax,
+			"currency": job.SalaryCurrency,
+			"period":   job.SalaryPeriod,
+		}
+	}
+	if full {
+		entry["description"] = job.Description
+		entry["requirements"] = job.Requirements
+	}
+	return entry
+}
+
+func locationsOf(jobs []models.Job) map[uint]models.Location {
+	ids := []uint{}
+	for _, job := range jobs {
+		if job.LocationID != nil {
+			ids = append(ids, *job.LocationID)
+		}
+	}
+	locations := make(map[uint]models.Location, len(ids))
+	if len(ids) == 0 {
+		return locations
+	}
+
+	var rows []models.Location
+	database.DB.Where("id IN ?", ids).Find(&rows)
+	for _, location := range rows {
+		locations[location.ID] = location
+	}
+	return locations
+}
+
+// GetPublicJobs lists the published jobs for the careers site, newest
+// first. Filters: department_id (including sub-departments), location_id,
+// workplace_type, country, type and q (title). Paginated like GetJobs.
+func GetPublicJobs(c *gin.Context) {
+	query := database.DB.Model(&models.Job{}).Scopes(publicJobs)
+
+	if departmentID, err := strconv.ParseUint(c.Query("department_id"), 10, 64); err == nil {
+		tree, err := utils.LoadDepartmentTree(database.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+			return
+		}
+		query = query.Where("department_id IN ?", tree.Descendants(uint(departmentID)))
+	}
+	if locationID := c.Query("location_id"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if workplaceType := c.Query("workplace_type"); workplaceType != "" {
+		query = query.Where("location_id IN (SELECT id FROM locations WHERE workplace_type = ?)", workplaceType)
+	}
+	if country := c.Query("country"); country != "" {
+		query = query.Where("location_id IN (SELECT id FROM locations WHERE country_code = ?)", strings.ToUpper(country))
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("employment_type = ?", jobType)
+	}
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = query.Where("title ILIKE ?", "%"+escapeLike(search)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	page := parsePagination(c)
+	jobs := []models.Job{}
+	if err := page.apply(query).
+		Order(parseSort(c, publicJobSortColumns, "published_at DESC")).
+		Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	locations := locationsOf(jobs)
+	entries := make([]gin.H, len(jobs))
+	for i := range jobs {
+		entries[i] = publicJobJSON(&jobs[i], locations, false)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":       entries,
+		"pagination": page.JSON(total),
+	})
+}
+
+// GetPublicJob returns a published job with the questions of its
+// application form and the form_token the form has to send back.
+func GetPublicJob(c *gin.Context) {
+	var job models.Job
+	if err := database.DB.Scopes(publicJobs).First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	questions, err := utils.JobQuestions(database.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+		return
+	}
+	entries := make([]gin.H, len(questions))
+	for i := range questions {
+		entries[i] = publicQuestionJSON(&questions[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":        publicJobJSON(&job, locationsOf([]models.Job{job}), true),
+		"questions":  entries,
+		"form_token": utils.IssueApplyToken(job.ID, time.Now()),
+	})
+}
+
+// setSubmission records what became of a public application for
+// middleware.RateLimitPublicApply to log.
+func setSubmission(c *gin.Context, outcome models.SubmissionOutcome, reason string) {
+	c.Set("submission_outcome", string(outcome))
+	c.Set("submission_reason", reason)
+}
+
+// ApplyToJob takes an application from the careers site as multipart form
+// data: name, email, phone, location, current_title, current_company,
+// linkedin_url, portfolio_url, cover_letter, source, a resume file, the
+// answers to the job's questions as answers[<question id>] and the
+// form_token from GetPublicJob. The website field is a honeypot that has
+// to stay empty.
+//
+// The candidate is matched by email, or created; the application starts
+// in the job's applied stage, and is rejected right away when an answer
+// fails a knockout question.
+func ApplyToJob(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxApplicationSize)
+
+	var job models.Job
+	if err := database.DB.Scopes(publicJobs).First(&job, c.Param("id")).Error; err != nil {
+		setSubmission(c, models.SubmissionInvalid, "job not found")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.Set("submission_job_id", job.ID)
+
+	if err := c.Request.ParseMultipartForm(1 << 20); err != nil {
+		setSubmission(c, models.SubmissionInvalid, "unreadable form")
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send the application as multipart form data, at most %d MB", utils.MaxApplicationSize>>20)})
+		return
+	}
+	c.Set("submission_email", strings.ToLower(strings.TrimSpace(c.PostForm("email"))))
+
+	if c.PostForm("website") != "" {
+		setSubmission(c, models.SubmissionSpam, "honeypot")
+		c.JSON(http.StatusCreated, gin.H{"message": applicationReceived})
+		return
+	}
+	if err := utils.VerifyApplyToken(c.PostForm("form_token"), job.ID, time.Now()); err != nil {
+		if errors.Is(err, utils.ErrApplyTooFast) {
+			setSubmission(c, models.SubmissionSpam, err.Error())
+			c.JSON(http.StatusCreated, gin.H{"message": applicationReceived})
+			return
+		}
+		setSubmission(c, models.SubmissionInvalid, "form token")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := CandidateRequest{
+		Name:           c.PostForm("name"),
+		Email:          c.PostForm("email"),
+		Phone:          c.PostForm("phone"),
+		Location:       c.PostForm("location"),
+		CurrentTitle:   c.PostForm("current_title"),
+		CurrentCompany: c.PostForm("current_company"),
+		Source:         models.SourceCareerSite,
+		SourceDetail:   c.PostForm("source"),
+	}
+	for linkType, field := range map[string]string{models.LinkLinkedIn: "linkedin_url", models.LinkPortfolio: "portfolio_url"} {
+		if url := c.PostForm(field); url != "" {
+			req.Links = append(req.Links, models.CandidateLink{Type: linkType, URL: url})
+		}
+	}
+	coverLetter := strings.TrimSpace(c.PostForm("cover_letter"))
+	if err := validatePublicApplication(&req, coverLetter); err != nil {
+		setSubmission(c, models.SubmissionInvalid, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if utils.LooksLikeSpam(coverLetter) {
+		setSubmission(c, models.SubmissionSpam, "cover letter content")
+		c.JSON(http.StatusCreated, gin.H{"message": applicationReceived})
+		return
+	}
+
+	var recent int64
+	database.DB.Model(&models.PublicSubmission{}).
+		Where("email = ? AND outcome = ? AND created_at > ?", req.Email, models.SubmissionAccepted, time.Now().Add(-24*time.Hour)).
+		Count(&recent)
+	if recent >= maxApplicationsPerEmail {
+		setSubmission(c, models.SubmissionRateLimited, "email")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "You have sent a lot of applications today. Please try again tomorrow."})
+		return
+	}
+
+	questions, err := utils.JobQuestions(database.DB, job.ID)
+	if err != nil {
+		setSubmission(c, models.SubmissionInvalid, "server error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+	answers, err := readAnswers(c.Request.MultipartForm, questions)
+	if err != nil {
+		var invalid utils.FormError
+		if errors.As(err, &invalid) {
+			setSubmission(c, models.SubmissionInvalid, "answers")
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+		} else {
+			setSubmission(c, models.SubmissionInvalid, "server error")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store answers"})
+		}
+		return
+	}
+
+	resume, err := storePublicResume(c)
+	if err != nil {
+		setSubmission(c, models.SubmissionInvalid, "resume")
+		if errors.Is(err, errResumeMissing) || isUploadError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store resume"})
+		}
+		return
+	}
+
+	now := time.Now()
+	application := models.Application{
+		JobID:          job.ID,
+		Status:         models.ApplicationActive,
+		AppliedAt:      now,
+		StageChangedAt: now,
+		CoverLetter:    coverLetter,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		candidate, err := matchCandidate(tx, &req)
+		if err != nil {
+			return err
+		}
+
+		var existing int64
+		tx.Model(&models.Application{}).Where("candidate_id = ? AND job_id = ?", candidate.ID, job.ID).Count(&existing)
+		if existing > 0 {
+			return errAlreadyApplied
+		}
+
+		stage, err := utils.FirstJobStage(tx, job.ID)
+		if err != nil {
+			return err
+		}
+		application.CandidateID = candidate.ID
+		application.StageID = stage.ID
+		if err := tx.Omit(clause.Associations).Create(&application).Error; err != nil {
+			// A submission racing this one got in between the check and here
+			if isUniqueViolation(err, "idx_application_candidate_job") {
+				return errAlreadyApplied
+			}
+			return err
+		}
+		attachment, err := resume.attach(tx, application.ID, models.AttachmentResume, nil)
+		if err != nil {
+			return err
+		}
+		application.ResumeID = &attachment.ID
+		if err := tx.Model(&application).UpdateColumn("resume_id", attachment.ID).Error; err != nil {
+			return err
+		}
+		if err := utils.QueueResumeParse(tx, attachment.ID); err != nil {
+			return err
+		}
+		if err := utils.RecordApplied(tx, &application, stage, nil); err != nil {
+			return err
+		}
+		if err := saveAnswers(tx, &application, answers); err != nil {
+			return err
+		}
+		return utils.RefreshApplicantCount(tx, job.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errAlreadyApplied) {
+			setSubmission(c, models.SubmissionDuplicate, err.Error())
+			c.JSON(http.StatusCreated, gin.H{"message": applicationReceived})
+			return
+		}
+		setSubmission(c, models.SubmissionInvalid, "server error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+
+	setSubmission(c, models.SubmissionAccepted, "")
+	c.Set("submission_application_id", application.ID)
+	detectDuplicates(application.CandidateID)
+	c.JSON(http.StatusCreated, gin.H{"message": applicationReceived})
+}
+
+// validatePublicApplication checks what a candidate entered. Unlike
+// candidates added by the team, applicants always need an email address.
+func validatePublicApplication(req *CandidateRequest, coverLetter string) error {
+	switch {
+	case len(req.Name) > 200:
+		return errors.New("name must be at most 200 characters")
+	case len(req.Email) > 255:
+		return errors.New("email must be at most 255 characters")
+	case len(req.Location) > 100 || len(req.SourceDetail) > 100:
+		return errors.New("location and source must be at most 100 characters")
+	case len(req.CurrentTitle) > 200 || len(req.CurrentCompany) > 200:
+		return errors.New("current title and company must be at most 200 characters")
+	case len(coverLetter) > maxPublicCoverLetter:
+		return fmt.Errorf("cover letter must be at most %d characters", maxPublicCoverLetter)
+	}
+	if err := req.normalize(); err != nil {
+		return err
+	}
+	if req.Email == "" {
+		return errors.New("email is required")
+	}
+	return nil
+}
+
+// matchCandidate finds the candidate with the email of req, filling in
+// details they did not have yet, or creates one. What the team already
+// recorded is never overwritten by an application. Matching holds a lock on
+// the email until tx ends, so simultaneous applications from one address
+// cannot each create a candidate.
+func matchCandidate(tx *gorm.DB, req *CandidateRequest) (*models.Candidate, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "candidate-email|"+strings.ToLower(req.Email)).Error; err != nil {
+		return nil, err
+	}
+
+	var candidate models.Candidate
+	err := tx.Where("LOWER(email) = ?", req.Email).Order("id").First(&candidate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		req.applyTo(&candidate)
+		return &candidate, tx.Create(&candidate).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&candidate.Phone, req.Phone)
+	fill(&candidate.Location, req.Location)
+	fill(&candidate.CurrentTitle, req.CurrentTitle)
+	fill(&candidate.CurrentCompany, req.CurrentCompany)
+	for _, link := range req.Links {
+		if !hasCandidateLink(&candidate, link.URL) && len(candidate.Links) < maxCandidateLinks {
+			candidate.Links = append(candidate.Links, link)
+		}
+	}
+	return &candidate, tx.Save(&candidate).Error
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a row that
+// breaks the unique index named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+func hasCandidateLink(candidate *models.Candidate, url string) bool {
+	for _, link := range candidate.Links {
+		if strings.EqualFold(link.URL, url) {
+			return true
+		}
+	}
+	return false
+}
+
+var errResumeMissing = errors.New("a resume file is required")
+
+// upload is a file in storage that is not attached to anything yet.
+// Uploads that never get attached are removed by the cleanup job.
+type upload struct {
+	file *models.StoredFile
+	name string
+}
+
+func (u *upload) attach(tx *gorm.DB, applicationID uint, kind models.AttachmentKind, uploadedBy *uint) (*models.Attachment, error) {
+	attachment := models.Attachment{
+		ApplicationID: applicationID,
+		FileID:        u.file.ID,
+		Kind:          kind,
+		Name:          u.name,
+		UploadedBy:    uploadedBy,
+	}
+	return &attachment, tx.Create(&attachment).Error
+}
+
+// isUploadError tells whether err from storeUpload is the candidate's to
+// fix.
+func isUploadError(err error) bool {
+	return errors.Is(err, utils.ErrInvalidDocument) || errors.Is(err, utils.ErrFileTooLarge)
+}
+
+// storePublicResume checks the multipart "resume" file and stores it.
+func storePublicResume(c *gin.Context) (*upload, error) {
+	_, header, err := c.Request.FormFile("resume")
+	if err != nil {
+		return nil, errResumeMissing
+	}
+	resume, err := storeUpload(header)
+	if isUploadError(err) {
+		return nil, fmt.Errorf("resume %w", err)
+	}
+	return resume, err
+}
+
+// storeUpload checks an uploaded document and stores it.
+func storeUpload(header *multipart.FileHeader) (*upload, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stored, err := utils.StoreDocument(database.DB, file, header.Filename)
+	if err != nil {
+		return nil, err
+	}
+	return &upload{file: stored, name: utils.UploadName(header.Filename)}, nil
+}
+
+// pendingAnswer is an answer checked ahead of the application it belongs
+// to, with the file sent for it.
+type pendingAnswer struct {
+	answer models.ApplicationAnswer
+	file   *upload
+}
+
+// readAnswers checks the answers to a job's questions, sent as
+// answers[<question id>] fields and files, and stores the files once
+// every answer is valid.
+func readAnswers(form *multipart.Form, questions []models.JobQuestion) ([]pendingAnswer, error) {
+	answers := make([]pendingAnswer, 0, len(questions))
+	headers := make(map[int]*multipart.FileHeader)
+	for _, question := range questions {
+		field := fmt.Sprintf("answers[%d]", question.ID)
+		var header *multipart.FileHeader
+		if question.Type == models.QuestionFile && len(form.File[field]) > 0 {
+			header = form.File[field][0]
+		}
+
+		values, err := utils.ParseAnswer(&question.QuestionDefinition, form.Value[field], header != nil)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil && header == nil {
+			continue
+		}
+		if header != nil {
+			headers[len(answers)] = header
+		}
+		answers = append(answers, pendingAnswer{answer: models.ApplicationAnswer{
+			QuestionID: question.ID,
+			Label:      question.Label,
+			Type:       question.Type,
+			Values:     values,
+			KnockedOut: utils.KnocksOut(&question.QuestionDefinition, values),
+		}})
+	}
+
+	for i, header := range headers {
+		file, err := storeUpload(header)
+		if isUploadError(err) {
+			return nil, utils.FormError(fmt.Sprintf("%q %v", answers[i].answer.Label, err))
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers[i].file = file
+	}
+	return answers, nil
+}
+
+// saveAnswers stores the answers sent with a new application and rejects
+// it when one of them failed a knockout question.
+func saveAnswers(tx *gorm.DB, application *models.Application, answers []pendingAnswer) error {
+	knockedOut := []string{}
+	for i := range answers {
+		answer := &answers[i].answer
+		answer.ApplicationID = application.ID
+		if answers[i].file != nil {
+			attachment, err := answers[i].file.attach(tx, application.ID, models.AttachmentAnswer, nil)
+			if err != nil {
+				return err
+			}
+			answer.AttachmentID = &attachment.ID
+		}
+		if err := tx.Omit(clause.Associations).Create(answer).Error; err != nil {
+			return err
+		}
+		if answer.KnockedOut {
+			knockedOut = append(knockedOut, answer.Label)
+		}
+	}
+	if len(knockedOut) == 0 {
+		return nil
+	}
+	note := "Knocked out by: " + strings.Join(knockedOut, "; ")
+	return utils.RejectApplication(tx, application, models.RejectionUnqualified, note, nil)
+}
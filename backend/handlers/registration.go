@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+)
+
+type ReviewRegistrationRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// GetRegistrationPolicy tells the web app whether to offer sign-up, and
+// which email domains to hint at. It is public.
+func GetRegistrationPolicy(c *gin.Context) {
+	policy := utils.GetRegistrationPolicy()
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode":              policy.Mode,
+		"enabled":           policy.Mode != utils.RegistrationDisabled,
+		"allowed_domains":   policy.AllowedDomains,
+		"requires_approval": policy.RequireApproval,
+	})
+}
+
+func GetRegistrationSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"settings": utils.GetRegistrationPolicy(),
+	})
+}
+
+func UpdateRegistrationSettings(c *gin.Context) {
+	var policy utils.RegistrationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := policy.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	if err := utils.SaveSetting(models.SettingRegistration, policy, &adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditSettingsChanged, "setting", 0, gin.H{
+		"key":   models.SettingRegistration,
+		"value": policy,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Registration settings updated",
+		"settings": policy,
+	})
+}
+
+// GetPendingRegistrations is the admin approval queue, oldest first.
+func GetPendingRegistrations(c *gin.Context) {
+	var users []models.User
+
+	if err := database.DB.Where("approval_status = ?", models.ApprovalPending).
+		Order("created_at ASC").
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registrations"})
+		return
+	}
+
+	registrations := make([]gin.H, len(users))
+	for i, user := range users {
+		registrations[i] = gin.H{
+			"id":         user.ID,
+			"email":      user.Email,
+			"name":       user.Name,
+			"role":       user.Role,
+			"created_at": user.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"registrations": registrations,
+		"count":         len(registrations),
+	})
+}
+
+func ApproveRegistration(c *gin.Context) {
+	reviewRegistration(c, models.ApprovalApproved)
+}
+
+func RejectRegistration(c *gin.Context) {
+	reviewRegistration(c, models.ApprovalRejected)
+}
+
+func reviewRegistration(c *gin.Context, decision models.ApprovalStatus) {
+	var req ReviewRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.IsAwaitingApproval() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Registration is not awaiting approval"})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	now := time.Now()
+	user.ApprovalStatus = decision
+	user.ReviewedBy = &adminID
+	user.ReviewedAt = &now
+	user.IsActive = decision == models.ApprovalApproved
+
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review registration"})
+		return
+	}
+
+	action := models.AuditUserApproved
+	email := utils.Email{
+		To:      user.Email,
+		Subject: "Your Kandy account has been approved",
+		Body: fmt.Sprintf(`Hi %s,
+
+Your Kandy account has been approved. You can sign in now:
+%s
+`, user.Name, utils.AppURL("/auth/login")),
+	}
+	if decision == models.ApprovalRejected {
+		action = models.AuditUserRejected
+		email.Subject = "Your Kandy registration"
+		email.Body = fmt.Sprintf(`Hi %s,
+
+Your request for a Kandy account was not approved.
+%s`, user.Name, req.Reason)
+	}
+
+	recordAudit(c, database.DB, action, "user", user.ID, gin.H{
+		"reason": req.Reason,
+	})
+
+	if err := utils.QueueEmail(database.DB, email); err != nil {
+		log.Printf("Failed to queue registration review email for user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Registration " + string(decision),
+		"user": gin.H{
+			"id":              user.ID,
+			"email":           user.Email,
+			"name":            user.Name,
+			"approval_status": user.ApprovalStatus,
+			"is_active":       user.IsActive,
+		},
+	})
+}
+
+// notifyAdminsOfRegistration emails every active admin about an account
+// waiting in the approval queue.
+func notifyAdminsOfRegistration(user *models.User) {
+	var admins []models.User
+	if err := database.DB.Where("role = ? AND is_active = ?", models.RoleAdmin, true).Find(&admins).Error; err != nil {
+		log.Printf("Failed to load admins for registration notice: %v", err)
+		return
+	}
+
+	for _, admin := range admins {
+		err := utils.QueueEmail(database.DB, utils.Email{
+			To:      admin.Email,
+			Subject: "New Kandy registration awaiting approval",
+			Body: fmt.Sprintf(`Hi %s,
+
+%s (%s) registered for a Kandy account and is waiting for approval.
+
+Review pending registrations here:
+%s
+`, admin.Name, user.Name, user.Email, utils.AppURL("/settings")),
+		})
+		if err != nil {
+			log.Printf("Failed to queue registration notice for admin %d: %v", admin.ID, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+)
+
+// jobCounts are the numbers reported for one group of jobs
+type jobCounts struct {
+	Jobs       int64                      `json:"jobs"`
+	Applicants int64                      `json:"applicants"`
+	Statuses   map[models.JobStatus]int64 `json:"statuses"`
+}
+
+func newJobCounts() *jobCounts {
+	counts := &jobCounts{Statuses: make(map[models.JobStatus]int64, len(models.JobStatuses))}
+	for _, status := range models.JobStatuses {
+		counts.Statuses[status] = 0
+	}
+	return counts
+}
+
+func (c *jobCounts) add(other *jobCounts) {
+	c.Jobs += other.Jobs
+	c.Applicants += other.Applicants
+	for status, count := range other.Statuses {
+		c.Statuses[status] += count
+	}
+}
+
+// GetJobReport counts the jobs the current user can see and their
+// applicants per department (?group_by=department, the default) or per
+// location (?group_by=location), broken down by status. Filter with
+// ?status= (comma separated). Departments also report the totals
+// including their sub-departments. Jobs without a department or location
+// are reported in a group with a null id.
+func GetJobReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "department")
+	if groupBy != "department" && groupBy != "location" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be department or location"})
+		return
+	}
+	column := groupBy + "_id"
+
+	query := database.DB.Model(&models.Job{}).Scopes(scopeToVisibleJobs(c, "jobs.id"))
+	if status := c.Query("status"); status != "" && status != "all" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+
+	var rows []struct {
+		GroupID    *uint
+		Status     models.JobStatus
+		Jobs       int64
+		Applicants int64
+	}
+	if err := query.
+		Select(column + " AS group_id, status, COUNT(*) AS jobs, COALESCE(SUM(applicant_count), 0) AS applicants").
+		Group(column + ", status").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	counts := make(map[uint]*jobCounts)
+	unassigned := newJobCounts()
+	for _, row := range rows {
+		group := unassigned
+		if row.GroupID != nil {
+			if counts[*row.GroupID] == nil {
+				counts[*row.GroupID] = newJobCounts()
+			}
+			group = counts[*row.GroupID]
+		}
+		group.Jobs += row.Jobs
+		group.Applicants += row.Applicants
+		group.Statuses[row.Status] += row.Jobs
+	}
+	countsFor := func(id uint) *jobCounts {
+		if group := counts[id]; group != nil {
+			return group
+		}
+		return newJobCounts()
+	}
+
+	groups := []gin.H{}
+	if groupBy == "department" {
+		tree, err := utils.LoadDepartmentTree(database.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+			return
+		}
+		tree.Walk(func(department *models.Department, depth int) {
+			subtree := newJobCounts()
+			for _, id := range tree.Descendants(department.ID) {
+				subtree.add(countsFor(id))
+			}
+			groups = append(groups, gin.H{
+				"id":                department.ID,
+				"name":              department.Name,
+				"path":              tree.Path(department.ID),
+				"parent_id":         department.ParentID,
+				"depth":             depth,
+				"counts":            countsFor(department.ID),
+				"including_subtree": subtree,
+			})
+		})
+	} else {
+		var locations []models.Location
+		if err := database.DB.Order("name").Find(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+			return
+		}
+		for _, location := range locations {
+			groups = append(groups, gin.H{
+				"id":             location.ID,
+				"name":           location.Name,
+				"workplace_type": location.WorkplaceType,
+				"country_code":   location.CountryCode,
+				"counts":         countsFor(location.ID),
+			})
+		}
+	}
+	groups = append(groups, gin.H{
+		"id":     nil,
+		"name":   "Unassigned",
+		"counts": unassigned,
+	})
+
+	total := newJobCounts()
+	total.add(unassigned)
+	for _, group := range counts {
+		total.add(group)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by": groupBy,
+		"groups":   groups,
+		"total":    total,
+	})
+}
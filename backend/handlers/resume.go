@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
+)
+
+// resumeParseRow is a resume parse with the attachment it is of
+type resumeParseRow struct {
+	models.ResumeParse
+	ApplicationID  uint
+	AttachmentName string
+}
+
+// GetCandidateResumeParses lists what was read from the candidate's
+// resumes, newest first, on the applications the current user can see.
+// parsed_fields of the candidate tells which fields were filled in from
+// them.
+func GetCandidateResumeParses(c *gin.Context) {
+	candidate, ok := findCandidate(c)
+	if !ok {
+		return
+	}
+
+	var rows []resumeParseRow
+	if err := database.DB.Model(&models.ResumeParse{}).
+		Select("resume_parses.*, attachments.application_id, attachments.name AS attachment_name").
+		Joins("JOIN attachments ON attachments.id = resume_parses.attachment_id").
+		Joins("JOIN applications ON applications.id = attachments.application_id").
+		Scopes(scopeToVisibleJobs(c, "applications.job_id")).
+		Where("applications.candidate_id = ?", candidate.ID).
+		Order("resume_parses.created_at DESC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve resumes"})
+		return
+	}
+
+	entries := make([]gin.H, len(rows))
+	for i, row := range rows {
+		entries[i] = gin.H{
+			"id":              row.ID,
+			"application_id":  row.ApplicationID,
+			"attachment_id":   row.AttachmentID,
+			"attachment_name": row.AttachmentName,
+			"status":          row.Status,
+			"last_error":      row.LastError,
+			"profile":         row.Profile,
+			"parsed_at":       row.ParsedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resumes":       entries,
+		"parsed_fields": candidate.ParsedFields,
+	})
+}
+
+// ParseApplicationAttachment queues an attached file to be read as a
+// resume, again if it was before. Only empty fields of the candidate are
+// filled in from it.
+func ParseApplicationAttachment(c *gin.Context) {
+	application, attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	if !canManageApplications(c, application.JobID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	if err := utils.QueueResumeParse(database.DB, attachment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue resume"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Resume queued for parsing",
+	})
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/search"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSearchQuery = 500
+
+	defaultSuggestions = 5
+	maxSuggestions     = 20
+	// Suggestions are asked of the index before hiding what the current
+	// user cannot see, so it is asked for more than are shown
+	suggestOverfetch = 4
+)
+
+// Matches in snippets are marked with private use characters first and
+// turned into <mark> once the rest of the snippet is escaped
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+var (
+	highlightMarks  = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"`
+	profileHeadline = "HighlightAll=true, " + highlightMarks
+	textHeadline    = `MaxFragments=2, MaxWords=25, MinWords=8, FragmentDelimiter=" … ", ` + highlightMarks
+)
+
+// candidateSearchRow is a candidate found by a search, with its rank
+type candidateSearchRow struct {
+	models.Candidate
+	Rank float64
+}
+
+// searchHighlight is a snippet of a matching text
+type searchHighlight struct {
+	CandidateID   uint   `json:"-"`
+	Source        string `json:"source"`
+	Snippet       string `json:"snippet"`
+	SourceID      uint   `json:"source_id,omitempty"`
+	ApplicationID uint   `json:"application_id,omitempty"`
+}
+
+// SearchCandidates searches the candidates the current user can see by
+// name, email address, title, skills, tags, custom fields and location,
+// the text of their resumes and the notes on their applications, best
+// matches first.
+//
+// q takes web search syntax: words must all match, "quoted phrases"
+// match in order, OR matches either side and a leading - excludes a
+// word. A candidate matches when their profile, one of their resumes or
+// one of their notes matches. Filters: job_id, stage_id, applied_from
+// and applied_to (YYYY-MM-DD, inclusive; they have an application like
+// that), source, location, tag and custom fields (see
+// filterByTagsAndFields). Without q the filters alone apply.
+// Results carry highlighted snippets, HTML-escaped with matches in
+// <mark>.
+func SearchCandidates(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if len(text) > maxSearchQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at most 500 characters"})
+		return
+	}
+
+	query := database.DB.Model(&models.Candidate{}).Scopes(scopeToVisibleCandidates(c))
+	if source := c.Query("source"); source != "" {
+		query = query.Where("candidates.source = ?", source)
+	}
+	if location := strings.TrimSpace(c.Query("location")); location != "" {
+		query = query.Where("candidates.location ILIKE ?", "%"+escapeLike(location)+"%")
+	}
+
+	query, err := filterByTagsAndFields(c, query, models.CustomFieldCandidate, "candidates")
+	if err != nil {
+		respondCustomFieldError(c, err, "Failed to search candidates")
+		return
+	}
+
+	applications, filtered, err := searchApplicationFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filtered {
+		query = query.Where("candidates.id IN (?)", applications)
+	}
+
+	var tsquery clause.Expr
+	if text != "" {
+		tsquery = gorm.Expr("websearch_to_tsquery(?::regconfig, ?)", database.SearchConfig, text)
+		query = query.Where("(candidates.search_vector @@ ? OR candidates.id IN (?) OR candidates.id IN (?))",
+			tsquery,
+			searchResumes(c, tsquery).Select("applications.candidate_id"),
+			searchNotes(c, tsquery).Select("application_activities.candidate_id"))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search candidates"})
+		return
+	}
+
+	page := parsePagination(c)
+	rows := []candidateSearchRow{}
+	if text != "" {
+		// Resume and note matches count for less than the profile, as
+		// they mention many more words
+		resumeRank := searchResumes(c, tsquery).
+			Select("MAX(ts_rank(resume_parses.search_vector, ?))", tsquery).
+			Where("applications.candidate_id = candidates.id")
+		noteRank := searchNotes(c, tsquery).
+			Select("MAX(ts_rank(application_activities.search_vector, ?))", tsquery).
+			Where("application_activities.candidate_id = candidates.id")
+		query = query.
+			Select("candidates.*, ts_rank(candidates.search_vector, ?) + COALESCE((?), 0) * 0.5 + COALESCE((?), 0) * 0.3 AS rank",
+				tsquery, resumeRank, noteRank).
+			Order(parseSort(c, candidateSortColumns, "rank DESC"))
+	} else {
+		query = query.Order(parseSort(c, candidateSortColumns, "created_at DESC"))
+	}
+	if err := page.apply(query).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search candidates"})
+		return
+	}
+
+	highlights := map[uint][]searchHighlight{}
+	if text != "" && len(rows) > 0 {
+		highlights, err = searchHighlights(c, tsquery, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search candidates"})
+			return
+		}
+	}
+
+	entries := make([]gin.H, len(rows))
+	for i := range rows {
+		found := highlights[rows[i].ID]
+		if found == nil {
+			found = []searchHighlight{}
+		}
+		entries[i] = gin.H{
+			"candidate":  rows[i].Candidate,
+			"rank":       rows[i].Rank,
+			"highlights": found,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"candidates": entries,
+		"pagination": page.JSON(total),
+	})
+}
+
+// searchApplicationFilter is the candidates with an application matching
+// the job, stage and date filters of a search, on jobs the current user
+// can see. It tells whether any of these filters was given.
+func searchApplicationFilter(c *gin.Context) (*gorm.DB, bool, error) {
+	applications := database.DB.Model(&models.Application{}).
+		Select("applications.candidate_id").
+		Scopes(scopeToVisibleJobs(c, "applications.job_id"))
+	filtered := false
+
+	if jobID := c.Query("job_id"); jobID != "" {
+		applications = applications.Where("applications.job_id = ?", jobID)
+		filtered = true
+	}
+	if stageID := c.Query("stage_id"); stageID != "" {
+		applications = applications.Where("applications.stage_id = ?", stageID)
+		filtered = true
+	}
+	if from := c.Query("applied_from"); from != "" {
+		day, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return nil, false, errInvalidSearchDate
+		}
+		applications = applications.Where("applications.applied_at >= ?", day)
+		filtered = true
+	}
+	if to := c.Query("applied_to"); to != "" {
+		day, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return nil, false, errInvalidSearchDate
+		}
+		applications = applications.Where("applications.applied_at < ?", day.AddDate(0, 0, 1))
+		filtered = true
+	}
+	return applications, filtered, nil
+}
+
+var errInvalidSearchDate = errors.New("applied_from and applied_to must be dates like 2006-01-02")
+
+// searchResumes is the parsed resumes matching tsquery on applications to
+// jobs the current user can see.
+func searchResumes(c *gin.Context, tsquery clause.Expr) *gorm.DB {
+	return database.DB.Table("resume_parses").
+		Joins("JOIN attachments ON attachments.id = resume_parses.attachment_id").
+		Joins("JOIN applications ON applications.id = attachments.application_id AND applications.deleted_at IS NULL").
+		Scopes(scopeToVisibleJobs(c, "applications.job_id")).
+		Where("resume_parses.search_vector @@ ?", tsquery)
+}
+
+// searchNotes is the notes matching tsquery on applications to jobs the
+// current user can see.
+func searchNotes(c *gin.Context, tsquery clause.Expr) *gorm.DB {
+	return database.DB.Table("application_activities").
+		Joins("JOIN applications ON applications.id = application_activities.application_id AND applications.deleted_at IS NULL").
+		Scopes(scopeToVisibleJobs(c, "application_activities.job_id")).
+		Where("application_activities.search_vector @@ ?", tsquery)
+}
+
+// searchHighlights makes snippets of what matched for the candidates
+// found: their profile, their best matching resume and their best
+// matching note.
+func searchHighlights(c *gin.Context, tsquery clause.Expr, rows []candidateSearchRow) (map[uint][]searchHighlight, error) {
+	ids := make([]uint, len(rows))
+	for i := range rows {
+		ids[i] = rows[i].ID
+	}
+
+	var profiles []searchHighlight
+	if err := database.DB.Table("candidates").
+		Select(`id AS candidate_id, 'profile' AS source, ts_headline(?::regconfig,
+			concat_ws(' · ', name, NULLIF(email, ''), NULLIF(current_title, ''), NULLIF(current_company, ''), NULLIF(location, ''),
+				NULLIF(array_to_string(ARRAY(SELECT jsonb_array_elements_text(skills)), ', '), '')),
+			?, ?) AS snippet`, database.SearchConfig, tsquery, profileHeadline).
+		Where("id IN ? AND search_vector @@ ?", ids, tsquery).
+		Scan(&profiles).Error; err != nil {
+		return nil, err
+	}
+
+	var resumes []searchHighlight
+	if err := database.DB.Table("(?) AS best", searchResumes(c, tsquery).
+		Select(`DISTINCT ON (applications.candidate_id) applications.candidate_id, applications.id AS application_id,
+			resume_parses.attachment_id AS source_id, resume_parses.text`).
+		Where("applications.candidate_id IN ?", ids).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "applications.candidate_id, ts_rank(resume_parses.search_vector, ?) DESC", Vars: []interface{}{tsquery}}})).
+		Select("candidate_id, application_id, source_id, 'resume' AS source, ts_headline(?::regconfig, text, ?, ?) AS snippet",
+			database.SearchConfig, tsquery, textHeadline).
+		Scan(&resumes).Error; err != nil {
+		return nil, err
+	}
+
+	var notes []searchHighlight
+	if err := database.DB.Table("(?) AS best", searchNotes(c, tsquery).
+		Select(`DISTINCT ON (application_activities.candidate_id) application_activities.candidate_id,
+			application_activities.application_id, application_activities.id AS source_id, application_activities.body`).
+		Where("application_activities.candidate_id IN ?", ids).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "application_activities.candidate_id, ts_rank(application_activities.search_vector, ?) DESC", Vars: []interface{}{tsquery}}})).
+		Select("candidate_id, application_id, source_id, 'note' AS source, ts_headline(?::regconfig, body, ?, ?) AS snippet",
+			database.SearchConfig, tsquery, textHeadline).
+		Scan(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	highlights := make(map[uint][]searchHighlight, len(rows))
+	for _, found := range [][]searchHighlight{profiles, resumes, notes} {
+		for _, highlight := range found {
+			highlight.Snippet = markHighlights(highlight.Snippet)
+			highlights[highlight.CandidateID] = append(highlights[highlight.CandidateID], highlight)
+		}
+	}
+	return highlights, nil
+}
+
+// markHighlights escapes a snippet from ts_headline for HTML and marks
+// its matches with <mark>.
+func markHighlights(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
+
+// SuggestSearch completes a partly typed query with the candidates, jobs
+// and users the current user can see, best matches first. types limits
+// the kinds searched (comma separated: candidate, job, user) and limit is
+// the number of suggestions per kind. How forgiving matching is depends on
+// the search driver.
+func SuggestSearch(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if len(text) > maxSearchQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at most 500 characters"})
+		return
+	}
+
+	kinds := search.Kinds
+	if types := c.Query("types"); types != "" {
+		kinds = nil
+		for _, name := range strings.Split(types, ",") {
+			kind := search.Kind(strings.TrimSpace(name))
+			if !kind.IsValid() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "types must be candidate, job or user"})
+				return
+			}
+			kinds = append(kinds, kind)
+		}
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSuggestions)))
+	if limit < 1 || limit > maxSuggestions {
+		limit = defaultSuggestions
+	}
+
+	suggestions := gin.H{
+		"candidates": []gin.H{},
+		"jobs":       []gin.H{},
+		"users":      []gin.H{},
+	}
+	if text == "" {
+		c.JSON(http.StatusOK, suggestions)
+		return
+	}
+
+	hits, err := search.Get().Suggest(text, kinds, limit*suggestOverfetch)
+	if err != nil {
+		log.Printf("Failed to search for suggestions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+		return
+	}
+	ids := map[search.Kind][]uint{}
+	for _, hit := range hits {
+		ids[hit.Kind] = append(ids[hit.Kind], hit.ID)
+	}
+
+	if len(ids[search.KindCandidate]) > 0 {
+		var candidates []models.Candidate
+		if err := database.DB.Scopes(scopeToVisibleCandidates(c)).
+			Where("candidates.id IN ?", ids[search.KindCandidate]).
+			Find(&candidates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+			return
+		}
+		found := make(map[uint]*models.Candidate, len(candidates))
+		for i := range candidates {
+			found[candidates[i].ID] = &candidates[i]
+		}
+		suggestions["candidates"] = suggestionsInOrder(ids[search.KindCandidate], limit, func(id uint) gin.H {
+			candidate, ok := found[id]
+			if !ok {
+				return nil
+			}
+			return gin.H{
+				"id":              candidate.ID,
+				"name":            candidate.Name,
+				"email":           candidate.Email,
+				"current_title":   candidate.CurrentTitle,
+				"current_company": candidate.CurrentCompany,
+			}
+		})
+	}
+
+	if len(ids[search.KindJob]) > 0 {
+		var jobs []models.Job
+		if err := database.DB.Scopes(scopeToVisibleJobs(c, "jobs.id")).
+			Where("jobs.id IN ?", ids[search.KindJob]).
+			Find(&jobs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+			return
+		}
+		found := make(map[uint]*models.Job, len(jobs))
+		for i := range jobs {
+			found[jobs[i].ID] = &jobs[i]
+		}
+		suggestions["jobs"] = suggestionsInOrder(ids[search.KindJob], limit, func(id uint) gin.H {
+			job, ok := found[id]
+			if !ok {
+				return nil
+			}
+			return gin.H{
+				"id":         job.ID,
+				"title":      job.Title,
+				"department": job.Department,
+				"location":   job.Location,
+				"status":     job.Status,
+			}
+		})
+	}
+
+	// Users as the user directory lists them
+	if len(ids[search.KindUser]) > 0 {
+		var users []models.User
+		if err := database.DB.Where("is_active = ? AND role <> ?", true, models.RoleReadOnly).
+			Where("id IN ?", ids[search.KindUser]).
+			Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+			return
+		}
+		found := make(map[uint]*models.User, len(users))
+		for i := range users {
+			found[users[i].ID] = &users[i]
+		}
+		suggestions["users"] = suggestionsInOrder(ids[search.KindUser], limit, func(id uint) gin.H {
+			user, ok := found[id]
+			if !ok {
+				return nil
+			}
+			return gin.H{
+				"id":                   user.ID,
+				"name":                 user.Name,
+				"email":                user.Email,
+				"job_title":            user.JobTitle,
+				"avatar_thumbnail_url": avatarURL(user, "thumbnail"),
+			}
+		})
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// suggestionsInOrder lists the records the index found in its order, up
+// to limit, leaving out those entry returns nil for.
+func suggestionsInOrder(ids []uint, limit int, entry func(uint) gin.H) []gin.H {
+	entries := []gin.H{}
+	for _, id := range ids {
+		if len(entries) == limit {
+			break
+		}
+		if suggestion := entry(id); suggestion != nil {
+			entries = append(entries, suggestion)
+		}
+	}
+	return entries
+}
@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sebastian/kandy/backend/database"
 	"github.com/sebastian/kandy/backend/models"
 	"github.com/sebastian/kandy/backend/utils"
+	"gorm.io/gorm"
 )
 
+// How long the "this wasn't me" link in a new sign-in email works
+const sessionReportTTL = 24 * time.Hour
+
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" binding:"required"`
 	NewPassword     string `json:"new_password" binding:"required,min=8"`
@@ -19,6 +25,14 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+type RenameSessionRequest struct {
+	Name string `json:"name" binding:"max=100"`
+}
+
+type ReportSessionRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 func ChangePassword(c *gin.Context) {
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,6 +63,8 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, database.DB, models.AuditPasswordChanged, "user", user.ID, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Password changed successfully",
 	})
@@ -65,13 +81,26 @@ func GetActiveSessions(c *gin.Context) {
 		return
 	}
 
+	currentToken := bearerToken(c)
+
 	// Format response
 	sessionList := make([]gin.H, len(sessions))
 	for i, session := range sessions {
 		sessionList[i] = gin.H{
-			"id":           session.ID,
-			"ip_address":   session.IPAddress,
-			"user_agent":   session.UserAgent,
+			"id":            session.ID,
+			"ip_address":    session.IPAddress,
+			"user_agent":    session.UserAgent,
+			"browser":       session.Browser,
+			"os":            session.OS,
+			"device_type":   session.DeviceType,
+			"device_name":   session.DeviceName,
+			"is_new_device": session.IsNewDevice,
+			"is_current":    session.Token == currentToken,
+			"location": gin.H{
+				"country_code": session.CountryCode,
+				"country":      session.Country,
+				"city":         session.City,
+			},
 			"last_used_at": session.LastUsedAt,
 			"created_at":   session.CreatedAt,
 			"expires_at":   session.ExpiresAt,
@@ -99,6 +128,10 @@ func RevokeSession(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, database.DB, models.AuditSessionRevoked, "session", session.ID, gin.H{
+		"ip_address": session.IPAddress,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Session revoked successfully",
 	})
@@ -107,18 +140,19 @@ func RevokeSession(c *gin.Context) {
 func RevokeAllSessions(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	authHeader := c.GetHeader("Authorization")
-	currentToken := ""
-	if len(authHeader) > 7 {
-		currentToken = authHeader[7:] // Remove "Bearer " prefix
-	}
+	currentToken := bearerToken(c)
 
-	if err := database.DB.Unscoped().Where("user_id = ? AND token != ?", userID, currentToken).
-		Delete(&models.Session{}).Error; err != nil {
+	result := database.DB.Unscoped().Where("user_id = ? AND token != ?", userID, currentToken).
+		Delete(&models.Session{})
+	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
 		return
 	}
 
+	recordAudit(c, database.DB, models.AuditSessionsRevoked, "user", userID.(uint), gin.H{
+		"revoked": result.RowsAffected,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "All other sessions revoked successfully",
 	})
@@ -149,6 +183,13 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
+	policy := utils.GetSessionPolicy()
+	if session.IsIdle(policy.IdleTimeout) {
+		database.DB.Unscoped().Delete(&session)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired due to inactivity"})
+		return
+	}
+
 	newToken, err := utils.GenerateJWT(claims.UserID, claims.Email, claims.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
@@ -157,6 +198,7 @@ func RefreshToken(c *gin.Context) {
 
 	session.Token = newToken
 	session.LastUsedAt = time.Now()
+	session.ExpiresAt = policy.ExpiresAt(session.CreatedAt, session.LastUsedAt)
 	database.DB.Save(&session)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -164,3 +206,232 @@ func RefreshToken(c *gin.Context) {
 		"message": "Token refreshed successfully",
 	})
 }
+
+func RenameSession(c *gin.Context) {
+	var req RenameSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID := c.Param("id")
+	userID, _ := c.Get("user_id")
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	session.DeviceName = name
+	if err := database.DB.Save(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename session"})
+		return
+	}
+
+	// Later sessions from the same device pick the name up again
+	database.DB.Model(&models.UserDevice{}).
+		Where("user_id = ? AND fingerprint = ?", session.UserID, session.Fingerprint).
+		Update("name", name)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Session renamed successfully",
+		"device_name": session.DeviceName,
+	})
+}
+
+// ReportSession handles the "this wasn't me" link from a new-device email.
+// It revokes the reported session and forgets the device so the next login
+// from it is flagged again.
+func ReportSession(c *gin.Context) {
+	var req ReportSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var session models.Session
+	err := database.DB.Where("report_token_hash = ? AND created_at > ?", utils.HashToken(req.Token), time.Now().Add(-sessionReportTTL)).
+		First(&session).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&session).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND fingerprint = ?", session.UserID, session.Fingerprint).
+			Delete(&models.UserDevice{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	recordAudit(c, database.DB, models.AuditSessionReported, "session", session.ID, gin.H{
+		"user_id":    session.UserID,
+		"ip_address": session.IPAddress,
+		"device":     session.Browser + " on " + session.OS,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "The session has been signed out. We recommend changing your password now.",
+	})
+}
+
+// startSession issues a token pair for user and stores the session for the
+// current request. Logins from a device or country the user has not used
+// before are flagged and the user is emailed a link to revoke them.
+func startSession(c *gin.Context, user *models.User) (*models.Session, error) {
+	token, err := utils.GenerateJWT(user.ID, user.Email, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := c.GetHeader("User-Agent")
+	device := utils.ParseUserAgent(userAgent)
+	location := utils.LookupLocation(c.ClientIP())
+	now := time.Now()
+
+	session := models.Session{
+		UserID:       user.ID,
+		Token:        token,
+		RefreshToken: refreshToken,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    userAgent,
+		Browser:      device.Browser,
+		OS:           device.OS,
+		DeviceType:   device.DeviceType,
+		Fingerprint:  device.Fingerprint(),
+		CountryCode:  location.CountryCode,
+		Country:      location.Country,
+		City:         location.City,
+		ExpiresAt:    utils.GetSessionPolicy().ExpiresAt(now, now),
+		LastUsedAt:   now,
+		CreatedAt:    now,
+	}
+
+	newDevice, newCountry, deviceName := rememberDevice(user.ID, device, location)
+	session.DeviceName = deviceName
+	session.IsNewDevice = newDevice || newCountry
+
+	var reportToken string
+	if session.IsNewDevice {
+		reportToken, err = utils.GenerateRandomToken()
+		if err != nil {
+			return nil, err
+		}
+		hash := utils.HashToken(reportToken)
+		session.ReportTokenHash = &hash
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	if session.IsNewDevice {
+		notifyNewDevice(user, &session, device, location, reportToken, newCountry)
+	}
+
+	return &session, nil
+}
+
+// rememberDevice records the device and country for user and reports which
+// of them had not been seen before. A user's very first login is never
+// reported as new.
+func rememberDevice(userID uint, device utils.UserAgentInfo, location utils.GeoLocation) (newDevice, newCountry bool, name string) {
+	now := time.Now()
+
+	var knownDevices int64
+	database.DB.Model(&models.UserDevice{}).Where("user_id = ?", userID).Count(&knownDevices)
+
+	record := models.UserDevice{
+		UserID:      userID,
+		Fingerprint: device.Fingerprint(),
+	}
+	result := database.DB.Where(record).Attrs(models.UserDevice{
+		Browser:     device.Browser,
+		OS:          device.OS,
+		DeviceType:  device.DeviceType,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}).FirstOrCreate(&record)
+	if result.Error == nil {
+		newDevice = result.RowsAffected > 0 && knownDevices > 0
+		database.DB.Model(&record).Update("last_seen_at", now)
+	}
+
+	if location.CountryCode != "" {
+		var knownCountries int64
+		database.DB.Model(&models.UserLoginCountry{}).Where("user_id = ?", userID).Count(&knownCountries)
+
+		country := models.UserLoginCountry{
+			UserID:      userID,
+			CountryCode: location.CountryCode,
+		}
+		result := database.DB.Where(country).Attrs(models.UserLoginCountry{
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+		}).FirstOrCreate(&country)
+		if result.Error == nil {
+			newCountry = result.RowsAffected > 0 && knownCountries > 0
+			database.DB.Model(&country).Update("last_seen_at", now)
+		}
+	}
+
+	return newDevice, newCountry, record.Name
+}
+
+func notifyNewDevice(user *models.User, session *models.Session, device utils.UserAgentInfo, location utils.GeoLocation, reportToken string, newCountry bool) {
+	reason := "a device you have not used before"
+	if newCountry {
+		reason = "a country you have not signed in from before"
+	}
+
+	body := fmt.Sprintf(`Hi %s,
+
+Your Kandy account was just signed in to from %s.
+
+Device:   %s
+Location: %s
+IP:       %s
+Time:     %s
+
+If this was you, there is nothing to do.
+
+If this wasn't you, sign that session out immediately and then change your password.
+This link works for %d hours:
+%s
+`,
+		user.Name,
+		reason,
+		device.Label(),
+		location.Label(),
+		session.IPAddress,
+		session.CreatedAt.Format(time.RFC1123),
+		int(sessionReportTTL.Hours()),
+		utils.AppURL("/auth/report-session?token="+reportToken),
+	)
+
+	utils.SendEmailAsync(utils.Email{
+		To:      user.Email,
+		Subject: "New sign-in to your Kandy account",
+		Body:    body,
+	})
+}
+
+func bearerToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) > 7 {
+		return authHeader[7:] // Remove "Bearer " prefix
+	}
+	return ""
+}
@@ -19,6 +19,12 @@ func main() {
 	database.Connect()
 
 	utils.ScheduleCleanup()
+	utils.ScheduleAuditCheckpoints()
+	utils.StartEmailWorker()
+	utils.StartResumeParser()
+	utils.StartSearchIndexer()
+	utils.ScheduleInvitationLifecycle()
+	utils.ScheduleJobLifecycle()
 
 	r := routes.SetupRouter()
 
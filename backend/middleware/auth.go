@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sebastian/kandy/backend/database"
@@ -48,6 +49,16 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		policy := utils.GetSessionPolicy()
+		if session.IsIdle(policy.IdleTimeout) {
+			database.DB.Unscoped().Delete(&session)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired due to inactivity"})
+			c.Abort()
+			return
+		}
+
+		touchSession(&session, policy)
+
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
@@ -56,6 +67,23 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// touchSession records activity on the session and slides its expiry.
+// Writes are throttled to one per TouchInterval, and the conditional update
+// keeps concurrent requests from writing the same row twice.
+func touchSession(session *models.Session, policy utils.SessionPolicy) {
+	now := time.Now()
+	if !policy.NeedsTouch(session.LastUsedAt, now) {
+		return
+	}
+
+	database.DB.Model(&models.Session{}).
+		Where("id = ? AND last_used_at < ?", session.ID, now.Add(-policy.TouchInterval)).
+		UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   policy.ExpiresAt(session.CreatedAt, now),
+		})
+}
+
 func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sebastian/kandy/backend/database"
 	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/utils"
 )
 
 func RateLimitLogin() gin.HandlerFunc {
@@ -64,6 +66,32 @@ func RateLimitLogin() gin.HandlerFunc {
 	}
 }
 
+// RateLimitMagicLinkRequests limits magic link requests to 10 per 15
+// minutes from one IP address, whichever emails they are for.
+func RateLimitMagicLinkRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+
+		var recent int64
+		database.DB.Model(&models.MagicLinkRequestLog{}).
+			Where("ip_address = ? AND created_at > ?", clientIP, time.Now().Add(-15*time.Minute)).
+			Count(&recent)
+
+		if recent >= 10 {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many sign-in links requested. Please try again in 15 minutes.",
+			})
+			c.Abort()
+			return
+		}
+
+		if err := database.DB.Create(&models.MagicLinkRequestLog{IPAddress: clientIP}).Error; err != nil {
+			log.Printf("Failed to log magic link request: %v", err)
+		}
+		c.Next()
+	}
+}
+
 func logLoginAttempt(c *gin.Context, email string) {
 	if email == "" {
 		return
@@ -85,7 +113,76 @@ func logLoginAttempt(c *gin.Context, email string) {
 		IPAddress:  c.ClientIP(),
 		Success:    success,
 		FailReason: failReason,
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		return
+	}
+
+	action := models.AuditLoginSucceeded
+	if !success {
+		action = models.AuditLoginFailed
+	}
+
+	// A hash of the attempt is sealed into the audit chain so edits to the
+	// login_attempts table are detectable on verification.
+	if err := utils.RecordAudit(database.DB, utils.AuditEntry{
+		Action:     action,
+		EntityType: "login_attempt",
+		EntityID:   &attempt.ID,
+		Details:    attempt.AuditDetails(),
+		IPAddress:  attempt.IPAddress,
+	}); err != nil {
+		log.Printf("Failed to record audit event %s: %v", action, err)
+	}
+}
+
+// RateLimitPublicApply limits applications through the careers site to
+// 10 per hour from one IP address. Every submission is logged with the
+// outcome the handler sets, see handlers.ApplyToJob.
+func RateLimitPublicApply() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+
+		var recent int64
+		database.DB.Model(&models.PublicSubmission{}).
+			Where("ip_address = ? AND outcome <> ? AND created_at > ?",
+				clientIP, models.SubmissionRateLimited, time.Now().Add(-time.Hour)).
+			Count(&recent)
+
+		if recent >= 10 {
+			c.Set("submission_outcome", string(models.SubmissionRateLimited))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many applications from your network. Please try again in an hour.",
+			})
+			c.Abort()
+			logPublicSubmission(c)
+			return
+		}
+
+		c.Next()
+
+		logPublicSubmission(c)
 	}
+}
 
-	database.DB.Create(&attempt)
+func logPublicSubmission(c *gin.Context) {
+	submission := models.PublicSubmission{
+		JobID:     c.GetUint("submission_job_id"),
+		IPAddress: c.ClientIP(),
+		Email:     c.GetString("submission_email"),
+		Outcome:   models.SubmissionOutcome(c.GetString("submission_outcome")),
+		Reason:    c.GetString("submission_reason"),
+	}
+	if submission.Outcome == "" {
+		submission.Outcome = models.SubmissionInvalid
+	}
+	if id := c.GetUint("submission_application_id"); id != 0 {
+		submission.ApplicationID = &id
+	}
+
+	if err := database.DB.Create(&submission).Error; err != nil {
+		log.Printf("Failed to log public submission: %v", err)
+	}
 }
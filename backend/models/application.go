@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApplicationStatus tells whether an application is still moving through
+// the pipeline. Rejected and withdrawn applications keep the stage they
+// left at.
+type ApplicationStatus string
+
+const (
+	ApplicationActive    ApplicationStatus = "active"
+	ApplicationRejected  ApplicationStatus = "rejected"
+	ApplicationWithdrawn ApplicationStatus = "withdrawn"
+	ApplicationHired     ApplicationStatus = "hired"
+)
+
+var ApplicationStatuses = []ApplicationStatus{ApplicationActive, ApplicationRejected, ApplicationWithdrawn, ApplicationHired}
+
+func (s ApplicationStatus) IsValid() bool {
+	for _, valid := range ApplicationStatuses {
+		if s == valid {
+			return true
+		}
+	}
+	return false
+}
+
+type RejectionReason string
+
+const (
+	RejectionUnqualified     RejectionReason = "unqualified"
+	RejectionBetterCandidate RejectionReason = "better_candidate"
+	RejectionPositionFilled  RejectionReason = "position_filled"
+	RejectionCompensation    RejectionReason = "compensation"
+	RejectionNoShow          RejectionReason = "no_show"
+	RejectionUnresponsive    RejectionReason = "unresponsive"
+	RejectionOther           RejectionReason = "other"
+)
+
+var RejectionReasons = []RejectionReason{RejectionUnqualified, RejectionBetterCandidate, RejectionPositionFilled, RejectionCompensation, RejectionNoShow, RejectionUnresponsive, RejectionOther}
+
+func (r RejectionReason) IsValid() bool {
+	for _, valid := range RejectionReasons {
+		if r == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Application is a candidate considered for one job. A candidate applies to
+// a job at most once.
+type Application struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CandidateID uint       `gorm:"uniqueIndex:idx_application_candidate_job,where:deleted_at IS NULL;not null" json:"candidate_id"`
+	Candidate   *Candidate `gorm:"foreignKey:CandidateID" json:"candidate,omitempty"`
+	JobID       uint       `gorm:"uniqueIndex:idx_application_candidate_job,where:deleted_at IS NULL;index;not null" json:"job_id"`
+	Job         *Job       `gorm:"foreignKey:JobID" json:"-"`
+
+	// StageID is the stage of the job's pipeline the application is in
+	StageID uint              `gorm:"index" json:"stage_id"`
+	Stage   *JobStage         `gorm:"foreignKey:StageID" json:"stage,omitempty"`
+	Status  ApplicationStatus `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+
+	AppliedAt      time.Time `gorm:"not null;index" json:"applied_at"`
+	StageChangedAt time.Time `gorm:"not null" json:"stage_changed_at"`
+
+	CoverLetter string `gorm:"type:text" json:"cover_letter"`
+
+	// ResumeID is the attachment that is the candidate's resume for this
+	// application, usually the one they applied with
+	ResumeID *uint       `json:"resume_id"`
+	Resume   *Attachment `gorm:"foreignKey:ResumeID" json:"resume,omitempty"`
+
+	// Set while the application is rejected
+	RejectionReason RejectionReason `gorm:"type:varchar(20)" json:"rejection_reason,omitempty"`
+	RejectionNote   string          `gorm:"type:text" json:"rejection_note,omitempty"`
+	RejectedAt      *time.Time      `json:"rejected_at,omitempty"`
+
+	WithdrawnAt *time.Time `json:"withdrawn_at,omitempty"`
+	HiredAt     *time.Time `json:"hired_at,omitempty"`
+
+	// Tags and the values of custom fields, keyed by CustomField.Key
+	Tags         []string               `gorm:"type:jsonb;serializer:json;not null;default:'[]';index:,type:gin" json:"tags"`
+	CustomFields map[string]interface{} `gorm:"type:jsonb;serializer:json;not null;default:'{}';index:,type:gin" json:"custom_fields"`
+
+	// CreatedBy is nil for applications the candidate sent themselves
+	CreatedBy *uint `gorm:"index" json:"created_by"`
+
+	// Soft delete support
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BeforeSave stores empty tags and custom fields as [] and {} rather than
+// null
+func (a *Application) BeforeSave(_ *gorm.DB) error {
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	if a.CustomFields == nil {
+		a.CustomFields = map[string]interface{}{}
+	}
+	return nil
+}
+
+func (a *Application) TableName() string {
+	return "applications"
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// ActivityType is what an entry in an application's timeline records
+type ActivityType string
+
+const (
+	ActivityApplied       ActivityType = "applied"
+	ActivityStageChanged  ActivityType = "stage_changed"
+	ActivityStatusChanged ActivityType = "status_changed"
+	ActivityNote          ActivityType = "note"
+	ActivityEmail         ActivityType = "email"
+	ActivityInterview     ActivityType = "interview"
+	ActivityScorecard     ActivityType = "scorecard"
+)
+
+var ActivityTypes = []ActivityType{
+	ActivityApplied, ActivityStageChanged, ActivityStatusChanged,
+	ActivityNote, ActivityEmail, ActivityInterview, ActivityScorecard,
+}
+
+// LoggedActivityTypes can be added to a timeline by hand; the others are
+// recorded by the pipeline itself.
+var LoggedActivityTypes = []ActivityType{ActivityNote, ActivityEmail, ActivityInterview, ActivityScorecard}
+
+func (t ActivityType) IsValid() bool {
+	for _, valid := range ActivityTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func (t ActivityType) IsLogged() bool {
+	for _, logged := range LoggedActivityTypes {
+		if t == logged {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplicationActivity is an append-only entry in an application's
+// timeline. Stage names are copied so the history survives stages being
+// renamed or deleted. Time in stage is computed from the applied and
+// stage_changed entries.
+type ApplicationActivity struct {
+	ID            uint         `gorm:"primarykey" json:"id"`
+	ApplicationID uint         `gorm:"index;not null" json:"application_id"`
+	CandidateID   uint         `gorm:"index;not null" json:"candidate_id"`
+	JobID         uint         `gorm:"index;not null" json:"job_id"`
+	Type          ActivityType `gorm:"type:varchar(30);not null;index" json:"type"`
+	ActorID       *uint        `gorm:"index" json:"actor_id"`
+
+	// Stage moves
+	FromStageID *uint  `json:"from_stage_id,omitempty"`
+	FromStage   string `gorm:"type:varchar(100)" json:"from_stage,omitempty"`
+	ToStageID   *uint  `json:"to_stage_id,omitempty"`
+	ToStage     string `gorm:"type:varchar(100)" json:"to_stage,omitempty"`
+
+	// Status changes
+	FromStatus ApplicationStatus `gorm:"type:varchar(20)" json:"from_status,omitempty"`
+	ToStatus   ApplicationStatus `gorm:"type:varchar(20)" json:"to_status,omitempty"`
+
+	// Body holds the text of notes and emails and the rejection note;
+	// Details the type specific fields
+	Body    string                 `gorm:"type:text" json:"body,omitempty"`
+	Details map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"details,omitempty"`
+
+	// OccurredAt is when it happened, which for logged emails and
+	// interviews may be before the entry was created
+	OccurredAt time.Time `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (a *ApplicationActivity) TableName() string {
+	return "application_activities"
+}
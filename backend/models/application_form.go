@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+)
+
+// QuestionType is the kind of answer an application question takes
+type QuestionType string
+
+const (
+	QuestionShortText      QuestionType = "short_text"
+	QuestionLongText       QuestionType = "long_text"
+	QuestionSingleChoice   QuestionType = "single_choice"
+	QuestionMultipleChoice QuestionType = "multiple_choice"
+	QuestionYesNo          QuestionType = "yes_no"
+	QuestionNumber         QuestionType = "number"
+	QuestionFile           QuestionType = "file"
+)
+
+var QuestionTypes = []QuestionType{QuestionShortText, QuestionLongText, QuestionSingleChoice, QuestionMultipleChoice, QuestionYesNo, QuestionNumber, QuestionFile}
+
+func (t QuestionType) IsValid() bool {
+	for _, valid := range QuestionTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOptions tells whether candidates pick the answer from Options
+func (t QuestionType) HasOptions() bool {
+	return t == QuestionSingleChoice || t == QuestionMultipleChoice
+}
+
+// KnockoutRule rejects applications automatically. Choice and yes/no
+// questions knock out on any of RejectOptions, number questions on an
+// answer below Min or above Max.
+type KnockoutRule struct {
+	RejectOptions []string `json:"reject_options,omitempty"`
+	Min           *float64 `json:"min,omitempty"`
+	Max           *float64 `json:"max,omitempty"`
+}
+
+// QuestionDefinition is what a question asks, shared by the question
+// library and job forms
+type QuestionDefinition struct {
+	Label    string        `gorm:"type:varchar(500);not null" json:"label"`
+	HelpText string        `gorm:"type:text" json:"help_text"`
+	Type     QuestionType  `gorm:"type:varchar(20);not null" json:"type"`
+	Options  []string      `gorm:"type:jsonb;serializer:json" json:"options"`
+	Required bool          `gorm:"not null;default:false" json:"required"`
+	Knockout *KnockoutRule `gorm:"type:jsonb;serializer:json" json:"knockout"`
+}
+
+// LibraryQuestion is a reusable question. Jobs copy it into their form;
+// later changes to the library do not affect them.
+type LibraryQuestion struct {
+	ID uint `gorm:"primarykey" json:"id"`
+	QuestionDefinition
+	CreatedBy *uint `json:"created_by"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *LibraryQuestion) TableName() string {
+	return "library_questions"
+}
+
+// JobQuestion is one question of a job's application form. Positions
+// start at 1 and have no gaps.
+type JobQuestion struct {
+	ID                uint  `gorm:"primarykey" json:"id"`
+	JobID             uint  `gorm:"index;not null" json:"job_id"`
+	LibraryQuestionID *uint `json:"library_question_id"`
+	QuestionDefinition
+	Position int `gorm:"not null" json:"position"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *JobQuestion) TableName() string {
+	return "job_questions"
+}
+
+// ApplicationAnswer is a candidate's answer to one question of the form
+// they applied with. The question is copied, since the form may change
+// or lose the question later.
+type ApplicationAnswer struct {
+	ID            uint         `gorm:"primarykey" json:"id"`
+	ApplicationID uint         `gorm:"uniqueIndex:idx_answer_application_question;not null" json:"application_id"`
+	QuestionID    uint         `gorm:"uniqueIndex:idx_answer_application_question;not null" json:"question_id"`
+	Label         string       `gorm:"type:varchar(500);not null" json:"label"`
+	Type          QuestionType `gorm:"type:varchar(20);not null" json:"type"`
+
+	// Values holds the answer; choices are the picked options, yes/no is
+	// "yes" or "no" and numbers are in decimal notation
+	Values []string `gorm:"type:jsonb;serializer:json" json:"values"`
+
+	// File sent for file questions
+	AttachmentID *uint       `json:"attachment_id"`
+	Attachment   *Attachment `gorm:"foreignKey:AttachmentID" json:"attachment,omitempty"`
+
+	// KnockedOut is set when the answer rejected the application
+	KnockedOut bool      `gorm:"not null;default:false" json:"knocked_out"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (a *ApplicationAnswer) TableName() string {
+	return "application_answers"
+}
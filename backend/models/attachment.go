@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// StoredFile is content in file storage. Uploads with the same content
+// share one, found by its SHA-256. Files no attachment refers to are
+// removed by the cleanup job.
+type StoredFile struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	SHA256      string `gorm:"column:sha256;type:char(64);uniqueIndex;not null" json:"sha256"`
+	Key         string `gorm:"type:varchar(255);not null" json:"-"`
+	Size        int64  `gorm:"not null" json:"size"`
+	ContentType string `gorm:"type:varchar(100);not null" json:"content_type"`
+
+	// Timestamps; UpdatedAt is refreshed whenever an upload reuses the file
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (f *StoredFile) TableName() string {
+	return "stored_files"
+}
+
+// AttachmentKind says what an attached file is
+type AttachmentKind string
+
+const (
+	AttachmentResume      AttachmentKind = "resume"
+	AttachmentCoverLetter AttachmentKind = "cover_letter"
+	AttachmentOffer       AttachmentKind = "offer"
+	AttachmentAnswer      AttachmentKind = "answer"
+	AttachmentOther       AttachmentKind = "other"
+)
+
+var AttachmentKinds = []AttachmentKind{AttachmentResume, AttachmentCoverLetter, AttachmentOffer, AttachmentAnswer, AttachmentOther}
+
+func (k AttachmentKind) IsValid() bool {
+	for _, valid := range AttachmentKinds {
+		if k == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Attachment is a file attached to an application under the name it was
+// uploaded with.
+type Attachment struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	ApplicationID uint           `gorm:"index;not null" json:"application_id"`
+	FileID        uint           `gorm:"index;not null" json:"-"`
+	File          *StoredFile    `gorm:"foreignKey:FileID" json:"file,omitempty"`
+	Kind          AttachmentKind `gorm:"type:varchar(20);not null" json:"kind"`
+	Name          string         `gorm:"type:varchar(255);not null" json:"name"`
+
+	// UploadedBy is nil for files the candidate sent themselves
+	UploadedBy *uint     `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (a *Attachment) TableName() string {
+	return "attachments"
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audit actions recorded in the hash chain
+const (
+	AuditLoginSucceeded     = "auth.login_succeeded"
+	AuditLoginFailed        = "auth.login_failed"
+	AuditMagicLinkLogin     = "auth.magic_link_login"
+	AuditUserRegistered     = "auth.user_registered"
+	AuditUserApproved       = "auth.user_approved"
+	AuditUserRejected       = "auth.user_rejected"
+	AuditUserCreated        = "user.created"
+	AuditRoleChanged        = "user.role_changed"
+	AuditPasswordChanged    = "auth.password_changed"
+	AuditPasswordReset      = "auth.password_reset"
+	AuditSessionRevoked     = "session.revoked"
+	AuditSessionsRevoked    = "session.revoked_all"
+	AuditSessionReported    = "session.reported"
+	AuditInvitationSent     = "invitation.sent"
+	AuditInvitationResent   = "invitation.resent"
+	AuditInvitationAccepted = "invitation.accepted"
+	AuditInvitationCanceled = "invitation.cancelled"
+	AuditProfileUpdated     = "account.profile_updated"
+	AuditAvatarUpdated      = "account.avatar_updated"
+	AuditEmailChangeStarted = "account.email_change_requested"
+	AuditEmailChanged       = "account.email_changed"
+	AuditDataExported       = "account.data_exported"
+	AuditDeletionRequested  = "account.deletion_requested"
+	AuditDeletionCanceled   = "account.deletion_cancelled"
+	AuditUserAnonymized     = "account.anonymized"
+	AuditSettingsChanged    = "settings.changed"
+	AuditDepartmentChanged  = "org.department_changed"
+	AuditLocationChanged    = "org.location_changed"
+	AuditTemplateChanged    = "pipeline.template_changed"
+	AuditJobPipelineChanged = "pipeline.job_stages_changed"
+	AuditQuestionChanged    = "form.library_question_changed"
+	AuditJobFormChanged     = "form.job_questions_changed"
+	AuditAttachmentChanged  = "file.attachment_changed"
+	AuditCandidateMerged    = "candidate.merged"
+	AuditMergeUndone        = "candidate.merge_undone"
+	AuditCustomFieldChanged = "settings.custom_field_changed"
+	AuditRecordsExported    = "data.records_exported"
+)
+
+// AuditLog is an append-only record. Every row stores the hash of the row
+// before it, so editing or deleting any row breaks the chain from that point on.
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Sequence   uint64    `gorm:"uniqueIndex;not null" json:"sequence"`
+	ActorID    *uint     `gorm:"index" json:"actor_id,omitempty"`
+	Action     string    `gorm:"type:varchar(100);index;not null" json:"action"`
+	EntityType string    `gorm:"type:varchar(50);index:idx_audit_entity" json:"entity_type,omitempty"`
+	EntityID   *uint     `gorm:"index:idx_audit_entity" json:"entity_id,omitempty"`
+	Details    string    `gorm:"type:text" json:"details,omitempty"`
+	IPAddress  string    `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	PrevHash   string    `gorm:"type:char(64);not null" json:"prev_hash"`
+	Hash       string    `gorm:"type:char(64);uniqueIndex;not null" json:"hash"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+}
+
+func (a *AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// ComputeHash returns the chain hash over the row's content and PrevHash.
+// CreatedAt must already be truncated to the precision the database stores.
+func (a *AuditLog) ComputeHash() string {
+	actor := ""
+	if a.ActorID != nil {
+		actor = fmt.Sprint(*a.ActorID)
+	}
+	entityID := ""
+	if a.EntityID != nil {
+		entityID = fmt.Sprint(*a.EntityID)
+	}
+
+	fields := []string{
+		fmt.Sprint(a.Sequence),
+		a.PrevHash,
+		actor,
+		a.Action,
+		a.EntityType,
+		entityID,
+		a.Details,
+		a.IPAddress,
+		a.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpoint is a server-signed snapshot of the chain head. Checkpoints
+// let a verifier prove that history up to Sequence has not been rewritten,
+// even by someone able to recompute every hash in the table.
+type AuditCheckpoint struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Sequence  uint64    `gorm:"index;not null" json:"sequence"`
+	Hash      string    `gorm:"type:char(64);not null" json:"hash"`
+	Signature string    `gorm:"type:varchar(128);not null" json:"signature"`
+	KeyID     string    `gorm:"type:varchar(16);not null" json:"key_id"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+func (a *AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
+
+// SigningPayload is the message covered by the checkpoint signature.
+func (a *AuditCheckpoint) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("kandy-audit-checkpoint|%d|%s|%s",
+		a.Sequence, a.Hash, a.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CandidateSource is how a candidate first came to us
+type CandidateSource string
+
+const (
+	SourceCareerSite CandidateSource = "career_site"
+	SourceJobBoard   CandidateSource = "job_board"
+	SourceReferral   CandidateSource = "referral"
+	SourceSourced    CandidateSource = "sourced"
+	SourceAgency     CandidateSource = "agency"
+	SourceInternal   CandidateSource = "internal"
+	SourceOther      CandidateSource = "other"
+)
+
+var CandidateSources = []CandidateSource{SourceCareerSite, SourceJobBoard, SourceReferral, SourceSourced, SourceAgency, SourceInternal, SourceOther}
+
+func (s CandidateSource) IsValid() bool {
+	for _, valid := range CandidateSources {
+		if s == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Kinds of candidate links
+const (
+	LinkLinkedIn  = "linkedin"
+	LinkGitHub    = "github"
+	LinkPortfolio = "portfolio"
+	LinkOther     = "other"
+)
+
+var CandidateLinkTypes = []string{LinkLinkedIn, LinkGitHub, LinkPortfolio, LinkOther}
+
+type CandidateLink struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Candidate is a person, independent of the jobs they apply to. Each job
+// they are considered for is an Application.
+type Candidate struct {
+	ID    uint   `gorm:"primarykey" json:"id"`
+	Name  string `gorm:"type:varchar(200);not null" json:"name"`
+	Email string `gorm:"type:varchar(255);index" json:"email"`
+	Phone string `gorm:"type:varchar(30)" json:"phone"`
+
+	// Where they live and what they do now, all optional
+	Location       string `gorm:"type:varchar(100)" json:"location"`
+	CurrentTitle   string `gorm:"type:varchar(200)" json:"current_title"`
+	CurrentCompany string `gorm:"type:varchar(200)" json:"current_company"`
+
+	Links []CandidateLink `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"links"`
+
+	// Their background, usually filled in from their resume
+	WorkHistory []WorkExperience `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"work_history"`
+	Education   []Education      `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"education"`
+	Skills      []string         `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"skills"`
+
+	// ParsedFields holds the fields filled in from a resume, with how sure
+	// the parser was of each, from 0 to 1. A field leaves it once someone
+	// changes it.
+	ParsedFields map[string]float64 `gorm:"type:jsonb;serializer:json;not null;default:'{}'" json:"parsed_fields"`
+
+	// Source is how the candidate came to us; SourceDetail names the job
+	// board, referrer or agency
+	Source       CandidateSource `gorm:"type:varchar(20);not null;default:'other';index" json:"source"`
+	SourceDetail string          `gorm:"type:varchar(100)" json:"source_detail"`
+
+	// Tags and the values of custom fields, keyed by CustomField.Key
+	Tags         []string               `gorm:"type:jsonb;serializer:json;not null;default:'[]';index:,type:gin" json:"tags"`
+	CustomFields map[string]interface{} `gorm:"type:jsonb;serializer:json;not null;default:'{}';index:,type:gin" json:"custom_fields"`
+
+	// CreatedBy is nil for candidates who applied themselves
+	CreatedBy *uint `gorm:"index" json:"created_by"`
+
+	// Keys for finding duplicates, kept up to date on save; see
+	// CandidateEmailKey, CandidatePhoneKey and CandidateNameTokens
+	EmailKey   string   `gorm:"type:varchar(255);index" json:"-"`
+	PhoneKey   string   `gorm:"type:varchar(20);index" json:"-"`
+	NameTokens []string `gorm:"type:jsonb;serializer:json;index:,type:gin" json:"-"`
+
+	// Soft delete support
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BeforeSave stores empty lists as [] rather than null and refreshes the
+// duplicate keys
+func (c *Candidate) BeforeSave(_ *gorm.DB) error {
+	c.SetMatchKeys()
+	if c.Links == nil {
+		c.Links = []CandidateLink{}
+	}
+	if c.WorkHistory == nil {
+		c.WorkHistory = []WorkExperience{}
+	}
+	if c.Education == nil {
+		c.Education = []Education{}
+	}
+	if c.Skills == nil {
+		c.Skills = []string{}
+	}
+	if c.ParsedFields == nil {
+		c.ParsedFields = map[string]float64{}
+	}
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	if c.CustomFields == nil {
+		c.CustomFields = map[string]interface{}{}
+	}
+	return nil
+}
+
+// SetMatchKeys computes the keys duplicates are found by
+func (c *Candidate) SetMatchKeys() {
+	c.EmailKey = CandidateEmailKey(c.Email)
+	c.PhoneKey = CandidatePhoneKey(c.Phone)
+	c.NameTokens = CandidateNameTokens(c.Name)
+	if c.NameTokens == nil {
+		c.NameTokens = []string{}
+	}
+}
+
+func (c *Candidate) TableName() string {
+	return "candidates"
+}
+
+func (c *Candidate) AfterSave(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchCandidate, c.ID)
+}
+
+func (c *Candidate) AfterDelete(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchCandidate, c.ID)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Why two candidates look like the same person
+const (
+	DuplicateEmail       = "email"
+	DuplicatePhone       = "phone"
+	DuplicateResume      = "resume"
+	DuplicateName        = "name"
+	DuplicateSimilarName = "similar_name"
+)
+
+type DuplicateStatus string
+
+const (
+	DuplicatePending   DuplicateStatus = "pending"
+	DuplicateMerged    DuplicateStatus = "merged"
+	DuplicateDismissed DuplicateStatus = "dismissed"
+)
+
+var DuplicateStatuses = []DuplicateStatus{DuplicatePending, DuplicateMerged, DuplicateDismissed}
+
+func (s DuplicateStatus) IsValid() bool {
+	for _, valid := range DuplicateStatuses {
+		if s == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// CandidateDuplicate is a pair of candidates that may be the same person,
+// waiting in the review queue until someone merges or dismisses it.
+// CandidateID is the newer record, MatchID the older one. Score is from 0
+// to 1; Reasons says what matched.
+type CandidateDuplicate struct {
+	ID          uint            `gorm:"primarykey" json:"id"`
+	CandidateID uint            `gorm:"uniqueIndex:idx_candidate_duplicate_pair;not null" json:"candidate_id"`
+	Candidate   *Candidate      `gorm:"foreignKey:CandidateID" json:"candidate,omitempty"`
+	MatchID     uint            `gorm:"uniqueIndex:idx_candidate_duplicate_pair;index;not null" json:"match_id"`
+	Match       *Candidate      `gorm:"foreignKey:MatchID" json:"match,omitempty"`
+	Score       float64         `gorm:"not null" json:"score"`
+	Reasons     []string        `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"reasons"`
+	Status      DuplicateStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ReviewedBy  *uint           `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+func (d *CandidateDuplicate) TableName() string {
+	return "candidate_duplicates"
+}
+
+// CandidateMerge records merging MergedID into CandidateID, with what was
+// moved so the merge can be undone.
+type CandidateMerge struct {
+	ID          uint                  `gorm:"primarykey" json:"id"`
+	CandidateID uint                  `gorm:"index;not null" json:"candidate_id"`
+	MergedID    uint                  `gorm:"index;not null" json:"merged_id"`
+	ActorID     *uint                 `json:"actor_id"`
+	Changes     CandidateMergeChanges `gorm:"type:jsonb;serializer:json;not null" json:"changes"`
+	UndoneAt    *time.Time            `json:"undone_at"`
+	UndoneBy    *uint                 `json:"undone_by,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+}
+
+func (m *CandidateMerge) TableName() string {
+	return "candidate_merges"
+}
+
+// CandidateMergeChanges is what a merge did. Applications to jobs only the
+// merged candidate applied to are moved; applications to the same job are
+// folded into the surviving candidate's one. Fields holds the candidate
+// fields that were filled in, keyed like their JSON.
+type CandidateMergeChanges struct {
+	MovedApplications  []uint                 `json:"moved_applications"`
+	FoldedApplications []FoldedApplication    `json:"folded_applications"`
+	Fields             map[string]MergedField `json:"fields"`
+}
+
+// FoldedApplication is an application of the merged candidate whose files
+// and timeline went to IntoID, the surviving candidate's application to
+// the same job. ResumeID is set when its resume became the resume of IntoID.
+type FoldedApplication struct {
+	ApplicationID uint   `json:"application_id"`
+	IntoID        uint   `json:"into_id"`
+	AttachmentIDs []uint `json:"attachment_ids"`
+	ActivityIDs   []uint `json:"activity_ids"`
+	ResumeID      *uint  `json:"resume_id,omitempty"`
+}
+
+type MergedField struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// Gmail ignores dots in the local part and googlemail.com is the same
+// mailbox
+var dotlessEmailDomains = map[string]string{
+	"gmail.com":      "gmail.com",
+	"googlemail.com": "gmail.com",
+}
+
+// CandidateEmailKey is the mailbox an address delivers to: lower case,
+// without a +tag and, for Gmail, without dots.
+func CandidateEmailKey(email string) string {
+	local, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok || local == "" || domain == "" {
+		return ""
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if canonical, ok := dotlessEmailDomains[domain]; ok {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = canonical
+	}
+	return local + "@" + domain
+}
+
+// candidatePhoneDigits is how many trailing digits identify a number, so
+// the same number with and without country code or trunk prefix matches
+const candidatePhoneDigits = 9
+
+// CandidatePhoneKey is the last digits of a phone number, or "" when it has
+// too few digits to tell numbers apart.
+func CandidatePhoneKey(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) < 7 {
+		return ""
+	}
+	if len(digits) > candidatePhoneDigits {
+		digits = digits[len(digits)-candidatePhoneDigits:]
+	}
+	return digits
+}
+
+// CandidateNameTokens splits a name into lower case words without accents,
+// sorted, so "Müller, Anna" and "anna muller" give the same tokens.
+func CandidateNameTokens(name string) []string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	tokens := strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(tokens)
+	return tokens
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CustomFieldEntity is the kind of record a custom field belongs to
+type CustomFieldEntity string
+
+const (
+	CustomFieldCandidate   CustomFieldEntity = "candidate"
+	CustomFieldApplication CustomFieldEntity = "application"
+	CustomFieldJob         CustomFieldEntity = "job"
+)
+
+var CustomFieldEntities = []CustomFieldEntity{CustomFieldCandidate, CustomFieldApplication, CustomFieldJob}
+
+func (e CustomFieldEntity) IsValid() bool {
+	for _, valid := range CustomFieldEntities {
+		if e == valid {
+			return true
+		}
+	}
+	return false
+}
+
+type CustomFieldType string
+
+const (
+	CustomFieldText        CustomFieldType = "text"
+	CustomFieldNumber      CustomFieldType = "number"
+	CustomFieldDate        CustomFieldType = "date"
+	CustomFieldSelect      CustomFieldType = "select"
+	CustomFieldMultiSelect CustomFieldType = "multi_select"
+)
+
+var CustomFieldTypes = []CustomFieldType{CustomFieldText, CustomFieldNumber, CustomFieldDate, CustomFieldSelect, CustomFieldMultiSelect}
+
+func (t CustomFieldType) IsValid() bool {
+	for _, valid := range CustomFieldTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOptions reports whether values are picked from the field's options
+func (t CustomFieldType) HasOptions() bool {
+	return t == CustomFieldSelect || t == CustomFieldMultiSelect
+}
+
+// CustomField is a field admins define for candidates, applications or
+// jobs, such as visa status or notice period. Records keep their values in
+// CustomFields keyed by Key: text and select values as strings, numbers as
+// numbers, dates as "2006-01-02" and multi-select values as lists of
+// options. Key and Type cannot change once values may exist.
+type CustomField struct {
+	ID       uint              `gorm:"primarykey" json:"id"`
+	Entity   CustomFieldEntity `gorm:"type:varchar(20);not null;uniqueIndex:idx_custom_field_key,where:deleted_at IS NULL" json:"entity"`
+	Key      string            `gorm:"type:varchar(50);not null;uniqueIndex:idx_custom_field_key,where:deleted_at IS NULL" json:"key"`
+	Label    string            `gorm:"type:varchar(100);not null" json:"label"`
+	Type     CustomFieldType   `gorm:"type:varchar(20);not null" json:"type"`
+	Options  []string          `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"options"`
+	HelpText string            `gorm:"type:varchar(500)" json:"help_text"`
+
+	// Required fields have to be filled in when the team creates a record.
+	// Candidates applying on the careers site never see them.
+	Required bool `gorm:"not null;default:false" json:"required"`
+	Position int  `gorm:"not null;default:0" json:"position"`
+
+	// Soft delete support
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (f *CustomField) TableName() string {
+	return "custom_fields"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Department is an organisational unit jobs hire for. Departments nest
+// through ParentID; top-level departments have none.
+type Department struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Code        string `gorm:"type:varchar(30);index" json:"code"`
+	ParentID    *uint  `gorm:"index" json:"parent_id"`
+	Description string `gorm:"type:text" json:"description"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (d *Department) TableName() string {
+	return "departments"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// UserDevice is a device class a user has logged in from before. It outlives
+// the sessions created on it so new-device detection keeps working after
+// logout and session cleanup.
+type UserDevice struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_user_device;not null" json:"user_id"`
+	Fingerprint string    `gorm:"type:varchar(32);uniqueIndex:idx_user_device;not null" json:"-"`
+	Browser     string    `gorm:"type:varchar(50)" json:"browser"`
+	OS          string    `gorm:"type:varchar(50)" json:"os"`
+	DeviceType  string    `gorm:"type:varchar(20)" json:"device_type"`
+	Name        string    `gorm:"type:varchar(100)" json:"name,omitempty"`
+	FirstSeenAt time.Time `gorm:"not null" json:"first_seen_at"`
+	LastSeenAt  time.Time `gorm:"not null" json:"last_seen_at"`
+}
+
+func (d *UserDevice) TableName() string {
+	return "user_devices"
+}
+
+// UserLoginCountry is a country a user has logged in from before.
+type UserLoginCountry struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_user_country;not null" json:"user_id"`
+	CountryCode string    `gorm:"type:varchar(2);uniqueIndex:idx_user_country;not null" json:"country_code"`
+	FirstSeenAt time.Time `gorm:"not null" json:"first_seen_at"`
+	LastSeenAt  time.Time `gorm:"not null" json:"last_seen_at"`
+}
+
+func (c *UserLoginCountry) TableName() string {
+	return "user_login_countries"
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+type EmailStatus string
+
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSending EmailStatus = "sending"
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed"
+)
+
+// EmailOutbox holds emails waiting to be delivered by the background
+// worker. Writing to it inside a transaction means an email is only sent
+// if the change that triggered it commits.
+type EmailOutbox struct {
+	ID        uint        `gorm:"primarykey" json:"id"`
+	To        string      `gorm:"type:varchar(255);not null" json:"to"`
+	Subject   string      `gorm:"type:varchar(255);not null" json:"subject"`
+	Body      string      `gorm:"type:text;not null" json:"-"`
+	Status    EmailStatus `gorm:"type:varchar(20);index;not null;default:'pending'" json:"status"`
+	Attempts  int         `gorm:"not null;default:0" json:"attempts"`
+	LastError string      `gorm:"type:text" json:"last_error,omitempty"`
+	SendAfter time.Time   `gorm:"index;not null" json:"send_after"`
+	SentAt    *time.Time  `json:"sent_at,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (e *EmailOutbox) TableName() string {
+	return "email_outbox"
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+type HiringTeamRole string
+
+const (
+	TeamRecruiter     HiringTeamRole = "recruiter"
+	TeamHiringManager HiringTeamRole = "hiring_manager"
+	TeamCoordinator   HiringTeamRole = "coordinator"
+	TeamInterviewer   HiringTeamRole = "interviewer"
+)
+
+var HiringTeamRoles = []HiringTeamRole{TeamRecruiter, TeamHiringManager, TeamCoordinator, TeamInterviewer}
+
+func (r HiringTeamRole) IsValid() bool {
+	for _, role := range HiringTeamRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManageJob reports whether members with this role may edit the job,
+// change its status and staff its team.
+func (r HiringTeamRole) CanManageJob() bool {
+	return r == TeamRecruiter || r == TeamHiringManager
+}
+
+// CanManageApplications reports whether members with this role may add
+// candidates to the job and move them through its pipeline. Interviewers
+// only see them.
+func (r HiringTeamRole) CanManageApplications() bool {
+	return r == TeamRecruiter || r == TeamHiringManager || r == TeamCoordinator
+}
+
+// JobTeamMember staffs a user on a job. Apart from admins, users only see
+// the jobs, candidates and applications of jobs they are staffed on.
+type JobTeamMember struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	JobID     uint           `gorm:"uniqueIndex:idx_job_team_member;not null" json:"job_id"`
+	UserID    uint           `gorm:"uniqueIndex:idx_job_team_member;index;not null" json:"user_id"`
+	User      *User          `gorm:"foreignKey:UserID" json:"-"`
+	Role      HiringTeamRole `gorm:"type:varchar(20);not null" json:"role"`
+	AddedBy   *uint          `json:"added_by,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (m *JobTeamMember) TableName() string {
+	return "job_team_members"
+}
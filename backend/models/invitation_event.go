@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+type InvitationEventType string
+
+const (
+	InvitationEventSent      InvitationEventType = "sent"
+	InvitationEventResent    InvitationEventType = "resent"
+	InvitationEventReminded  InvitationEventType = "reminded"
+	InvitationEventAccepted  InvitationEventType = "accepted"
+	InvitationEventCancelled InvitationEventType = "cancelled"
+	InvitationEventExpired   InvitationEventType = "expired"
+)
+
+// InvitationEvent is one step in an invitation's history. UserID is not a
+// foreign key because cancelled invitations delete the invited user while
+// their history is kept. ActorID is nil for events raised by the system.
+type InvitationEvent struct {
+	ID        uint                `gorm:"primarykey" json:"id"`
+	UserID    uint                `gorm:"index;not null" json:"user_id"`
+	Email     string              `gorm:"type:varchar(255);not null" json:"email"`
+	Type      InvitationEventType `gorm:"type:varchar(20);not null" json:"type"`
+	ActorID   *uint               `gorm:"index" json:"actor_id,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+func (e *InvitationEvent) TableName() string {
+	return "invitation_events"
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmploymentType string
+
+const (
+	EmploymentFullTime   EmploymentType = "full-time"
+	EmploymentPartTime   EmploymentType = "part-time"
+	EmploymentContract   EmploymentType = "contract"
+	EmploymentInternship EmploymentType = "internship"
+)
+
+var EmploymentTypes = []EmploymentType{EmploymentFullTime, EmploymentPartTime, EmploymentContract, EmploymentInternship}
+
+func (t EmploymentType) IsValid() bool {
+	for _, valid := range EmploymentTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+type JobStatus string
+
+const (
+	JobDraft           JobStatus = "draft"
+	JobPendingApproval JobStatus = "pending_approval"
+	JobPublished       JobStatus = "published"
+	JobPaused          JobStatus = "paused"
+	JobClosed          JobStatus = "closed"
+)
+
+var JobStatuses = []JobStatus{JobDraft, JobPendingApproval, JobPublished, JobPaused, JobClosed}
+
+// jobTransitions lists the statuses each status can move to
+var jobTransitions = map[JobStatus][]JobStatus{
+	JobDraft:           {JobPendingApproval, JobPublished, JobClosed},
+	JobPendingApproval: {JobDraft, JobPublished},
+	JobPublished:       {JobPaused, JobClosed},
+	JobPaused:          {JobPublished, JobClosed},
+	JobClosed:          {JobDraft},
+}
+
+func (s JobStatus) IsValid() bool {
+	for _, valid := range JobStatuses {
+		if s == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s JobStatus) CanTransitionTo(to JobStatus) bool {
+	for _, allowed := range jobTransitions[s] {
+		if to == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the job is live or only temporarily paused.
+func (s JobStatus) IsOpen() bool {
+	return s == JobPublished || s == JobPaused
+}
+
+type ClosingReason string
+
+const (
+	ClosingFilled    ClosingReason = "filled"
+	ClosingCancelled ClosingReason = "cancelled"
+	ClosingOnHold    ClosingReason = "on_hold"
+	ClosingBudget    ClosingReason = "budget"
+	ClosingDuplicate ClosingReason = "duplicate"
+	ClosingScheduled ClosingReason = "scheduled"
+	ClosingOther     ClosingReason = "other"
+)
+
+var ClosingReasons = []ClosingReason{ClosingFilled, ClosingCancelled, ClosingOnHold, ClosingBudget, ClosingDuplicate, ClosingScheduled, ClosingOther}
+
+func (r ClosingReason) IsValid() bool {
+	for _, valid := range ClosingReasons {
+		if r == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Channels a job can be published to
+const (
+	JobChannelInternal  = "internal"
+	JobChannelLinkedIn  = "linkedin"
+	JobChannelIndeed    = "indeed"
+	JobChannelGlassdoor = "glassdoor"
+)
+
+var JobChannels = []string{JobChannelInternal, JobChannelLinkedIn, JobChannelIndeed, JobChannelGlassdoor}
+
+type SalaryPeriod string
+
+const (
+	SalaryPerYear  SalaryPeriod = "year"
+	SalaryPerMonth SalaryPeriod = "month"
+	SalaryPerHour  SalaryPeriod = "hour"
+)
+
+type Job struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	Title          string         `gorm:"type:varchar(200);not null" json:"title"`
+	DepartmentID   *uint          `gorm:"index" json:"department_id"`
+	LocationID     *uint          `gorm:"index" json:"location_id"`
+	EmploymentType EmploymentType `gorm:"type:varchar(20);not null;default:'full-time'" json:"type"`
+	Status         JobStatus      `gorm:"type:varchar(20);not null;default:'draft';index" json:"status"`
+	Description    string         `gorm:"type:text" json:"description"`
+	Requirements   string         `gorm:"type:text" json:"requirements"`
+
+	// Names of the referenced department and location, kept in sync when
+	// they are renamed. Jobs created before departments and locations were
+	// managed may have a name without a reference.
+	Department string `gorm:"type:varchar(100);index" json:"department"`
+	Location   string `gorm:"type:varchar(100);index" json:"location"`
+
+	// Salary range, all optional
+	SalaryMin      *int         `json:"salary_min"`
+	SalaryMax      *int         `json:"salary_max"`
+	SalaryCurrency string       `gorm:"type:varchar(3)" json:"salary_currency"`
+	SalaryPeriod   SalaryPeriod `gorm:"type:varchar(10)" json:"salary_period"`
+
+	// PublishedTo lists the channels the job is posted to
+	PublishedTo []string `gorm:"type:jsonb;serializer:json;not null;default:'[]'" json:"published_to"`
+
+	// Tags and the values of custom fields, keyed by CustomField.Key
+	Tags         []string               `gorm:"type:jsonb;serializer:json;not null;default:'[]';index:,type:gin" json:"tags"`
+	CustomFields map[string]interface{} `gorm:"type:jsonb;serializer:json;not null;default:'{}';index:,type:gin" json:"custom_fields"`
+
+	// Number of applications, kept up to date as candidates apply
+	ApplicantCount int `gorm:"not null;default:0" json:"applicants"`
+
+	CreatedBy     uint  `gorm:"index;not null" json:"created_by"`
+	CreatedByUser *User `gorm:"foreignKey:CreatedBy" json:"-"`
+
+	// Lifecycle. PublishAt and CloseAt are run by the job scheduler.
+	PublishAt     *time.Time    `gorm:"index" json:"publish_at"`
+	CloseAt       *time.Time    `gorm:"index" json:"close_at"`
+	PublishedAt   *time.Time    `json:"published_at,omitempty"`
+	PausedAt      *time.Time    `json:"paused_at,omitempty"`
+	ClosedAt      *time.Time    `json:"closed_at,omitempty"`
+	ClosingReason ClosingReason `gorm:"type:varchar(20)" json:"closing_reason,omitempty"`
+
+	// ApprovedAt is set once the job is cleared for publishing: every step
+	// of its approver chain approved, or no approval was needed. Editing a
+	// draft clears it again.
+	ApprovedAt    *time.Time `json:"approved_at,omitempty"`
+	ApprovalRound int        `gorm:"not null;default:0" json:"approval_round"`
+
+	// Soft delete support
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (j *Job) TableName() string {
+	return "jobs"
+}
+
+// BeforeSave stores empty tags and custom fields as [] and {} rather than
+// null
+func (j *Job) BeforeSave(_ *gorm.DB) error {
+	if j.Tags == nil {
+		j.Tags = []string{}
+	}
+	if j.CustomFields == nil {
+		j.CustomFields = map[string]interface{}{}
+	}
+	return nil
+}
+
+func (j *Job) AfterSave(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchJob, j.ID)
+}
+
+func (j *Job) AfterDelete(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchJob, j.ID)
+}
+
+// MissingForPublish lists the fields a job needs before it can be published.
+func (j *Job) MissingForPublish() []string {
+	missing := []string{}
+	if strings.TrimSpace(j.Department) == "" {
+		missing = append(missing, "department")
+	}
+	if strings.TrimSpace(j.Location) == "" {
+		missing = append(missing, "location")
+	}
+	if strings.TrimSpace(j.Description) == "" {
+		missing = append(missing, "description")
+	}
+	if strings.TrimSpace(j.Requirements) == "" {
+		missing = append(missing, "requirements")
+	}
+	return missing
+}
+
+// IsPublishScheduled reports whether the job waits for its publish date.
+func (j *Job) IsPublishScheduled() bool {
+	return j.PublishAt != nil && j.PublishAt.After(time.Now())
+}
+
+// JobTransition records one status change. ActorID is nil for changes made
+// by the scheduler.
+type JobTransition struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	JobID      uint      `gorm:"index;not null" json:"job_id"`
+	FromStatus JobStatus `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus   JobStatus `gorm:"type:varchar(20);not null;index" json:"to_status"`
+	ActorID    *uint     `gorm:"index" json:"actor_id,omitempty"`
+	Reason     string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (t *JobTransition) TableName() string {
+	return "job_transitions"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+type JobApprovalStatus string
+
+const (
+	JobApprovalPending  JobApprovalStatus = "pending"
+	JobApprovalApproved JobApprovalStatus = "approved"
+	JobApprovalRejected JobApprovalStatus = "rejected"
+	// JobApprovalSkipped marks later steps once an earlier one rejected
+	JobApprovalSkipped JobApprovalStatus = "skipped"
+)
+
+// JobApproval is one step of a job's approver chain. Steps are created
+// when the job is submitted, with the approvers configured at that time,
+// and decided in order. Every submission starts a new round.
+type JobApproval struct {
+	ID          uint              `gorm:"primarykey" json:"id"`
+	JobID       uint              `gorm:"index:idx_job_approval_round;not null" json:"job_id"`
+	Round       int               `gorm:"index:idx_job_approval_round;not null" json:"round"`
+	Step        int               `gorm:"not null" json:"step"`
+	StepName    string            `gorm:"type:varchar(100);not null" json:"step_name"`
+	ApproverIDs []uint            `gorm:"type:jsonb;serializer:json;not null" json:"approver_ids"`
+	Status      JobApprovalStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	DecidedBy   *uint             `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time        `json:"decided_at,omitempty"`
+	Comment     string            `gorm:"type:text" json:"comment,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+func (a *JobApproval) TableName() string {
+	return "job_approvals"
+}
+
+func (a *JobApproval) CanDecide(userID uint) bool {
+	for _, id := range a.ApproverIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
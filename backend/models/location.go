@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+type WorkplaceType string
+
+const (
+	WorkplaceOnsite WorkplaceType = "onsite"
+	WorkplaceHybrid WorkplaceType = "hybrid"
+	WorkplaceRemote WorkplaceType = "remote"
+)
+
+var WorkplaceTypes = []WorkplaceType{WorkplaceOnsite, WorkplaceHybrid, WorkplaceRemote}
+
+func (t WorkplaceType) IsValid() bool {
+	for _, valid := range WorkplaceTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Location is an office or a remote region jobs are based in. Remote
+// locations usually only have a country and a timezone.
+type Location struct {
+	ID            uint          `gorm:"primarykey" json:"id"`
+	Name          string        `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	WorkplaceType WorkplaceType `gorm:"type:varchar(10);not null;default:'onsite'" json:"workplace_type"`
+
+	// Address, all optional
+	AddressLine1 string `gorm:"type:varchar(200)" json:"address_line1"`
+	AddressLine2 string `gorm:"type:varchar(200)" json:"address_line2"`
+	City         string `gorm:"type:varchar(100)" json:"city"`
+	Region       string `gorm:"type:varchar(100)" json:"region"`
+	PostalCode   string `gorm:"type:varchar(20)" json:"postal_code"`
+
+	// CountryCode is an ISO 3166-1 alpha-2 code
+	CountryCode string `gorm:"type:varchar(2);index" json:"country_code"`
+	// Timezone is an IANA name such as Europe/Berlin
+	Timezone string `gorm:"type:varchar(64)" json:"timezone"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (l *Location) TableName() string {
+	return "locations"
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,3 +22,20 @@ type LoginAttempt struct {
 func (l *LoginAttempt) TableName() string {
 	return "login_attempts"
 }
+
+// AuditDetails is what gets sealed into the audit chain for the attempt: a
+// SHA-256 of its canonical content. Verification recomputes it to detect
+// edited rows, and the chain never holds the email address itself.
+func (l *LoginAttempt) AuditDetails() string {
+	canonical, _ := json.Marshal(struct {
+		Email      string `json:"email"`
+		IPAddress  string `json:"ip_address"`
+		Success    bool   `json:"success"`
+		FailReason string `json:"fail_reason"`
+		CreatedAt  string `json:"created_at"`
+	}{l.Email, l.IPAddress, l.Success, l.FailReason, l.CreatedAt.UTC().Format(time.RFC3339Nano)})
+	sum := sha256.Sum256(canonical)
+
+	details, _ := json.Marshal(map[string]string{"attempt_sha256": hex.EncodeToString(sum[:])})
+	return string(details)
+}
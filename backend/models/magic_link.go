@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// MagicLinkToken is a single-use passwordless login link. Only the SHA-256
+// of the token is stored; the token itself only exists in the email.
+type MagicLinkToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	User      *User      `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	IPAddress string     `gorm:"type:varchar(45)" json:"ip_address"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (m *MagicLinkToken) TableName() string {
+	return "magic_link_tokens"
+}
+
+func (m *MagicLinkToken) IsUsable() bool {
+	return m.UsedAt == nil && time.Now().Before(m.ExpiresAt)
+}
+
+// MagicLinkRequestLog records each request for a magic link, whether or
+// not a link was sent, so requests can be limited per IP address.
+type MagicLinkRequestLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	IPAddress string    `gorm:"type:varchar(45);index;not null" json:"ip_address"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (m *MagicLinkRequestLog) TableName() string {
+	return "magic_link_request_logs"
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+)
+
+// StageType says what happens in a pipeline stage. Every pipeline starts
+// with its one applied stage and ends with its one hired stage.
+type StageType string
+
+const (
+	StageApplied    StageType = "applied"
+	StageScreen     StageType = "screen"
+	StageInterview  StageType = "interview"
+	StageAssessment StageType = "assessment"
+	StageOffer      StageType = "offer"
+	StageHired      StageType = "hired"
+)
+
+var StageTypes = []StageType{StageApplied, StageScreen, StageInterview, StageAssessment, StageOffer, StageHired}
+
+func (t StageType) IsValid() bool {
+	for _, valid := range StageTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// PipelineStage describes a stage in a template
+type PipelineStage struct {
+	Name string    `json:"name"`
+	Type StageType `json:"type"`
+}
+
+// DefaultPipeline is used for new jobs when no template is the default
+var DefaultPipeline = []PipelineStage{
+	{Name: "Applied", Type: StageApplied},
+	{Name: "Screening", Type: StageScreen},
+	{Name: "Interview", Type: StageInterview},
+	{Name: "Offer", Type: StageOffer},
+	{Name: "Hired", Type: StageHired},
+}
+
+// PipelineTemplate is a blueprint for job pipelines. Jobs copy its stages
+// when they are created; later changes to the template do not affect them.
+type PipelineTemplate struct {
+	ID          uint            `gorm:"primarykey" json:"id"`
+	Name        string          `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string          `gorm:"type:text" json:"description"`
+	Stages      []PipelineStage `gorm:"type:jsonb;serializer:json;not null" json:"stages"`
+	IsDefault   bool            `gorm:"not null;default:false" json:"is_default"`
+	CreatedBy   *uint           `json:"created_by"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t *PipelineTemplate) TableName() string {
+	return "pipeline_templates"
+}
+
+// JobStage is one stage of a job's own pipeline. Positions start at 1 and
+// have no gaps.
+type JobStage struct {
+	ID       uint      `gorm:"primarykey" json:"id"`
+	JobID    uint      `gorm:"index;not null" json:"job_id"`
+	Name     string    `gorm:"type:varchar(100);not null" json:"name"`
+	Type     StageType `gorm:"type:varchar(20);not null" json:"type"`
+	Position int       `gorm:"not null" json:"position"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *JobStage) TableName() string {
+	return "job_stages"
+}
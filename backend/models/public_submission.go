@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// SubmissionOutcome is what became of a submission to the public apply
+// endpoint
+type SubmissionOutcome string
+
+const (
+	SubmissionAccepted    SubmissionOutcome = "accepted"
+	SubmissionDuplicate   SubmissionOutcome = "duplicate"
+	SubmissionInvalid     SubmissionOutcome = "invalid"
+	SubmissionSpam        SubmissionOutcome = "spam"
+	SubmissionRateLimited SubmissionOutcome = "rate_limited"
+)
+
+// PublicSubmission logs every attempt to apply through the careers site,
+// accepted or not. Rate limits count them per IP address and email.
+type PublicSubmission struct {
+	ID            uint              `gorm:"primarykey" json:"id"`
+	JobID         uint              `gorm:"index" json:"job_id"`
+	IPAddress     string            `gorm:"type:varchar(45);index;not null" json:"ip_address"`
+	Email         string            `gorm:"type:varchar(255);index" json:"email"`
+	Outcome       SubmissionOutcome `gorm:"type:varchar(20);not null" json:"outcome"`
+	Reason        string            `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	ApplicationID *uint             `json:"application_id,omitempty"`
+	CreatedAt     time.Time         `gorm:"index" json:"created_at"`
+}
+
+func (s *PublicSubmission) TableName() string {
+	return "public_submissions"
+}
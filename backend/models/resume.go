@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// WorkExperience is one position in a candidate's work history. Dates are
+// "2006" or "2006-01"; an empty EndDate means the position is current.
+type WorkExperience struct {
+	Title     string `json:"title"`
+	Company   string `json:"company"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// Education is one degree or school in a candidate's education, with dates
+// like WorkExperience
+type Education struct {
+	Institution string `json:"institution"`
+	Degree      string `json:"degree"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+}
+
+// ParsedProfile is what the resume parser read from a resume. Confidence
+// holds how sure it is of each field it found, from 0 to 1, keyed like the
+// candidate's JSON fields.
+type ParsedProfile struct {
+	Name           string             `json:"name,omitempty"`
+	Email          string             `json:"email,omitempty"`
+	Phone          string             `json:"phone,omitempty"`
+	Location       string             `json:"location,omitempty"`
+	CurrentTitle   string             `json:"current_title,omitempty"`
+	CurrentCompany string             `json:"current_company,omitempty"`
+	Links          []CandidateLink    `json:"links,omitempty"`
+	WorkHistory    []WorkExperience   `json:"work_history,omitempty"`
+	Education      []Education        `json:"education,omitempty"`
+	Skills         []string           `json:"skills,omitempty"`
+	Confidence     map[string]float64 `json:"confidence"`
+}
+
+type ResumeParseStatus string
+
+const (
+	ResumeParsePending    ResumeParseStatus = "pending"
+	ResumeParseProcessing ResumeParseStatus = "processing"
+	ResumeParseDone       ResumeParseStatus = "parsed"
+	ResumeParseFailed     ResumeParseStatus = "failed"
+)
+
+// ResumeParse is the parsing of one resume attachment, queued when the
+// resume is attached and done by the background worker. Text keeps what
+// was extracted from the file, for searching.
+type ResumeParse struct {
+	ID           uint              `gorm:"primarykey" json:"id"`
+	AttachmentID uint              `gorm:"uniqueIndex;not null" json:"attachment_id"`
+	Status       ResumeParseStatus `gorm:"type:varchar(20);index;not null;default:'pending'" json:"status"`
+	Attempts     int               `gorm:"not null;default:0" json:"attempts"`
+	LastError    string            `gorm:"type:text" json:"last_error,omitempty"`
+	Text         string            `gorm:"type:text" json:"-"`
+	Profile      *ParsedProfile    `gorm:"type:jsonb;serializer:json" json:"profile"`
+	ParsedAt     *time.Time        `json:"parsed_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (p *ResumeParse) TableName() string {
+	return "resume_parses"
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of records kept in the search index
+const (
+	SearchCandidate = "candidate"
+	SearchJob       = "job"
+	SearchUser      = "user"
+	// SearchAll asks every search index to be rebuilt
+	SearchAll = "all"
+)
+
+// SearchIndexEvent tells search indexes that a record changed and has to
+// be indexed again. Events are written in the transaction of the change,
+// so they only exist if it commits; each server applies them to its own
+// index in order.
+type SearchIndexEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Entity    string    `gorm:"type:varchar(20);not null" json:"entity"`
+	EntityID  uint      `gorm:"not null" json:"entity_id"`
+	CreatedAt time.Time `gorm:"index;not null" json:"created_at"`
+}
+
+func (e *SearchIndexEvent) TableName() string {
+	return "search_index_events"
+}
+
+// queueSearchIndex records that a saved or deleted record has to be
+// indexed again. Updates without a primary key, such as a bulk
+// UpdateColumn, do not know which records they touched and queue nothing.
+func queueSearchIndex(tx *gorm.DB, entity string, id uint) error {
+	if id == 0 {
+		return nil
+	}
+	return tx.Create(&SearchIndexEvent{Entity: entity, EntityID: id}).Error
+}
@@ -7,17 +7,32 @@ import (
 )
 
 type Session struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	UserID       uint           `gorm:"index;not null" json:"user_id"`
-	User         *User          `gorm:"foreignKey:UserID" json:"-"`
-	Token        string         `gorm:"type:varchar(500);uniqueIndex;not null" json:"-"`
-	RefreshToken string         `gorm:"type:varchar(500);uniqueIndex;not null" json:"-"`
-	IPAddress    string         `gorm:"type:varchar(45)" json:"ip_address"`
-	UserAgent    string         `gorm:"type:varchar(500)" json:"user_agent"`
-	ExpiresAt    time.Time      `gorm:"not null" json:"expires_at"`
-	LastUsedAt   time.Time      `gorm:"not null" json:"last_used_at"`
-	CreatedAt    time.Time      `json:"created_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint   `gorm:"primarykey" json:"id"`
+	UserID       uint   `gorm:"index;not null" json:"user_id"`
+	User         *User  `gorm:"foreignKey:UserID" json:"-"`
+	Token        string `gorm:"type:varchar(500);uniqueIndex;not null" json:"-"`
+	RefreshToken string `gorm:"type:varchar(500);uniqueIndex;not null" json:"-"`
+	IPAddress    string `gorm:"type:varchar(45)" json:"ip_address"`
+	UserAgent    string `gorm:"type:varchar(500)" json:"user_agent"`
+
+	// Device and location derived from UserAgent and IPAddress at login
+	Browser     string `gorm:"type:varchar(50)" json:"browser"`
+	OS          string `gorm:"type:varchar(50)" json:"os"`
+	DeviceType  string `gorm:"type:varchar(20)" json:"device_type"`
+	DeviceName  string `gorm:"type:varchar(100)" json:"device_name,omitempty"`
+	Fingerprint string `gorm:"type:varchar(32);index" json:"-"`
+	CountryCode string `gorm:"type:varchar(2)" json:"country_code,omitempty"`
+	Country     string `gorm:"type:varchar(100)" json:"country,omitempty"`
+	City        string `gorm:"type:varchar(100)" json:"city,omitempty"`
+	IsNewDevice bool   `gorm:"default:false" json:"is_new_device"`
+
+	// Hash of the token in the "this wasn't me" link sent for new devices
+	ReportTokenHash *string `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+
+	ExpiresAt  time.Time      `gorm:"not null" json:"expires_at"`
+	LastUsedAt time.Time      `gorm:"not null" json:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (s *Session) TableName() string {
@@ -27,3 +42,8 @@ func (s *Session) TableName() string {
 func (s *Session) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
+
+// IsIdle reports whether the session has gone unused for longer than timeout.
+func (s *Session) IsIdle(timeout time.Duration) bool {
+	return time.Since(s.LastUsedAt) > timeout
+}
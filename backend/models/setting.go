@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Keys of the settings admins can change at runtime
+const (
+	SettingMagicLinkRoles = "auth.magic_link_roles"
+	SettingInvitations    = "invitations"
+	SettingRegistration   = "registration"
+	SettingJobApprovals   = "jobs.approvals"
+)
+
+// Setting is a JSON encoded value that admins can change without a
+// redeploy. Settings apply to the whole Kandy instance.
+type Setting struct {
+	Key       string    `gorm:"primarykey;type:varchar(100)" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	UpdatedBy *uint     `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Setting) TableName() string {
+	return "settings"
+}
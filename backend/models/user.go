@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -9,11 +10,54 @@ import (
 
 type UserRole string
 
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+)
+
 const (
 	RoleAdmin         UserRole = "admin"
+	RoleRecruiter     UserRole = "recruiter"
 	RoleHiringManager UserRole = "hiring_manager"
+	RoleInterviewer   UserRole = "interviewer"
+	RoleReadOnly      UserRole = "read_only"
 )
 
+var ValidRoles = []UserRole{RoleAdmin, RoleRecruiter, RoleHiringManager, RoleInterviewer, RoleReadOnly}
+
+func (r UserRole) IsValid() bool {
+	for _, role := range ValidRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreferences are the emails a user has opted into.
+type NotificationPreferences struct {
+	NewApplications  bool `json:"new_applications"`
+	StageChanges     bool `json:"stage_changes"`
+	InterviewUpdates bool `json:"interview_updates"`
+	Mentions         bool `json:"mentions"`
+	WeeklyDigest     bool `json:"weekly_digest"`
+	SecurityAlerts   bool `json:"security_alerts"`
+}
+
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		NewApplications:  true,
+		StageChanges:     true,
+		InterviewUpdates: true,
+		Mentions:         true,
+		WeeklyDigest:     false,
+		SecurityAlerts:   true,
+	}
+}
+
 type User struct {
 	ID           uint     `gorm:"primarykey" json:"id"`
 	Email        string   `gorm:"uniqueIndex;not null" json:"email"`
@@ -22,6 +66,16 @@ type User struct {
 	Name         string   `gorm:"not null" json:"name"`
 	IsActive     bool     `gorm:"default:true" json:"is_active"`
 
+	// Profile
+	JobTitle                string                   `gorm:"type:varchar(100)" json:"job_title"`
+	Phone                   string                   `gorm:"type:varchar(30)" json:"phone"`
+	Timezone                string                   `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone"`
+	Locale                  string                   `gorm:"type:varchar(35);not null;default:'en'" json:"locale"`
+	NotificationPreferences *NotificationPreferences `gorm:"type:jsonb;serializer:json" json:"-"`
+	AvatarKey               *string                  `gorm:"type:varchar(255)" json:"-"`
+	AvatarThumbnailKey      *string                  `gorm:"type:varchar(255)" json:"-"`
+	AvatarUpdatedAt         *time.Time               `json:"avatar_updated_at,omitempty"`
+
 	// Login tracking
 	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
 
@@ -31,12 +85,32 @@ type User struct {
 	ResetPasswordToken  *string    `gorm:"type:varchar(255)" json:"-"`
 	ResetPasswordExpiry *time.Time `json:"-"`
 
+	// Email change waiting for the new address to be confirmed
+	PendingEmail         *string    `gorm:"type:varchar(255)" json:"pending_email,omitempty"`
+	EmailChangeTokenHash *string    `gorm:"type:char(64);uniqueIndex" json:"-"`
+	EmailChangeExpiry    *time.Time `json:"-"`
+	// Addresses the account had before, to find records kept by email
+	PreviousEmails []string `gorm:"type:jsonb;serializer:json" json:"-"`
+
 	// Invitation system
 	InvitedBy            *uint      `gorm:"index" json:"invited_by,omitempty"`
 	InvitedByUser        *User      `gorm:"foreignKey:InvitedBy" json:"-"`
 	InvitationToken      *string    `gorm:"type:varchar(255);uniqueIndex" json:"-"`
 	InvitationSentAt     *time.Time `json:"invitation_sent_at,omitempty"`
 	InvitationAcceptedAt *time.Time `json:"invitation_accepted_at,omitempty"`
+	InvitationExpiresAt  *time.Time `gorm:"index" json:"invitation_expires_at,omitempty"`
+	InvitationExpiredAt  *time.Time `json:"invitation_expired_at,omitempty"`
+	InvitationReminders  int        `gorm:"not null;default:0" json:"invitation_reminders"`
+
+	// Self-registration approval, empty when no approval was required
+	ApprovalStatus ApprovalStatus `gorm:"type:varchar(20);index" json:"approval_status,omitempty"`
+	ReviewedBy     *uint          `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time     `json:"reviewed_at,omitempty"`
+
+	// Self-service deletion: PII is anonymized once the grace period ends
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at,omitempty"`
+	AnonymizedAt        *time.Time `json:"-"`
 
 	// Soft delete support
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
@@ -71,6 +145,14 @@ func (u *User) TableName() string {
 	return "users"
 }
 
+func (u *User) AfterSave(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchUser, u.ID)
+}
+
+func (u *User) AfterDelete(tx *gorm.DB) error {
+	return queueSearchIndex(tx, SearchUser, u.ID)
+}
+
 func (u *User) IsInvitationPending() bool {
 	return u.InvitationToken != nil && u.InvitationAcceptedAt == nil
 }
@@ -78,3 +160,59 @@ func (u *User) IsInvitationPending() bool {
 func (u *User) HasAcceptedInvitation() bool {
 	return u.InvitationAcceptedAt != nil
 }
+
+func (u *User) IsInvitationExpired() bool {
+	if u.InvitationExpiredAt != nil {
+		return true
+	}
+	return u.InvitationExpiresAt != nil && time.Now().After(*u.InvitationExpiresAt)
+}
+
+// InvitationStatus is "accepted", "expired" or "pending" for invited users
+// and empty for users who registered themselves.
+func (u *User) InvitationStatus() InvitationStatus {
+	switch {
+	case u.HasAcceptedInvitation():
+		return InvitationAccepted
+	case u.InvitationSentAt == nil:
+		return ""
+	case u.IsInvitationExpired():
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+func (u *User) IsAwaitingApproval() bool {
+	return u.ApprovalStatus == ApprovalPending
+}
+
+func (u *User) IsDeletionPending() bool {
+	return u.DeletionScheduledAt != nil && u.AnonymizedAt == nil
+}
+
+// EmailAddresses returns, lower cased, every address the user has, had or
+// is changing to, for finding what is kept by email rather than user ID.
+func (u *User) EmailAddresses() []string {
+	addresses := []string{strings.ToLower(u.Email)}
+	if u.PendingEmail != nil {
+		addresses = append(addresses, strings.ToLower(*u.PendingEmail))
+	}
+	for _, previous := range u.PreviousEmails {
+		addresses = append(addresses, strings.ToLower(previous))
+	}
+	return addresses
+}
+
+// Notifications returns the user's preferences, or the defaults if they
+// never saved any.
+func (u *User) Notifications() NotificationPreferences {
+	if u.NotificationPreferences == nil {
+		return DefaultNotificationPreferences()
+	}
+	return *u.NotificationPreferences
+}
+
+func (u *User) HasAvatar() bool {
+	return u.AvatarKey != nil
+}
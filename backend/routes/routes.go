@@ -25,17 +25,33 @@ func registerPublicRoutes(r *gin.Engine) {
 			"status": "healthy",
 		})
 	})
+
+	// The careers site; candidates apply without an account
+	public := r.Group("/api/public")
+	{
+		public.GET("/jobs", handlers.GetPublicJobs)
+		public.GET("/jobs/:id", handlers.GetPublicJob)
+		public.POST("/jobs/:id/apply", middleware.RateLimitPublicApply(), handlers.ApplyToJob)
+	}
+
+	// Signed, short-lived download links; see handlers.GetAttachmentLink
+	r.GET("/api/files/:token", handlers.DownloadFile)
 }
 
 func registerAuthRoutes(r *gin.Engine) {
 	auth := r.Group("/api/auth")
 	{
+		auth.GET("/registration-policy", handlers.GetRegistrationPolicy)
 		auth.POST("/register", handlers.Register)
 		auth.POST("/login", middleware.RateLimitLogin(), handlers.Login)
 		auth.POST("/logout", middleware.AuthMiddleware(), handlers.Logout)
 		auth.POST("/password-reset/request", handlers.RequestPasswordReset)
 		auth.POST("/password-reset/confirm", handlers.ResetPassword)
 		auth.POST("/accept-invitation", handlers.AcceptInvitation)
+		auth.POST("/sessions/report", handlers.ReportSession)
+		auth.POST("/magic-link/request", middleware.RateLimitMagicLinkRequests(), handlers.RequestMagicLink)
+		auth.POST("/magic-link/consume", handlers.ConsumeMagicLink)
+		auth.POST("/email-change/confirm", handlers.ConfirmEmailChange)
 
 		auth.POST("/refresh", handlers.RefreshToken)
 	}
@@ -46,11 +62,124 @@ func registerProtectedRoutes(r *gin.Engine) {
 	api.Use(middleware.AuthMiddleware())
 	{
 		api.GET("/profile", handlers.GetProfile)
+		api.PATCH("/profile", handlers.UpdateProfile)
+		api.POST("/profile/email", handlers.RequestEmailChange)
+		api.DELETE("/profile/email", handlers.CancelEmailChange)
+		api.PUT("/profile/avatar", handlers.UploadAvatar)
+		api.DELETE("/profile/avatar", handlers.DeleteAvatar)
+		api.GET("/profile/export", handlers.ExportAccountData)
+		api.POST("/profile/deletion", handlers.RequestAccountDeletion)
+		api.DELETE("/profile/deletion", handlers.CancelAccountDeletion)
 		api.POST("/password/change", handlers.ChangePassword)
 
+		api.GET("/users", handlers.GetUserDirectory)
+		api.GET("/users/:id/avatar", handlers.GetUserAvatar)
+
+		api.GET("/departments", handlers.GetDepartments)
+		api.GET("/locations", handlers.GetLocations)
+		api.GET("/reports/jobs", handlers.GetJobReport)
+		api.GET("/pipeline-templates", handlers.GetPipelineTemplates)
+		api.GET("/search/suggest", handlers.SuggestSearch)
+		api.GET("/custom-fields", handlers.GetCustomFields)
+		api.GET("/tags", handlers.GetTags)
+
+		questions := api.Group("/question-library")
+		{
+			manageQuestions := middleware.RequireRole(models.RoleAdmin, models.RoleRecruiter)
+
+			questions.GET("", handlers.GetLibraryQuestions)
+			questions.POST("", manageQuestions, handlers.CreateLibraryQuestion)
+			questions.PUT("/:id", manageQuestions, handlers.UpdateLibraryQuestion)
+			questions.DELETE("/:id", manageQuestions, handlers.DeleteLibraryQuestion)
+		}
+
+		jobs := api.Group("/jobs")
+		{
+			// Changes to an existing job are authorized by the user's role
+			// on its hiring team, see handlers.canManageJob
+			createJobs := middleware.RequireRole(models.RoleAdmin, models.RoleRecruiter, models.RoleHiringManager)
+
+			jobs.GET("", handlers.GetJobs)
+			jobs.GET("/stats", handlers.GetJobStats)
+			jobs.GET("/export", createJobs, handlers.ExportJobs)
+			jobs.GET("/approvals/pending", handlers.GetMyPendingApprovals)
+			jobs.GET("/:id", handlers.GetJob)
+			jobs.POST("", createJobs, handlers.CreateJob)
+			jobs.PUT("/:id", handlers.UpdateJob)
+			jobs.DELETE("/:id", handlers.DeleteJob)
+
+			jobs.POST("/:id/transitions", handlers.TransitionJob)
+			jobs.GET("/:id/transitions", handlers.GetJobTransitions)
+			jobs.GET("/:id/approvals", handlers.GetJobApprovals)
+			jobs.POST("/:id/approvals", handlers.DecideJobApproval)
+
+			jobs.GET("/:id/pipeline", handlers.GetJobPipeline)
+			jobs.GET("/:id/stages", handlers.GetJobStages)
+			jobs.POST("/:id/stages", handlers.CreateJobStage)
+			jobs.PUT("/:id/stages/order", handlers.ReorderJobStages)
+			jobs.PUT("/:id/stages/:stageId", handlers.UpdateJobStage)
+			jobs.DELETE("/:id/stages/:stageId", handlers.DeleteJobStage)
+			jobs.GET("/:id/questions", handlers.GetJobForm)
+			jobs.PUT("/:id/questions", handlers.UpdateJobForm)
+
+			jobs.GET("/:id/team", handlers.GetJobTeam)
+			jobs.POST("/:id/team", handlers.AddJobTeamMember)
+			jobs.PUT("/:id/team/:userId", handlers.UpdateJobTeamMember)
+			jobs.DELETE("/:id/team/:userId", handlers.RemoveJobTeamMember)
+		}
+
+		// Candidates and applications are authorized by the user's role on
+		// the hiring team of the jobs involved, see handlers.canManageApplications
+		candidates := api.Group("/candidates")
+		{
+			createCandidates := middleware.RequireRole(models.RoleAdmin, models.RoleRecruiter, models.RoleHiringManager)
+
+			candidates.GET("", handlers.GetCandidates)
+			candidates.GET("/search", handlers.SearchCandidates)
+			candidates.GET("/export", createCandidates, handlers.ExportCandidates)
+			candidates.GET("/duplicates", handlers.GetCandidateDuplicates)
+			candidates.POST("/duplicates/:id/dismiss", createCandidates, handlers.DismissCandidateDuplicate)
+			candidates.POST("/merges/:id/undo", createCandidates, handlers.UndoCandidateMerge)
+			candidates.GET("/:id", handlers.GetCandidate)
+			candidates.POST("", createCandidates, handlers.CreateCandidate)
+			candidates.PUT("/:id", handlers.UpdateCandidate)
+			candidates.GET("/:id/activities", handlers.GetCandidateActivities)
+			candidates.GET("/:id/resumes", handlers.GetCandidateResumeParses)
+			candidates.GET("/:id/duplicates", handlers.GetCandidateDuplicatesOf)
+			candidates.POST("/:id/merge", createCandidates, handlers.MergeCandidate)
+			candidates.GET("/:id/merges", handlers.GetCandidateMerges)
+		}
+
+		applications := api.Group("/applications")
+		{
+			exportApplications := middleware.RequireRole(models.RoleAdmin, models.RoleRecruiter, models.RoleHiringManager)
+
+			applications.GET("", handlers.GetApplications)
+			applications.GET("/export", exportApplications, handlers.ExportApplications)
+			applications.GET("/:id", handlers.GetApplication)
+			applications.POST("", handlers.CreateApplication)
+			applications.PATCH("/:id", handlers.UpdateApplication)
+			applications.DELETE("/:id", handlers.DeleteApplication)
+			applications.POST("/:id/move", handlers.MoveApplication)
+			applications.POST("/:id/status", handlers.SetApplicationStatus)
+			applications.GET("/:id/activities", handlers.GetApplicationActivities)
+			applications.POST("/:id/activities", handlers.LogApplicationActivity)
+			applications.GET("/:id/time-in-stage", handlers.GetApplicationTimeInStage)
+			applications.GET("/:id/resume", handlers.GetApplicationResume)
+			applications.GET("/:id/attachments", handlers.GetApplicationAttachments)
+			applications.POST("/:id/attachments", handlers.UploadApplicationAttachment)
+			applications.GET("/:id/attachments/:attachmentId", handlers.GetApplicationAttachment)
+			applications.GET("/:id/attachments/:attachmentId/link", handlers.GetAttachmentLink)
+			applications.POST("/:id/attachments/:attachmentId/parse", handlers.ParseApplicationAttachment)
+			applications.DELETE("/:id/attachments/:attachmentId", handlers.DeleteApplicationAttachment)
+			applications.GET("/:id/answers", handlers.GetApplicationAnswers)
+			applications.GET("/:id/answers/:answerId/file", handlers.GetApplicationAnswerFile)
+		}
+
 		sessions := api.Group("/sessions")
 		{
 			sessions.GET("", handlers.GetActiveSessions)
+			sessions.PATCH("/:id", handlers.RenameSession)
 			sessions.DELETE("/:id", handlers.RevokeSession)
 			sessions.DELETE("", handlers.RevokeAllSessions)
 		}
@@ -59,9 +188,45 @@ func registerProtectedRoutes(r *gin.Engine) {
 		admin.Use(middleware.RequireRole(models.RoleAdmin))
 		{
 			admin.POST("/invite", handlers.InviteUser)
+			admin.POST("/invitations/bulk", handlers.BulkInviteUsers)
 			admin.GET("/invitations", handlers.GetPendingInvitations)
+			admin.GET("/invitations/:id/events", handlers.GetInvitationEvents)
 			admin.POST("/invitations/:id/resend", handlers.ResendInvitation)
 			admin.DELETE("/invitations/:id", handlers.CancelInvitation)
+
+			admin.GET("/registrations", handlers.GetPendingRegistrations)
+			admin.POST("/registrations/:id/approve", handlers.ApproveRegistration)
+			admin.POST("/registrations/:id/reject", handlers.RejectRegistration)
+
+			admin.GET("/settings/registration", handlers.GetRegistrationSettings)
+			admin.PUT("/settings/registration", handlers.UpdateRegistrationSettings)
+			admin.GET("/settings/invitations", handlers.GetInvitationSettings)
+			admin.PUT("/settings/invitations", handlers.UpdateInvitationSettings)
+			admin.GET("/settings/job-approvals", handlers.GetJobApprovalSettings)
+			admin.PUT("/settings/job-approvals", handlers.UpdateJobApprovalSettings)
+			admin.GET("/settings/magic-link", handlers.GetMagicLinkSettings)
+			admin.PUT("/settings/magic-link", handlers.UpdateMagicLinkSettings)
+
+			admin.POST("/departments", handlers.CreateDepartment)
+			admin.POST("/departments/import", handlers.ImportDepartments)
+			admin.PUT("/departments/:id", handlers.UpdateDepartment)
+			admin.DELETE("/departments/:id", handlers.DeleteDepartment)
+			admin.POST("/locations", handlers.CreateLocation)
+			admin.POST("/locations/import", handlers.ImportLocations)
+			admin.PUT("/locations/:id", handlers.UpdateLocation)
+			admin.DELETE("/locations/:id", handlers.DeleteLocation)
+
+			admin.POST("/custom-fields", handlers.CreateCustomField)
+			admin.PUT("/custom-fields/:id", handlers.UpdateCustomField)
+			admin.DELETE("/custom-fields/:id", handlers.DeleteCustomField)
+
+			admin.POST("/pipeline-templates", handlers.CreatePipelineTemplate)
+			admin.PUT("/pipeline-templates/:id", handlers.UpdatePipelineTemplate)
+			admin.DELETE("/pipeline-templates/:id", handlers.DeletePipelineTemplate)
+
+			admin.GET("/audit", handlers.GetAuditLogs)
+			admin.GET("/audit/verify", handlers.VerifyAuditLog)
+			admin.POST("/audit/checkpoints", handlers.CreateAuditCheckpoint)
 		}
 	}
 }
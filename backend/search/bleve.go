@@ -0,0 +1,251 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/char/asciifolding"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	// bleveVersion changes whenever the mapping does; an index built with
+	// another version is rebuilt when it is opened
+	bleveVersion  = "1"
+	bleveAnalyzer = "suggest"
+)
+
+var (
+	bleveVersionKey    = []byte("kandy_version")
+	bleveCheckpointKey = []byte("kandy_checkpoint")
+
+	// Only one process can have the index open; another one gives up
+	// instead of waiting forever
+	bleveConfig = map[string]interface{}{"bolt_timeout": "5s"}
+)
+
+// Bleve keeps an embedded Bleve index in a directory. It matches words
+// while they are typed and forgives a typo or two in longer words.
+type Bleve struct {
+	path string
+
+	// mu guards index, which Reset replaces
+	mu    sync.RWMutex
+	index bleve.Index
+}
+
+// OpenBleve opens the index in path, creating it if needed.
+func OpenBleve(path string) (*Bleve, error) {
+	b := &Bleve{path: path}
+
+	index, err := bleve.OpenUsing(path, bleveConfig)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return b, b.create()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s (is another server or command using it?): %w", path, err)
+	}
+
+	version, err := index.GetInternal(bleveVersionKey)
+	if err == nil && string(version) == bleveVersion {
+		b.index = index
+		return b, nil
+	}
+	index.Close()
+	return b, b.create()
+}
+
+// create replaces whatever is in the index directory with an empty index.
+func (b *Bleve) create() error {
+	if err := os.RemoveAll(b.path); err != nil {
+		return err
+	}
+	indexMapping, err := bleveMapping()
+	if err != nil {
+		return err
+	}
+	index, err := bleve.NewUsing(b.path, indexMapping, bleve.Config.DefaultIndexType, bleve.Config.DefaultKVStore, bleveConfig)
+	if err != nil {
+		return err
+	}
+	if err := index.SetInternal(bleveVersionKey, []byte(bleveVersion)); err != nil {
+		index.Close()
+		return err
+	}
+	b.index = index
+	return nil
+}
+
+// bleveMapping indexes the kind as is and the name and text as words,
+// lowercased and without accents.
+func bleveMapping() (*mapping.IndexMappingImpl, error) {
+	indexMapping := bleve.NewIndexMapping()
+	err := indexMapping.AddCustomAnalyzer(bleveAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"char_filters":  []string{asciifolding.Name},
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(analyzer string) *mapping.FieldMapping {
+		fieldMapping := bleve.NewTextFieldMapping()
+		fieldMapping.Analyzer = analyzer
+		fieldMapping.Store = false
+		fieldMapping.IncludeInAll = false
+		fieldMapping.IncludeTermVectors = false
+		fieldMapping.DocValues = false
+		return fieldMapping
+	}
+
+	document := bleve.NewDocumentStaticMapping()
+	document.AddFieldMappingsAt("kind", field("keyword"))
+	document.AddFieldMappingsAt("name", field(bleveAnalyzer))
+	document.AddFieldMappingsAt("text", field(bleveAnalyzer))
+	indexMapping.DefaultMapping = document
+	indexMapping.DefaultAnalyzer = bleveAnalyzer
+	return indexMapping, nil
+}
+
+func bleveID(kind Kind, id uint) string {
+	return string(kind) + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (b *Bleve) Put(docs []Document) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	batch := b.index.NewBatch()
+	for _, doc := range docs {
+		err := batch.Index(bleveID(doc.Kind, doc.ID), map[string]interface{}{
+			"kind": string(doc.Kind),
+			"name": doc.Name,
+			"text": doc.Text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *Bleve) Delete(kind Kind, ids []uint) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	batch := b.index.NewBatch()
+	for _, id := range ids {
+		batch.Delete(bleveID(kind, id))
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *Bleve) Reset() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.index.Close(); err != nil {
+		return err
+	}
+	return b.create()
+}
+
+func (b *Bleve) Checkpoint() (Checkpoint, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var checkpoint Checkpoint
+	data, err := b.index.GetInternal(bleveCheckpointKey)
+	if err != nil || data == nil {
+		return checkpoint, err
+	}
+	return checkpoint, json.Unmarshal(data, &checkpoint)
+}
+
+func (b *Bleve) SetCheckpoint(checkpoint Checkpoint) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return b.index.SetInternal(bleveCheckpointKey, data)
+}
+
+// Suggest finds records with every word of the query in their name or
+// text. Each word may be the start of an indexed word, since the query is
+// still being typed, or be a few letters off one; names count double.
+func (b *Bleve) Suggest(text string, kinds []Kind, limit int) ([]Hit, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var words []query.Query
+	for _, token := range b.index.Mapping().AnalyzerNamed(bleveAnalyzer).Analyze([]byte(text)) {
+		words = append(words, bleveWordQuery(string(token.Term)))
+	}
+	if len(words) == 0 {
+		return []Hit{}, nil
+	}
+
+	hits := []Hit{}
+	for _, kind := range kinds {
+		kindQuery := bleve.NewTermQuery(string(kind))
+		kindQuery.SetField("kind")
+
+		request := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(append([]query.Query{kindQuery}, words...)...), limit, 0, false)
+		result, err := b.index.Search(request)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range result.Hits {
+			id, err := strconv.ParseUint(strings.TrimPrefix(match.ID, string(kind)+":"), 10, 64)
+			if err != nil {
+				continue
+			}
+			hits = append(hits, Hit{Kind: kind, ID: uint(id), Score: match.Score})
+		}
+	}
+	return hits, nil
+}
+
+// bleveWordQuery matches a word of a query in the name or text.
+func bleveWordQuery(word string) query.Query {
+	// Short words would match nearly anything with a typo allowed
+	fuzziness := 0
+	switch length := len([]rune(word)); {
+	case length >= 6:
+		fuzziness = 2
+	case length >= 3:
+		fuzziness = 1
+	}
+
+	match := bleve.NewDisjunctionQuery()
+	for field, boost := range map[string]float64{"name": 2, "text": 1} {
+		prefix := bleve.NewPrefixQuery(word)
+		prefix.SetField(field)
+		prefix.SetBoost(boost * 1.5)
+		match.AddQuery(prefix)
+
+		if fuzziness > 0 {
+			fuzzy := bleve.NewFuzzyQuery(word)
+			fuzzy.SetField(field)
+			fuzzy.SetFuzziness(fuzziness)
+			fuzzy.SetBoost(boost)
+			match.AddQuery(fuzzy)
+		}
+	}
+	return match
+}
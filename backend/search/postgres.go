@@ -0,0 +1,72 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/sebastian/kandy/backend/database"
+)
+
+// postgresSource is where the postgres driver finds a kind of record
+type postgresSource struct {
+	table string
+	name  string
+	// text is everything a query may match, name included
+	text string
+}
+
+var postgresSources = map[Kind]postgresSource{
+	KindCandidate: {"candidates", "name", "concat_ws(' ', name, email, current_title, current_company, location, tags::text)"},
+	KindJob:       {"jobs", "title", "concat_ws(' ', title, department, location, tags::text)"},
+	KindUser:      {"users", "name", "concat_ws(' ', name, email)"},
+}
+
+// Postgres matches queries against the tables with ILIKE. It needs no
+// index of its own but does not forgive typos: every word of the query
+// has to appear as typed.
+type Postgres struct{}
+
+func NewPostgres() *Postgres {
+	return &Postgres{}
+}
+
+func (p *Postgres) Suggest(query string, kinds []Kind, limit int) ([]Hit, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return []Hit{}, nil
+	}
+
+	hits := []Hit{}
+	for _, kind := range kinds {
+		source, ok := postgresSources[kind]
+		if !ok {
+			continue
+		}
+
+		// Names starting with the query come first, then names with a word
+		// starting with its first word
+		first := escapeLike(words[0])
+		db := database.DB.Table(source.table).
+			Select("id, CASE WHEN "+source.name+" ILIKE ? THEN 3 WHEN "+source.name+" ILIKE ? OR "+source.name+" ILIKE ? THEN 2 ELSE 1 END AS score",
+				escapeLike(strings.Join(words, " "))+"%", first+"%", "% "+first+"%").
+			Where("deleted_at IS NULL")
+		for _, word := range words {
+			db = db.Where(source.text+" ILIKE ?", "%"+escapeLike(word)+"%")
+		}
+
+		var rows []struct {
+			ID    uint
+			Score float64
+		}
+		if err := db.Order("score DESC, " + source.name + ", id").Limit(limit).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			hits = append(hits, Hit{Kind: kind, ID: row.ID, Score: row.Score})
+		}
+	}
+	return hits, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
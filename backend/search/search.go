@@ -0,0 +1,113 @@
+// Package search answers typeahead queries over candidates, jobs and
+// users. The postgres driver queries the tables directly; embedded
+// drivers such as bleve keep their own index, which adds typo tolerance
+// and is kept in line with the database by the search indexer.
+package search
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kind is the kind of record a document describes.
+type Kind string
+
+const (
+	KindCandidate Kind = "candidate"
+	KindJob       Kind = "job"
+	KindUser      Kind = "user"
+)
+
+var Kinds = []Kind{KindCandidate, KindJob, KindUser}
+
+func (k Kind) IsValid() bool {
+	for _, valid := range Kinds {
+		if k == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Document is what an index knows about a record. Name is matched before
+// Text, which holds everything else worth finding the record by.
+type Document struct {
+	Kind Kind
+	ID   uint
+	Name string
+	Text string
+}
+
+// Hit is a record matching a query. Scores only compare hits of one query.
+type Hit struct {
+	Kind  Kind
+	ID    uint
+	Score float64
+}
+
+// Index is implemented by every search driver.
+type Index interface {
+	// Suggest returns the best matches for a partly typed query, at most
+	// limit of each kind, best first
+	Suggest(query string, kinds []Kind, limit int) ([]Hit, error)
+}
+
+// SyncedIndex is an index with its own copy of the records. The search
+// indexer applies changes to it and stores how far it got with it.
+type SyncedIndex interface {
+	Index
+	// Put indexes documents, replacing earlier versions of them
+	Put(docs []Document) error
+	// Delete removes records; removing a missing record is not an error
+	Delete(kind Kind, ids []uint) error
+	// Reset removes every document and the checkpoint
+	Reset() error
+	Checkpoint() (Checkpoint, error)
+	SetCheckpoint(Checkpoint) error
+}
+
+// Checkpoint is the last search index event applied to an index and when
+// the index was last brought up to date.
+type Checkpoint struct {
+	EventID  uint      `json:"event_id"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+var (
+	index     Index
+	indexOnce sync.Once
+)
+
+// Get returns the index configured through the environment.
+func Get() Index {
+	indexOnce.Do(func() {
+		switch driver := Driver(); driver {
+		case "postgres":
+			index = NewPostgres()
+		case "bleve":
+			bleve, err := OpenBleve(getEnv("SEARCH_INDEX_DIR", "./search-index"))
+			if err != nil {
+				log.Fatalf("Failed to open search index: %v", err)
+			}
+			index = bleve
+		default:
+			log.Fatalf("Unknown SEARCH_DRIVER %q", driver)
+		}
+	})
+	return index
+}
+
+// Driver is the name of the configured search driver.
+func Driver() string {
+	return getEnv("SEARCH_DRIVER", "postgres")
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
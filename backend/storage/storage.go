@@ -0,0 +1,62 @@
+// Package storage keeps uploaded files such as avatars and resumes outside
+// the database. Objects are addressed by slash separated keys.
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage is implemented by every storage backend.
+type Storage interface {
+	// Put stores the content of r under key, replacing any existing object
+	Put(key string, r io.Reader, contentType string) error
+	// Open returns the object stored under key, or ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(key string) error
+}
+
+var (
+	backend     Storage
+	backendOnce sync.Once
+)
+
+// Get returns the backend configured through the environment.
+func Get() Storage {
+	backendOnce.Do(func() {
+		switch driver := getEnv("STORAGE_DRIVER", "local"); driver {
+		case "local":
+			backend = NewLocal(getEnv("STORAGE_LOCAL_DIR", "./uploads"))
+		case "s3":
+			s3, err := NewS3(S3Config{
+				Endpoint:  getEnv("STORAGE_S3_ENDPOINT", "s3.amazonaws.com"),
+				Region:    os.Getenv("STORAGE_S3_REGION"),
+				Bucket:    getEnv("STORAGE_S3_BUCKET", "kandy"),
+				AccessKey: os.Getenv("STORAGE_S3_ACCESS_KEY"),
+				SecretKey: os.Getenv("STORAGE_S3_SECRET_KEY"),
+				UseSSL:    getEnv("STORAGE_S3_USE_SSL", "true") == "true",
+			})
+			if err != nil {
+				log.Fatalf("Failed to connect to S3 storage: %v", err)
+			}
+			backend = s3
+		default:
+			log.Fatalf("Unknown STORAGE_DRIVER %q", driver)
+		}
+	})
+	return backend
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
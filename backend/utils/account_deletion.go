@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+	"gorm.io/gorm"
+)
+
+// AccountDeletionGracePeriod is how long a deletion request can be cancelled.
+func AccountDeletionGracePeriod() time.Duration {
+	return durationFromEnv("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+}
+
+// AnonymizeUser strips personal data from user and soft deletes the row.
+// The row itself is kept so records the user created (invitations, audit
+// entries) still point at a valid ID.
+func AnonymizeUser(db *gorm.DB, user *models.User) error {
+	addresses := user.EmailAddresses()
+	avatarKeys := []*string{user.AvatarKey, user.AvatarThumbnailKey}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		unusablePassword, err := GenerateRandomToken()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user.Email = fmt.Sprintf("deleted-user-%d@deleted.invalid", user.ID)
+		user.Name = "Deleted user"
+		user.IsActive = false
+		user.EmailVerified = false
+		user.VerificationToken = nil
+		user.ResetPasswordToken = nil
+		user.ResetPasswordExpiry = nil
+		user.InvitationToken = nil
+		user.JobTitle = ""
+		user.Phone = ""
+		user.PendingEmail = nil
+		user.PreviousEmails = nil
+		user.EmailChangeTokenHash = nil
+		user.EmailChangeExpiry = nil
+		user.AvatarKey = nil
+		user.AvatarThumbnailKey = nil
+		user.AvatarUpdatedAt = nil
+		user.AnonymizedAt = &now
+		if err := user.HashPassword(unusablePassword); err != nil {
+			return err
+		}
+
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserDevice{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserLoginCountry{}).Error; err != nil {
+			return err
+		}
+		// Invitation history is kept for the audit trail, without the address
+		if err := tx.Model(&models.InvitationEvent{}).Where("user_id = ?", user.ID).
+			Update("email", user.Email).Error; err != nil {
+			return err
+		}
+		// Queued and sent mail to the user, or naming them, such as the
+		// registration notices sent to admins
+		for _, address := range addresses {
+			if err := tx.Where(`LOWER("to") = ? OR strpos(LOWER(body), ?) > 0`, address, address).
+				Delete(&models.EmailOutbox{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// The IDs go into the audit entry so verifying the chain knows these
+		// sealed attempts were erased rather than tampered with
+		erasedAttempts := []uint{}
+		if err := tx.Unscoped().Model(&models.LoginAttempt{}).Where("LOWER(email) IN ?", addresses).
+			Pluck("id", &erasedAttempts).Error; err != nil {
+			return err
+		}
+		if len(erasedAttempts) > 0 {
+			if err := tx.Unscoped().Delete(&models.LoginAttempt{}, erasedAttempts).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+
+		details, err := json.Marshal(map[string][]uint{"erased_login_attempts": erasedAttempts})
+		if err != nil {
+			return err
+		}
+		return RecordAudit(tx, AuditEntry{
+			Action:     models.AuditUserAnonymized,
+			EntityType: "user",
+			EntityID:   &user.ID,
+			Details:    string(details),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files cannot be rolled back, so they go only once the rest committed
+	for _, key := range avatarKeys {
+		if key == nil {
+			continue
+		}
+		if err := storage.Get().Delete(*key); err != nil {
+			log.Printf("Failed to delete avatar %s of anonymized user %d: %v", *key, user.ID, err)
+		}
+	}
+	return nil
+}
+
+// ProcessScheduledDeletions anonymizes every account whose deletion grace
+// period has ended.
+func ProcessScheduledDeletions() {
+	var users []models.User
+	err := database.DB.Where("deletion_scheduled_at <= ? AND anonymized_at IS NULL", time.Now()).
+		Find(&users).Error
+	if err != nil {
+		log.Printf("Failed to load scheduled account deletions: %v", err)
+		return
+	}
+
+	for i := range users {
+		if err := AnonymizeUser(database.DB, &users[i]); err != nil {
+			log.Printf("Failed to anonymize user %d: %v", users[i].ID, err)
+			continue
+		}
+		log.Printf("Anonymized user %d after deletion grace period", users[i].ID)
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// newActivity starts a timeline entry for application, happening now.
+func newActivity(application *models.Application, activityType models.ActivityType, actorID *uint) *models.ApplicationActivity {
+	return &models.ApplicationActivity{
+		ApplicationID: application.ID,
+		CandidateID:   application.CandidateID,
+		JobID:         application.JobID,
+		Type:          activityType,
+		ActorID:       actorID,
+		OccurredAt:    time.Now(),
+	}
+}
+
+// RecordApplied starts the timeline of a new application in stage.
+func RecordApplied(tx *gorm.DB, application *models.Application, stage *models.JobStage, actorID *uint) error {
+	activity := newActivity(application, models.ActivityApplied, actorID)
+	activity.ToStageID = &stage.ID
+	activity.ToStage = stage.Name
+	activity.ToStatus = application.Status
+	activity.OccurredAt = application.AppliedAt
+	return tx.Create(activity).Error
+}
+
+// LogApplicationActivity adds a note, email, interview or scorecard to the
+// timeline of application.
+func LogApplicationActivity(tx *gorm.DB, application *models.Application, activityType models.ActivityType,
+	actorID *uint, body string, details map[string]interface{}, occurredAt *time.Time) (*models.ApplicationActivity, error) {
+	activity := newActivity(application, activityType, actorID)
+	activity.Body = body
+	activity.Details = details
+	if occurredAt != nil {
+		activity.OccurredAt = *occurredAt
+	}
+	return activity, tx.Create(activity).Error
+}
+
+func recordStatusChange(tx *gorm.DB, application *models.Application, from models.ApplicationStatus,
+	actorID *uint, note string, details map[string]interface{}) error {
+	activity := newActivity(application, models.ActivityStatusChanged, actorID)
+	activity.FromStatus = from
+	activity.ToStatus = application.Status
+	activity.Body = note
+	activity.Details = details
+	return tx.Create(activity).Error
+}
+
+// recordStageMoves adds a stage_changed entry for every application still
+// in stage from, before they are moved to stage to in bulk.
+func recordStageMoves(tx *gorm.DB, from, to *models.JobStage, actorID *uint, reason string) error {
+	var applications []models.Application
+	if err := tx.Select("id", "candidate_id", "job_id").
+		Where("stage_id = ?", from.ID).
+		Find(&applications).Error; err != nil {
+		return err
+	}
+	if len(applications) == 0 {
+		return nil
+	}
+
+	activities := make([]models.ApplicationActivity, len(applications))
+	for i := range applications {
+		activity := newActivity(&applications[i], models.ActivityStageChanged, actorID)
+		activity.FromStageID = &from.ID
+		activity.FromStage = from.Name
+		activity.ToStageID = &to.ID
+		activity.ToStage = to.Name
+		activity.Details = map[string]interface{}{"reason": reason}
+		activities[i] = *activity
+	}
+	return tx.Create(&activities).Error
+}
+
+// StageSpan is one stretch of time an application spent in a stage. Left
+// is nil while it is still there.
+type StageSpan struct {
+	StageID *uint      `json:"stage_id"`
+	Stage   string     `json:"stage"`
+	Entered time.Time  `json:"entered_at"`
+	Left    *time.Time `json:"left_at"`
+	Seconds int64      `json:"seconds"`
+}
+
+// TimeInStage computes the stays of an application in its stages from its
+// timeline, oldest first. Time spent rejected or withdrawn does not count;
+// reopening starts a new stay in the same stage.
+func TimeInStage(activities []models.ApplicationActivity, now time.Time) []StageSpan {
+	spans := []StageSpan{}
+	var current *StageSpan
+	closeCurrent := func(at time.Time) {
+		if current == nil {
+			return
+		}
+		left := at
+		current.Left = &left
+		current.Seconds = int64(at.Sub(current.Entered).Seconds())
+		spans = append(spans, *current)
+		current = nil
+	}
+	lastStageID, lastStage := (*uint)(nil), ""
+
+	for _, activity := range activities {
+		switch activity.Type {
+		case models.ActivityApplied, models.ActivityStageChanged:
+			closeCurrent(activity.OccurredAt)
+			lastStageID, lastStage = activity.ToStageID, activity.ToStage
+			current = &StageSpan{StageID: lastStageID, Stage: lastStage, Entered: activity.OccurredAt}
+		case models.ActivityStatusChanged:
+			switch activity.ToStatus {
+			case models.ApplicationRejected, models.ApplicationWithdrawn:
+				closeCurrent(activity.OccurredAt)
+			case models.ApplicationActive:
+				if current == nil && lastStage != "" {
+					current = &StageSpan{StageID: lastStageID, Stage: lastStage, Entered: activity.OccurredAt}
+				}
+			}
+		}
+	}
+
+	if current != nil {
+		current.Seconds = int64(now.Sub(current.Entered).Seconds())
+		spans = append(spans, *current)
+	}
+	return spans
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxFormQuestions   = 50
+	maxQuestionOptions = 50
+	maxOptionLength    = 200
+	maxShortAnswer     = 500
+	maxLongAnswer      = 10000
+)
+
+// yesNoAnswers are the answers to yes/no questions
+var yesNoAnswers = []string{"yes", "no"}
+
+// FormError is a question or answer that cannot be accepted. Its message
+// is meant for the user.
+type FormError string
+
+func (e FormError) Error() string {
+	return string(e)
+}
+
+// NormalizeQuestion trims a question and checks that it is complete and
+// that its knockout rule fits its type. Knockout questions have to be
+// required, so a blank answer cannot slip past them.
+func NormalizeQuestion(question *models.QuestionDefinition) error {
+	question.Label = strings.TrimSpace(question.Label)
+	question.HelpText = strings.TrimSpace(question.HelpText)
+	switch {
+	case question.Label == "":
+		return FormError("question label is required")
+	case len(question.Label) > 500:
+		return FormError("question label must be at most 500 characters")
+	case !question.Type.IsValid():
+		return FormError(fmt.Sprintf("%q is not a question type", question.Type))
+	}
+
+	if question.Type.HasOptions() {
+		options, err := normalizeOptions(question)
+		if err != nil {
+			return err
+		}
+		question.Options = options
+	} else {
+		question.Options = nil
+	}
+
+	rule := question.Knockout
+	if rule == nil {
+		return nil
+	}
+	if !question.Required {
+		return FormError(fmt.Sprintf("%q knocks out applications, so it has to be required", question.Label))
+	}
+
+	switch question.Type {
+	case models.QuestionYesNo, models.QuestionSingleChoice, models.QuestionMultipleChoice:
+		choices := question.Options
+		if question.Type == models.QuestionYesNo {
+			choices = yesNoAnswers
+		}
+		rejected := make([]string, 0, len(rule.RejectOptions))
+		for _, option := range rule.RejectOptions {
+			option = strings.TrimSpace(option)
+			if question.Type == models.QuestionYesNo {
+				option = strings.ToLower(option)
+			}
+			if !containsString(choices, option) {
+				return FormError(fmt.Sprintf("%q has no option %q to knock out on", question.Label, option))
+			}
+			if !containsString(rejected, option) {
+				rejected = append(rejected, option)
+			}
+		}
+		if len(rejected) == 0 {
+			return FormError(fmt.Sprintf("the knockout rule of %q needs options to reject", question.Label))
+		}
+		if question.Type != models.QuestionMultipleChoice && len(rejected) == len(choices) {
+			return FormError(fmt.Sprintf("the knockout rule of %q rejects every answer", question.Label))
+		}
+		question.Knockout = &models.KnockoutRule{RejectOptions: rejected}
+	case models.QuestionNumber:
+		if rule.Min == nil && rule.Max == nil {
+			return FormError(fmt.Sprintf("the knockout rule of %q needs a min or max", question.Label))
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return FormError(fmt.Sprintf("the knockout rule of %q has a min above its max", question.Label))
+		}
+		question.Knockout = &models.KnockoutRule{Min: rule.Min, Max: rule.Max}
+	default:
+		return FormError(fmt.Sprintf("%s questions cannot knock out applications", question.Type))
+	}
+	return nil
+}
+
+func normalizeOptions(question *models.QuestionDefinition) ([]string, error) {
+	if len(question.Options) < 2 || len(question.Options) > maxQuestionOptions {
+		return nil, FormError(fmt.Sprintf("%q needs between 2 and %d options", question.Label, maxQuestionOptions))
+	}
+	options := make([]string, 0, len(question.Options))
+	seen := make(map[string]bool, len(question.Options))
+	for _, option := range question.Options {
+		option = strings.TrimSpace(option)
+		switch {
+		case option == "":
+			return nil, FormError(fmt.Sprintf("options of %q cannot be blank", question.Label))
+		case len(option) > maxOptionLength:
+			return nil, FormError(fmt.Sprintf("options of %q must be at most %d characters", question.Label, maxOptionLength))
+		case seen[strings.ToLower(option)]:
+			return nil, FormError(fmt.Sprintf("%q has option %q twice", question.Label, option))
+		}
+		seen[strings.ToLower(option)] = true
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+// JobQuestions returns the questions of a job's application form in order.
+func JobQuestions(tx *gorm.DB, jobID uint) ([]models.JobQuestion, error) {
+	questions := []models.JobQuestion{}
+	err := tx.Where("job_id = ?", jobID).Order("position").Find(&questions).Error
+	return questions, err
+}
+
+// SaveJobForm replaces the questions of a job's application form with
+// questions, in their order. Questions with an ID change that question of
+// the form; the others are added. Questions left out are removed; answers
+// already given to them are kept. The caller should hold the job's lock.
+func SaveJobForm(tx *gorm.DB, jobID uint, questions []models.JobQuestion) ([]models.JobQuestion, error) {
+	if len(questions) > maxFormQuestions {
+		return nil, FormError(fmt.Sprintf("a form can have at most %d questions", maxFormQuestions))
+	}
+
+	current, err := JobQuestions(tx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.JobQuestion, len(current))
+	for i := range current {
+		byID[current[i].ID] = &current[i]
+	}
+
+	kept := make(map[uint]bool, len(questions))
+	for i := range questions {
+		question := questions[i]
+		if err := NormalizeQuestion(&question.QuestionDefinition); err != nil {
+			return nil, err
+		}
+
+		if question.ID == 0 {
+			question.JobID = jobID
+			question.Position = i + 1
+			if err := tx.Create(&question).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		existing, ok := byID[question.ID]
+		if !ok || kept[question.ID] {
+			return nil, FormError(fmt.Sprintf("question %d is not on this job's form, or listed twice", question.ID))
+		}
+		kept[question.ID] = true
+		existing.QuestionDefinition = question.QuestionDefinition
+		existing.Position = i + 1
+		if question.LibraryQuestionID != nil {
+			existing.LibraryQuestionID = question.LibraryQuestionID
+		}
+		if err := tx.Save(existing).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	removed := []uint{}
+	for _, question := range current {
+		if !kept[question.ID] {
+			removed = append(removed, question.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Delete(&models.JobQuestion{}, removed).Error; err != nil {
+			return nil, err
+		}
+	}
+	return JobQuestions(tx, jobID)
+}
+
+// ParseAnswer checks the values a candidate sent for a question and
+// returns them normalized. File questions are answered by a file instead,
+// hasFile tells whether one was sent. Blank answers to optional questions
+// give nil.
+func ParseAnswer(question *models.QuestionDefinition, values []string, hasFile bool) ([]string, error) {
+	if question.Type == models.QuestionFile {
+		if !hasFile && question.Required {
+			return nil, FormError(fmt.Sprintf("%q needs a file", question.Label))
+		}
+		return nil, nil
+	}
+
+	answers := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			answers = append(answers, value)
+		}
+	}
+	if len(answers) == 0 {
+		if question.Required {
+			return nil, FormError(fmt.Sprintf("%q is required", question.Label))
+		}
+		return nil, nil
+	}
+	if len(answers) > 1 && question.Type != models.QuestionMultipleChoice {
+		return nil, FormError(fmt.Sprintf("%q takes one answer", question.Label))
+	}
+	answer := answers[0]
+
+	switch question.Type {
+	case models.QuestionShortText:
+		if len(answer) > maxShortAnswer {
+			return nil, FormError(fmt.Sprintf("%q must be at most %d characters", question.Label, maxShortAnswer))
+		}
+	case models.QuestionLongText:
+		if len(answer) > maxLongAnswer {
+			return nil, FormError(fmt.Sprintf("%q must be at most %d characters", question.Label, maxLongAnswer))
+		}
+	case models.QuestionSingleChoice, models.QuestionMultipleChoice:
+		picked := make([]string, 0, len(answers))
+		for _, answer := range answers {
+			if !containsString(question.Options, answer) {
+				return nil, FormError(fmt.Sprintf("%q is not an option of %q", answer, question.Label))
+			}
+			if !containsString(picked, answer) {
+				picked = append(picked, answer)
+			}
+		}
+		return picked, nil
+	case models.QuestionYesNo:
+		answer = strings.ToLower(answer)
+		if !containsString(yesNoAnswers, answer) {
+			return nil, FormError(fmt.Sprintf("%q must be answered yes or no", question.Label))
+		}
+	case models.QuestionNumber:
+		number, err := strconv.ParseFloat(answer, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, FormError(fmt.Sprintf("%q must be a number", question.Label))
+		}
+		answer = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return []string{answer}, nil
+}
+
+// KnocksOut tells whether an answer from ParseAnswer rejects the
+// application under the question's knockout rule.
+func KnocksOut(question *models.QuestionDefinition, answer []string) bool {
+	rule := question.Knockout
+	if rule == nil || len(answer) == 0 {
+		return false
+	}
+
+	if question.Type == models.QuestionNumber {
+		number, err := strconv.ParseFloat(answer[0], 64)
+		if err != nil {
+			return false
+		}
+		return (rule.Min != nil && number < *rule.Min) || (rule.Max != nil && number > *rule.Max)
+	}
+	for _, value := range answer {
+		if containsString(rule.RejectOptions, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrApplicationNotActive = errors.New("application is not active, reopen it first")
+	ErrApplicationHired     = errors.New("application is hired, move it out of the hired stage first")
+	ErrStageNotInPipeline   = errors.New("stage is not part of the job's pipeline")
+)
+
+// LockApplication reloads application inside tx with a row lock, so
+// concurrent moves are applied one after another.
+func LockApplication(tx *gorm.DB, application *models.Application) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(application, application.ID).Error
+}
+
+// RefreshApplicantCount recounts the applications of a job.
+func RefreshApplicantCount(tx *gorm.DB, jobID uint) error {
+	return tx.Model(&models.Job{}).Unscoped().Where("id = ?", jobID).
+		UpdateColumn("applicant_count", tx.Model(&models.Application{}).
+			Select("COUNT(*)").
+			Where("job_id = ?", jobID)).Error
+}
+
+// MoveApplication moves an active or hired application to a stage of its
+// job's pipeline and records the move on its timeline. Moving into the
+// hired stage hires the candidate; moving out of it makes the application
+// active again.
+func MoveApplication(tx *gorm.DB, application *models.Application, stage *models.JobStage, actorID *uint) error {
+	if stage.JobID != application.JobID {
+		return ErrStageNotInPipeline
+	}
+	if application.Status != models.ApplicationActive && application.Status != models.ApplicationHired {
+		return ErrApplicationNotActive
+	}
+	if application.StageID == stage.ID {
+		return nil
+	}
+
+	var from models.JobStage
+	if err := tx.Select("id", "name").First(&from, application.StageID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	activity := newActivity(application, models.ActivityStageChanged, actorID)
+	activity.FromStageID = &from.ID
+	activity.FromStage = from.Name
+	activity.ToStageID = &stage.ID
+	activity.ToStage = stage.Name
+	activity.FromStatus = application.Status
+
+	application.StageID = stage.ID
+	application.Stage = stage
+	application.StageChangedAt = now
+	if stage.Type == models.StageHired {
+		application.Status = models.ApplicationHired
+		application.HiredAt = &now
+	} else {
+		application.Status = models.ApplicationActive
+		application.HiredAt = nil
+	}
+	activity.ToStatus = application.Status
+
+	if err := tx.Omit(clause.Associations).Save(application).Error; err != nil {
+		return err
+	}
+	return tx.Create(activity).Error
+}
+
+// RejectApplication takes an active application out of the pipeline.
+func RejectApplication(tx *gorm.DB, application *models.Application, reason models.RejectionReason, note string, actorID *uint) error {
+	if err := requireActive(application); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from := application.Status
+	application.Status = models.ApplicationRejected
+	application.RejectionReason = reason
+	application.RejectionNote = note
+	application.RejectedAt = &now
+	if err := tx.Omit(clause.Associations).Save(application).Error; err != nil {
+		return err
+	}
+	return recordStatusChange(tx, application, from, actorID, note, map[string]interface{}{"rejection_reason": reason})
+}
+
+// WithdrawApplication records that the candidate pulled out.
+func WithdrawApplication(tx *gorm.DB, application *models.Application, actorID *uint) error {
+	if err := requireActive(application); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from := application.Status
+	application.Status = models.ApplicationWithdrawn
+	application.WithdrawnAt = &now
+	if err := tx.Omit(clause.Associations).Save(application).Error; err != nil {
+		return err
+	}
+	return recordStatusChange(tx, application, from, actorID, "", nil)
+}
+
+// ReopenApplication puts a rejected or withdrawn application back into the
+// stage it left at.
+func ReopenApplication(tx *gorm.DB, application *models.Application, actorID *uint) error {
+	if application.Status == models.ApplicationActive {
+		return nil
+	}
+	if application.Status == models.ApplicationHired {
+		return ErrApplicationHired
+	}
+
+	from := application.Status
+	application.Status = models.ApplicationActive
+	application.RejectionReason = ""
+	application.RejectionNote = ""
+	application.RejectedAt = nil
+	application.WithdrawnAt = nil
+	if err := tx.Omit(clause.Associations).Save(application).Error; err != nil {
+		return err
+	}
+	return recordStatusChange(tx, application, from, actorID, "", nil)
+}
+
+func requireActive(application *models.Application) error {
+	switch application.Status {
+	case models.ApplicationActive:
+		return nil
+	case models.ApplicationHired:
+		return ErrApplicationHired
+	default:
+		return ErrApplicationNotActive
+	}
+}
@@ -0,0 +1,335 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// GenesisHash is the PrevHash of the first audit record.
+var GenesisHash = strings.Repeat("0", 64)
+
+// auditLockKey serialises appends to the chain across server instances.
+const auditLockKey = 0x6b616e6479 // "kandy"
+
+const auditVerifyBatchSize = 500
+
+// AuditEntry describes an event to append to the audit chain.
+type AuditEntry struct {
+	ActorID    *uint
+	Action     string
+	EntityType string
+	EntityID   *uint
+	Details    string
+	IPAddress  string
+}
+
+// AuditVerification is the result of walking the audit chain.
+type AuditVerification struct {
+	Valid              bool      `json:"valid"`
+	EntriesChecked     int64     `json:"entries_checked"`
+	CheckpointsChecked int       `json:"checkpoints_checked"`
+	HeadSequence       uint64    `json:"head_sequence"`
+	HeadHash           string    `json:"head_hash,omitempty"`
+	BrokenAtSequence   *uint64   `json:"broken_at_sequence,omitempty"`
+	Reason             string    `json:"reason,omitempty"`
+	PublicKey          string    `json:"public_key"`
+	VerifiedAt         time.Time `json:"verified_at"`
+}
+
+// RecordAudit appends an entry to the audit chain using db, which may be a
+// transaction so the audit row commits together with the change it describes.
+func RecordAudit(db *gorm.DB, entry AuditEntry) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+			return err
+		}
+
+		prevHash := GenesisHash
+		var sequence uint64 = 1
+
+		var last models.AuditLog
+		err := tx.Order("sequence DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+		if last.ID != 0 {
+			prevHash = last.Hash
+			sequence = last.Sequence + 1
+		}
+
+		record := models.AuditLog{
+			Sequence:   sequence,
+			ActorID:    entry.ActorID,
+			Action:     entry.Action,
+			EntityType: entry.EntityType,
+			EntityID:   entry.EntityID,
+			Details:    entry.Details,
+			IPAddress:  entry.IPAddress,
+			PrevHash:   prevHash,
+			// Postgres keeps microseconds; hash what will be read back
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		}
+		record.Hash = record.ComputeHash()
+
+		return tx.Create(&record).Error
+	})
+}
+
+// AuditEmailHash is how audit entries refer to an email address. The chain
+// cannot be edited once written, so it holds a keyed hash instead of the
+// address: erasing the user leaves nothing personal behind, while someone
+// holding the key can still tell which entries concern an address.
+func AuditEmailHash(email string) string {
+	key := sha256.Sum256([]byte("kandy-audit-email|" + getEnv("AUDIT_SIGNING_KEY", getEnv("JWT_SECRET", "your-secret-key-change-in-production"))))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditSigningKey derives the checkpoint signing key from AUDIT_SIGNING_KEY.
+func auditSigningKey() ed25519.PrivateKey {
+	secret := getEnv("AUDIT_SIGNING_KEY", getEnv("JWT_SECRET", "your-secret-key-change-in-production"))
+	seed := sha256.Sum256([]byte("kandy-audit|" + secret))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// AuditPublicKey returns the hex encoded key that verifies checkpoint signatures.
+func AuditPublicKey() string {
+	return hex.EncodeToString(auditSigningKey().Public().(ed25519.PublicKey))
+}
+
+func auditKeyID() string {
+	return AuditPublicKey()[:16]
+}
+
+// CreateAuditCheckpoint signs the current head of the chain. It is a no-op
+// when nothing has been appended since the previous checkpoint.
+func CreateAuditCheckpoint() (*models.AuditCheckpoint, error) {
+	var head models.AuditLog
+	if err := database.DB.Order("sequence DESC").Limit(1).Find(&head).Error; err != nil {
+		return nil, err
+	}
+	if head.ID == 0 {
+		return nil, nil
+	}
+
+	var previous models.AuditCheckpoint
+	if err := database.DB.Order("sequence DESC").Limit(1).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	if previous.ID != 0 && previous.Sequence == head.Sequence {
+		return nil, nil
+	}
+
+	checkpoint := models.AuditCheckpoint{
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		KeyID:     auditKeyID(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	signature := ed25519.Sign(auditSigningKey(), checkpoint.SigningPayload())
+	checkpoint.Signature = hex.EncodeToString(signature)
+
+	if err := database.DB.Create(&checkpoint).Error; err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// VerifyAuditChain walks the whole chain in order and reports the first
+// broken link, if any.
+func VerifyAuditChain() (*AuditVerification, error) {
+	result := &AuditVerification{
+		Valid:      true,
+		PublicKey:  AuditPublicKey(),
+		VerifiedAt: time.Now(),
+	}
+
+	fail := func(sequence uint64, reason string) {
+		if !result.Valid {
+			return
+		}
+		result.Valid = false
+		result.BrokenAtSequence = &sequence
+		result.Reason = reason
+	}
+
+	var checkpoints []models.AuditCheckpoint
+	if err := database.DB.Order("sequence ASC").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	publicKey := auditSigningKey().Public().(ed25519.PublicKey)
+	expected := make(map[uint64]string, len(checkpoints))
+	for _, cp := range checkpoints {
+		signature, err := hex.DecodeString(cp.Signature)
+		if err != nil || cp.KeyID != auditKeyID() || !ed25519.Verify(publicKey, cp.SigningPayload(), signature) {
+			fail(cp.Sequence, fmt.Sprintf("checkpoint %d has an invalid signature", cp.ID))
+			continue
+		}
+		expected[cp.Sequence] = cp.Hash
+	}
+	result.CheckpointsChecked = len(checkpoints)
+
+	erased, err := loadErasedLoginAttempts()
+	if err != nil {
+		return nil, err
+	}
+	// Attempts older than this may have been removed by retention cleanup
+	retentionCutoff := time.Now().Add(-LoginAttemptRetention)
+
+	prevHash := GenesisHash
+	var prevSequence uint64
+
+	var lastSeen uint64
+	for result.Valid {
+		var batch []models.AuditLog
+		err := database.DB.Where("sequence > ?", lastSeen).
+			Order("sequence ASC").
+			Limit(auditVerifyBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		attempts, err := loadAuditedLoginAttempts(batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range batch {
+			result.EntriesChecked++
+
+			switch {
+			case entry.Sequence != prevSequence+1:
+				fail(prevSequence+1, "record is missing from the chain")
+			case entry.PrevHash != prevHash:
+				fail(entry.Sequence, "previous hash does not match the preceding record")
+			case entry.ComputeHash() != entry.Hash:
+				fail(entry.Sequence, "record content does not match its hash")
+			}
+
+			if hash, ok := expected[entry.Sequence]; ok && hash != entry.Hash {
+				fail(entry.Sequence, "record does not match its signed checkpoint")
+			}
+
+			if entry.EntityType == "login_attempt" && entry.EntityID != nil {
+				attempt, ok := attempts[*entry.EntityID]
+				switch {
+				case !ok && !erased[*entry.EntityID] && !entry.CreatedAt.Before(retentionCutoff):
+					fail(entry.Sequence, "login attempt has been deleted before its retention period ended")
+				case ok && entry.Details != attempt.AuditDetails():
+					fail(entry.Sequence, "login attempt has been modified since it was recorded")
+				}
+			}
+
+			if !result.Valid {
+				break
+			}
+			prevHash = entry.Hash
+			prevSequence = entry.Sequence
+		}
+
+		lastSeen = batch[len(batch)-1].Sequence
+	}
+
+	if result.Valid {
+		for _, cp := range checkpoints {
+			if cp.Sequence > prevSequence {
+				fail(prevSequence+1, "chain is shorter than a signed checkpoint")
+				break
+			}
+		}
+	}
+
+	result.HeadSequence = prevSequence
+	if prevSequence > 0 {
+		result.HeadHash = prevHash
+	}
+
+	return result, nil
+}
+
+func loadAuditedLoginAttempts(entries []models.AuditLog) (map[uint]models.LoginAttempt, error) {
+	var ids []uint
+	for _, entry := range entries {
+		if entry.EntityType == "login_attempt" && entry.EntityID != nil {
+			ids = append(ids, *entry.EntityID)
+		}
+	}
+
+	attempts := make(map[uint]models.LoginAttempt, len(ids))
+	if len(ids) == 0 {
+		return attempts, nil
+	}
+
+	var rows []models.LoginAttempt
+	if err := database.DB.Unscoped().Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		attempts[row.ID] = row
+	}
+	return attempts, nil
+}
+
+// loadErasedLoginAttempts returns the IDs of login attempts deleted when
+// their user was anonymized. AnonymizeUser lists them in the details of its
+// entry, which the chain itself protects.
+func loadErasedLoginAttempts() (map[uint]bool, error) {
+	var details []string
+	err := database.DB.Model(&models.AuditLog{}).
+		Where("action = ? AND details IS NOT NULL", models.AuditUserAnonymized).
+		Pluck("details", &details).Error
+	if err != nil {
+		return nil, err
+	}
+
+	erased := map[uint]bool{}
+	for _, d := range details {
+		var anonymized struct {
+			ErasedLoginAttempts []uint `json:"erased_login_attempts"`
+		}
+		if json.Unmarshal([]byte(d), &anonymized) != nil {
+			continue
+		}
+		for _, id := range anonymized.ErasedLoginAttempts {
+			erased[id] = true
+		}
+	}
+	return erased, nil
+}
+
+func ScheduleAuditCheckpoints() {
+	interval, err := time.ParseDuration(getEnv("AUDIT_CHECKPOINT_INTERVAL", "1h"))
+	if err != nil || interval <= 0 {
+		log.Printf("Invalid AUDIT_CHECKPOINT_INTERVAL, falling back to 1h")
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			checkpoint, err := CreateAuditCheckpoint()
+			if err != nil {
+				log.Printf("Failed to create audit checkpoint: %v", err)
+			} else if checkpoint != nil {
+				log.Printf("Signed audit checkpoint at sequence %d", checkpoint.Sequence)
+			}
+		}
+	}()
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// Decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// MaxAvatarUploadSize is the largest avatar file accepted, in bytes
+	MaxAvatarUploadSize = 5 << 20
+
+	avatarSize         = 256
+	avatarThumbSize    = 64
+	avatarMinDimension = 32
+	// Checked before decoding so a small file cannot expand into a huge bitmap
+	avatarMaxDimension = 6000
+)
+
+var ErrInvalidAvatar = errors.New("avatar must be a JPEG, PNG, GIF or WebP image")
+
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ProcessedAvatar holds the JPEG encoded avatar and its thumbnail.
+type ProcessedAvatar struct {
+	Image     []byte
+	Thumbnail []byte
+}
+
+// ProcessAvatar validates an uploaded image, crops it to a centred square
+// and renders the avatar and thumbnail sizes. Re-encoding also strips any
+// metadata, such as EXIF location, from the upload.
+func ProcessAvatar(data []byte) (*ProcessedAvatar, error) {
+	if len(data) > MaxAvatarUploadSize {
+		return nil, fmt.Errorf("avatar must be smaller than %d MB", MaxAvatarUploadSize>>20)
+	}
+	if !avatarContentTypes[http.DetectContentType(data)] {
+		return nil, ErrInvalidAvatar
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidAvatar
+	}
+	if config.Width < avatarMinDimension || config.Height < avatarMinDimension {
+		return nil, fmt.Errorf("avatar must be at least %dx%d pixels", avatarMinDimension, avatarMinDimension)
+	}
+	if config.Width > avatarMaxDimension || config.Height > avatarMaxDimension {
+		return nil, fmt.Errorf("avatar must be at most %dx%d pixels", avatarMaxDimension, avatarMaxDimension)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidAvatar
+	}
+	square := centerSquare(src.Bounds())
+
+	avatar, err := renderAvatar(src, square, avatarSize)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := renderAvatar(src, square, avatarThumbSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessedAvatar{Image: avatar, Thumbnail: thumbnail}, nil
+}
+
+func centerSquare(bounds image.Rectangle) image.Rectangle {
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// renderAvatar scales the crop of src to size x size. Transparent areas
+// become white since JPEG has no alpha channel.
+func renderAvatar(src image.Image, crop image.Rectangle, size int) ([]byte, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
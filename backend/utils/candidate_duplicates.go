@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// duplicateThreshold is the score a pair needs to be queued for review.
+	// A similar name alone is not enough, a similar name and anything else
+	// is.
+	duplicateThreshold = 0.5
+	// maxDuplicateCandidates bounds how many candidates sharing a name
+	// token are compared
+	maxDuplicateCandidates = 200
+)
+
+// duplicateScores is how strongly each kind of match alone suggests the
+// same person
+var duplicateScores = map[string]float64{
+	models.DuplicateEmail:       1,
+	models.DuplicatePhone:       0.9,
+	models.DuplicateResume:      0.9,
+	models.DuplicateName:        0.6,
+	models.DuplicateSimilarName: 0.4,
+}
+
+// DetectCandidateDuplicates compares a candidate with everyone else by
+// email, phone, name and the files of their resumes, queues the pairs that
+// look like the same person for review and returns the pending ones, with
+// Candidate and Match loaded. Pairs someone merged or dismissed stay as
+// they are.
+func DetectCandidateDuplicates(db *gorm.DB, candidateID uint) ([]models.CandidateDuplicate, error) {
+	var candidate models.Candidate
+	if err := db.First(&candidate, candidateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	sharedResume, err := sharedResumeCandidates(db, candidate.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if candidate.EmailKey != "" {
+		conditions = append(conditions, "email_key = ?")
+		args = append(args, candidate.EmailKey)
+	}
+	if candidate.PhoneKey != "" {
+		conditions = append(conditions, "phone_key = ?")
+		args = append(args, candidate.PhoneKey)
+	}
+	if len(sharedResume) > 0 {
+		conditions = append(conditions, "id IN ?")
+		args = append(args, sharedResume)
+	}
+	for _, token := range candidate.NameTokens {
+		// Initials match too many people to narrow anything down
+		if len([]rune(token)) < 2 {
+			continue
+		}
+		contains, err := json.Marshal([]string{token})
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "name_tokens @> ?::jsonb")
+		args = append(args, string(contains))
+	}
+	if len(conditions) == 0 {
+		return []models.CandidateDuplicate{}, nil
+	}
+
+	var others []models.Candidate
+	if err := db.Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Where("id <> ?", candidate.ID).
+		Order("id DESC").
+		Limit(maxDuplicateCandidates).
+		Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	duplicates := []models.CandidateDuplicate{}
+	for i := range others {
+		other := &others[i]
+		reasons := duplicateReasons(&candidate, other, slices.Contains(sharedResume, other.ID))
+		score := duplicateScore(reasons)
+		if score < duplicateThreshold {
+			continue
+		}
+
+		pair := models.CandidateDuplicate{Score: score, Reasons: reasons, Status: models.DuplicatePending}
+		if candidate.ID > other.ID {
+			pair.CandidateID, pair.MatchID = candidate.ID, other.ID
+			pair.Candidate, pair.Match = &candidate, other
+		} else {
+			pair.CandidateID, pair.MatchID = other.ID, candidate.ID
+			pair.Candidate, pair.Match = other, &candidate
+		}
+		err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "candidate_id"}, {Name: "match_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "reasons", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: "candidate_duplicates", Name: "status"}, Value: models.DuplicatePending},
+			}},
+		}).Create(&pair).Error
+		if err != nil {
+			return nil, err
+		}
+		// Nothing comes back for pairs that were reviewed already
+		if pair.ID != 0 {
+			duplicates = append(duplicates, pair)
+		}
+	}
+	return duplicates, nil
+}
+
+// sharedResumeCandidates finds the other candidates who sent a resume with
+// the same content as one of candidateID's.
+func sharedResumeCandidates(db *gorm.DB, candidateID uint) ([]uint, error) {
+	ids := []uint{}
+	err := db.Table("attachments AS theirs").
+		Joins("JOIN applications AS their_applications ON their_applications.id = theirs.application_id AND their_applications.deleted_at IS NULL").
+		Joins("JOIN attachments AS ours ON ours.file_id = theirs.file_id AND ours.kind = ?", models.AttachmentResume).
+		Joins("JOIN applications AS our_applications ON our_applications.id = ours.application_id AND our_applications.deleted_at IS NULL").
+		Where("theirs.kind = ? AND our_applications.candidate_id = ? AND their_applications.candidate_id <> ?",
+			models.AttachmentResume, candidateID, candidateID).
+		Distinct().
+		Pluck("their_applications.candidate_id", &ids).Error
+	return ids, err
+}
+
+// duplicateReasons lists what two candidates have in common.
+func duplicateReasons(a, b *models.Candidate, sharedResume bool) []string {
+	reasons := []string{}
+	if a.EmailKey != "" && a.EmailKey == b.EmailKey {
+		reasons = append(reasons, models.DuplicateEmail)
+	}
+	if a.PhoneKey != "" && a.PhoneKey == b.PhoneKey {
+		reasons = append(reasons, models.DuplicatePhone)
+	}
+	if sharedResume {
+		reasons = append(reasons, models.DuplicateResume)
+	}
+	if name := nameMatch(a.NameTokens, b.NameTokens); name != "" {
+		reasons = append(reasons, name)
+	}
+	return reasons
+}
+
+// duplicateScore combines the scores of the reasons as independent
+// evidence: two weak matches together are stronger than either alone.
+func duplicateScore(reasons []string) float64 {
+	different := 1.0
+	for _, reason := range reasons {
+		different *= 1 - duplicateScores[reason]
+	}
+	return 1 - different
+}
+
+// nameMatch compares two tokenized names. Names with the same words are
+// the same name; names whose words all match words of the other, allowing
+// a typo, an initial or a missing middle name, are similar.
+func nameMatch(a, b []string) string {
+	if len(a) >= 2 && slices.Equal(a, b) {
+		return models.DuplicateName
+	}
+
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) < 2 {
+		return ""
+	}
+	used := make([]bool, len(long))
+	for _, token := range short {
+		found := false
+		for i, other := range long {
+			if !used[i] && similarNameToken(token, other) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ""
+		}
+	}
+	return models.DuplicateSimilarName
+}
+
+func similarNameToken(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	switch {
+	case a == b:
+		return true
+	case len(ra) == 1 || len(rb) == 1:
+		return ra[0] == rb[0]
+	case len(ra) >= 4 && len(rb) >= 4:
+		return editDistance(ra, rb) <= 1
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between two words.
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
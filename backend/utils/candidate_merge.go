@@ -0,0 +1,482 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrMergeSameCandidate = errors.New("a candidate cannot be merged into itself")
+	ErrMergeUndone        = errors.New("the merge was undone already")
+	ErrMergeUndoExpired   = errors.New("the merge is too old to be undone")
+	ErrMergeCandidateGone = errors.New("one of the candidates was deleted since the merge")
+)
+
+const (
+	mergeMaxLinks  = 10
+	mergeMaxSkills = 100
+)
+
+// mergedFields are the candidate fields a merge may fill in, keyed like
+// their JSON
+var mergedFields = []string{
+	"email", "phone", "location", "current_title", "current_company",
+	"links", "work_history", "education", "skills", "parsed_fields", "source_detail",
+	"tags", "custom_fields",
+}
+
+// MergeUndoWindow is how long after a merge it can be undone.
+func MergeUndoWindow() time.Duration {
+	return durationFromEnv("CANDIDATE_MERGE_UNDO_WINDOW", 30*24*time.Hour)
+}
+
+// MergeCandidates merges duplicate into survivor: applications, with
+// their timeline, files and answers, move to survivor, fields survivor is
+// missing are filled in from duplicate and duplicate is deleted. Where
+// both applied to the same job, duplicate's files and timeline are folded
+// into survivor's application. The returned record is what
+// UndoCandidateMerge needs to take it back.
+func MergeCandidates(tx *gorm.DB, survivor, duplicate *models.Candidate, actorID *uint) (*models.CandidateMerge, error) {
+	if survivor.ID == duplicate.ID {
+		return nil, ErrMergeSameCandidate
+	}
+	if err := lockCandidatePair(tx, survivor, duplicate, false); err != nil {
+		return nil, err
+	}
+
+	changes := models.CandidateMergeChanges{
+		MovedApplications:  []uint{},
+		FoldedApplications: []models.FoldedApplication{},
+		Fields:             map[string]models.MergedField{},
+	}
+
+	var ours, theirs []models.Application
+	if err := tx.Where("candidate_id = ?", survivor.ID).Find(&ours).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("candidate_id = ?", duplicate.ID).Order("id").Find(&theirs).Error; err != nil {
+		return nil, err
+	}
+	ourJobs := make(map[uint]*models.Application, len(ours))
+	for i := range ours {
+		ourJobs[ours[i].JobID] = &ours[i]
+	}
+
+	for i := range theirs {
+		application := &theirs[i]
+		into, ok := ourJobs[application.JobID]
+		if !ok {
+			changes.MovedApplications = append(changes.MovedApplications, application.ID)
+			continue
+		}
+		folded, err := foldApplication(tx, application, into)
+		if err != nil {
+			return nil, err
+		}
+		changes.FoldedApplications = append(changes.FoldedApplications, *folded)
+		if err := RefreshApplicantCount(tx, application.JobID); err != nil {
+			return nil, err
+		}
+	}
+	if len(changes.MovedApplications) > 0 {
+		if err := tx.Model(&models.Application{}).
+			Where("id IN ?", changes.MovedApplications).
+			UpdateColumn("candidate_id", survivor.ID).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.ApplicationActivity{}).
+			Where("application_id IN ?", changes.MovedApplications).
+			UpdateColumn("candidate_id", survivor.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	before, err := mergedFieldValues(survivor)
+	if err != nil {
+		return nil, err
+	}
+	fillFromDuplicate(survivor, duplicate)
+	after, err := mergedFieldValues(survivor)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range mergedFields {
+		if !bytes.Equal(before[field], after[field]) {
+			changes.Fields[field] = models.MergedField{Before: before[field], After: after[field]}
+		}
+	}
+	if err := tx.Save(survivor).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Delete(duplicate).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	newer, older := duplicatePair(survivor.ID, duplicate.ID)
+	if err := tx.Model(&models.CandidateDuplicate{}).
+		Where("candidate_id = ? AND match_id = ?", newer, older).
+		Updates(map[string]interface{}{
+			"status":      models.DuplicateMerged,
+			"reviewed_by": actorID,
+			"reviewed_at": now,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	merge := &models.CandidateMerge{
+		CandidateID: survivor.ID,
+		MergedID:    duplicate.ID,
+		ActorID:     actorID,
+		Changes:     changes,
+	}
+	return merge, tx.Create(merge).Error
+}
+
+// UndoCandidateMerge takes a merge back: the merged candidate is restored
+// with their applications, folded files and timeline entries. Fields that
+// were filled in go back to how they were unless someone changed them
+// since. Applications and entries added after the merge stay with the
+// surviving candidate.
+func UndoCandidateMerge(tx *gorm.DB, merge *models.CandidateMerge, actorID *uint) (*models.Candidate, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(merge, merge.ID).Error; err != nil {
+		return nil, err
+	}
+	if merge.UndoneAt != nil {
+		return nil, ErrMergeUndone
+	}
+	if time.Since(merge.CreatedAt) > MergeUndoWindow() {
+		return nil, ErrMergeUndoExpired
+	}
+
+	survivor := &models.Candidate{ID: merge.CandidateID}
+	duplicate := &models.Candidate{ID: merge.MergedID}
+	if err := lockCandidatePair(tx, survivor, duplicate, true); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMergeCandidateGone
+		}
+		return nil, err
+	}
+	if survivor.DeletedAt.Valid || !duplicate.DeletedAt.Valid {
+		return nil, ErrMergeCandidateGone
+	}
+
+	duplicate.DeletedAt = gorm.DeletedAt{}
+	if err := tx.Unscoped().Save(duplicate).Error; err != nil {
+		return nil, err
+	}
+
+	changes := merge.Changes
+	if len(changes.MovedApplications) > 0 {
+		if err := tx.Model(&models.Application{}).
+			Where("id IN ? AND candidate_id = ?", changes.MovedApplications, survivor.ID).
+			UpdateColumn("candidate_id", duplicate.ID).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.ApplicationActivity{}).
+			Where("application_id IN ? AND candidate_id = ?", changes.MovedApplications, survivor.ID).
+			UpdateColumn("candidate_id", duplicate.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, folded := range changes.FoldedApplications {
+		if err := unfoldApplication(tx, &folded, duplicate.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := restoreMergedFields(survivor, changes.Fields); err != nil {
+		return nil, err
+	}
+	if err := tx.Save(survivor).Error; err != nil {
+		return nil, err
+	}
+
+	newer, older := duplicatePair(survivor.ID, duplicate.ID)
+	if err := tx.Model(&models.CandidateDuplicate{}).
+		Where("candidate_id = ? AND match_id = ? AND status = ?", newer, older, models.DuplicateMerged).
+		Updates(map[string]interface{}{
+			"status":      models.DuplicatePending,
+			"reviewed_by": nil,
+			"reviewed_at": nil,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	merge.UndoneAt = &now
+	merge.UndoneBy = actorID
+	return duplicate, tx.Save(merge).Error
+}
+
+// lockCandidatePair loads both candidates with a row lock, in id order so
+// two merges of the same pair cannot deadlock.
+func lockCandidatePair(tx *gorm.DB, a, b *models.Candidate, withDeleted bool) error {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if withDeleted {
+		query = query.Unscoped()
+	}
+	var locked []models.Candidate
+	if err := query.Where("id IN ?", []uint{a.ID, b.ID}).Order("id").Find(&locked).Error; err != nil {
+		return err
+	}
+	if len(locked) != 2 {
+		return gorm.ErrRecordNotFound
+	}
+	for i := range locked {
+		if locked[i].ID == a.ID {
+			*a = locked[i]
+		} else {
+			*b = locked[i]
+		}
+	}
+	return nil
+}
+
+// duplicatePair orders two candidate ids like CandidateDuplicate
+func duplicatePair(a, b uint) (newer, older uint) {
+	if a > b {
+		return a, b
+	}
+	return b, a
+}
+
+// foldApplication moves the files and timeline of application into into,
+// an application to the same job, and deletes application. Files sent
+// with answers stay with the answers.
+func foldApplication(tx *gorm.DB, application, into *models.Application) (*models.FoldedApplication, error) {
+	folded := &models.FoldedApplication{
+		ApplicationID: application.ID,
+		IntoID:        into.ID,
+		AttachmentIDs: []uint{},
+		ActivityIDs:   []uint{},
+	}
+	if err := tx.Model(&models.Attachment{}).
+		Where("application_id = ? AND kind <> ?", application.ID, models.AttachmentAnswer).
+		Pluck("id", &folded.AttachmentIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.ApplicationActivity{}).
+		Where("application_id = ?", application.ID).
+		Pluck("id", &folded.ActivityIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(folded.AttachmentIDs) > 0 {
+		if err := tx.Model(&models.Attachment{}).
+			Where("id IN ?", folded.AttachmentIDs).
+			UpdateColumn("application_id", into.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(folded.ActivityIDs) > 0 {
+		if err := tx.Model(&models.ApplicationActivity{}).
+			Where("id IN ?", folded.ActivityIDs).
+			UpdateColumns(map[string]interface{}{
+				"application_id": into.ID,
+				"candidate_id":   into.CandidateID,
+			}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if into.ResumeID == nil && application.ResumeID != nil {
+		folded.ResumeID = application.ResumeID
+		if err := tx.Model(into).UpdateColumn("resume_id", *application.ResumeID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return folded, tx.Delete(application).Error
+}
+
+// unfoldApplication restores a folded application and takes back its
+// files and timeline entries.
+func unfoldApplication(tx *gorm.DB, folded *models.FoldedApplication, candidateID uint) error {
+	if err := tx.Unscoped().Model(&models.Application{}).
+		Where("id = ?", folded.ApplicationID).
+		UpdateColumn("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	if len(folded.AttachmentIDs) > 0 {
+		if err := tx.Model(&models.Attachment{}).
+			Where("id IN ? AND application_id = ?", folded.AttachmentIDs, folded.IntoID).
+			UpdateColumn("application_id", folded.ApplicationID).Error; err != nil {
+			return err
+		}
+	}
+	if len(folded.ActivityIDs) > 0 {
+		if err := tx.Model(&models.ApplicationActivity{}).
+			Where("id IN ? AND application_id = ?", folded.ActivityIDs, folded.IntoID).
+			UpdateColumns(map[string]interface{}{
+				"application_id": folded.ApplicationID,
+				"candidate_id":   candidateID,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	if folded.ResumeID != nil {
+		if err := tx.Model(&models.Application{}).
+			Where("id = ? AND resume_id = ?", folded.IntoID, *folded.ResumeID).
+			UpdateColumn("resume_id", nil).Error; err != nil {
+			return err
+		}
+	}
+
+	var jobID uint
+	if err := tx.Model(&models.Application{}).Where("id = ?", folded.ApplicationID).
+		Pluck("job_id", &jobID).Error; err != nil {
+		return err
+	}
+	return RefreshApplicantCount(tx, jobID)
+}
+
+// fillFromDuplicate fills in what survivor is missing from duplicate, like
+// ApplyParsedProfile does from a resume, and adds duplicate's links and
+// skills. Fields duplicate had from a resume keep the parser's confidence.
+func fillFromDuplicate(survivor, duplicate *models.Candidate) {
+	if survivor.ParsedFields == nil {
+		survivor.ParsedFields = map[string]float64{}
+	}
+	took := func(field string) {
+		if confidence, ok := duplicate.ParsedFields[field]; ok {
+			survivor.ParsedFields[field] = confidence
+		}
+	}
+	fill := func(field string, value *string, theirs string) {
+		if *value == "" && theirs != "" {
+			*value = theirs
+			took(field)
+		}
+	}
+
+	fill("email", &survivor.Email, duplicate.Email)
+	fill("phone", &survivor.Phone, duplicate.Phone)
+	fill("location", &survivor.Location, duplicate.Location)
+	if survivor.CurrentTitle == "" && survivor.CurrentCompany == "" {
+		fill("current_title", &survivor.CurrentTitle, duplicate.CurrentTitle)
+		fill("current_company", &survivor.CurrentCompany, duplicate.CurrentCompany)
+	}
+	if survivor.SourceDetail == "" {
+		survivor.SourceDetail = duplicate.SourceDetail
+	}
+	if len(survivor.WorkHistory) == 0 && len(duplicate.WorkHistory) > 0 {
+		survivor.WorkHistory = duplicate.WorkHistory
+		took("work_history")
+	}
+	if len(survivor.Education) == 0 && len(duplicate.Education) > 0 {
+		survivor.Education = duplicate.Education
+		took("education")
+	}
+
+	for _, link := range duplicate.Links {
+		if len(survivor.Links) >= mergeMaxLinks {
+			break
+		}
+		known := false
+		for _, existing := range survivor.Links {
+			if strings.EqualFold(existing.URL, link.URL) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			survivor.Links = append(survivor.Links, link)
+		}
+	}
+	for _, skill := range duplicate.Skills {
+		if len(survivor.Skills) >= mergeMaxSkills {
+			break
+		}
+		known := false
+		for _, existing := range survivor.Skills {
+			if strings.EqualFold(existing, skill) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			survivor.Skills = append(survivor.Skills, skill)
+		}
+	}
+	for _, tag := range duplicate.Tags {
+		if len(survivor.Tags) >= maxTags {
+			break
+		}
+		if !slices.Contains(survivor.Tags, tag) {
+			survivor.Tags = append(survivor.Tags, tag)
+		}
+	}
+	for key, value := range duplicate.CustomFields {
+		if _, ok := survivor.CustomFields[key]; !ok {
+			if survivor.CustomFields == nil {
+				survivor.CustomFields = map[string]interface{}{}
+			}
+			survivor.CustomFields[key] = value
+		}
+	}
+}
+
+// mergedFieldValues returns the JSON of the fields a merge may change.
+func mergedFieldValues(candidate *models.Candidate) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(candidate)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	values := make(map[string]json.RawMessage, len(mergedFields))
+	for _, field := range mergedFields {
+		values[field] = all[field]
+	}
+	return values, nil
+}
+
+// restoreMergedFields sets the fields a merge filled in back to their
+// value before it, where they still hold what the merge put there.
+func restoreMergedFields(candidate *models.Candidate, fields map[string]models.MergedField) error {
+	current, err := mergedFieldValues(candidate)
+	if err != nil {
+		return err
+	}
+	restore := map[string]json.RawMessage{}
+	for field, change := range fields {
+		if sameJSON(current[field], change.After) {
+			restore[field] = change.Before
+		}
+	}
+	if len(restore) == 0 {
+		return nil
+	}
+	// Unmarshalling adds to a map rather than replacing it
+	if _, ok := restore["parsed_fields"]; ok {
+		candidate.ParsedFields = nil
+	}
+	if _, ok := restore["custom_fields"]; ok {
+		candidate.CustomFields = nil
+	}
+	data, err := json.Marshal(restore)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, candidate)
+}
+
+// sameJSON compares two JSON values by what they hold, as jsonb does not
+// keep the key order and spacing they were written with.
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
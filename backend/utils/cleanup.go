@@ -9,7 +9,10 @@ import (
 )
 
 func CleanupExpiredSessions() {
-	result := database.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.Session{})
+	idleCutoff := time.Now().Add(-GetSessionPolicy().IdleTimeout)
+	result := database.DB.Unscoped().
+		Where("expires_at < ? OR last_used_at < ?", time.Now(), idleCutoff).
+		Delete(&models.Session{})
 	if result.Error != nil {
 		log.Printf("Failed to cleanup expired sessions: %v", result.Error)
 	} else if result.RowsAffected > 0 {
@@ -17,9 +20,12 @@ func CleanupExpiredSessions() {
 	}
 }
 
+// LoginAttemptRetention is how long login attempts are kept.
+const LoginAttemptRetention = 30 * 24 * time.Hour
+
 func CleanupOldLoginAttempts() {
-	thirtyDaysAgo := time.Now().Add(-30 * 24 * time.Hour)
-	result := database.DB.Unscoped().Where("created_at < ?", thirtyDaysAgo).Delete(&models.LoginAttempt{})
+	cutoff := time.Now().Add(-LoginAttemptRetention)
+	result := database.DB.Unscoped().Where("created_at < ?", cutoff).Delete(&models.LoginAttempt{})
 	if result.Error != nil {
 		log.Printf("Failed to cleanup old login attempts: %v", result.Error)
 	} else if result.RowsAffected > 0 {
@@ -27,9 +33,28 @@ func CleanupOldLoginAttempts() {
 	}
 }
 
+func CleanupExpiredMagicLinks() {
+	result := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.MagicLinkToken{})
+	if result.Error != nil {
+		log.Printf("Failed to cleanup expired magic links: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Cleaned up %d expired magic links", result.RowsAffected)
+	}
+
+	// Only the last 15 minutes count towards the request limit
+	result = database.DB.Where("created_at < ?", time.Now().Add(-time.Hour)).Delete(&models.MagicLinkRequestLog{})
+	if result.Error != nil {
+		log.Printf("Failed to cleanup magic link request logs: %v", result.Error)
+	}
+}
+
 func ScheduleCleanup() {
 	CleanupExpiredSessions()
 	CleanupOldLoginAttempts()
+	CleanupExpiredMagicLinks()
+	CleanupUnusedFiles()
+	CleanupSearchIndexEvents()
+	ProcessScheduledDeletions()
 
 	// Schedule cleanup to run every 24 hours
 	ticker := time.NewTicker(24 * time.Hour)
@@ -37,6 +62,10 @@ func ScheduleCleanup() {
 		for range ticker.C {
 			CleanupExpiredSessions()
 			CleanupOldLoginAttempts()
+			CleanupExpiredMagicLinks()
+			CleanupUnusedFiles()
+			CleanupSearchIndexEvents()
+			ProcessScheduledDeletions()
 		}
 	}()
 }
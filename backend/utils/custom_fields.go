@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTags               = 30
+	maxTagLength          = 50
+	maxCustomFieldOptions = 100
+	maxCustomTextLength   = 1000
+)
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// CustomFieldError is a tag, custom field or value that cannot be
+// accepted. Its message is meant for the user.
+type CustomFieldError string
+
+func (e CustomFieldError) Error() string {
+	return string(e)
+}
+
+// NormalizeTags trims tags, lower-cases them and drops blanks and
+// repeats, so filtering by a tag finds it however it was typed.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, CustomFieldError(fmt.Sprintf("tags must be at most %d characters", maxTagLength))
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, CustomFieldError(fmt.Sprintf("at most %d tags", maxTags))
+	}
+	return normalized, nil
+}
+
+// NormalizeCustomField trims a field definition and checks that it is
+// complete. Fields with options need at least one.
+func NormalizeCustomField(field *models.CustomField) error {
+	field.Key = strings.TrimSpace(field.Key)
+	field.Label = strings.TrimSpace(field.Label)
+	field.HelpText = strings.TrimSpace(field.HelpText)
+
+	switch {
+	case !field.Entity.IsValid():
+		return CustomFieldError("entity must be candidate, application or job")
+	case !customFieldKeyPattern.MatchString(field.Key):
+		return CustomFieldError("key must be 1 to 50 lower case letters, digits or _, starting with a letter")
+	case field.Label == "":
+		return CustomFieldError("label is required")
+	case len(field.Label) > 100:
+		return CustomFieldError("label must be at most 100 characters")
+	case len(field.HelpText) > 500:
+		return CustomFieldError("help text must be at most 500 characters")
+	case !field.Type.IsValid():
+		return CustomFieldError("type must be text, number, date, select or multi_select")
+	}
+
+	if !field.Type.HasOptions() {
+		field.Options = []string{}
+		return nil
+	}
+	if len(field.Options) == 0 || len(field.Options) > maxCustomFieldOptions {
+		return CustomFieldError(fmt.Sprintf("%q needs between 1 and %d options", field.Label, maxCustomFieldOptions))
+	}
+	options := make([]string, 0, len(field.Options))
+	seen := make(map[string]bool, len(field.Options))
+	for _, option := range field.Options {
+		option = strings.TrimSpace(option)
+		switch {
+		case option == "":
+			return CustomFieldError(fmt.Sprintf("options of %q cannot be blank", field.Label))
+		case len(option) > maxOptionLength:
+			return CustomFieldError(fmt.Sprintf("options of %q must be at most %d characters", field.Label, maxOptionLength))
+		case seen[strings.ToLower(option)]:
+			return CustomFieldError(fmt.Sprintf("%q has option %q twice", field.Label, option))
+		}
+		seen[strings.ToLower(option)] = true
+		options = append(options, option)
+	}
+	field.Options = options
+	return nil
+}
+
+// CustomFieldsFor returns the custom fields of an entity in order.
+func CustomFieldsFor(db *gorm.DB, entity models.CustomFieldEntity) ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+	err := db.Where("entity = ?", entity).Order("position, id").Find(&fields).Error
+	return fields, err
+}
+
+// ApplyCustomFields validates changes to the custom fields of a record and
+// returns its values with them applied. Fields left out of changes keep
+// their value; null or an empty value clears one. When creating, required
+// fields have to end up with a value.
+func ApplyCustomFields(fields []models.CustomField, values map[string]interface{}, changes map[string]json.RawMessage, creating bool) (map[string]interface{}, error) {
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+
+	result := make(map[string]interface{}, len(values)+len(changes))
+	for key, value := range values {
+		result[key] = value
+	}
+
+	// Sorted, so the first problem reported does not change between
+	// requests
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, ok := byKey[key]
+		if !ok {
+			return nil, CustomFieldError(fmt.Sprintf("unknown custom field %q", key))
+		}
+		value, err := ParseCustomFieldValue(field, changes[key])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			if field.Required {
+				return nil, CustomFieldError(fmt.Sprintf("%s is required", field.Label))
+			}
+			delete(result, key)
+			continue
+		}
+		result[key] = value
+	}
+
+	if creating {
+		for _, field := range fields {
+			if _, ok := result[field.Key]; field.Required && !ok {
+				return nil, CustomFieldError(fmt.Sprintf("%s is required", field.Label))
+			}
+		}
+	}
+	return result, nil
+}
+
+// ParseCustomFieldValue checks a value sent for field and returns it the
+// way it is stored, or nil when it is empty. Options are matched ignoring
+// case.
+func ParseCustomFieldValue(field *models.CustomField, raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case models.CustomFieldText:
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be text", field.Label))
+		}
+		text = strings.TrimSpace(text)
+		if len(text) > maxCustomTextLength {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be at most %d characters", field.Label, maxCustomTextLength))
+		}
+		if text == "" {
+			return nil, nil
+		}
+		return text, nil
+
+	case models.CustomFieldNumber:
+		var number float64
+		if err := json.Unmarshal(raw, &number); err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be a number", field.Label))
+		}
+		return number, nil
+
+	case models.CustomFieldDate:
+		var date string
+		if err := json.Unmarshal(raw, &date); err != nil {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be a date like 2006-01-02", field.Label))
+		}
+		if date = strings.TrimSpace(date); date == "" {
+			return nil, nil
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be a date like 2006-01-02", field.Label))
+		}
+		return date, nil
+
+	case models.CustomFieldSelect:
+		var choice string
+		if err := json.Unmarshal(raw, &choice); err != nil {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be one of its options", field.Label))
+		}
+		if strings.TrimSpace(choice) == "" {
+			return nil, nil
+		}
+		option, ok := customFieldOption(field, choice)
+		if !ok {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be one of: %s", field.Label, strings.Join(field.Options, ", ")))
+		}
+		return option, nil
+
+	case models.CustomFieldMultiSelect:
+		var choices []string
+		if err := json.Unmarshal(raw, &choices); err != nil {
+			return nil, CustomFieldError(fmt.Sprintf("%s must be a list of its options", field.Label))
+		}
+		picked := map[string]bool{}
+		for _, choice := range choices {
+			option, ok := customFieldOption(field, choice)
+			if !ok {
+				return nil, CustomFieldError(fmt.Sprintf("%s must be picked from: %s", field.Label, strings.Join(field.Options, ", ")))
+			}
+			picked[option] = true
+		}
+		if len(picked) == 0 {
+			return nil, nil
+		}
+		// Kept in the order of the options
+		options := []string{}
+		for _, option := range field.Options {
+			if picked[option] {
+				options = append(options, option)
+			}
+		}
+		return options, nil
+	}
+	return nil, CustomFieldError(fmt.Sprintf("%s has an unknown type", field.Label))
+}
+
+// customFieldOption finds the option of field matching choice, ignoring
+// case.
+func customFieldOption(field *models.CustomField, choice string) (string, bool) {
+	choice = strings.TrimSpace(choice)
+	for _, option := range field.Options {
+		if strings.EqualFold(option, choice) {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// CustomFieldText formats a stored value for exports: numbers without
+// trailing zeros and multi-select options separated by "; ".
+func CustomFieldText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, "; ")
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = fmt.Sprint(part)
+		}
+		return strings.Join(parts, "; ")
+	}
+	return fmt.Sprint(value)
+}
+
+// CustomFieldModel returns the model of the records of entity.
+func CustomFieldModel(entity models.CustomFieldEntity) interface{} {
+	switch entity {
+	case models.CustomFieldApplication:
+		return &models.Application{}
+	case models.CustomFieldJob:
+		return &models.Job{}
+	default:
+		return &models.Candidate{}
+	}
+}
+
+// RemoveCustomFieldValues clears a deleted field from every record of its
+// entity, so a new field can reuse the key.
+func RemoveCustomFieldValues(tx *gorm.DB, field *models.CustomField) error {
+	return tx.Model(CustomFieldModel(field.Entity)).Unscoped().
+		Where("custom_fields -> ? IS NOT NULL", field.Key).
+		UpdateColumn("custom_fields", gorm.Expr("custom_fields - ?", field.Key)).Error
+}
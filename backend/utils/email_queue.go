@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"log"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailBatchSize   = 50
+	emailMaxAttempts = 5
+	// A claimed email not sent by then is taken to be abandoned
+	emailClaimTimeout = 10 * time.Minute
+)
+
+// QueueEmail adds an email to the outbox using db, which may be a
+// transaction. The email worker delivers it shortly after it commits.
+func QueueEmail(db *gorm.DB, email Email) error {
+	return db.Create(&models.EmailOutbox{
+		To:        email.To,
+		Subject:   email.Subject,
+		Body:      email.Body,
+		Status:    models.EmailPending,
+		SendAfter: time.Now(),
+	}).Error
+}
+
+// DeliverQueuedEmails sends the next batch of pending emails. Failed sends
+// are retried with exponential backoff until emailMaxAttempts is reached.
+func DeliverQueuedEmails() {
+	batch, err := claimQueuedEmails()
+	if err != nil {
+		log.Printf("Failed to process email queue: %v", err)
+		return
+	}
+
+	for i := range batch {
+		deliverQueuedEmail(&batch[i])
+	}
+}
+
+// claimQueuedEmails marks the next batch of pending emails as sending and
+// counts the attempt, so talking to the mail server happens outside any
+// transaction. Emails left sending by a worker that stopped are claimed
+// again after emailClaimTimeout.
+func claimQueuedEmails() ([]models.EmailOutbox, error) {
+	var batch []models.EmailOutbox
+
+	// SKIP LOCKED lets several server instances drain the queue together
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND send_after <= ?) OR (status = ? AND updated_at < ?)",
+				models.EmailPending, now, models.EmailSending, now.Add(-emailClaimTimeout)).
+			Order("send_after").
+			Limit(emailBatchSize).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+			batch[i].Status = models.EmailSending
+			batch[i].Attempts++
+		}
+		return tx.Model(&models.EmailOutbox{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":     models.EmailSending,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		}).Error
+	})
+	return batch, err
+}
+
+// deliverQueuedEmail sends one claimed email and records the result. Only
+// a row that is still claimed is updated, so an email erased while it was
+// being sent is not written back.
+func deliverQueuedEmail(queued *models.EmailOutbox) {
+	updates := map[string]interface{}{"updated_at": time.Now()}
+
+	err := SendEmail(Email{To: queued.To, Subject: queued.Subject, Body: queued.Body})
+	switch {
+	case err == nil:
+		updates["status"] = models.EmailSent
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+	case queued.Attempts >= emailMaxAttempts:
+		updates["status"] = models.EmailFailed
+		updates["last_error"] = err.Error()
+		log.Printf("Giving up on email %d to %s: %v", queued.ID, queued.To, err)
+	default:
+		backoff := time.Duration(1<<queued.Attempts) * time.Minute
+		updates["status"] = models.EmailPending
+		updates["send_after"] = time.Now().Add(backoff)
+		updates["last_error"] = err.Error()
+	}
+
+	if err := database.DB.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status = ?", queued.ID, models.EmailSending).
+		Updates(updates).Error; err != nil {
+		log.Printf("Failed to update queued email %d: %v", queued.ID, err)
+	}
+}
+
+func StartEmailWorker() {
+	interval := durationFromEnv("EMAIL_QUEUE_INTERVAL", 10*time.Second)
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			DeliverQueuedEmails()
+		}
+	}()
+}
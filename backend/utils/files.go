@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Files nothing refers to are kept this long, so uploads that are still
+// being attached to a record are not removed under them
+const unusedFileGracePeriod = 24 * time.Hour
+
+var (
+	ErrFileTooLarge        = fmt.Errorf("must be smaller than %d MB", MaxDocumentSize>>20)
+	ErrInvalidDownloadLink = errors.New("download link is invalid or has expired")
+)
+
+// StoreDocument streams an uploaded document into file storage, checking
+// its size and that its content matches its extension on the way. Content
+// that was stored before is not stored again; its StoredFile is returned
+// instead.
+func StoreDocument(tx *gorm.DB, r io.Reader, filename string) (*models.StoredFile, error) {
+	tmp, err := os.CreateTemp("", "kandy-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, MaxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxDocumentSize {
+		return nil, ErrFileTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	contentType, err := CheckDocument(filename, head[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	var existing models.StoredFile
+	err = tx.Where("sha256 = ?", sum).First(&existing).Error
+	if err == nil {
+		// Keeps the cleanup job away from a file that is about to be used;
+		// if the job got to it first, the content is stored again
+		touched := tx.Model(&existing).Update("updated_at", time.Now())
+		if touched.Error != nil {
+			return nil, touched.Error
+		}
+		if touched.RowsAffected == 1 {
+			return &existing, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	file := models.StoredFile{
+		SHA256:      sum,
+		Key:         fmt.Sprintf("files/%s/%s-%d", sum[:2], sum, time.Now().UnixNano()),
+		Size:        size,
+		ContentType: contentType,
+	}
+	if err := storage.Get().Put(file.Key, tmp, contentType); err != nil {
+		log.Printf("Failed to store file %s: %v", file.Key, err)
+		return nil, err
+	}
+
+	// The same content may have been uploaded concurrently; the first row
+	// wins and this copy is dropped
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&file).Error; err != nil {
+		storage.Get().Delete(file.Key)
+		return nil, err
+	}
+	if file.ID == 0 {
+		storage.Get().Delete(file.Key)
+		if err := tx.Where("sha256 = ?", sum).First(&file).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+// UploadName is the name an uploaded file is attached under: its base
+// name, or a generic one when the name is unusable.
+func UploadName(filename string) string {
+	name := strings.TrimSpace(filepath.Base(filename))
+	if name == "" || name == "." || name == ".." || name == "/" || len(name) > 255 {
+		return "document" + strings.ToLower(filepath.Ext(filename))
+	}
+	return name
+}
+
+// IssueDownloadToken returns a token for downloading an attachment without
+// further authorization. Only hand it to users allowed to see the
+// attachment; it is valid for FILE_LINK_TTL, five minutes by default.
+func IssueDownloadToken(attachmentID uint, now time.Time) (string, time.Time) {
+	expiresAt := now.Add(durationFromEnv("FILE_LINK_TTL", 5*time.Minute))
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload[:8], uint64(attachmentID))
+	binary.BigEndian.PutUint64(payload[8:], uint64(expiresAt.Unix()))
+	return base64.RawURLEncoding.EncodeToString(append(payload, downloadTokenMAC(payload)...)), expiresAt
+}
+
+// VerifyDownloadToken returns the attachment a token from
+// IssueDownloadToken is for.
+func VerifyDownloadToken(token string, now time.Time) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 16+sha256.Size {
+		return 0, ErrInvalidDownloadLink
+	}
+	payload, mac := raw[:16], raw[16:]
+	if !hmac.Equal(mac, downloadTokenMAC(payload)) {
+		return 0, ErrInvalidDownloadLink
+	}
+	if now.Unix() > int64(binary.BigEndian.Uint64(payload[8:])) {
+		return 0, ErrInvalidDownloadLink
+	}
+	return uint(binary.BigEndian.Uint64(payload[:8])), nil
+}
+
+func downloadTokenMAC(payload []byte) []byte {
+	key := sha256.Sum256([]byte("kandy-download|" + getEnv("JWT_SECRET", "your-secret-key-change-in-production")))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// CleanupUnusedFiles removes stored files no attachment has referred to
+// for a day. Rows go first, so a file is never served after its content
+// is gone.
+func CleanupUnusedFiles() {
+	cutoff := time.Now().Add(-unusedFileGracePeriod)
+	referenced := database.DB.Model(&models.Attachment{}).Select("file_id")
+
+	var files []models.StoredFile
+	if err := database.DB.Where("updated_at < ? AND id NOT IN (?)", cutoff, referenced).Find(&files).Error; err != nil {
+		log.Printf("Failed to find unused files: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, file := range files {
+		result := database.DB.Where("updated_at < ? AND id NOT IN (?)", cutoff, referenced).Delete(&file)
+		if result.Error != nil {
+			log.Printf("Failed to delete stored file %d: %v", file.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := storage.Get().Delete(file.Key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", file.Key, err)
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("Cleaned up %d unused files", removed)
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocation is the approximate location of an IP address.
+type GeoLocation struct {
+	CountryCode string `json:"country_code,omitempty"`
+	Country     string `json:"country,omitempty"`
+	City        string `json:"city,omitempty"`
+}
+
+var (
+	geoIPOnce   sync.Once
+	geoIPReader *geoip2.Reader
+)
+
+// LookupLocation resolves an IP address against the offline MaxMind
+// database at GEOIP_DB_PATH (GeoLite2-City or GeoLite2-Country). It returns
+// an empty location when no database is configured or the address is private.
+func LookupLocation(ipAddress string) GeoLocation {
+	geoIPOnce.Do(openGeoIPDatabase)
+
+	ip := net.ParseIP(ipAddress)
+	if geoIPReader == nil || ip == nil || ip.IsPrivate() || ip.IsLoopback() {
+		return GeoLocation{}
+	}
+
+	if record, err := geoIPReader.City(ip); err == nil {
+		return GeoLocation{
+			CountryCode: record.Country.IsoCode,
+			Country:     record.Country.Names["en"],
+			City:        record.City.Names["en"],
+		}
+	}
+
+	// Country-only databases reject City lookups
+	if record, err := geoIPReader.Country(ip); err == nil {
+		return GeoLocation{
+			CountryCode: record.Country.IsoCode,
+			Country:     record.Country.Names["en"],
+		}
+	}
+
+	return GeoLocation{}
+}
+
+func openGeoIPDatabase() {
+	path := getEnv("GEOIP_DB_PATH", "")
+	if path == "" {
+		return
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		log.Printf("Failed to open GeoIP database %s: %v", path, err)
+		return
+	}
+	geoIPReader = reader
+	log.Printf("GeoIP database loaded from %s", path)
+}
+
+// Label is a short description such as "Berlin, Germany".
+func (g GeoLocation) Label() string {
+	switch {
+	case g.City != "" && g.Country != "":
+		return g.City + ", " + g.Country
+	case g.Country != "":
+		return g.Country
+	default:
+		return "Unknown location"
+	}
+}
@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+// InvitationSettings control the invitation lifecycle. Changes apply to
+// invitations sent afterwards; each invitation keeps the expiry it was sent with.
+type InvitationSettings struct {
+	// ExpiryHours is how long an invitation can be accepted
+	ExpiryHours int `json:"expiry_hours"`
+	// BaseURL is the web app address used in invitation links. Empty
+	// falls back to APP_BASE_URL.
+	BaseURL string `json:"base_url"`
+	// ReminderHours lists when to remind, in hours before expiry
+	ReminderHours []int `json:"reminder_hours"`
+}
+
+func DefaultInvitationSettings() InvitationSettings {
+	return InvitationSettings{
+		ExpiryHours:   7 * 24,
+		ReminderHours: []int{48},
+	}
+}
+
+// GetInvitationSettings returns the saved settings, or the defaults.
+func GetInvitationSettings() InvitationSettings {
+	settings := DefaultInvitationSettings()
+	if err := GetSetting(models.SettingInvitations, &settings); err != nil {
+		log.Printf("Failed to load invitation settings, using defaults: %v", err)
+		return DefaultInvitationSettings()
+	}
+	return settings
+}
+
+// Normalize validates the settings and sorts reminders so the earliest
+// (largest offset) comes first.
+func (s *InvitationSettings) Normalize() error {
+	if s.ExpiryHours < 1 || s.ExpiryHours > 90*24 {
+		return errors.New("expiry_hours must be between 1 and 2160")
+	}
+
+	s.BaseURL = strings.TrimRight(strings.TrimSpace(s.BaseURL), "/")
+	if s.BaseURL != "" {
+		parsed, err := url.Parse(s.BaseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("base_url must be an absolute http(s) URL")
+		}
+	}
+
+	seen := make(map[int]bool)
+	reminders := []int{}
+	for _, hours := range s.ReminderHours {
+		if hours <= 0 || hours >= s.ExpiryHours {
+			return fmt.Errorf("reminder_hours must be between 1 and %d", s.ExpiryHours-1)
+		}
+		if !seen[hours] {
+			seen[hours] = true
+			reminders = append(reminders, hours)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(reminders)))
+	s.ReminderHours = reminders
+
+	return nil
+}
+
+func (s InvitationSettings) Expiry() time.Duration {
+	return time.Duration(s.ExpiryHours) * time.Hour
+}
+
+// AcceptURL is the link an invited user follows to accept.
+func (s InvitationSettings) AcceptURL(token string) string {
+	path := "/accept-invitation?token=" + token
+	if s.BaseURL != "" {
+		return s.BaseURL + path
+	}
+	return AppURL(path)
+}
+
+func InvitationEmail(user *models.User, token string, settings InvitationSettings) Email {
+	return Email{
+		To:      user.Email,
+		Subject: "You have been invited to Kandy",
+		Body: fmt.Sprintf(`Hi %s,
+
+You have been invited to join Kandy as %s.
+
+Accept the invitation and choose a password here:
+%s
+
+This invitation expires on %s.
+`, user.Name, strings.ReplaceAll(string(user.Role), "_", " "), settings.AcceptURL(token), formatExpiry(user)),
+	}
+}
+
+func InvitationReminderEmail(user *models.User, token string, settings InvitationSettings) Email {
+	return Email{
+		To:      user.Email,
+		Subject: "Reminder: your Kandy invitation expires soon",
+		Body: fmt.Sprintf(`Hi %s,
+
+Your invitation to join Kandy has not been accepted yet and expires on %s.
+
+Accept it and choose a password here:
+%s
+`, user.Name, formatExpiry(user), settings.AcceptURL(token)),
+	}
+}
+
+func formatExpiry(user *models.User) string {
+	if user.InvitationExpiresAt == nil {
+		return "soon"
+	}
+	return user.InvitationExpiresAt.Format("January 2, 2006 at 15:04 MST")
+}
+
+// RecordInvitationEvent appends to an invitation's history. actorID is nil
+// for events raised by the system.
+func RecordInvitationEvent(db *gorm.DB, user *models.User, eventType models.InvitationEventType, actorID *uint) error {
+	return db.Create(&models.InvitationEvent{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Type:    eventType,
+		ActorID: actorID,
+	}).Error
+}
+
+// ProcessInvitationLifecycle sends due reminders and marks invitations
+// that have run out as expired.
+func ProcessInvitationLifecycle() {
+	settings := GetInvitationSettings()
+	now := time.Now()
+
+	// Invitations sent before expiry was stored per invitation
+	database.DB.Model(&models.User{}).
+		Where("invitation_sent_at IS NOT NULL AND invitation_expires_at IS NULL AND invitation_accepted_at IS NULL").
+		Update("invitation_expires_at", gorm.Expr("invitation_sent_at + ? * interval '1 hour'", settings.ExpiryHours))
+
+	sendInvitationReminders(settings, now)
+	expireInvitations(now)
+}
+
+func sendInvitationReminders(settings InvitationSettings, now time.Time) {
+	if len(settings.ReminderHours) == 0 {
+		return
+	}
+
+	var users []models.User
+	err := database.DB.
+		Where("invitation_token IS NOT NULL AND invitation_accepted_at IS NULL AND invitation_expired_at IS NULL").
+		Where("invitation_expires_at > ? AND invitation_reminders < ?", now, len(settings.ReminderHours)).
+		Find(&users).Error
+	if err != nil {
+		log.Printf("Failed to load invitations for reminders: %v", err)
+		return
+	}
+
+	for i := range users {
+		user := &users[i]
+
+		// Count the reminders that are due by now; several may have passed
+		// if the invitation was sent close to its expiry
+		due := 0
+		for _, hours := range settings.ReminderHours {
+			if !now.Before(user.InvitationExpiresAt.Add(-time.Duration(hours) * time.Hour)) {
+				due++
+			}
+		}
+		if due <= user.InvitationReminders {
+			continue
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("invitation_reminders", due).Error; err != nil {
+				return err
+			}
+			if err := QueueEmail(tx, InvitationReminderEmail(user, *user.InvitationToken, settings)); err != nil {
+				return err
+			}
+			return RecordInvitationEvent(tx, user, models.InvitationEventReminded, nil)
+		})
+		if err != nil {
+			log.Printf("Failed to send invitation reminder to user %d: %v", user.ID, err)
+		}
+	}
+}
+
+func expireInvitations(now time.Time) {
+	var users []models.User
+	err := database.DB.
+		Where("invitation_token IS NOT NULL AND invitation_accepted_at IS NULL AND invitation_expired_at IS NULL").
+		Where("invitation_expires_at <= ?", now).
+		Find(&users).Error
+	if err != nil {
+		log.Printf("Failed to load expired invitations: %v", err)
+		return
+	}
+
+	for i := range users {
+		user := &users[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("invitation_expired_at", now).Error; err != nil {
+				return err
+			}
+			return RecordInvitationEvent(tx, user, models.InvitationEventExpired, nil)
+		})
+		if err != nil {
+			log.Printf("Failed to expire invitation for user %d: %v", user.ID, err)
+		}
+	}
+}
+
+func ScheduleInvitationLifecycle() {
+	interval := durationFromEnv("INVITATION_CHECK_INTERVAL", 15*time.Minute)
+
+	ProcessInvitationLifecycle()
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ProcessInvitationLifecycle()
+		}
+	}()
+}
@@ -0,0 +1,434 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApprovalStep is one link of an approver chain. Any one of its approvers
+// can decide the step.
+type ApprovalStep struct {
+	Name        string `json:"name"`
+	ApproverIDs []uint `json:"approver_ids"`
+}
+
+// ApprovalChain applies to jobs of one department and, unless they have a
+// chain of their own, its sub-departments. The chain without a department
+// is the default for every other job.
+type ApprovalChain struct {
+	DepartmentID *uint          `json:"department_id,omitempty"`
+	Steps        []ApprovalStep `json:"steps"`
+}
+
+func (c ApprovalChain) label() string {
+	if c.DepartmentID == nil {
+		return "the default chain"
+	}
+	return fmt.Sprintf("the chain for department %d", *c.DepartmentID)
+}
+
+// JobApprovalSettings hold the approver chains jobs go through before they
+// can be published. Without chains jobs publish directly.
+type JobApprovalSettings struct {
+	Chains []ApprovalChain `json:"chains"`
+}
+
+func GetJobApprovalSettings() JobApprovalSettings {
+	settings := JobApprovalSettings{Chains: []ApprovalChain{}}
+	if err := GetSetting(models.SettingJobApprovals, &settings); err != nil {
+		log.Printf("Failed to load job approval settings, using defaults: %v", err)
+		return JobApprovalSettings{Chains: []ApprovalChain{}}
+	}
+	return settings
+}
+
+// Normalize validates the chains. Approver and department IDs are checked
+// against the database by the caller.
+func (s *JobApprovalSettings) Normalize() error {
+	if s.Chains == nil {
+		s.Chains = []ApprovalChain{}
+	}
+
+	seen := make(map[uint]bool)
+	for i := range s.Chains {
+		chain := &s.Chains[i]
+
+		var key uint
+		if chain.DepartmentID != nil {
+			key = *chain.DepartmentID
+		}
+		if seen[key] {
+			return fmt.Errorf("there is more than one of %s", chain.label())
+		}
+		seen[key] = true
+
+		if len(chain.Steps) == 0 {
+			return fmt.Errorf("%s has no steps", chain.label())
+		}
+		for j := range chain.Steps {
+			step := &chain.Steps[j]
+			step.Name = strings.TrimSpace(step.Name)
+			if step.Name == "" {
+				return errors.New("every approval step needs a name")
+			}
+			if len(step.ApproverIDs) == 0 {
+				return fmt.Errorf("approval step %q has no approvers", step.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ApproverIDs returns every user referenced by the chains.
+func (s JobApprovalSettings) ApproverIDs() []uint {
+	seen := make(map[uint]bool)
+	ids := []uint{}
+	for _, chain := range s.Chains {
+		for _, step := range chain.Steps {
+			for _, id := range step.ApproverIDs {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// DepartmentIDs returns every department referenced by the chains.
+func (s JobApprovalSettings) DepartmentIDs() []uint {
+	ids := []uint{}
+	for _, chain := range s.Chains {
+		if chain.DepartmentID != nil {
+			ids = append(ids, *chain.DepartmentID)
+		}
+	}
+	return ids
+}
+
+// ChainFor returns the chain for a job's department, the default chain, or
+// nil if the job needs no approval. departmentIDs are the job's department
+// followed by its ancestors, the closest department with a chain wins.
+func (s JobApprovalSettings) ChainFor(departmentIDs []uint) *ApprovalChain {
+	for _, id := range departmentIDs {
+		for i := range s.Chains {
+			if chain := &s.Chains[i]; chain.DepartmentID != nil && *chain.DepartmentID == id {
+				return chain
+			}
+		}
+	}
+
+	for i := range s.Chains {
+		if chain := &s.Chains[i]; chain.DepartmentID == nil {
+			return chain
+		}
+	}
+	return nil
+}
+
+// ApprovalChainForJob looks up the chain that applies to job. Jobs created
+// before departments were managed only have a department name, which is
+// used when exactly one department has it.
+func ApprovalChainForJob(tx *gorm.DB, job *models.Job) (*ApprovalChain, error) {
+	departmentIDs := []uint{}
+	if job.DepartmentID != nil || job.Department != "" {
+		tree, err := LoadDepartmentTree(tx)
+		if err != nil {
+			return nil, err
+		}
+		if job.DepartmentID != nil {
+			departmentIDs = tree.Ancestors(*job.DepartmentID)
+		} else if id, ok := tree.FindByName(job.Department); ok {
+			departmentIDs = tree.Ancestors(id)
+		}
+	}
+	return GetJobApprovalSettings().ChainFor(departmentIDs), nil
+}
+
+// JobTransitionError is returned for status changes the workflow does not allow.
+type JobTransitionError struct {
+	From models.JobStatus
+	To   models.JobStatus
+}
+
+func (e *JobTransitionError) Error() string {
+	return fmt.Sprintf("a %s job cannot be moved to %s", strings.ReplaceAll(string(e.From), "_", " "), e.To)
+}
+
+var ErrNotApprover = errors.New("you are not an approver for the current step")
+
+// LockJob reloads job inside tx with a row lock, so concurrent transitions
+// and approval decisions are applied one after another.
+func LockJob(tx *gorm.DB, job *models.Job) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(job, job.ID).Error
+}
+
+// TransitionJob moves job to status and records who did it. actorID is nil
+// for the scheduler. reason is free text kept with the transition.
+func TransitionJob(tx *gorm.DB, job *models.Job, to models.JobStatus, actorID *uint, reason string) error {
+	from := job.Status
+	if !from.CanTransitionTo(to) {
+		return &JobTransitionError{From: from, To: to}
+	}
+
+	now := time.Now()
+	switch to {
+	case models.JobPublished:
+		if job.PublishedAt == nil {
+			job.PublishedAt = &now
+		}
+		job.PausedAt = nil
+	case models.JobPaused:
+		job.PausedAt = &now
+	case models.JobClosed:
+		job.ClosedAt = &now
+		job.PausedAt = nil
+	case models.JobDraft:
+		if from == models.JobClosed {
+			// Reopened jobs go through approval and publishing again
+			job.ClosedAt = nil
+			job.ClosingReason = ""
+			job.ApprovedAt = nil
+			job.PublishAt = nil
+			job.CloseAt = nil
+		}
+	}
+	job.Status = to
+
+	if err := tx.Save(job).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.JobTransition{
+		JobID:      job.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Reason:     reason,
+	}).Error
+}
+
+// RequestJobPublish publishes a draft, or takes the steps that have to
+// come first: submitting it to its approver chain, or leaving it to the
+// scheduler when its publish date is still ahead.
+func RequestJobPublish(tx *gorm.DB, job *models.Job, actorID *uint) error {
+	if job.Status != models.JobDraft {
+		return TransitionJob(tx, job, models.JobPublished, actorID, "")
+	}
+
+	if job.ApprovedAt == nil {
+		chain, err := ApprovalChainForJob(tx, job)
+		if err != nil {
+			return err
+		}
+		if chain != nil {
+			return submitJobForApproval(tx, job, chain, actorID)
+		}
+
+		now := time.Now()
+		job.ApprovedAt = &now
+	}
+
+	if job.IsPublishScheduled() {
+		return tx.Save(job).Error
+	}
+	return TransitionJob(tx, job, models.JobPublished, actorID, "")
+}
+
+func submitJobForApproval(tx *gorm.DB, job *models.Job, chain *ApprovalChain, actorID *uint) error {
+	job.ApprovalRound++
+	for i, step := range chain.Steps {
+		err := tx.Create(&models.JobApproval{
+			JobID:       job.ID,
+			Round:       job.ApprovalRound,
+			Step:        i + 1,
+			StepName:    step.Name,
+			ApproverIDs: step.ApproverIDs,
+			Status:      models.JobApprovalPending,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := TransitionJob(tx, job, models.JobPendingApproval, actorID, "Submitted for approval"); err != nil {
+		return err
+	}
+	return notifyApprovers(tx, job, chain.Steps[0].Name, chain.Steps[0].ApproverIDs)
+}
+
+// WithdrawJobApproval moves a job waiting for approval back to draft.
+func WithdrawJobApproval(tx *gorm.DB, job *models.Job, actorID *uint) error {
+	if job.Status != models.JobPendingApproval {
+		return &JobTransitionError{From: job.Status, To: models.JobDraft}
+	}
+
+	if err := tx.Model(&models.JobApproval{}).
+		Where("job_id = ? AND round = ? AND status = ?", job.ID, job.ApprovalRound, models.JobApprovalPending).
+		Update("status", models.JobApprovalSkipped).Error; err != nil {
+		return err
+	}
+	return TransitionJob(tx, job, models.JobDraft, actorID, "Withdrawn from approval")
+}
+
+// CurrentJobApproval returns the first undecided step of the job's
+// current approval round.
+func CurrentJobApproval(tx *gorm.DB, job *models.Job) (*models.JobApproval, error) {
+	var approval models.JobApproval
+	err := tx.Where("job_id = ? AND round = ? AND status = ?", job.ID, job.ApprovalRound, models.JobApprovalPending).
+		Order("step").
+		First(&approval).Error
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// DecideJobApproval records an approver's decision on the current step.
+// A rejection sends the job back to draft. Approving the last step clears
+// the job for publishing, right away or at its publish date.
+func DecideJobApproval(tx *gorm.DB, job *models.Job, approverID uint, approve bool, comment string) error {
+	if job.Status != models.JobPendingApproval {
+		return errors.New("job is not waiting for approval")
+	}
+
+	approval, err := CurrentJobApproval(tx, job)
+	if err != nil {
+		return err
+	}
+	if !approval.CanDecide(approverID) {
+		return ErrNotApprover
+	}
+
+	now := time.Now()
+	approval.DecidedBy = &approverID
+	approval.DecidedAt = &now
+	approval.Comment = comment
+	approval.Status = models.JobApprovalApproved
+	if !approve {
+		approval.Status = models.JobApprovalRejected
+	}
+	if err := tx.Save(approval).Error; err != nil {
+		return err
+	}
+
+	if !approve {
+		if err := tx.Model(&models.JobApproval{}).
+			Where("job_id = ? AND round = ? AND status = ?", job.ID, job.ApprovalRound, models.JobApprovalPending).
+			Update("status", models.JobApprovalSkipped).Error; err != nil {
+			return err
+		}
+		reason := "Rejected at " + approval.StepName
+		if comment != "" {
+			reason += ": " + comment
+		}
+		return TransitionJob(tx, job, models.JobDraft, &approverID, reason)
+	}
+
+	next, err := CurrentJobApproval(tx, job)
+	if err == nil {
+		return notifyApprovers(tx, job, next.StepName, next.ApproverIDs)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	job.ApprovedAt = &now
+	if job.IsPublishScheduled() {
+		reason := "Approved, publishing on " + job.PublishAt.Format(time.RFC3339)
+		return TransitionJob(tx, job, models.JobDraft, &approverID, reason)
+	}
+	return TransitionJob(tx, job, models.JobPublished, &approverID, "Approved")
+}
+
+func notifyApprovers(tx *gorm.DB, job *models.Job, stepName string, approverIDs []uint) error {
+	var approvers []models.User
+	if err := tx.Where("id IN ? AND is_active = ?", approverIDs, true).Find(&approvers).Error; err != nil {
+		return err
+	}
+
+	for _, approver := range approvers {
+		err := QueueEmail(tx, Email{
+			To:      approver.Email,
+			Subject: "Job waiting for your approval: " + job.Title,
+			Body: fmt.Sprintf(`Hi %s,
+
+The job "%s" is waiting for your approval (%s).
+
+Review it here:
+%s
+`, approver.Name, job.Title, stepName, AppURL(fmt.Sprintf("/jobs/%d/edit", job.ID))),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProcessJobSchedules publishes approved drafts whose publish date has
+// come and closes open jobs whose close date has passed.
+func ProcessJobSchedules() {
+	now := time.Now()
+
+	var toPublish []models.Job
+	if err := database.DB.
+		Where("status = ? AND approved_at IS NOT NULL AND publish_at <= ?", models.JobDraft, now).
+		Find(&toPublish).Error; err != nil {
+		log.Printf("Failed to load jobs due for publishing: %v", err)
+	}
+	for i := range toPublish {
+		runScheduledTransition(&toPublish[i], models.JobDraft, models.JobPublished, "Scheduled publish")
+	}
+
+	var toClose []models.Job
+	if err := database.DB.
+		Where("status IN ? AND close_at <= ?", []models.JobStatus{models.JobPublished, models.JobPaused}, now).
+		Find(&toClose).Error; err != nil {
+		log.Printf("Failed to load jobs due for closing: %v", err)
+	}
+	for i := range toClose {
+		runScheduledTransition(&toClose[i], toClose[i].Status, models.JobClosed, "Scheduled close")
+	}
+}
+
+// runScheduledTransition re-checks the job under lock, someone may have
+// changed it since it was loaded.
+func runScheduledTransition(job *models.Job, from, to models.JobStatus, reason string) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := LockJob(tx, job); err != nil {
+			return err
+		}
+		if job.Status != from {
+			return nil
+		}
+		if to == models.JobClosed {
+			job.ClosingReason = models.ClosingScheduled
+		}
+		return TransitionJob(tx, job, to, nil, reason)
+	})
+	if err != nil {
+		log.Printf("Failed to move job %d to %s: %v", job.ID, to, err)
+	}
+}
+
+func ScheduleJobLifecycle() {
+	interval := durationFromEnv("JOB_SCHEDULE_INTERVAL", time.Minute)
+
+	ProcessJobSchedules()
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ProcessJobSchedules()
+		}
+	}()
+}
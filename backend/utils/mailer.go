@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email is a plain-text message to a single recipient.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SendEmail delivers an email over SMTP. When SMTP_HOST is not configured
+// the message is written to the log instead, which is what local
+// development relies on.
+func SendEmail(email Email) error {
+	host := getEnv("SMTP_HOST", "")
+	from := getEnv("SMTP_FROM", "Kandy <no-reply@kandy.local>")
+
+	if host == "" {
+		log.Printf("Email (SMTP not configured)\nTo: %s\nSubject: %s\n\n%s", email.To, email.Subject, email.Body)
+		return nil
+	}
+
+	port := getEnv("SMTP_PORT", "587")
+	username := getEnv("SMTP_USERNAME", "")
+	password := getEnv("SMTP_PASSWORD", "")
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	headers := []string{
+		"From: " + from,
+		"To: " + email.To,
+		"Subject: " + email.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + email.Body
+
+	envelopeFrom := from
+	if start := strings.Index(from, "<"); start >= 0 {
+		envelopeFrom = strings.Trim(from[start:], "<>")
+	}
+
+	if err := smtp.SendMail(host+":"+port, auth, envelopeFrom, []string{email.To}, []byte(message)); err != nil {
+		return fmt.Errorf("send email to %s: %w", email.To, err)
+	}
+	return nil
+}
+
+// SendEmailAsync sends an email in the background so request handlers do
+// not wait on the mail server.
+func SendEmailAsync(email Email) {
+	go func() {
+		if err := SendEmail(email); err != nil {
+			log.Printf("Failed to send email %q: %v", email.Subject, err)
+		}
+	}()
+}
+
+// AppURL builds a link into the web app from APP_BASE_URL.
+func AppURL(path string) string {
+	base := strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:3000"), "/")
+	return base + path
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sebastian/kandy/backend/models"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// DepartmentTree indexes every department by ID and by parent. There are
+// few enough departments to load all of them whenever the hierarchy is
+// needed.
+type DepartmentTree struct {
+	byID     map[uint]*models.Department
+	children map[uint][]uint
+	roots    []uint
+}
+
+func LoadDepartmentTree(db *gorm.DB) (*DepartmentTree, error) {
+	var departments []models.Department
+	if err := db.Order("name").Find(&departments).Error; err != nil {
+		return nil, err
+	}
+	return NewDepartmentTree(departments), nil
+}
+
+func NewDepartmentTree(departments []models.Department) *DepartmentTree {
+	tree := &DepartmentTree{
+		byID:     make(map[uint]*models.Department, len(departments)),
+		children: make(map[uint][]uint),
+	}
+	for i := range departments {
+		tree.byID[departments[i].ID] = &departments[i]
+	}
+	for i := range departments {
+		department := &departments[i]
+		if department.ParentID != nil && tree.byID[*department.ParentID] != nil {
+			tree.children[*department.ParentID] = append(tree.children[*department.ParentID], department.ID)
+		} else {
+			tree.roots = append(tree.roots, department.ID)
+		}
+	}
+	return tree
+}
+
+func (t *DepartmentTree) Get(id uint) (*models.Department, bool) {
+	department, ok := t.byID[id]
+	return department, ok
+}
+
+// FindByName returns the department called name, ignoring case. It reports
+// false when no department or more than one has the name.
+func (t *DepartmentTree) FindByName(name string) (uint, bool) {
+	var found []uint
+	for id, department := range t.byID {
+		if strings.EqualFold(department.Name, name) {
+			found = append(found, id)
+		}
+	}
+	if len(found) != 1 {
+		return 0, false
+	}
+	return found[0], true
+}
+
+// Ancestors returns id followed by its parent, grandparent and so on up to
+// the top-level department.
+func (t *DepartmentTree) Ancestors(id uint) []uint {
+	ids := []uint{}
+	seen := make(map[uint]bool)
+	for department, ok := t.byID[id]; ok && !seen[department.ID]; {
+		seen[department.ID] = true
+		ids = append(ids, department.ID)
+		if department.ParentID == nil {
+			break
+		}
+		department, ok = t.byID[*department.ParentID]
+	}
+	return ids
+}
+
+// Descendants returns id and every department below it.
+func (t *DepartmentTree) Descendants(id uint) []uint {
+	if _, ok := t.byID[id]; !ok {
+		return []uint{}
+	}
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+// Path returns the names from the top-level department down to id, e.g.
+// "Engineering / Platform".
+func (t *DepartmentTree) Path(id uint) string {
+	ancestors := t.Ancestors(id)
+	names := make([]string, len(ancestors))
+	for i, ancestorID := range ancestors {
+		names[len(ancestors)-1-i] = t.byID[ancestorID].Name
+	}
+	return strings.Join(names, " / ")
+}
+
+// Depth is 0 for top-level departments.
+func (t *DepartmentTree) Depth(id uint) int {
+	return len(t.Ancestors(id)) - 1
+}
+
+// Walk visits every department depth first, parents before their children,
+// siblings by name.
+func (t *DepartmentTree) Walk(visit func(department *models.Department, depth int)) {
+	var walk func(ids []uint, depth int)
+	walk = func(ids []uint, depth int) {
+		for _, id := range ids {
+			visit(t.byID[id], depth)
+			walk(t.children[id], depth+1)
+		}
+	}
+	walk(t.roots, 0)
+}
+
+// NormalizeCountryCode returns the upper-case ISO 3166-1 alpha-2 form of code.
+func NormalizeCountryCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	region, err := language.ParseRegion(code)
+	if err != nil || len(code) != 2 || !region.IsCountry() {
+		return "", errors.New("country must be an ISO 3166-1 alpha-2 code such as DE or US")
+	}
+	return region.String(), nil
+}
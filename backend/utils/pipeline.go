@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+)
+
+const maxPipelineStages = 20
+
+// PipelineError is returned for pipelines and stage changes that break the
+// pipeline rules. Its message can be shown to the user as is.
+type PipelineError string
+
+func (e PipelineError) Error() string {
+	return string(e)
+}
+
+// NormalizePipeline trims the stage names and checks the rules every
+// pipeline follows: 2 to 20 stages with unique names, the first and only
+// applied stage at the start and the only hired stage at the end.
+func NormalizePipeline(stages []models.PipelineStage) ([]models.PipelineStage, error) {
+	if len(stages) < 2 {
+		return nil, PipelineError("a pipeline needs at least an applied and a hired stage")
+	}
+	if len(stages) > maxPipelineStages {
+		return nil, PipelineError(fmt.Sprintf("a pipeline has at most %d stages", maxPipelineStages))
+	}
+
+	normalized := make([]models.PipelineStage, len(stages))
+	seen := make(map[string]bool, len(stages))
+	for i, stage := range stages {
+		stage.Name = strings.TrimSpace(stage.Name)
+		switch {
+		case stage.Name == "":
+			return nil, PipelineError("every stage needs a name")
+		case len(stage.Name) > 100:
+			return nil, PipelineError("stage names must be at most 100 characters")
+		case seen[strings.ToLower(stage.Name)]:
+			return nil, PipelineError(fmt.Sprintf("there is more than one stage named %q", stage.Name))
+		case !stage.Type.IsValid():
+			return nil, PipelineError("stage type must be applied, screen, interview, assessment, offer or hired")
+		case stage.Type == models.StageApplied && i != 0:
+			return nil, PipelineError("only the first stage can be an applied stage")
+		case stage.Type != models.StageApplied && i == 0:
+			return nil, PipelineError("the first stage must be an applied stage")
+		case stage.Type == models.StageHired && i != len(stages)-1:
+			return nil, PipelineError("only the last stage can be a hired stage")
+		case stage.Type != models.StageHired && i == len(stages)-1:
+			return nil, PipelineError("the last stage must be a hired stage")
+		}
+		seen[strings.ToLower(stage.Name)] = true
+		normalized[i] = stage
+	}
+	return normalized, nil
+}
+
+// PipelineStagesFor returns the stages a new job starts with: those of
+// the template templateID, or without one those of the default template,
+// or the built-in pipeline if no template is the default.
+func PipelineStagesFor(tx *gorm.DB, templateID *uint) ([]models.PipelineStage, error) {
+	var template models.PipelineTemplate
+	if templateID != nil {
+		if err := tx.First(&template, *templateID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, PipelineError("pipeline template not found")
+			}
+			return nil, err
+		}
+		return template.Stages, nil
+	}
+
+	err := tx.Where("is_default = ?", true).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultPipeline, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return template.Stages, nil
+}
+
+// CreateJobPipeline gives a new job its own copy of stages.
+func CreateJobPipeline(tx *gorm.DB, jobID uint, stages []models.PipelineStage) error {
+	rows := make([]models.JobStage, len(stages))
+	for i, stage := range stages {
+		rows[i] = models.JobStage{
+			JobID:    jobID,
+			Name:     stage.Name,
+			Type:     stage.Type,
+			Position: i + 1,
+		}
+	}
+	return tx.Create(&rows).Error
+}
+
+// JobStages loads a job's pipeline in order.
+func JobStages(tx *gorm.DB, jobID uint) ([]models.JobStage, error) {
+	var stages []models.JobStage
+	err := tx.Where("job_id = ?", jobID).Order("position").Find(&stages).Error
+	return stages, err
+}
+
+// FirstJobStage returns the applied stage new applications start in.
+func FirstJobStage(tx *gorm.DB, jobID uint) (*models.JobStage, error) {
+	var stage models.JobStage
+	if err := tx.Where("job_id = ?", jobID).Order("position").First(&stage).Error; err != nil {
+		return nil, err
+	}
+	return &stage, nil
+}
+
+// The functions below change a job's pipeline. Call them in a transaction
+// holding the job's row lock (LockJob), so concurrent changes cannot
+// interleave and leave the positions or the pipeline rules broken.
+
+// AddJobStage inserts stage into a job's pipeline at position (1-based).
+// Position 0 inserts it just before the hired stage.
+func AddJobStage(tx *gorm.DB, jobID uint, stage models.PipelineStage, position int) (*models.JobStage, error) {
+	stages, err := JobStages(tx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if position == 0 {
+		position = len(stages)
+	}
+	if position < 1 || position > len(stages)+1 {
+		return nil, PipelineError(fmt.Sprintf("position must be between 1 and %d", len(stages)+1))
+	}
+
+	created := models.JobStage{
+		JobID:    jobID,
+		Name:     strings.TrimSpace(stage.Name),
+		Type:     stage.Type,
+		Position: position,
+	}
+	updated := make([]models.JobStage, 0, len(stages)+1)
+	updated = append(updated, stages[:position-1]...)
+	updated = append(updated, created)
+	updated = append(updated, stages[position-1:]...)
+	if err := checkJobPipeline(updated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&created).Error; err != nil {
+		return nil, err
+	}
+	updated[position-1] = created
+	return &created, renumberJobStages(tx, updated)
+}
+
+// UpdateJobStage renames or retypes one stage of a job's pipeline.
+func UpdateJobStage(tx *gorm.DB, jobID, stageID uint, stage models.PipelineStage) (*models.JobStage, error) {
+	stages, err := JobStages(tx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	index := stageIndex(stages, stageID)
+	if index < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	stages[index].Name = strings.TrimSpace(stage.Name)
+	stages[index].Type = stage.Type
+	if err := checkJobPipeline(stages); err != nil {
+		return nil, err
+	}
+
+	updated := &stages[index]
+	if err := tx.Model(updated).Updates(map[string]interface{}{
+		"name": updated.Name,
+		"type": updated.Type,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ReorderJobStages puts a job's stages in the order of stageIDs, which has
+// to list every stage once. Applications stay in their stage.
+func ReorderJobStages(tx *gorm.DB, jobID uint, stageIDs []uint) ([]models.JobStage, error) {
+	stages, err := JobStages(tx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(stageIDs) != len(stages) {
+		return nil, PipelineError("stage_ids must list every stage of the job once")
+	}
+
+	ordered := make([]models.JobStage, 0, len(stages))
+	seen := make(map[uint]bool, len(stageIDs))
+	for _, id := range stageIDs {
+		index := stageIndex(stages, id)
+		if index < 0 || seen[id] {
+			return nil, PipelineError("stage_ids must list every stage of the job once")
+		}
+		seen[id] = true
+		ordered = append(ordered, stages[index])
+	}
+	if err := checkJobPipeline(ordered); err != nil {
+		return nil, err
+	}
+
+	return ordered, renumberJobStages(tx, ordered)
+}
+
+// DeleteJobStage removes a stage from a job's pipeline and moves the
+// applications in it, including rejected and withdrawn ones, to the stage
+// moveTo, or to the previous stage when moveTo is 0. The moves are recorded
+// on the applications' timelines. It returns the number of applications
+// moved. The applied and hired stages cannot be deleted, and applications
+// cannot be moved into the hired stage this way.
+func DeleteJobStage(tx *gorm.DB, jobID, stageID, moveTo uint, actorID *uint) (int64, error) {
+	stages, err := JobStages(tx, jobID)
+	if err != nil {
+		return 0, err
+	}
+	index := stageIndex(stages, stageID)
+	if index < 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	switch stages[index].Type {
+	case models.StageApplied:
+		return 0, PipelineError("the applied stage cannot be deleted")
+	case models.StageHired:
+		return 0, PipelineError("the hired stage cannot be deleted")
+	}
+
+	target := index - 1
+	if moveTo != 0 {
+		target = stageIndex(stages, moveTo)
+		switch {
+		case target < 0 || target == index:
+			return 0, PipelineError("move_to must be another stage of the job")
+		case stages[target].Type == models.StageHired:
+			return 0, PipelineError("applications cannot be moved to the hired stage, pick another stage")
+		}
+	}
+
+	if err := recordStageMoves(tx, &stages[index], &stages[target], actorID, "stage_deleted"); err != nil {
+		return 0, err
+	}
+	moved := tx.Model(&models.Application{}).Unscoped().
+		Where("stage_id = ?", stageID).
+		Updates(map[string]interface{}{
+			"stage_id":         stages[target].ID,
+			"stage_changed_at": time.Now(),
+		})
+	if moved.Error != nil {
+		return 0, moved.Error
+	}
+
+	if err := tx.Delete(&stages[index]).Error; err != nil {
+		return 0, err
+	}
+	remaining := append(stages[:index:index], stages[index+1:]...)
+	return moved.RowsAffected, renumberJobStages(tx, remaining)
+}
+
+func checkJobPipeline(stages []models.JobStage) error {
+	pipeline := make([]models.PipelineStage, len(stages))
+	for i, stage := range stages {
+		pipeline[i] = models.PipelineStage{Name: stage.Name, Type: stage.Type}
+	}
+	_, err := NormalizePipeline(pipeline)
+	return err
+}
+
+// renumberJobStages stores the positions of stages as listed.
+func renumberJobStages(tx *gorm.DB, stages []models.JobStage) error {
+	for i := range stages {
+		if stages[i].Position == i+1 {
+			continue
+		}
+		stages[i].Position = i + 1
+		if err := tx.Model(&stages[i]).UpdateColumn("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stageIndex(stages []models.JobStage, id uint) int {
+	for i := range stages {
+		if stages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	// Bundle the timezone database so validation works on hosts without one
+	_ "time/tzdata"
+
+	"golang.org/x/text/language"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 ().-]{4,28}[0-9]$`)
+
+// EmailChangeTTL is how long the confirmation link for a new email
+// address stays valid.
+func EmailChangeTTL() time.Duration {
+	return durationFromEnv("EMAIL_CHANGE_TTL", 24*time.Hour)
+}
+
+// NormalizeTimezone checks name against the IANA timezone database.
+func NormalizeTimezone(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return "", errors.New("timezone must be an IANA name such as Europe/Berlin")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return "", errors.New("unknown timezone: " + name)
+	}
+	return name, nil
+}
+
+// NormalizeLocale returns the canonical BCP 47 form of tag, e.g. "en-US".
+func NormalizeLocale(tag string) (string, error) {
+	parsed, err := language.Parse(strings.TrimSpace(tag))
+	if err != nil || parsed == language.Und {
+		return "", errors.New("locale must be a BCP 47 language tag such as en or de-AT")
+	}
+	return parsed.String(), nil
+}
+
+// NormalizePhone trims a phone number and checks it looks like one. An
+// empty number clears it.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone != "" && !phonePattern.MatchString(phone) {
+		return "", errors.New("phone must contain only digits, spaces and + ( ) - .")
+	}
+	return phone, nil
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxDocumentSize is the largest resume or other document accepted
+	// with an application, in bytes
+	MaxDocumentSize = 10 << 20
+	// MaxApplicationSize is the largest application form, files included
+	MaxApplicationSize = 25 << 20
+
+	// Forms sent back faster than a person can fill them in are bots
+	applyTokenMinAge = 3 * time.Second
+	applyTokenMaxAge = 24 * time.Hour
+)
+
+var (
+	ErrInvalidDocument   = errors.New("must be a PDF, Word document or plain text file")
+	ErrInvalidApplyToken = errors.New("the form has expired, reload the page and try again")
+	ErrApplyTooFast      = errors.New("form submitted too quickly")
+)
+
+// documentTypes maps the accepted extensions to the content types they are
+// stored with and the types content sniffing may report for them. Word
+// documents sniff as a zip archive (docx) or unknown binary (doc).
+var documentTypes = map[string]struct {
+	contentType string
+	sniffed     []string
+}{
+	".pdf":  {"application/pdf", []string{"application/pdf"}},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{"application/zip"}},
+	".doc":  {"application/msword", []string{"application/octet-stream"}},
+	".txt":  {"text/plain", []string{"text/plain; charset=utf-8"}},
+}
+
+// CheckDocument checks a document's file name against the first bytes of
+// its content and returns the content type to store it with.
+func CheckDocument(filename string, head []byte) (string, error) {
+	accepted, ok := documentTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok || len(head) == 0 {
+		return "", ErrInvalidDocument
+	}
+	sniffed := http.DetectContentType(head)
+	for _, valid := range accepted.sniffed {
+		if sniffed == valid {
+			return accepted.contentType, nil
+		}
+	}
+	return "", ErrInvalidDocument
+}
+
+// IssueApplyToken returns the token the careers site sends back with an
+// application to jobID. It proves the form was fetched, and when.
+func IssueApplyToken(jobID uint, now time.Time) string {
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload[:8], uint64(jobID))
+	binary.BigEndian.PutUint64(payload[8:], uint64(now.Unix()))
+	return base64.RawURLEncoding.EncodeToString(append(payload, applyTokenMAC(payload)...))
+}
+
+// VerifyApplyToken checks a token from IssueApplyToken for jobID. Tokens
+// are refused when forged, older than a day or younger than a few seconds.
+func VerifyApplyToken(token string, jobID uint, now time.Time) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 16+sha256.Size {
+		return ErrInvalidApplyToken
+	}
+	payload, mac := raw[:16], raw[16:]
+	if !hmac.Equal(mac, applyTokenMAC(payload)) || binary.BigEndian.Uint64(payload[:8]) != uint64(jobID) {
+		return ErrInvalidApplyToken
+	}
+
+	age := now.Sub(time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0))
+	switch {
+	case age > applyTokenMaxAge:
+		return ErrInvalidApplyToken
+	case age < applyTokenMinAge:
+		return ErrApplyTooFast
+	}
+	return nil
+}
+
+func applyTokenMAC(payload []byte) []byte {
+	key := sha256.Sum256([]byte("kandy-apply|" + getEnv("JWT_SECRET", "your-secret-key-change-in-production")))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// LooksLikeSpam catches the usual content of automated submissions: link
+// lists and markup in fields meant for plain text.
+func LooksLikeSpam(text string) bool {
+	lower := strings.ToLower(text)
+	if strings.Count(lower, "http://")+strings.Count(lower, "https://") > 5 {
+		return true
+	}
+	return strings.Contains(lower, "<a href") || strings.Contains(lower, "[url=")
+}
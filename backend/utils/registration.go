@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/sebastian/kandy/backend/models"
+)
+
+type RegistrationMode string
+
+const (
+	// RegistrationDisabled only lets people in through invitations
+	RegistrationDisabled RegistrationMode = "disabled"
+	// RegistrationOpen lets anyone register
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationDomains lets people register with an allowlisted email domain
+	RegistrationDomains RegistrationMode = "domain_allowlist"
+)
+
+// RegistrationPolicy controls self-service sign-up through /api/auth/register.
+type RegistrationPolicy struct {
+	Mode           RegistrationMode `json:"mode"`
+	AllowedDomains []string         `json:"allowed_domains"`
+	// RequireApproval holds new accounts until an admin approves them
+	RequireApproval bool `json:"require_approval"`
+}
+
+// DefaultRegistrationPolicy is used until an admin saves a policy. Its mode
+// comes from REGISTRATION_MODE and is invite-only unless set otherwise.
+func DefaultRegistrationPolicy() RegistrationPolicy {
+	policy := RegistrationPolicy{
+		Mode:           RegistrationMode(getEnv("REGISTRATION_MODE", string(RegistrationDisabled))),
+		AllowedDomains: []string{},
+	}
+	if policy.Normalize() != nil {
+		policy.Mode = RegistrationDisabled
+	}
+	return policy
+}
+
+func GetRegistrationPolicy() RegistrationPolicy {
+	policy := DefaultRegistrationPolicy()
+	if err := GetSetting(models.SettingRegistration, &policy); err != nil {
+		log.Printf("Failed to load registration policy, using defaults: %v", err)
+		return DefaultRegistrationPolicy()
+	}
+	return policy
+}
+
+// Normalize validates the policy and lower-cases its domains.
+func (p *RegistrationPolicy) Normalize() error {
+	switch p.Mode {
+	case RegistrationDisabled, RegistrationOpen, RegistrationDomains:
+	default:
+		return errors.New("mode must be disabled, open or domain_allowlist")
+	}
+
+	domains := []string{}
+	seen := make(map[string]bool)
+	for _, domain := range p.AllowedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain == "" || seen[domain] {
+			continue
+		}
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@ /") {
+			return errors.New("invalid domain: " + domain)
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	p.AllowedDomains = domains
+
+	if p.Mode == RegistrationDomains && len(p.AllowedDomains) == 0 {
+		return errors.New("allowed_domains is required for domain_allowlist mode")
+	}
+	return nil
+}
+
+// Allows reports whether email may register under this policy, and if not, why.
+func (p RegistrationPolicy) Allows(email string) (bool, string) {
+	switch p.Mode {
+	case RegistrationOpen:
+		return true, ""
+	case RegistrationDomains:
+		at := strings.LastIndex(email, "@")
+		domain := strings.ToLower(email[at+1:])
+		for _, allowed := range p.AllowedDomains {
+			if domain == allowed {
+				return true, ""
+			}
+		}
+		return false, "Registration is limited to approved email domains"
+	default:
+		return false, "Registration is by invitation only"
+	}
+}
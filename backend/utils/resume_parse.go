@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	resumeParseBatchSize   = 10
+	resumeParseMaxAttempts = 3
+	// A claimed resume not finished by then is taken to be abandoned
+	resumeParseClaimTimeout = 10 * time.Minute
+)
+
+// QueueResumeParse queues parsing an attachment as a resume using db, which
+// may be a transaction. An attachment parsed before is parsed again.
+func QueueResumeParse(db *gorm.DB, attachmentID uint) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "attachment_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":     models.ResumeParsePending,
+			"attempts":   0,
+			"last_error": "",
+			"updated_at": time.Now(),
+		}),
+	}).Create(&models.ResumeParse{
+		AttachmentID: attachmentID,
+		Status:       models.ResumeParsePending,
+	}).Error
+}
+
+// ParseQueuedResumes parses the next batch of queued resumes and fills in
+// their candidates. Files that cannot be read are retried a few times, in
+// case storage was unavailable.
+func ParseQueuedResumes() {
+	batch, err := claimQueuedResumes()
+	if err != nil {
+		log.Printf("Failed to process resume queue: %v", err)
+		return
+	}
+
+	filled := []uint{}
+	for i := range batch {
+		if candidateID := parseQueuedResume(&batch[i]); candidateID != 0 {
+			filled = append(filled, candidateID)
+		}
+	}
+
+	// What a resume filled in may show the candidate applied before
+	for _, candidateID := range filled {
+		if _, err := DetectCandidateDuplicates(database.DB, candidateID); err != nil {
+			log.Printf("Failed to look for duplicates of candidate %d: %v", candidateID, err)
+		}
+	}
+}
+
+// claimQueuedResumes marks the next batch of queued resumes as processing
+// and counts the attempt, so reading the files happens outside any
+// transaction. Resumes left processing by a worker that stopped are
+// claimed again after resumeParseClaimTimeout.
+func claimQueuedResumes() ([]models.ResumeParse, error) {
+	var batch []models.ResumeParse
+
+	// SKIP LOCKED lets several server instances work through the queue
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)",
+				models.ResumeParsePending, models.ResumeParseProcessing, time.Now().Add(-resumeParseClaimTimeout)).
+			Order("updated_at").
+			Limit(resumeParseBatchSize).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+			batch[i].Status = models.ResumeParseProcessing
+			batch[i].Attempts++
+		}
+		return tx.Model(&models.ResumeParse{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":     models.ResumeParseProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": time.Now(),
+		}).Error
+	})
+	return batch, err
+}
+
+// parseQueuedResume parses one claimed resume and returns the id of the
+// candidate it filled in, if any. The result is saved together with the
+// candidate in a transaction of its own, so one bad resume does not hold
+// up the rest of the batch.
+func parseQueuedResume(parse *models.ResumeParse) uint {
+	var attachment models.Attachment
+	if err := database.DB.Preload("File").First(&attachment, parse.AttachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The attachment was removed while it waited
+			if err := database.DB.Delete(parse).Error; err != nil {
+				log.Printf("Failed to remove resume parse %d: %v", parse.ID, err)
+			}
+			return 0
+		}
+		releaseResumeParse(parse, err, false)
+		return 0
+	}
+
+	text, err := ExtractText(attachment.File)
+	if err != nil {
+		// Retrying a file type the parser cannot read will not help
+		releaseResumeParse(parse, err, errors.Is(err, ErrUnsupportedDocument))
+		return 0
+	}
+
+	now := time.Now()
+	parse.Status = models.ResumeParseDone
+	parse.Text = text
+	parse.Profile = ParseResume(text)
+	parse.ParsedAt = &now
+	parse.LastError = ""
+
+	candidateID := uint(0)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(parse).Error; err != nil {
+			return err
+		}
+		candidateID, err = prefillCandidate(tx, attachment.ApplicationID, parse.Profile)
+		return err
+	})
+	if err != nil {
+		releaseResumeParse(parse, err, false)
+		return 0
+	}
+	return candidateID
+}
+
+// releaseResumeParse records a failed attempt at parsing a resume. It goes
+// back in the queue until it has used up its attempts, or giveUp is set.
+// What an earlier parse extracted is kept.
+func releaseResumeParse(parse *models.ResumeParse, cause error, giveUp bool) {
+	status := models.ResumeParsePending
+	if giveUp || parse.Attempts >= resumeParseMaxAttempts {
+		status = models.ResumeParseFailed
+		log.Printf("Giving up on parsing resume %d: %v", parse.AttachmentID, cause)
+	}
+
+	err := database.DB.Model(parse).Updates(map[string]interface{}{
+		"status":     status,
+		"last_error": cause.Error(),
+	}).Error
+	if err != nil {
+		log.Printf("Failed to update resume parse %d: %v", parse.ID, err)
+	}
+}
+
+// prefillCandidate fills in the candidate of an application from their
+// parsed resume and returns their id if anything was filled in.
+func prefillCandidate(tx *gorm.DB, applicationID uint, profile *models.ParsedProfile) (uint, error) {
+	var candidate models.Candidate
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = (?)", tx.Model(&models.Application{}).Select("candidate_id").Where("id = ?", applicationID)).
+		First(&candidate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ApplyParsedProfile(&candidate, profile)) == 0 {
+		return 0, nil
+	}
+	if err := tx.Save(&candidate).Error; err != nil {
+		return 0, err
+	}
+	return candidate.ID, nil
+}
+
+// ApplyParsedProfile fills in the fields of candidate that are still empty
+// from a parsed resume, notes them in ParsedFields with the parser's
+// confidence and returns their names. What someone entered is never
+// replaced.
+func ApplyParsedProfile(candidate *models.Candidate, profile *models.ParsedProfile) []string {
+	if candidate.ParsedFields == nil {
+		candidate.ParsedFields = map[string]float64{}
+	}
+	filled := []string{}
+	note := func(field string) {
+		candidate.ParsedFields[field] = profile.Confidence[field]
+		filled = append(filled, field)
+	}
+	fill := func(field string, value *string, parsed string) {
+		if *value == "" && parsed != "" {
+			*value = parsed
+			note(field)
+		}
+	}
+
+	fill("email", &candidate.Email, profile.Email)
+	fill("phone", &candidate.Phone, profile.Phone)
+	fill("location", &candidate.Location, profile.Location)
+	// Title and company describe one position, so they are only filled in
+	// together
+	if candidate.CurrentTitle == "" && candidate.CurrentCompany == "" {
+		fill("current_title", &candidate.CurrentTitle, profile.CurrentTitle)
+		fill("current_company", &candidate.CurrentCompany, profile.CurrentCompany)
+	}
+
+	if len(candidate.Links) == 0 && len(profile.Links) > 0 {
+		candidate.Links = profile.Links
+		note("links")
+	}
+	if len(candidate.WorkHistory) == 0 && len(profile.WorkHistory) > 0 {
+		candidate.WorkHistory = profile.WorkHistory
+		note("work_history")
+	}
+	if len(candidate.Education) == 0 && len(profile.Education) > 0 {
+		candidate.Education = profile.Education
+		note("education")
+	}
+	if len(candidate.Skills) == 0 && len(profile.Skills) > 0 {
+		candidate.Skills = profile.Skills
+		note("skills")
+	}
+	return filled
+}
+
+func StartResumeParser() {
+	interval := durationFromEnv("RESUME_PARSE_INTERVAL", 10*time.Second)
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ParseQueuedResumes()
+		}
+	}()
+}
@@ -0,0 +1,640 @@
+package utils
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sebastian/kandy/backend/models"
+)
+
+const (
+	maxParsedPositions  = 20
+	maxParsedEducation  = 10
+	maxParsedSkills     = 50
+	maxParsedLinks      = 5
+	maxParsedSkillWords = 5
+)
+
+// Sections of a resume, named by their headings
+const (
+	sectionHeader     = ""
+	sectionExperience = "experience"
+	sectionEducation  = "education"
+	sectionSkills     = "skills"
+	sectionOther      = "other"
+)
+
+var resumeHeadings = map[string]string{
+	"experience":              sectionExperience,
+	"work experience":         sectionExperience,
+	"professional experience": sectionExperience,
+	"relevant experience":     sectionExperience,
+	"employment":              sectionExperience,
+	"employment history":      sectionExperience,
+	"work history":            sectionExperience,
+	"career history":          sectionExperience,
+	"career":                  sectionExperience,
+	"berufserfahrung":         sectionExperience,
+	"education":               sectionEducation,
+	"academic background":     sectionEducation,
+	"education and training":  sectionEducation,
+	"ausbildung":              sectionEducation,
+	"skills":                  sectionSkills,
+	"technical skills":        sectionSkills,
+	"core skills":             sectionSkills,
+	"key skills":              sectionSkills,
+	"competencies":            sectionSkills,
+	"core competencies":       sectionSkills,
+	"technologies":            sectionSkills,
+	"tech stack":              sectionSkills,
+	"tools":                   sectionSkills,
+	"kenntnisse":              sectionSkills,
+	"summary":                 sectionOther,
+	"profile":                 sectionOther,
+	"professional summary":    sectionOther,
+	"about me":                sectionOther,
+	"objective":               sectionOther,
+	"projects":                sectionOther,
+	"certifications":          sectionOther,
+	"certificates":            sectionOther,
+	"languages":               sectionOther,
+	"interests":               sectionOther,
+	"hobbies":                 sectionOther,
+	"references":              sectionOther,
+	"publications":            sectionOther,
+	"awards":                  sectionOther,
+	"volunteering":            sectionOther,
+	"contact":                 sectionOther,
+}
+
+const resumeMonth = `(?:jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+
+var (
+	resumeEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	resumePhonePattern = regexp.MustCompile(`(?:\+|\b00)?\(?\d[\d\s().\-/]{5,}\d`)
+	resumePhoneLabel   = regexp.MustCompile(`(?i)\b(?:phone|tel|telephone|mobile|cell|telefon|handy)\b`)
+	resumeURLPattern   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s,;|()<>]+|\b(?:linkedin\.com/in|github\.com)/[^\s,;|()<>]+`)
+	resumeLocationTag  = regexp.MustCompile(`(?i)^(?:location|address|based in|lives in|wohnort)\s*:?\s*(.+)$`)
+	resumeCityPattern  = regexp.MustCompile(`^\p{Lu}[\p{L}.'\- ]{1,40}, \p{Lu}[\p{L}.'\- ]{1,40}$`)
+	resumeYearPattern  = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+
+	resumeDatePattern  = `(?:` + resumeMonth + `\s+|\d{1,2}\s*[/.]\s*)?(?:19|20)\d{2}`
+	resumeRangePattern = regexp.MustCompile(`(?i)(` + resumeDatePattern + `)\s*(?:-|–|—|to|until|bis)\s*(` + resumeDatePattern + `|present|current|now|today|heute)`)
+	resumeDateParts    = regexp.MustCompile(`(?i)^(?:(` + resumeMonth + `)\s+|(\d{1,2})\s*[/.]\s*)?((?:19|20)\d{2})$`)
+
+	resumeSeparators = regexp.MustCompile(`\s*(?:\||•|·|\s[-–—]\s|\t)\s*`)
+	resumeBullet     = regexp.MustCompile(`^[•\-*▪●◦·–>]+\s*`)
+	resumeSkillSplit = regexp.MustCompile(`\s*[,;|•·]\s*`)
+)
+
+var resumeMonths = map[string]string{
+	"jan": "01", "feb": "02", "mar": "03", "apr": "04", "may": "05", "jun": "06",
+	"jul": "07", "aug": "08", "sep": "09", "oct": "10", "nov": "11", "dec": "12",
+}
+
+// Words that mark a job title
+var titleWords = []string{
+	"engineer", "developer", "manager", "designer", "analyst", "consultant", "director",
+	"lead", "head", "intern", "specialist", "architect", "scientist", "officer",
+	"coordinator", "assistant", "administrator", "recruiter", "accountant", "owner",
+	"teacher", "nurse", "technician", "founder", "ceo", "cto", "cfo", "coo", "vp",
+	"president", "associate", "representative", "advisor", "executive", "writer",
+	"editor", "researcher", "partner", "supervisor", "programmer", "tester",
+	"administrator", "agent", "clerk", "trainee", "werkstudent", "entwickler", "leiter",
+}
+
+// Words that mark a school
+var institutionWords = []string{
+	"university", "college", "institute", "school", "academy", "polytechnic",
+	"universität", "universitat", "hochschule", "fachhochschule", "gymnasium",
+	"école", "ecole", "universidad", "università", "universiteit",
+}
+
+// Words that mark a degree
+var degreeWords = []string{
+	"bachelor", "master", "b.sc", "bsc", "m.sc", "msc", "b.a", "m.a", "b.s", "m.s",
+	"b.eng", "m.eng", "beng", "meng", "mba", "phd", "ph.d", "doctor", "doctorate",
+	"diploma", "diplom", "associate degree", "degree", "abitur", "a-levels", "ged",
+}
+
+// resumeLine is a line of a resume and the section it is in.
+type resumeLine struct {
+	text    string
+	section string
+}
+
+// ParseResume reads contact details, work history, education and skills
+// from the text of a resume. It works offline, on rules that fit the
+// usual resume layouts; Confidence says how sure it is of each field.
+func ParseResume(text string) *models.ParsedProfile {
+	profile := &models.ParsedProfile{Confidence: map[string]float64{}}
+	lines := resumeLines(text)
+
+	parseContact(profile, lines)
+	parseWorkHistory(profile, lines)
+	parseEducation(profile, lines)
+	parseSkills(profile, lines)
+
+	for field, confidence := range profile.Confidence {
+		profile.Confidence[field] = math.Round(confidence*100) / 100
+	}
+	return profile
+}
+
+// resumeLines splits a resume into lines and tells which section each is
+// in. Headings themselves are left out; blank lines are kept, as they
+// often separate entries.
+func resumeLines(text string) []resumeLine {
+	lines := []resumeLine{}
+	section := sectionHeader
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if heading, ok := resumeHeading(line); ok {
+			section = heading
+			continue
+		}
+		lines = append(lines, resumeLine{text: line, section: section})
+	}
+	return lines
+}
+
+// resumeHeading tells whether line is a section heading, such as
+// "WORK EXPERIENCE" or "Skills & Tools:".
+func resumeHeading(line string) (string, bool) {
+	if line == "" || len(line) > 40 {
+		return "", false
+	}
+	name := strings.ToLower(strings.TrimRight(line, ": "))
+	if section, ok := resumeHeadings[name]; ok {
+		return section, true
+	}
+	for _, separator := range []string{" & ", " and ", " / "} {
+		if first, _, found := strings.Cut(name, separator); found {
+			if section, ok := resumeHeadings[first]; ok {
+				return section, true
+			}
+		}
+	}
+	return "", false
+}
+
+func parseContact(profile *models.ParsedProfile, lines []resumeLine) {
+	header := []string{}
+	for _, line := range lines {
+		if line.section != sectionHeader {
+			break
+		}
+		if line.text != "" {
+			header = append(header, line.text)
+		}
+	}
+	if len(header) > 12 {
+		header = header[:12]
+	}
+
+	emails := []string{}
+	for _, line := range lines {
+		for _, email := range resumeEmailPattern.FindAllString(line.text, -1) {
+			if !containsFold(emails, email) {
+				emails = append(emails, email)
+			}
+		}
+	}
+	if len(emails) > 0 {
+		profile.Email = strings.ToLower(emails[0])
+		profile.Confidence["email"] = 0.95
+		if len(emails) > 1 {
+			profile.Confidence["email"] = 0.8
+		}
+	}
+
+	parsePhone(profile, lines)
+	parseLinks(profile, lines)
+
+	for _, line := range header[:min(len(header), 8)] {
+		if name, ok := resumeName(line); ok {
+			profile.Name = name
+			profile.Confidence["name"] = 0.6
+			if nameInEmail(name, profile.Email) {
+				profile.Confidence["name"] = 0.85
+			}
+			break
+		}
+	}
+
+	for _, line := range lines {
+		if match := resumeLocationTag.FindStringSubmatch(line.text); match != nil && len(match[1]) <= 100 {
+			profile.Location = strings.TrimSpace(match[1])
+			profile.Confidence["location"] = 0.85
+			return
+		}
+	}
+	for _, line := range header {
+		for _, part := range resumeSeparators.Split(line, -1) {
+			if resumeCityPattern.MatchString(part) && part != profile.Name {
+				profile.Location = part
+				profile.Confidence["location"] = 0.6
+				return
+			}
+		}
+	}
+}
+
+// parsePhone takes the first phone number that is labelled as one, starts
+// with a country code or stands in the header.
+func parsePhone(profile *models.ParsedProfile, lines []resumeLine) {
+	for _, line := range lines {
+		for _, match := range resumePhonePattern.FindAllString(line.text, -1) {
+			match = strings.TrimSpace(match)
+			digits := 0
+			for _, r := range match {
+				if unicode.IsDigit(r) {
+					digits++
+				}
+			}
+			if digits < 7 || digits > 15 || resumeRangePattern.MatchString(match) {
+				continue
+			}
+
+			confidence := 0.0
+			switch {
+			case resumePhoneLabel.MatchString(line.text) || strings.HasPrefix(match, "+"):
+				confidence = 0.9
+			case line.section == sectionHeader:
+				confidence = 0.7
+			default:
+				continue
+			}
+			phone, err := NormalizePhone(strings.ReplaceAll(match, "/", " "))
+			if err != nil || phone == "" || len(phone) > 30 {
+				continue
+			}
+			profile.Phone = phone
+			profile.Confidence["phone"] = confidence
+			return
+		}
+	}
+}
+
+// parseLinks collects LinkedIn and GitHub profiles, and takes other web
+// addresses for a portfolio.
+func parseLinks(profile *models.ParsedProfile, lines []resumeLine) {
+	confidence := 0.0
+	for _, line := range lines {
+		for _, match := range resumeURLPattern.FindAllString(line.text, -1) {
+			url := strings.TrimRight(match, ".")
+			lower := strings.ToLower(url)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				url = "https://" + url
+			}
+
+			link := models.CandidateLink{Type: models.LinkPortfolio, URL: url}
+			linkConfidence := 0.6
+			switch {
+			case strings.Contains(lower, "linkedin.com/in/"):
+				link.Type, linkConfidence = models.LinkLinkedIn, 0.9
+			case strings.Contains(lower, "github.com/"):
+				link.Type, linkConfidence = models.LinkGitHub, 0.9
+			case strings.Contains(lower, "linkedin.com") || strings.Contains(lower, "github.com"):
+				continue
+			}
+			if len(profile.Links) >= maxParsedLinks || hasLink(profile.Links, url) {
+				continue
+			}
+			profile.Links = append(profile.Links, link)
+			confidence = math.Max(confidence, linkConfidence)
+		}
+	}
+	if len(profile.Links) > 0 {
+		profile.Confidence["links"] = confidence
+	}
+}
+
+func hasLink(links []models.CandidateLink, url string) bool {
+	for _, link := range links {
+		if strings.EqualFold(link.URL, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// resumeName tells whether a header line, or its first part, looks like
+// a person's name: two to four capitalized words and nothing else.
+func resumeName(line string) (string, bool) {
+	line = resumeSeparators.Split(line, 2)[0]
+	lower := strings.ToLower(line)
+	if len(line) > 60 || strings.Contains(lower, "resume") || strings.Contains(lower, "curriculum") || lower == "cv" {
+		return "", false
+	}
+
+	words := strings.Fields(line)
+	if len(words) < 2 || len(words) > 4 {
+		return "", false
+	}
+	allCaps := strings.ToUpper(line) == line
+	for i, word := range words {
+		for _, r := range word {
+			if !unicode.IsLetter(r) && r != '-' && r != '\'' && r != '.' {
+				return "", false
+			}
+		}
+		first := []rune(word)[0]
+		if !unicode.IsUpper(first) {
+			return "", false
+		}
+		if allCaps {
+			words[i] = string(first) + strings.ToLower(string([]rune(word)[1:]))
+		}
+	}
+	return strings.Join(words, " "), true
+}
+
+// nameInEmail tells whether an email address contains part of name, which
+// makes it likelier that name is the candidate's.
+func nameInEmail(name, email string) bool {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if len(word) >= 3 && strings.Contains(local, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWorkHistory reads positions from the experience section. Every
+// date range starts a position; its title and company are on the same
+// line or the lines before it.
+func parseWorkHistory(profile *models.ParsedProfile, lines []resumeLine) {
+	section := sectionLines(lines, sectionExperience)
+	total := 0.0
+	boundary := 0
+	for i, line := range section {
+		if len(profile.WorkHistory) >= maxParsedPositions {
+			break
+		}
+		match := resumeRangePattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			if line == "" {
+				boundary = i + 1
+			}
+			continue
+		}
+
+		position := models.WorkExperience{
+			StartDate: resumeDate(line[match[2]:match[3]]),
+			EndDate:   resumeDate(line[match[4]:match[5]]),
+		}
+		parts := resumeParts(line[:match[0]] + " | " + line[match[1]:])
+		if len(parts) == 0 {
+			for j := max(boundary, i-2); j < i; j++ {
+				parts = append(parts, resumeParts(section[j])...)
+			}
+		}
+		if len(parts) < 2 && i+1 < len(section) && !resumeRangePattern.MatchString(section[i+1]) &&
+			!resumeBullet.MatchString(section[i+1]) && len(section[i+1]) <= 80 {
+			parts = append(parts, resumeParts(section[i+1])...)
+		}
+		position.Title, position.Company = titleAndCompany(parts)
+		boundary = i + 1
+
+		confidence := 0.5
+		if hasAnyWord(position.Title, titleWords) {
+			confidence += 0.2
+		}
+		if position.Company != "" {
+			confidence += 0.15
+		}
+		if position.Title == "" && position.Company == "" {
+			continue
+		}
+		profile.WorkHistory = append(profile.WorkHistory, position)
+		total += confidence
+
+		if position.EndDate == "" && profile.CurrentTitle == "" {
+			profile.CurrentTitle = position.Title
+			profile.CurrentCompany = position.Company
+			if position.Title != "" {
+				profile.Confidence["current_title"] = confidence * 0.9
+			}
+			if position.Company != "" {
+				profile.Confidence["current_company"] = confidence * 0.9
+			}
+		}
+	}
+	if len(profile.WorkHistory) > 0 {
+		profile.Confidence["work_history"] = total / float64(len(profile.WorkHistory))
+	}
+}
+
+// titleAndCompany tells the title from the company among the parts of a
+// position's description. "Engineer at Acme" is read the obvious way;
+// otherwise the part with a title word is the title, or the first one.
+func titleAndCompany(parts []string) (string, string) {
+	if len(parts) == 1 {
+		for _, separator := range []string{" at ", " @ ", ", "} {
+			if title, company, found := strings.Cut(parts[0], separator); found {
+				return strings.TrimSpace(title), strings.TrimSpace(company)
+			}
+		}
+		return parts[0], ""
+	}
+	if len(parts) == 0 {
+		return "", ""
+	}
+	if !hasAnyWord(parts[0], titleWords) && hasAnyWord(parts[1], titleWords) {
+		return parts[1], parts[0]
+	}
+	return parts[0], parts[1]
+}
+
+// parseEducation reads schools and degrees from the education section. A
+// line naming a school or degree the current entry already has starts the
+// next entry.
+func parseEducation(profile *models.ParsedProfile, lines []resumeLine) {
+	var entry *models.Education
+	total := 0.0
+	finish := func() {
+		if entry == nil || len(profile.Education) >= maxParsedEducation {
+			return
+		}
+		confidence := 0.5
+		if entry.Institution != "" && entry.Degree != "" {
+			confidence += 0.2
+		}
+		if entry.EndDate != "" {
+			confidence += 0.1
+		}
+		profile.Education = append(profile.Education, *entry)
+		total += confidence
+		entry = nil
+	}
+
+	for _, line := range sectionLines(lines, sectionEducation) {
+		if line == "" || resumeBullet.MatchString(line) {
+			continue
+		}
+		dates := resumeRangePattern.FindStringSubmatchIndex(line)
+		rest := line
+		if dates != nil {
+			rest = line[:dates[0]] + " | " + line[dates[1]:]
+		}
+
+		for _, part := range resumeParts(rest) {
+			isInstitution := hasAnyWord(part, institutionWords)
+			isDegree := !isInstitution && hasAnyWord(part, degreeWords)
+			if !isInstitution && !isDegree {
+				continue
+			}
+			if entry != nil && ((isInstitution && entry.Institution != "") || (isDegree && entry.Degree != "")) {
+				finish()
+			}
+			if entry == nil {
+				entry = &models.Education{}
+			}
+			if isInstitution {
+				entry.Institution = part
+			} else {
+				entry.Degree = part
+			}
+		}
+		if entry == nil {
+			continue
+		}
+
+		switch {
+		case dates != nil && entry.EndDate == "":
+			entry.StartDate = resumeDate(line[dates[2]:dates[3]])
+			entry.EndDate = resumeDate(line[dates[4]:dates[5]])
+		case entry.EndDate == "":
+			if years := resumeYearPattern.FindAllString(line, 2); len(years) == 2 {
+				entry.StartDate, entry.EndDate = years[0], years[1]
+			} else if len(years) == 1 {
+				entry.EndDate = years[0]
+			}
+		}
+	}
+	finish()
+
+	if len(profile.Education) > 0 {
+		profile.Confidence["education"] = total / float64(len(profile.Education))
+	}
+}
+
+// parseSkills reads the skills section, listed one per line or separated
+// by commas. Lines that read like sentences are left out.
+func parseSkills(profile *models.ParsedProfile, lines []resumeLine) {
+	for _, line := range sectionLines(lines, sectionSkills) {
+		line = resumeBullet.ReplaceAllString(line, "")
+		if _, list, found := strings.Cut(line, ":"); found {
+			line = list
+		}
+		items := resumeSkillSplit.Split(line, -1)
+		if len(items) == 1 && len(strings.Fields(line)) > maxParsedSkillWords {
+			continue
+		}
+
+		for _, skill := range items {
+			skill = strings.TrimSpace(strings.TrimRight(skill, ".:"))
+			if skill == "" || len(skill) > 50 || len(strings.Fields(skill)) > maxParsedSkillWords {
+				continue
+			}
+			if containsFold(profile.Skills, skill) {
+				continue
+			}
+			if len(profile.Skills) >= maxParsedSkills {
+				break
+			}
+			profile.Skills = append(profile.Skills, skill)
+		}
+	}
+	if len(profile.Skills) > 0 {
+		profile.Confidence["skills"] = 0.8
+	}
+}
+
+// sectionLines is the text of the lines in a section.
+func sectionLines(lines []resumeLine, section string) []string {
+	texts := []string{}
+	for _, line := range lines {
+		if line.section == section {
+			texts = append(texts, line.text)
+		}
+	}
+	return texts
+}
+
+// resumeParts splits a line at separators such as "|" and " - ", and drops
+// bullets, brackets and empty parts.
+func resumeParts(line string) []string {
+	parts := []string{}
+	for _, part := range resumeSeparators.Split(resumeBullet.ReplaceAllString(line, ""), -1) {
+		part = strings.Trim(part, " ,()[]")
+		if part != "" && len(part) <= 200 {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// resumeDate turns "March 2019", "03/2019" or "2019" into "2019-03" or
+// "2019". Present and the like are an empty date.
+func resumeDate(date string) string {
+	match := resumeDateParts.FindStringSubmatch(strings.TrimSpace(date))
+	if match == nil {
+		return ""
+	}
+	year := match[3]
+	switch {
+	case match[1] != "":
+		return year + "-" + resumeMonths[strings.ToLower(match[1])[:3]]
+	case match[2] != "":
+		month := match[2]
+		if len(month) == 1 {
+			month = "0" + month
+		}
+		if month >= "01" && month <= "12" {
+			return year + "-" + month
+		}
+	}
+	return year
+}
+
+// hasAnyWord tells whether text contains one of words as a whole word,
+// ignoring case.
+func hasAnyWord(text string, words []string) bool {
+	lower := strings.ToLower(text)
+	for _, word := range words {
+		for rest := lower; ; {
+			index := strings.Index(rest, word)
+			if index < 0 {
+				break
+			}
+			end := index + len(word)
+			if isWordBoundary(rest, index-1) && isWordBoundary(rest, end) {
+				return true
+			}
+			rest = rest[end:]
+		}
+	}
+	return false
+}
+
+func isWordBoundary(text string, index int) bool {
+	if index < 0 || index >= len(text) {
+		return true
+	}
+	r := rune(text[index])
+	return r < 0x80 && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/storage"
+)
+
+const (
+	// Text kept per resume; anything after it is not parsed or searched
+	maxResumeText = 100000
+	// A docx is a zip archive, so its document is read only up to this
+	// size, however well it compresses
+	maxDocxDocument = 20 << 20
+)
+
+var ErrUnsupportedDocument = errors.New("text can only be read from PDF, Word (.docx) and plain text files")
+
+// ExtractText reads the text of a stored document. Lines are kept, so
+// the result can be parsed line by line.
+func ExtractText(file *models.StoredFile) (string, error) {
+	reader, err := storage.Get().Open(file.Key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, MaxDocumentSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	switch file.ContentType {
+	case documentTypes[".pdf"].contentType:
+		text, err = pdfText(data)
+	case documentTypes[".docx"].contentType:
+		text, err = docxText(data)
+	case documentTypes[".txt"].contentType:
+		text = string(data)
+	default:
+		return "", ErrUnsupportedDocument
+	}
+	if err != nil {
+		return "", err
+	}
+	return cleanText(text), nil
+}
+
+// pdfText reads the text of a PDF. The PDF reader panics on some
+// malformed files, which makes them unreadable rather than fatal.
+func pdfText(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unreadable PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("unreadable PDF: %w", err)
+	}
+	var b strings.Builder
+	for i := 1; i <= reader.NumPage() && b.Len() < maxResumeText; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		writePDFLines(&b, page.Content().Text)
+	}
+	return b.String(), nil
+}
+
+// writePDFLines puts the glyphs of a page back into lines. PDFs place
+// glyphs one by one: glyphs on about the same baseline form a line, read
+// left to right, and a gap wider than a fraction of the font size is a
+// space. The line breaks the reader puts in are left out for that.
+func writePDFLines(b *strings.Builder, page []pdf.Text) {
+	glyphs := make([]pdf.Text, 0, len(page))
+	for _, glyph := range page {
+		if glyph.S != "\n" {
+			glyphs = append(glyphs, glyph)
+		}
+	}
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].Y > glyphs[j].Y })
+
+	for start := 0; start < len(glyphs); {
+		tolerance := math.Max(glyphs[start].FontSize*0.3, 1)
+		end := start + 1
+		for end < len(glyphs) && glyphs[start].Y-glyphs[end].Y <= tolerance {
+			end++
+		}
+		line := glyphs[start:end]
+		sort.SliceStable(line, func(i, j int) bool { return line[i].X < line[j].X })
+
+		for i, glyph := range line {
+			if i > 0 {
+				previous := line[i-1]
+				gap := glyph.X - (previous.X + previous.W)
+				if gap > math.Max(glyph.FontSize, 1)*0.15 && previous.S != " " && glyph.S != " " {
+					b.WriteByte(' ')
+				}
+			}
+			b.WriteString(glyph.S)
+		}
+		b.WriteByte('\n')
+		start = end
+	}
+	b.WriteByte('\n')
+}
+
+// docxText reads the paragraphs of a Word document.
+func docxText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("unreadable Word document: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		document, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("unreadable Word document: %w", err)
+		}
+		defer document.Close()
+		return wordprocessingText(io.LimitReader(document, maxDocxDocument))
+	}
+	return "", errors.New("unreadable Word document: no document.xml")
+}
+
+// wordprocessingText collects the text runs of a WordprocessingML
+// document, a line per paragraph.
+func wordprocessingText(r io.Reader) (string, error) {
+	var b strings.Builder
+	decoder := xml.NewDecoder(r)
+	inText := false
+	for b.Len() < maxResumeText {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("unreadable Word document: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(element)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// cleanText makes extracted text safe to store: valid UTF-8 without NUL
+// bytes, trimmed lines, at most one blank line in a row and at most
+// maxResumeText bytes.
+func cleanText(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var b strings.Builder
+	blank := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank {
+				b.WriteByte('\n')
+			}
+			blank = true
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		blank = false
+	}
+
+	text = strings.TrimSpace(b.String())
+	if len(text) > maxResumeText {
+		text = text[:maxResumeText]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text
+}
@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"github.com/sebastian/kandy/backend/search"
+	"gorm.io/gorm"
+)
+
+const (
+	searchIndexBatchSize = 500
+	// Events are applied as soon as they are seen, but the checkpoint only
+	// passes them once they are this old: a transaction still open at the
+	// time may yet commit an event with a lower id
+	searchIndexSettleTime = time.Minute
+	// Events are removed after this long; an index that fell further
+	// behind than that is rebuilt
+	searchIndexRetention = 7 * 24 * time.Hour
+	// The checkpoint is saved at least this often, so an index that is up
+	// to date is not taken for one that fell behind
+	searchIndexCheckpointAge = time.Hour
+)
+
+var (
+	searchIndexMu sync.Mutex
+	// searchIndexApplied holds the events past the checkpoint that were
+	// applied already
+	searchIndexApplied = map[uint]bool{}
+)
+
+// QueueSearchIndex queues the records of entity selected by ids, a query
+// for their id, to be indexed again. Saving or deleting a model queues
+// it; this is for changes that go around the model hooks.
+func QueueSearchIndex(tx *gorm.DB, entity string, ids *gorm.DB) error {
+	return tx.Exec("INSERT INTO search_index_events (entity, entity_id, created_at) SELECT ?, id, ? FROM (?) AS changed",
+		entity, time.Now(), ids).Error
+}
+
+// QueueSearchRebuild asks every server with an embedded search index to
+// build it again from the database.
+func QueueSearchRebuild() error {
+	return database.DB.Create(&models.SearchIndexEvent{Entity: models.SearchAll}).Error
+}
+
+// SyncSearchIndex applies the search index events that came in since the
+// last sync to index. An index that was never built, fell too far behind
+// or was asked to be rebuilt is built again from the database.
+func SyncSearchIndex(index search.SyncedIndex) error {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+
+	checkpoint, err := index.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if checkpoint.SyncedAt.IsZero() || time.Since(checkpoint.SyncedAt) > searchIndexRetention-24*time.Hour {
+		return rebuildSearchIndex(index)
+	}
+
+	previous := checkpoint
+	settled := time.Now().Add(-searchIndexSettleTime)
+	waiting := false
+	for after := checkpoint.EventID; ; {
+		var events []models.SearchIndexEvent
+		if err := database.DB.Where("id > ?", after).Order("id").Limit(searchIndexBatchSize).Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		changed := map[search.Kind][]uint{}
+		for _, event := range events {
+			if searchIndexApplied[event.ID] {
+				continue
+			}
+			if event.Entity == models.SearchAll {
+				return rebuildSearchIndex(index)
+			}
+			kind := search.Kind(event.Entity)
+			changed[kind] = append(changed[kind], event.EntityID)
+		}
+		for kind, ids := range changed {
+			if err := indexSearchRecords(index, kind, ids); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range events {
+			if !waiting && event.CreatedAt.Before(settled) {
+				checkpoint.EventID = event.ID
+			} else {
+				waiting = true
+				searchIndexApplied[event.ID] = true
+			}
+		}
+		after = events[len(events)-1].ID
+		if len(events) < searchIndexBatchSize {
+			break
+		}
+	}
+
+	for id := range searchIndexApplied {
+		if id <= checkpoint.EventID {
+			delete(searchIndexApplied, id)
+		}
+	}
+	if checkpoint.EventID == previous.EventID && time.Since(previous.SyncedAt) < searchIndexCheckpointAge {
+		return nil
+	}
+	checkpoint.SyncedAt = time.Now()
+	return index.SetCheckpoint(checkpoint)
+}
+
+// rebuildSearchIndex builds index again from the records in the database.
+func rebuildSearchIndex(index search.SyncedIndex) error {
+	started := time.Now()
+
+	// Events since the last settled one are applied again afterwards, the
+	// records may have changed while they were read. Rebuild requests among
+	// them are covered by this rebuild.
+	var checkpoint search.Checkpoint
+	if err := database.DB.Model(&models.SearchIndexEvent{}).
+		Where("created_at < ?", started.Add(-searchIndexSettleTime)).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&checkpoint.EventID).Error; err != nil {
+		return err
+	}
+	var rebuilds []uint
+	if err := database.DB.Model(&models.SearchIndexEvent{}).
+		Where("id > ? AND entity = ?", checkpoint.EventID, models.SearchAll).
+		Pluck("id", &rebuilds).Error; err != nil {
+		return err
+	}
+
+	if err := index.Reset(); err != nil {
+		return err
+	}
+	for kind, table := range map[search.Kind]string{
+		search.KindCandidate: "candidates",
+		search.KindJob:       "jobs",
+		search.KindUser:      "users",
+	} {
+		for after := uint(0); ; {
+			var ids []uint
+			if err := database.DB.Table(table).
+				Where("deleted_at IS NULL AND id > ?", after).
+				Order("id").
+				Limit(searchIndexBatchSize).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := indexSearchRecords(index, kind, ids); err != nil {
+				return err
+			}
+			after = ids[len(ids)-1]
+		}
+	}
+
+	searchIndexApplied = map[uint]bool{}
+	for _, id := range rebuilds {
+		searchIndexApplied[id] = true
+	}
+	checkpoint.SyncedAt = time.Now()
+	if err := index.SetCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	log.Printf("Rebuilt search index in %s", time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// indexSearchRecords indexes the current version of records; those that
+// were deleted are removed from the index.
+func indexSearchRecords(index search.SyncedIndex, kind search.Kind, ids []uint) error {
+	var docs []search.Document
+	switch kind {
+	case search.KindCandidate:
+		var candidates []models.Candidate
+		if err := database.DB.Where("id IN ?", ids).Find(&candidates).Error; err != nil {
+			return err
+		}
+		for i := range candidates {
+			candidate := &candidates[i]
+			docs = append(docs, search.Document{
+				Kind: kind,
+				ID:   candidate.ID,
+				Name: candidate.Name,
+				Text: searchText(candidate.Email, emailWords(candidate.Email), candidate.CurrentTitle,
+					candidate.CurrentCompany, candidate.Location, strings.Join(candidate.Skills, " "),
+					strings.Join(candidate.Tags, " ")),
+			})
+		}
+	case search.KindJob:
+		var jobs []models.Job
+		if err := database.DB.Where("id IN ?", ids).Find(&jobs).Error; err != nil {
+			return err
+		}
+		for i := range jobs {
+			docs = append(docs, search.Document{
+				Kind: kind,
+				ID:   jobs[i].ID,
+				Name: jobs[i].Title,
+				Text: searchText(jobs[i].Department, jobs[i].Location, strings.Join(jobs[i].Tags, " ")),
+			})
+		}
+	case search.KindUser:
+		var users []models.User
+		if err := database.DB.Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return err
+		}
+		for i := range users {
+			docs = append(docs, search.Document{
+				Kind: kind,
+				ID:   users[i].ID,
+				Name: users[i].Name,
+				Text: searchText(users[i].Email, emailWords(users[i].Email), users[i].JobTitle),
+			})
+		}
+	default:
+		return nil
+	}
+
+	found := make(map[uint]bool, len(docs))
+	for _, doc := range docs {
+		found[doc.ID] = true
+	}
+	var deleted []uint
+	for _, id := range ids {
+		if !found[id] {
+			deleted = append(deleted, id)
+		}
+	}
+
+	if len(docs) > 0 {
+		if err := index.Put(docs); err != nil {
+			return err
+		}
+	}
+	if len(deleted) > 0 {
+		return index.Delete(kind, deleted)
+	}
+	return nil
+}
+
+func searchText(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// emailWords splits an email address into words, so the parts of the name
+// and domain can be found on their own.
+func emailWords(email string) string {
+	return strings.NewReplacer("@", " ", ".", " ", "_", " ", "-", " ", "+", " ").Replace(email)
+}
+
+// CleanupSearchIndexEvents removes search index events every index has
+// long applied.
+func CleanupSearchIndexEvents() {
+	result := database.DB.Where("created_at < ?", time.Now().Add(-searchIndexRetention)).Delete(&models.SearchIndexEvent{})
+	if result.Error != nil {
+		log.Printf("Failed to cleanup search index events: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Cleaned up %d search index events", result.RowsAffected)
+	}
+}
+
+// StartSearchIndexer keeps an embedded search index in line with the
+// database. The postgres driver has no index of its own to keep.
+func StartSearchIndexer() {
+	index, ok := search.Get().(search.SyncedIndex)
+	if !ok {
+		return
+	}
+	interval := durationFromEnv("SEARCH_SYNC_INTERVAL", 2*time.Second)
+
+	run := func() {
+		if err := SyncSearchIndex(index); err != nil {
+			log.Printf("Failed to sync search index: %v", err)
+		}
+	}
+
+	// The first sync may build the whole index, so it does not hold up
+	// the server starting
+	ticker := time.NewTicker(interval)
+	go func() {
+		run()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionPolicy controls how long sessions live.
+//
+// IdleTimeout ends a session that has not been used for that long, even if
+// its JWT is still valid. MaxLifetime caps a session regardless of activity.
+// TouchInterval throttles how often activity is written back to the database.
+type SessionPolicy struct {
+	IdleTimeout   time.Duration
+	MaxLifetime   time.Duration
+	TouchInterval time.Duration
+}
+
+var (
+	sessionPolicyOnce sync.Once
+	sessionPolicy     SessionPolicy
+)
+
+// GetSessionPolicy reads the policy from SESSION_IDLE_TIMEOUT,
+// SESSION_MAX_LIFETIME and SESSION_TOUCH_INTERVAL on first use.
+func GetSessionPolicy() SessionPolicy {
+	sessionPolicyOnce.Do(func() {
+		sessionPolicy = SessionPolicy{
+			IdleTimeout:   durationFromEnv("SESSION_IDLE_TIMEOUT", 7*24*time.Hour),
+			MaxLifetime:   durationFromEnv("SESSION_MAX_LIFETIME", 30*24*time.Hour),
+			TouchInterval: durationFromEnv("SESSION_TOUCH_INTERVAL", time.Minute),
+		}
+		if sessionPolicy.IdleTimeout > sessionPolicy.MaxLifetime {
+			sessionPolicy.IdleTimeout = sessionPolicy.MaxLifetime
+		}
+	})
+	return sessionPolicy
+}
+
+// ExpiresAt is when a session created at createdAt and last used at
+// lastUsedAt ends: after the idle timeout, but never past the absolute lifetime.
+func (p SessionPolicy) ExpiresAt(createdAt, lastUsedAt time.Time) time.Time {
+	idle := lastUsedAt.Add(p.IdleTimeout)
+	absolute := createdAt.Add(p.MaxLifetime)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+// NeedsTouch reports whether activity at now should be written back for a
+// session last recorded as used at lastUsedAt.
+func (p SessionPolicy) NeedsTouch(lastUsedAt, now time.Time) bool {
+	return now.Sub(lastUsedAt) >= p.TouchInterval
+}
+
+// MagicLinkTTL is how long a passwordless sign-in link stays valid.
+func MagicLinkTTL() time.Duration {
+	return durationFromEnv("MAGIC_LINK_TTL", 15*time.Minute)
+}
+
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
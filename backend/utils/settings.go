@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/sebastian/kandy/backend/database"
+	"github.com/sebastian/kandy/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSetting decodes the setting stored under key into dest. dest is left
+// untouched when the setting has never been saved, so callers pre-fill it
+// with defaults.
+func GetSetting(key string, dest interface{}) error {
+	var setting models.Setting
+	err := database.DB.Where("key = ?", key).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(setting.Value), dest)
+}
+
+// SaveSetting stores value under key, replacing any previous value.
+func SaveSetting(key string, value interface{}, updatedBy *uint) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	setting := models.Setting{
+		Key:       key,
+		Value:     string(encoded),
+		UpdatedBy: updatedBy,
+	}
+	return database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Device types reported by ParseUserAgent
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// UserAgentInfo is the parsed form of a User-Agent header.
+type UserAgentInfo struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version,omitempty"`
+	DeviceType     string `json:"device_type"`
+}
+
+type uaPattern struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Order matters: Edge and Opera also announce Chrome, Chrome also announces Safari.
+var browserPatterns = []uaPattern{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/([\d.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+	{"curl", regexp.MustCompile(`curl/([\d.]+)`)},
+	{"Bruno", regexp.MustCompile(`bruno-runtime/([\d.]+)`)},
+	{"Postman", regexp.MustCompile(`PostmanRuntime/([\d.]+)`)},
+}
+
+var osPatterns = []uaPattern{
+	{"iOS", regexp.MustCompile(`(?:iPhone|iPad|iPod).*? OS ([\d_]+)`)},
+	{"Android", regexp.MustCompile(`Android ([\d.]+)`)},
+	{"Windows", regexp.MustCompile(`Windows NT ([\d.]+)`)},
+	{"ChromeOS", regexp.MustCompile(`CrOS \S+ ([\d.]+)`)},
+	{"macOS", regexp.MustCompile(`Mac OS X ([\d_.]+)`)},
+	{"Linux", regexp.MustCompile(`Linux()`)},
+}
+
+var windowsVersions = map[string]string{
+	"10.0": "10/11",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+}
+
+var botPattern = regexp.MustCompile(`(?i)bot|crawler|spider|slurp|preview`)
+
+// ParseUserAgent extracts browser, OS and device class from a User-Agent
+// header. It only needs to be good enough for people to recognise their own
+// sessions, not for analytics.
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	info := UserAgentInfo{
+		Browser:    "Unknown",
+		OS:         "Unknown",
+		DeviceType: DeviceUnknown,
+	}
+	if userAgent == "" {
+		return info
+	}
+
+	for _, p := range browserPatterns {
+		if m := p.pattern.FindStringSubmatch(userAgent); m != nil {
+			info.Browser = p.name
+			info.BrowserVersion = majorVersion(m[1])
+			break
+		}
+	}
+
+	for _, p := range osPatterns {
+		if m := p.pattern.FindStringSubmatch(userAgent); m != nil {
+			info.OS = p.name
+			version := strings.ReplaceAll(m[1], "_", ".")
+			if p.name == "Windows" {
+				if named, ok := windowsVersions[version]; ok {
+					version = named
+				}
+			}
+			info.OSVersion = version
+			break
+		}
+	}
+
+	switch {
+	case botPattern.MatchString(userAgent):
+		info.DeviceType = DeviceBot
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(info.OS == "Android" && !strings.Contains(userAgent, "Mobile")):
+		info.DeviceType = DeviceTablet
+	case strings.Contains(userAgent, "Mobi") || strings.Contains(userAgent, "iPhone"):
+		info.DeviceType = DeviceMobile
+	case info.OS == "Windows" || info.OS == "macOS" || info.OS == "Linux" || info.OS == "ChromeOS":
+		info.DeviceType = DeviceDesktop
+	}
+
+	return info
+}
+
+// Label is a short human readable description such as "Chrome on macOS".
+func (u UserAgentInfo) Label() string {
+	return u.Browser + " on " + u.OS
+}
+
+// Fingerprint identifies a device class for new-device detection. Versions
+// are left out so browser updates do not look like a new device.
+func (u UserAgentInfo) Fingerprint() string {
+	sum := sha256.Sum256([]byte(u.Browser + "|" + u.OS + "|" + u.DeviceType))
+	return hex.EncodeToString(sum[:16])
+}
+
+func majorVersion(version string) string {
+	if i := strings.IndexByte(version, '.'); i > 0 {
+		return version[:i]
+	}
+	return version
+}
@@ -0,0 +1,114 @@
+import { z } from 'zod';
+import { attachmentSchema } from './attachments.schema';
+
+export const questionTypeSchema = z.enum([
+  'short_text',
+  'long_text',
+  'single_choice',
+  'multiple_choice',
+  'yes_no',
+  'number',
+  'file',
+]);
+
+export const knockoutRuleSchema = z.object({
+  reject_options: z.array(z.string()).optional(),
+  min: z.number().optional(),
+  max: z.number().optional(),
+});
+
+export const questionDefinitionSchema = z.object({
+  label: z.string().min(1).max(500),
+  help_text: z.string(),
+  type: questionTypeSchema,
+  options: z.array(z.string()).nullable(),
+  required: z.boolean(),
+  knockout: knockoutRuleSchema.nullable(),
+});
+
+const toQuestion = (question: z.infer<typeof questionDefinitionSchema>) => ({
+  label: question.label,
+  helpText: question.help_text,
+  type: question.type,
+  options: question.options ?? [],
+  required: question.required,
+  knockout: question.knockout,
+});
+
+export const libraryQuestionSchema = questionDefinitionSchema
+  .extend({
+    id: z.number(),
+  })
+  .transform((question) => ({
+    id: question.id,
+    ...toQuestion(question),
+  }));
+
+export const jobQuestionSchema = questionDefinitionSchema
+  .extend({
+    id: z.number(),
+    job_id: z.number(),
+    library_question_id: z.number().nullable(),
+    position: z.number(),
+  })
+  .transform((question) => ({
+    id: question.id,
+    jobId: question.job_id,
+    libraryQuestionId: question.library_question_id,
+    position: question.position,
+    ...toQuestion(question),
+  }));
+
+export const jobFormResponseSchema = z.object({
+  questions: z.array(jobQuestionSchema),
+  can_manage: z.boolean().optional(),
+});
+
+export const jobFormRequestSchema = z.object({
+  questions: z.array(
+    questionDefinitionSchema.partial().extend({
+      id: z.number().optional(),
+      library_question_id: z.number().optional(),
+    }),
+  ),
+});
+
+export const libraryQuestionListResponseSchema = z.object({
+  questions: z.array(libraryQuestionSchema),
+});
+
+export const applicationAnswerSchema = z
+  .object({
+    id: z.number(),
+    question_id: z.number(),
+    label: z.string(),
+    type: questionTypeSchema,
+    values: z.array(z.string()).nullable(),
+    attachment_id: z.number().nullable(),
+    attachment: attachmentSchema.optional(),
+    knocked_out: z.boolean(),
+  })
+  .transform((answer) => ({
+    id: answer.id,
+    questionId: answer.question_id,
+    label: answer.label,
+    type: answer.type,
+    values: answer.values ?? [],
+    attachmentId: answer.attachment_id,
+    attachment: answer.attachment,
+    knockedOut: answer.knocked_out,
+  }));
+
+export const applicationAnswersResponseSchema = z.object({
+  answers: z.array(applicationAnswerSchema),
+});
+
+export type QuestionType = z.infer<typeof questionTypeSchema>;
+export type KnockoutRule = z.infer<typeof knockoutRuleSchema>;
+export type LibraryQuestion = z.infer<typeof libraryQuestionSchema>;
+export type JobQuestion = z.infer<typeof jobQuestionSchema>;
+export type JobFormResponse = z.infer<typeof jobFormResponseSchema>;
+export type JobFormRequest = z.infer<typeof jobFormRequestSchema>;
+export type LibraryQuestionListResponse = z.infer<typeof libraryQuestionListResponseSchema>;
+export type ApplicationAnswer = z.infer<typeof applicationAnswerSchema>;
+export type ApplicationAnswersResponse = z.infer<typeof applicationAnswersResponseSchema>;
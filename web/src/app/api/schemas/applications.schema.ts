@@ -0,0 +1,278 @@
+import { z } from 'zod';
+import { customFieldValuesSchema } from './custom-fields.schema';
+import { jobSchema, paginationSchema } from './jobs.schema';
+
+export const stageTypeSchema = z.enum(['applied', 'screen', 'interview', 'assessment', 'offer', 'hired']);
+export const applicationStatusSchema = z.enum(['active', 'rejected', 'withdrawn', 'hired']);
+export const rejectionReasonSchema = z.enum([
+  'unqualified',
+  'better_candidate',
+  'position_filled',
+  'compensation',
+  'no_show',
+  'unresponsive',
+  'other',
+]);
+export const candidateSourceSchema = z.enum([
+  'career_site',
+  'job_board',
+  'referral',
+  'sourced',
+  'agency',
+  'internal',
+  'other',
+]);
+
+export const jobStageSchema = z
+  .object({
+    id: z.number(),
+    job_id: z.number(),
+    name: z.string(),
+    type: stageTypeSchema,
+    position: z.number(),
+  })
+  .transform((stage) => ({
+    id: stage.id,
+    jobId: stage.job_id,
+    name: stage.name,
+    type: stage.type,
+    position: stage.position,
+  }));
+
+export const candidateLinkSchema = z.object({
+  type: z.string(),
+  url: z.string(),
+});
+
+/** Dates are '2006' or '2006-01'; an empty end date means current */
+export const workExperienceSchema = z
+  .object({
+    title: z.string(),
+    company: z.string(),
+    start_date: z.string(),
+    end_date: z.string(),
+  })
+  .transform((position) => ({
+    title: position.title,
+    company: position.company,
+    startDate: position.start_date,
+    endDate: position.end_date,
+  }));
+
+export const educationSchema = z
+  .object({
+    institution: z.string(),
+    degree: z.string(),
+    start_date: z.string(),
+    end_date: z.string(),
+  })
+  .transform((school) => ({
+    institution: school.institution,
+    degree: school.degree,
+    startDate: school.start_date,
+    endDate: school.end_date,
+  }));
+
+export const candidateSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    email: z.string(),
+    phone: z.string(),
+    location: z.string(),
+    current_title: z.string(),
+    current_company: z.string(),
+    links: z.array(candidateLinkSchema),
+    work_history: z.array(workExperienceSchema),
+    education: z.array(educationSchema),
+    skills: z.array(z.string()),
+    parsed_fields: z.record(z.string(), z.number()),
+    source: candidateSourceSchema,
+    source_detail: z.string(),
+    tags: z.array(z.string()).nullish(),
+    custom_fields: customFieldValuesSchema.nullish(),
+    created_at: z.string(),
+    updated_at: z.string(),
+  })
+  .transform((candidate) => ({
+    id: candidate.id,
+    name: candidate.name,
+    email: candidate.email,
+    phone: candidate.phone,
+    location: candidate.location,
+    currentTitle: candidate.current_title,
+    currentCompany: candidate.current_company,
+    links: candidate.links,
+    workHistory: candidate.work_history,
+    education: candidate.education,
+    skills: candidate.skills,
+    /** Fields filled in from a resume, with the parser's confidence from 0 to 1 */
+    parsedFields: candidate.parsed_fields,
+    source: candidate.source,
+    sourceDetail: candidate.source_detail,
+    tags: candidate.tags ?? [],
+    customFields: candidate.custom_fields ?? {},
+    createdAt: candidate.created_at,
+    updatedAt: candidate.updated_at,
+  }));
+
+export const applicationSchema = z
+  .object({
+    id: z.number(),
+    candidate_id: z.number(),
+    job_id: z.number(),
+    stage_id: z.number(),
+    stage: jobStageSchema.optional(),
+    status: applicationStatusSchema,
+    applied_at: z.string(),
+    stage_changed_at: z.string(),
+    cover_letter: z.string(),
+    tags: z.array(z.string()).nullish(),
+    custom_fields: customFieldValuesSchema.nullish(),
+    rejection_reason: z.string(),
+    rejection_note: z.string(),
+    rejected_at: z.string().nullable(),
+    withdrawn_at: z.string().nullable(),
+    hired_at: z.string().nullable(),
+    resume_id: z.number().nullable(),
+    candidate: candidateSchema.optional(),
+    job_title: z.string().optional(),
+  })
+  .transform((application) => ({
+    id: application.id,
+    candidateId: application.candidate_id,
+    jobId: application.job_id,
+    stageId: application.stage_id,
+    stage: application.stage,
+    status: application.status,
+    appliedAt: application.applied_at,
+    stageChangedAt: application.stage_changed_at,
+    coverLetter: application.cover_letter,
+    tags: application.tags ?? [],
+    customFields: application.custom_fields ?? {},
+    rejectionReason: application.rejection_reason,
+    rejectionNote: application.rejection_note,
+    rejectedAt: application.rejected_at,
+    withdrawnAt: application.withdrawn_at,
+    hiredAt: application.hired_at,
+    resumeId: application.resume_id,
+    candidate: application.candidate,
+    jobTitle: application.job_title,
+  }));
+
+export const pipelineResponseSchema = z.object({
+  job: jobSchema,
+  columns: z.array(
+    z.object({
+      stage: jobStageSchema,
+      count: z.number(),
+      applications: z.array(applicationSchema),
+    }),
+  ),
+  rejected: z.object({
+    count: z.number(),
+    applications: z.array(applicationSchema),
+  }),
+  can_manage: z.boolean(),
+});
+
+export const applicationResponseSchema = z.object({
+  message: z.string().optional(),
+  application: applicationSchema,
+  can_manage: z.boolean().optional(),
+});
+
+export const moveApplicationRequestSchema = z.object({
+  stage_id: z.number(),
+});
+
+export const applicationStatusRequestSchema = z.object({
+  status: z.enum(['active', 'rejected', 'withdrawn']),
+  rejection_reason: rejectionReasonSchema.optional(),
+  note: z.string().optional(),
+});
+
+export const activityTypeSchema = z.enum([
+  'applied',
+  'stage_changed',
+  'status_changed',
+  'note',
+  'email',
+  'interview',
+  'scorecard',
+]);
+
+export const activitySchema = z
+  .object({
+    id: z.number(),
+    application_id: z.number(),
+    candidate_id: z.number(),
+    job_id: z.number(),
+    type: activityTypeSchema,
+    actor_id: z.number().nullable(),
+    actor_name: z.string(),
+    from_stage: z.string(),
+    to_stage: z.string(),
+    from_status: z.string(),
+    to_status: z.string(),
+    body: z.string(),
+    details: z.record(z.string(), z.unknown()).nullable(),
+    occurred_at: z.string(),
+    job_title: z.string().optional(),
+  })
+  .transform((activity) => ({
+    id: activity.id,
+    applicationId: activity.application_id,
+    candidateId: activity.candidate_id,
+    jobId: activity.job_id,
+    type: activity.type,
+    actorId: activity.actor_id,
+    actorName: activity.actor_name,
+    fromStage: activity.from_stage,
+    toStage: activity.to_stage,
+    fromStatus: activity.from_status,
+    toStatus: activity.to_status,
+    body: activity.body,
+    details: activity.details ?? {},
+    occurredAt: activity.occurred_at,
+    jobTitle: activity.job_title,
+  }));
+
+export const activityListResponseSchema = z.object({
+  activities: z.array(activitySchema),
+  pagination: paginationSchema,
+});
+
+export const logActivityRequestSchema = z.object({
+  type: z.enum(['note', 'email', 'interview', 'scorecard']),
+  body: z.string().optional(),
+  occurred_at: z.string().optional(),
+  subject: z.string().max(200).optional(),
+  direction: z.enum(['outbound', 'inbound']).optional(),
+  title: z.string().max(200).optional(),
+  duration_minutes: z.number().int().min(0).max(1440).optional(),
+  rating: z.number().int().min(1).max(5).optional(),
+  recommendation: z.enum(['strong_yes', 'yes', 'no', 'strong_no']).optional(),
+});
+
+export const activityResponseSchema = z.object({
+  message: z.string(),
+  activity: activitySchema,
+});
+
+export type StageType = z.infer<typeof stageTypeSchema>;
+export type JobStage = z.infer<typeof jobStageSchema>;
+export type RejectionReason = z.infer<typeof rejectionReasonSchema>;
+export type WorkExperience = z.infer<typeof workExperienceSchema>;
+export type Education = z.infer<typeof educationSchema>;
+export type Candidate = z.infer<typeof candidateSchema>;
+export type Application = z.infer<typeof applicationSchema>;
+export type PipelineResponse = z.infer<typeof pipelineResponseSchema>;
+/** A stage id, or 'rejected' for the rejected and withdrawn applications */
+export type PipelineColumnKey = number | 'rejected';
+export type ApplicationResponse = z.infer<typeof applicationResponseSchema>;
+export type Activity = z.infer<typeof activitySchema>;
+export type ActivityListResponse = z.infer<typeof activityListResponseSchema>;
+export type LogActivityRequest = z.infer<typeof logActivityRequestSchema>;
+export type ActivityResponse = z.infer<typeof activityResponseSchema>;
+export type ApplicationStatusRequest = z.infer<typeof applicationStatusRequestSchema>;
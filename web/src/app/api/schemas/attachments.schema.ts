@@ -0,0 +1,60 @@
+import { z } from 'zod';
+
+export const attachmentKindSchema = z.enum(['resume', 'cover_letter', 'offer', 'answer', 'other']);
+
+export const storedFileSchema = z
+  .object({
+    id: z.number(),
+    sha256: z.string(),
+    size: z.number(),
+    content_type: z.string(),
+  })
+  .transform((file) => ({
+    id: file.id,
+    sha256: file.sha256,
+    size: file.size,
+    contentType: file.content_type,
+  }));
+
+export const attachmentSchema = z
+  .object({
+    id: z.number(),
+    application_id: z.number(),
+    kind: attachmentKindSchema,
+    name: z.string(),
+    file: storedFileSchema.optional(),
+    uploaded_by: z.number().nullable(),
+    created_at: z.string(),
+  })
+  .transform((attachment) => ({
+    id: attachment.id,
+    applicationId: attachment.application_id,
+    kind: attachment.kind,
+    name: attachment.name,
+    file: attachment.file,
+    uploadedBy: attachment.uploaded_by,
+    createdAt: attachment.created_at,
+  }));
+
+export const attachmentListResponseSchema = z.object({
+  attachments: z.array(attachmentSchema),
+  resume_id: z.number().nullable(),
+  can_manage: z.boolean(),
+});
+
+export const attachmentResponseSchema = z.object({
+  message: z.string(),
+  attachment: attachmentSchema,
+});
+
+export const attachmentLinkResponseSchema = z.object({
+  url: z.string(),
+  expires_at: z.string(),
+});
+
+export type AttachmentKind = z.infer<typeof attachmentKindSchema>;
+export type StoredFile = z.infer<typeof storedFileSchema>;
+export type Attachment = z.infer<typeof attachmentSchema>;
+export type AttachmentListResponse = z.infer<typeof attachmentListResponseSchema>;
+export type AttachmentResponse = z.infer<typeof attachmentResponseSchema>;
+export type AttachmentLinkResponse = z.infer<typeof attachmentLinkResponseSchema>;
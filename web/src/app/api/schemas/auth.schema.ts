@@ -26,19 +26,53 @@ export const registerRequestSchema = z.object({
   password: z.string().min(8, 'Password must be at least 8 characters'),
 });
 
+// Tokens are omitted when the account has to be approved by an admin first
 export const registerResponseSchema = z.object({
   message: z.string(),
-  token: z.string(),
-  refresh_token: z.string(),
+  token: z.string().optional(),
+  refresh_token: z.string().optional(),
   user: z.object({
     id: z.number(),
     email: z.string().email(),
     name: z.string(),
     role: z.string(),
     last_login_at: z.string().optional(),
+    approval_status: z.enum(['pending', 'approved', 'rejected']).optional(),
   }),
 });
 
+export const registrationPolicyResponseSchema = z.object({
+  mode: z.enum(['disabled', 'open', 'domain_allowlist']),
+  enabled: z.boolean(),
+  allowed_domains: z.array(z.string()),
+  requires_approval: z.boolean(),
+});
+
+export const magicLinkRequestSchema = z.object({
+  email: z.string().email('Invalid email address'),
+});
+
+export const magicLinkConsumeRequestSchema = z.object({
+  token: z.string().min(1),
+});
+
+export const reportSessionRequestSchema = z.object({
+  token: z.string().min(1),
+});
+
+export const emailChangeConfirmRequestSchema = z.object({
+  token: z.string().min(1),
+});
+
+export const emailChangeConfirmResponseSchema = z.object({
+  message: z.string(),
+  email: z.string().email(),
+});
+
+export const messageResponseSchema = z.object({
+  message: z.string(),
+});
+
 export const refreshTokenRequestSchema = z.object({
   refresh_token: z.string(),
 });
@@ -58,6 +92,13 @@ export type LoginRequest = z.infer<typeof loginRequestSchema>;
 export type LoginResponse = z.infer<typeof loginResponseSchema>;
 export type RegisterRequest = z.infer<typeof registerRequestSchema>;
 export type RegisterResponse = z.infer<typeof registerResponseSchema>;
+export type RegistrationPolicyResponse = z.infer<typeof registrationPolicyResponseSchema>;
+export type MagicLinkRequest = z.infer<typeof magicLinkRequestSchema>;
+export type MagicLinkConsumeRequest = z.infer<typeof magicLinkConsumeRequestSchema>;
+export type ReportSessionRequest = z.infer<typeof reportSessionRequestSchema>;
+export type EmailChangeConfirmRequest = z.infer<typeof emailChangeConfirmRequestSchema>;
+export type EmailChangeConfirmResponse = z.infer<typeof emailChangeConfirmResponseSchema>;
+export type MessageResponse = z.infer<typeof messageResponseSchema>;
 export type RefreshTokenRequest = z.infer<typeof refreshTokenRequestSchema>;
 export type RefreshTokenResponse = z.infer<typeof refreshTokenResponseSchema>;
 export type ErrorResponse = z.infer<typeof errorResponseSchema>;
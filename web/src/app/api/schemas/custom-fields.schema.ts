@@ -0,0 +1,81 @@
+import { z } from 'zod';
+
+export const customFieldEntitySchema = z.enum(['candidate', 'application', 'job']);
+export const customFieldTypeSchema = z.enum(['text', 'number', 'date', 'select', 'multi_select']);
+
+/** Text, select and date (YYYY-MM-DD) values are strings, multi-select values lists of options */
+export const customFieldValueSchema = z.union([z.string(), z.number(), z.array(z.string())]);
+
+/** Values of a record's custom fields, keyed by CustomField.key */
+export const customFieldValuesSchema = z.record(z.string(), customFieldValueSchema);
+
+export const customFieldSchema = z
+  .object({
+    id: z.number(),
+    entity: customFieldEntitySchema,
+    key: z.string(),
+    label: z.string(),
+    type: customFieldTypeSchema,
+    options: z.array(z.string()),
+    help_text: z.string(),
+    required: z.boolean(),
+    position: z.number(),
+    created_at: z.string(),
+    updated_at: z.string(),
+  })
+  .transform((field) => ({
+    id: field.id,
+    entity: field.entity,
+    key: field.key,
+    label: field.label,
+    type: field.type,
+    options: field.options,
+    helpText: field.help_text,
+    required: field.required,
+    position: field.position,
+    createdAt: field.created_at,
+    updatedAt: field.updated_at,
+  }));
+
+export const customFieldListResponseSchema = z.object({
+  custom_fields: z.array(customFieldSchema),
+});
+
+/** Entity, key and type are set on create and cannot change afterwards */
+export const customFieldRequestSchema = z.object({
+  entity: customFieldEntitySchema.optional(),
+  key: z
+    .string()
+    .regex(/^[a-z][a-z0-9_]{0,49}$/, 'Key must be lower case letters, digits or _')
+    .optional(),
+  label: z.string().min(1, 'Label is required').max(100),
+  type: customFieldTypeSchema.optional(),
+  options: z.array(z.string().min(1).max(200)).max(100),
+  help_text: z.string().max(500),
+  required: z.boolean(),
+  position: z.number().int(),
+});
+
+export const customFieldResponseSchema = z.object({
+  message: z.string(),
+  custom_field: customFieldSchema,
+});
+
+export const tagSuggestionListResponseSchema = z.object({
+  tags: z.array(
+    z.object({
+      name: z.string(),
+      count: z.number(),
+    }),
+  ),
+});
+
+export type CustomFieldEntity = z.infer<typeof customFieldEntitySchema>;
+export type CustomFieldType = z.infer<typeof customFieldTypeSchema>;
+export type CustomFieldValue = z.infer<typeof customFieldValueSchema>;
+export type CustomFieldValues = z.infer<typeof customFieldValuesSchema>;
+export type CustomField = z.infer<typeof customFieldSchema>;
+export type CustomFieldListResponse = z.infer<typeof customFieldListResponseSchema>;
+export type CustomFieldRequest = z.infer<typeof customFieldRequestSchema>;
+export type CustomFieldResponse = z.infer<typeof customFieldResponseSchema>;
+export type TagSuggestionListResponse = z.infer<typeof tagSuggestionListResponseSchema>;
@@ -0,0 +1,171 @@
+import { z } from 'zod';
+import { candidateSchema } from './applications.schema';
+import { paginationSchema } from './jobs.schema';
+
+export const duplicateReasonSchema = z.enum(['email', 'phone', 'resume', 'name', 'similar_name']);
+export const duplicateStatusSchema = z.enum(['pending', 'merged', 'dismissed']);
+
+/** A pair in the review queue; candidate is the newer record, match the older one */
+export const candidateDuplicateSchema = z
+  .object({
+    id: z.number(),
+    candidate_id: z.number(),
+    candidate: candidateSchema.optional(),
+    match_id: z.number(),
+    match: candidateSchema.optional(),
+    score: z.number(),
+    reasons: z.array(duplicateReasonSchema),
+    status: duplicateStatusSchema,
+    reviewed_by: z.number().optional(),
+    reviewed_at: z.string().optional(),
+    created_at: z.string(),
+  })
+  .transform((duplicate) => ({
+    id: duplicate.id,
+    candidateId: duplicate.candidate_id,
+    candidate: duplicate.candidate,
+    matchId: duplicate.match_id,
+    match: duplicate.match,
+    /** From 0 to 1 */
+    score: duplicate.score,
+    reasons: duplicate.reasons,
+    status: duplicate.status,
+    reviewedBy: duplicate.reviewed_by,
+    reviewedAt: duplicate.reviewed_at,
+    createdAt: duplicate.created_at,
+  }));
+
+export const candidateDuplicateListResponseSchema = z.object({
+  duplicates: z.array(candidateDuplicateSchema),
+  pagination: paginationSchema,
+});
+
+/** A possible duplicate seen from one candidate: candidate is the other one */
+export const possibleDuplicateSchema = z
+  .object({
+    id: z.number(),
+    candidate: candidateSchema,
+    score: z.number(),
+    reasons: z.array(duplicateReasonSchema),
+    status: duplicateStatusSchema,
+    created_at: z.string(),
+  })
+  .transform((duplicate) => ({
+    id: duplicate.id,
+    candidate: duplicate.candidate,
+    score: duplicate.score,
+    reasons: duplicate.reasons,
+    status: duplicate.status,
+    createdAt: duplicate.created_at,
+  }));
+
+export const possibleDuplicateListResponseSchema = z.object({
+  duplicates: z.array(possibleDuplicateSchema),
+});
+
+export const dismissDuplicateResponseSchema = z.object({
+  message: z.string(),
+  duplicate: candidateDuplicateSchema,
+});
+
+const mergedFieldSchema = z.object({
+  before: z.unknown(),
+  after: z.unknown(),
+});
+
+export const candidateMergeChangesSchema = z
+  .object({
+    moved_applications: z.array(z.number()),
+    folded_applications: z.array(
+      z.object({
+        application_id: z.number(),
+        into_id: z.number(),
+        attachment_ids: z.array(z.number()).nullable(),
+        activity_ids: z.array(z.number()).nullable(),
+        resume_id: z.number().optional(),
+      }),
+    ),
+    fields: z.record(z.string(), mergedFieldSchema),
+  })
+  .transform((changes) => ({
+    movedApplications: changes.moved_applications,
+    foldedApplications: changes.folded_applications.map((folded) => ({
+      applicationId: folded.application_id,
+      intoId: folded.into_id,
+      attachmentIds: folded.attachment_ids ?? [],
+      activityIds: folded.activity_ids ?? [],
+      resumeId: folded.resume_id,
+    })),
+    /** The fields that were filled in, keyed like the candidate's fields */
+    fields: changes.fields,
+  }));
+
+export const candidateMergeSchema = z
+  .object({
+    id: z.number(),
+    candidate_id: z.number(),
+    merged_id: z.number(),
+    actor_id: z.number().nullable(),
+    changes: candidateMergeChangesSchema,
+    undone_at: z.string().nullable(),
+    created_at: z.string(),
+  })
+  .transform((merge) => ({
+    id: merge.id,
+    candidateId: merge.candidate_id,
+    mergedId: merge.merged_id,
+    actorId: merge.actor_id,
+    changes: merge.changes,
+    undoneAt: merge.undone_at,
+    createdAt: merge.created_at,
+  }));
+
+export const mergeCandidateRequestSchema = z.object({
+  candidate_id: z.number(),
+});
+
+export const mergeResponseSchema = z.object({
+  message: z.string(),
+  candidate: candidateSchema,
+  merge: candidateMergeSchema,
+});
+
+export const candidateMergeEntrySchema = z
+  .object({
+    id: z.number(),
+    merged_id: z.number(),
+    merged_name: z.string(),
+    merged_email: z.string(),
+    actor_id: z.number().nullable(),
+    changes: candidateMergeChangesSchema,
+    created_at: z.string(),
+    undone_at: z.string().nullable(),
+    can_undo: z.boolean(),
+  })
+  .transform((merge) => ({
+    id: merge.id,
+    mergedId: merge.merged_id,
+    mergedName: merge.merged_name,
+    mergedEmail: merge.merged_email,
+    actorId: merge.actor_id,
+    changes: merge.changes,
+    createdAt: merge.created_at,
+    undoneAt: merge.undone_at,
+    canUndo: merge.can_undo,
+  }));
+
+export const candidateMergeListResponseSchema = z.object({
+  merges: z.array(candidateMergeEntrySchema),
+});
+
+export type DuplicateReason = z.infer<typeof duplicateReasonSchema>;
+export type DuplicateStatus = z.infer<typeof duplicateStatusSchema>;
+export type CandidateDuplicate = z.infer<typeof candidateDuplicateSchema>;
+export type CandidateDuplicateListResponse = z.infer<typeof candidateDuplicateListResponseSchema>;
+export type PossibleDuplicate = z.infer<typeof possibleDuplicateSchema>;
+export type PossibleDuplicateListResponse = z.infer<typeof possibleDuplicateListResponseSchema>;
+export type DismissDuplicateResponse = z.infer<typeof dismissDuplicateResponseSchema>;
+export type CandidateMerge = z.infer<typeof candidateMergeSchema>;
+export type MergeResponse = z.infer<typeof mergeResponseSchema>;
+export type CandidateMergeEntry = z.infer<typeof candidateMergeEntrySchema>;
+export type CandidateMergeListResponse = z.infer<typeof candidateMergeListResponseSchema>;
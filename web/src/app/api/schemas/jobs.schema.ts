@@ -0,0 +1,138 @@
+import { z } from 'zod';
+import { customFieldValueSchema, customFieldValuesSchema } from './custom-fields.schema';
+
+export const employmentTypeSchema = z.enum(['full-time', 'part-time', 'contract', 'internship']);
+export const jobStatusSchema = z.enum(['draft', 'pending_approval', 'published', 'paused', 'closed']);
+export const jobChannelSchema = z.enum(['internal', 'linkedin', 'indeed', 'glassdoor']);
+
+// The API uses snake_case; components work with the camelCase Job shape
+export const jobSchema = z
+  .object({
+    id: z.number(),
+    title: z.string(),
+    department_id: z.number().nullable(),
+    department: z.string(),
+    location_id: z.number().nullable(),
+    location: z.string(),
+    type: employmentTypeSchema,
+    status: jobStatusSchema,
+    description: z.string(),
+    requirements: z.string(),
+    salary_min: z.number().nullable(),
+    salary_max: z.number().nullable(),
+    salary_currency: z.string(),
+    salary_period: z.string(),
+    published_to: z.array(z.string()),
+    applicants: z.number(),
+    created_by: z.number(),
+    publish_at: z.string().nullable(),
+    close_at: z.string().nullable(),
+    published_at: z.string().optional(),
+    paused_at: z.string().optional(),
+    closed_at: z.string().optional(),
+    closing_reason: z.string().optional(),
+    approved_at: z.string().optional(),
+    approval_round: z.number(),
+    tags: z.array(z.string()).nullish(),
+    custom_fields: customFieldValuesSchema.nullish(),
+    created_at: z.string(),
+    updated_at: z.string(),
+  })
+  .transform((job) => ({
+    id: job.id,
+    title: job.title,
+    departmentId: job.department_id,
+    department: job.department,
+    locationId: job.location_id,
+    location: job.location,
+    type: job.type,
+    status: job.status,
+    description: job.description,
+    requirements: job.requirements,
+    salaryMin: job.salary_min,
+    salaryMax: job.salary_max,
+    salaryCurrency: job.salary_currency,
+    salaryPeriod: job.salary_period,
+    publishedTo: job.published_to,
+    applicants: job.applicants,
+    createdBy: job.created_by,
+    publishAt: job.publish_at,
+    closeAt: job.close_at,
+    publishedAt: job.published_at,
+    pausedAt: job.paused_at,
+    closedAt: job.closed_at,
+    closingReason: job.closing_reason,
+    approvedAt: job.approved_at,
+    tags: job.tags ?? [],
+    customFields: job.custom_fields ?? {},
+    createdAt: job.created_at,
+    updatedAt: job.updated_at,
+  }));
+
+export const jobRequestSchema = z.object({
+  title: z.string().min(1, 'Title is required').max(200),
+  department_id: z.number().nullable(),
+  department: z.string().max(100).optional(),
+  location_id: z.number().nullable(),
+  location: z.string().max(100).optional(),
+  type: employmentTypeSchema,
+  status: z.enum(['draft', 'published']).optional(),
+  description: z.string(),
+  requirements: z.string(),
+  salary_min: z.number().int().nonnegative().nullable(),
+  salary_max: z.number().int().nonnegative().nullable(),
+  salary_currency: z.string(),
+  salary_period: z.enum(['', 'year', 'month', 'hour']),
+  published_to: z.array(jobChannelSchema),
+  publish_at: z.string().nullable().optional(),
+  close_at: z.string().nullable().optional(),
+  pipeline_template_id: z.number().nullable().optional(),
+  tags: z.array(z.string().max(50)).max(30).optional(),
+  /** Only the fields sent change; null clears one */
+  custom_fields: z.record(z.string(), customFieldValueSchema.nullable()).optional(),
+});
+
+export const jobTransitionRequestSchema = z.object({
+  status: jobStatusSchema,
+  reason: z.string().optional(),
+  closing_reason: z
+    .enum(['filled', 'cancelled', 'on_hold', 'budget', 'duplicate', 'scheduled', 'other'])
+    .optional(),
+});
+
+export const paginationSchema = z.object({
+  page: z.number(),
+  per_page: z.number(),
+  total: z.number(),
+  total_pages: z.number(),
+});
+
+export const jobListResponseSchema = z.object({
+  jobs: z.array(jobSchema),
+  pagination: paginationSchema,
+});
+
+export const jobResponseSchema = z.object({
+  message: z.string().optional(),
+  job: jobSchema,
+  can_manage: z.boolean().optional(),
+  allowed_transitions: z.array(jobStatusSchema).optional(),
+});
+
+export const jobStatsResponseSchema = z.object({
+  total: z.number(),
+  published: z.number(),
+  drafts: z.number(),
+  pending_approval: z.number(),
+  paused: z.number(),
+  closed: z.number(),
+  applicants: z.number(),
+});
+
+export type Job = z.infer<typeof jobSchema>;
+export type JobStatus = z.infer<typeof jobStatusSchema>;
+export type JobRequest = z.infer<typeof jobRequestSchema>;
+export type JobTransitionRequest = z.infer<typeof jobTransitionRequestSchema>;
+export type JobListResponse = z.infer<typeof jobListResponseSchema>;
+export type JobResponse = z.infer<typeof jobResponseSchema>;
+export type JobStatsResponse = z.infer<typeof jobStatsResponseSchema>;
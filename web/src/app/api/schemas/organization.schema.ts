@@ -0,0 +1,70 @@
+import { z } from 'zod';
+
+export const workplaceTypeSchema = z.enum(['onsite', 'hybrid', 'remote']);
+
+export const departmentSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    code: z.string(),
+    parent_id: z.number().nullable(),
+    description: z.string(),
+    path: z.string(),
+    depth: z.number(),
+    jobs: z.number().optional(),
+  })
+  .transform((department) => ({
+    id: department.id,
+    name: department.name,
+    code: department.code,
+    parentId: department.parent_id,
+    description: department.description,
+    path: department.path,
+    depth: department.depth,
+    jobs: department.jobs ?? 0,
+  }));
+
+export const locationSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    workplace_type: workplaceTypeSchema,
+    address_line1: z.string(),
+    address_line2: z.string(),
+    city: z.string(),
+    region: z.string(),
+    postal_code: z.string(),
+    country_code: z.string(),
+    timezone: z.string(),
+  })
+  .transform((location) => ({
+    id: location.id,
+    name: location.name,
+    workplaceType: location.workplace_type,
+    addressLine1: location.address_line1,
+    addressLine2: location.address_line2,
+    city: location.city,
+    region: location.region,
+    postalCode: location.postal_code,
+    countryCode: location.country_code,
+    timezone: location.timezone,
+  }));
+
+export const departmentListResponseSchema = z.object({
+  departments: z.array(departmentSchema),
+});
+
+export const locationListResponseSchema = z.object({
+  locations: z.array(
+    z.object({
+      location: locationSchema,
+      jobs: z.number(),
+    }),
+  ),
+});
+
+export type WorkplaceType = z.infer<typeof workplaceTypeSchema>;
+export type Department = z.infer<typeof departmentSchema>;
+export type Location = z.infer<typeof locationSchema>;
+export type DepartmentListResponse = z.infer<typeof departmentListResponseSchema>;
+export type LocationListResponse = z.infer<typeof locationListResponseSchema>;
@@ -0,0 +1,32 @@
+import { z } from 'zod';
+import { stageTypeSchema } from './applications.schema';
+
+export const pipelineStageSchema = z.object({
+  name: z.string(),
+  type: stageTypeSchema,
+});
+
+export const pipelineTemplateSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    description: z.string(),
+    stages: z.array(pipelineStageSchema),
+    is_default: z.boolean(),
+  })
+  .transform((template) => ({
+    id: template.id,
+    name: template.name,
+    description: template.description,
+    stages: template.stages,
+    isDefault: template.is_default,
+  }));
+
+export const pipelineTemplateListResponseSchema = z.object({
+  templates: z.array(pipelineTemplateSchema),
+  default_stages: z.array(pipelineStageSchema),
+});
+
+export type PipelineStage = z.infer<typeof pipelineStageSchema>;
+export type PipelineTemplate = z.infer<typeof pipelineTemplateSchema>;
+export type PipelineTemplateListResponse = z.infer<typeof pipelineTemplateListResponseSchema>;
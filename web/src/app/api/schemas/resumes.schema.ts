@@ -0,0 +1,65 @@
+import { z } from 'zod';
+import { candidateLinkSchema, educationSchema, workExperienceSchema } from './applications.schema';
+
+export const resumeParseStatusSchema = z.enum(['pending', 'parsed', 'failed']);
+
+export const parsedProfileSchema = z
+  .object({
+    name: z.string().optional(),
+    email: z.string().optional(),
+    phone: z.string().optional(),
+    location: z.string().optional(),
+    current_title: z.string().optional(),
+    current_company: z.string().optional(),
+    links: z.array(candidateLinkSchema).optional(),
+    work_history: z.array(workExperienceSchema).optional(),
+    education: z.array(educationSchema).optional(),
+    skills: z.array(z.string()).optional(),
+    confidence: z.record(z.string(), z.number()),
+  })
+  .transform((profile) => ({
+    name: profile.name,
+    email: profile.email,
+    phone: profile.phone,
+    location: profile.location,
+    currentTitle: profile.current_title,
+    currentCompany: profile.current_company,
+    links: profile.links ?? [],
+    workHistory: profile.work_history ?? [],
+    education: profile.education ?? [],
+    skills: profile.skills ?? [],
+    /** How sure the parser is of each field, from 0 to 1, keyed like the candidate's fields */
+    confidence: profile.confidence,
+  }));
+
+export const resumeParseSchema = z
+  .object({
+    id: z.number(),
+    application_id: z.number(),
+    attachment_id: z.number(),
+    attachment_name: z.string(),
+    status: resumeParseStatusSchema,
+    last_error: z.string(),
+    profile: parsedProfileSchema.nullable(),
+    parsed_at: z.string().nullable(),
+  })
+  .transform((parse) => ({
+    id: parse.id,
+    applicationId: parse.application_id,
+    attachmentId: parse.attachment_id,
+    attachmentName: parse.attachment_name,
+    status: parse.status,
+    lastError: parse.last_error,
+    profile: parse.profile,
+    parsedAt: parse.parsed_at,
+  }));
+
+export const resumeParseListResponseSchema = z.object({
+  resumes: z.array(resumeParseSchema),
+  parsed_fields: z.record(z.string(), z.number()),
+});
+
+export type ResumeParseStatus = z.infer<typeof resumeParseStatusSchema>;
+export type ParsedProfile = z.infer<typeof parsedProfileSchema>;
+export type ResumeParse = z.infer<typeof resumeParseSchema>;
+export type ResumeParseListResponse = z.infer<typeof resumeParseListResponseSchema>;
@@ -0,0 +1,90 @@
+import { z } from 'zod';
+import { candidateSchema } from './applications.schema';
+import { jobStatusSchema, paginationSchema } from './jobs.schema';
+
+export const searchHighlightSourceSchema = z.enum(['profile', 'resume', 'note']);
+
+export const searchHighlightSchema = z
+  .object({
+    source: searchHighlightSourceSchema,
+    /** HTML-escaped, with the matching words in <mark> */
+    snippet: z.string(),
+    source_id: z.number().optional(),
+    application_id: z.number().optional(),
+  })
+  .transform((highlight) => ({
+    source: highlight.source,
+    snippet: highlight.snippet,
+    /** The attachment of a resume or the activity of a note */
+    sourceId: highlight.source_id,
+    applicationId: highlight.application_id,
+  }));
+
+export const candidateSearchResultSchema = z.object({
+  candidate: candidateSchema,
+  rank: z.number(),
+  highlights: z.array(searchHighlightSchema),
+});
+
+export const candidateSearchResponseSchema = z.object({
+  candidates: z.array(candidateSearchResultSchema),
+  pagination: paginationSchema,
+});
+
+export const searchKindSchema = z.enum(['candidate', 'job', 'user']);
+
+export const candidateSuggestionSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    email: z.string(),
+    current_title: z.string(),
+    current_company: z.string(),
+  })
+  .transform((candidate) => ({
+    id: candidate.id,
+    name: candidate.name,
+    email: candidate.email,
+    currentTitle: candidate.current_title,
+    currentCompany: candidate.current_company,
+  }));
+
+export const jobSuggestionSchema = z.object({
+  id: z.number(),
+  title: z.string(),
+  department: z.string(),
+  location: z.string(),
+  status: jobStatusSchema,
+});
+
+export const userSuggestionSchema = z
+  .object({
+    id: z.number(),
+    name: z.string(),
+    email: z.string(),
+    job_title: z.string(),
+    avatar_thumbnail_url: z.string().nullable(),
+  })
+  .transform((user) => ({
+    id: user.id,
+    name: user.name,
+    email: user.email,
+    jobTitle: user.job_title,
+    avatarThumbnailUrl: user.avatar_thumbnail_url,
+  }));
+
+export const searchSuggestionsResponseSchema = z.object({
+  candidates: z.array(candidateSuggestionSchema),
+  jobs: z.array(jobSuggestionSchema),
+  users: z.array(userSuggestionSchema),
+});
+
+export type SearchHighlightSource = z.infer<typeof searchHighlightSourceSchema>;
+export type SearchHighlight = z.infer<typeof searchHighlightSchema>;
+export type CandidateSearchResult = z.infer<typeof candidateSearchResultSchema>;
+export type CandidateSearchResponse = z.infer<typeof candidateSearchResponseSchema>;
+export type SearchKind = z.infer<typeof searchKindSchema>;
+export type CandidateSuggestion = z.infer<typeof candidateSuggestionSchema>;
+export type JobSuggestion = z.infer<typeof jobSuggestionSchema>;
+export type UserSuggestion = z.infer<typeof userSuggestionSchema>;
+export type SearchSuggestionsResponse = z.infer<typeof searchSuggestionsResponseSchema>;
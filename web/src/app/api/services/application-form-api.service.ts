@@ -0,0 +1,38 @@
+import { Injectable } from '@angular/core';
+import type { Observable } from 'rxjs';
+import {
+  type ApplicationAnswersResponse,
+  applicationAnswersResponseSchema,
+  type JobFormRequest,
+  jobFormRequestSchema,
+  type JobFormResponse,
+  jobFormResponseSchema,
+  type LibraryQuestionListResponse,
+  libraryQuestionListResponseSchema,
+} from '../schemas/application-form.schema';
+import { BaseApiService } from './base-api.service';
+
+@Injectable({
+  providedIn: 'root',
+})
+export class ApplicationFormApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  public getJobForm(jobId: number): Observable<JobFormResponse> {
+    return this.get(`/jobs/${jobId}/questions`, jobFormResponseSchema);
+  }
+
+  public updateJobForm(jobId: number, request: JobFormRequest): Observable<JobFormResponse> {
+    return this.put(`/jobs/${jobId}/questions`, request, jobFormRequestSchema, jobFormResponseSchema);
+  }
+
+  public listLibrary(): Observable<LibraryQuestionListResponse> {
+    return this.get('/question-library', libraryQuestionListResponseSchema);
+  }
+
+  public getAnswers(applicationId: number): Observable<ApplicationAnswersResponse> {
+    return this.get(`/applications/${applicationId}/answers`, applicationAnswersResponseSchema);
+  }
+}
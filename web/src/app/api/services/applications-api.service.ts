@@ -0,0 +1,165 @@
+import { HttpParams } from '@angular/common/http';
+import { Injectable } from '@angular/core';
+import type { Observable } from 'rxjs';
+import { z } from 'zod';
+import {
+  type ActivityListResponse,
+  type ActivityResponse,
+  type ApplicationResponse,
+  type ApplicationStatusRequest,
+  type LogActivityRequest,
+  type PipelineResponse,
+  activityListResponseSchema,
+  activityResponseSchema,
+  applicationResponseSchema,
+  applicationStatusRequestSchema,
+  logActivityRequestSchema,
+  moveApplicationRequestSchema,
+  pipelineResponseSchema,
+} from '../schemas/applications.schema';
+import {
+  type AttachmentLinkResponse,
+  type AttachmentListResponse,
+  attachmentLinkResponseSchema,
+  attachmentListResponseSchema,
+} from '../schemas/attachments.schema';
+import {
+  type CandidateDuplicateListResponse,
+  type CandidateMergeListResponse,
+  type DismissDuplicateResponse,
+  type DuplicateStatus,
+  type MergeResponse,
+  type PossibleDuplicateListResponse,
+  candidateDuplicateListResponseSchema,
+  candidateMergeListResponseSchema,
+  dismissDuplicateResponseSchema,
+  mergeCandidateRequestSchema,
+  mergeResponseSchema,
+  possibleDuplicateListResponseSchema,
+} from '../schemas/duplicates.schema';
+import { type ResumeParseListResponse, resumeParseListResponseSchema } from '../schemas/resumes.schema';
+import { type CandidateSearchResponse, candidateSearchResponseSchema } from '../schemas/search.schema';
+import { BaseApiService } from './base-api.service';
+
+export interface CandidateSearchQuery {
+  q?: string;
+  job_id?: number;
+  stage_id?: number;
+  /** YYYY-MM-DD, inclusive */
+  applied_from?: string;
+  applied_to?: string;
+  source?: string;
+  location?: string;
+  /** Comma separated; candidates have all of them */
+  tag?: string;
+  /** Custom field filters, e.g. 'cf.work_permit': 'Blue Card' or 'cf.notice_weeks.max': 4 */
+  [customField: `cf.${string}`]: string | number | undefined;
+  sort?: string;
+  page?: number;
+  perPage?: number;
+}
+
+@Injectable({
+  providedIn: 'root',
+})
+export class ApplicationsApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  public getPipeline(jobId: number): Observable<PipelineResponse> {
+    return this.get(`/jobs/${jobId}/pipeline`, pipelineResponseSchema);
+  }
+
+  public moveApplication(id: number, stageId: number): Observable<ApplicationResponse> {
+    return this.post(
+      `/applications/${id}/move`,
+      { stage_id: stageId },
+      moveApplicationRequestSchema,
+      applicationResponseSchema,
+    );
+  }
+
+  public getTimeline(id: number, page = 1): Observable<ActivityListResponse> {
+    return this.get(`/applications/${id}/activities?page=${page}`, activityListResponseSchema);
+  }
+
+  public getCandidateTimeline(candidateId: number, page = 1): Observable<ActivityListResponse> {
+    return this.get(`/candidates/${candidateId}/activities?page=${page}`, activityListResponseSchema);
+  }
+
+  public logActivity(id: number, request: LogActivityRequest): Observable<ActivityResponse> {
+    return this.post(`/applications/${id}/activities`, request, logActivityRequestSchema, activityResponseSchema);
+  }
+
+  public setStatus(id: number, request: ApplicationStatusRequest): Observable<ApplicationResponse> {
+    return this.post(`/applications/${id}/status`, request, applicationStatusRequestSchema, applicationResponseSchema);
+  }
+
+  public getAttachments(id: number): Observable<AttachmentListResponse> {
+    return this.get(`/applications/${id}/attachments`, attachmentListResponseSchema);
+  }
+
+  public getAttachmentLink(id: number, attachmentId: number): Observable<AttachmentLinkResponse> {
+    return this.get(`/applications/${id}/attachments/${attachmentId}/link`, attachmentLinkResponseSchema);
+  }
+
+  public deleteAttachment(id: number, attachmentId: number): Observable<{ message: string }> {
+    return this.delete(`/applications/${id}/attachments/${attachmentId}`, z.object({ message: z.string() }));
+  }
+
+  public parseAttachment(id: number, attachmentId: number): Observable<{ message: string }> {
+    return this.post(
+      `/applications/${id}/attachments/${attachmentId}/parse`,
+      {},
+      z.object({}),
+      z.object({ message: z.string() }),
+    );
+  }
+
+  public getCandidateResumes(candidateId: number): Observable<ResumeParseListResponse> {
+    return this.get(`/candidates/${candidateId}/resumes`, resumeParseListResponseSchema);
+  }
+
+  public searchCandidates(query: CandidateSearchQuery = {}): Observable<CandidateSearchResponse> {
+    let params = new HttpParams();
+    for (const [key, value] of Object.entries(query)) {
+      if (value !== undefined && value !== '') {
+        params = params.set(key === 'perPage' ? 'per_page' : key, String(value));
+      }
+    }
+
+    const queryString = params.toString();
+    return this.get(`/candidates/search${queryString ? `?${queryString}` : ''}`, candidateSearchResponseSchema);
+  }
+
+  public getDuplicates(status: DuplicateStatus = 'pending', page = 1): Observable<CandidateDuplicateListResponse> {
+    return this.get(`/candidates/duplicates?status=${status}&page=${page}`, candidateDuplicateListResponseSchema);
+  }
+
+  public getCandidateDuplicates(candidateId: number): Observable<PossibleDuplicateListResponse> {
+    return this.get(`/candidates/${candidateId}/duplicates`, possibleDuplicateListResponseSchema);
+  }
+
+  public dismissDuplicate(id: number): Observable<DismissDuplicateResponse> {
+    return this.post(`/candidates/duplicates/${id}/dismiss`, {}, z.object({}), dismissDuplicateResponseSchema);
+  }
+
+  // Merges duplicateId into candidateId, which survives
+  public mergeCandidates(candidateId: number, duplicateId: number): Observable<MergeResponse> {
+    return this.post(
+      `/candidates/${candidateId}/merge`,
+      { candidate_id: duplicateId },
+      mergeCandidateRequestSchema,
+      mergeResponseSchema,
+    );
+  }
+
+  public getCandidateMerges(candidateId: number): Observable<CandidateMergeListResponse> {
+    return this.get(`/candidates/${candidateId}/merges`, candidateMergeListResponseSchema);
+  }
+
+  public undoMerge(mergeId: number): Observable<MergeResponse> {
+    return this.post(`/candidates/merges/${mergeId}/undo`, {}, z.object({}), mergeResponseSchema);
+  }
+}
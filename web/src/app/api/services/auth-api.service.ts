@@ -2,18 +2,32 @@ import { Injectable } from '@angular/core';
 import type { Observable } from 'rxjs';
 import { z } from 'zod';
 import {
+  type EmailChangeConfirmRequest,
+  type EmailChangeConfirmResponse,
+  emailChangeConfirmRequestSchema,
+  emailChangeConfirmResponseSchema,
   type LoginRequest,
   type LoginResponse,
   loginRequestSchema,
   loginResponseSchema,
+  type MagicLinkConsumeRequest,
+  type MagicLinkRequest,
+  type MessageResponse,
+  magicLinkConsumeRequestSchema,
+  magicLinkRequestSchema,
+  messageResponseSchema,
   type RefreshTokenRequest,
   type RefreshTokenResponse,
   type RegisterRequest,
   type RegisterResponse,
+  type RegistrationPolicyResponse,
+  type ReportSessionRequest,
   refreshTokenRequestSchema,
   refreshTokenResponseSchema,
   registerRequestSchema,
   registerResponseSchema,
+  registrationPolicyResponseSchema,
+  reportSessionRequestSchema,
 } from '../schemas/auth.schema';
 import { BaseApiService } from './base-api.service';
 
@@ -45,6 +59,54 @@ export class AuthApiService extends BaseApiService {
     return this.post('/auth/register', request, registerRequestSchema, registerResponseSchema);
   }
 
+  public getRegistrationPolicy(): Observable<RegistrationPolicyResponse> {
+    return this.get('/auth/registration-policy', registrationPolicyResponseSchema);
+  }
+
+  public requestMagicLink(email: string): Observable<MessageResponse> {
+    const request: MagicLinkRequest = { email };
+
+    return this.post(
+      '/auth/magic-link/request',
+      request,
+      magicLinkRequestSchema,
+      messageResponseSchema,
+    );
+  }
+
+  public consumeMagicLink(token: string): Observable<LoginResponse> {
+    const request: MagicLinkConsumeRequest = { token };
+
+    return this.post(
+      '/auth/magic-link/consume',
+      request,
+      magicLinkConsumeRequestSchema,
+      loginResponseSchema,
+    );
+  }
+
+  public reportSession(token: string): Observable<MessageResponse> {
+    const request: ReportSessionRequest = { token };
+
+    return this.post(
+      '/auth/sessions/report',
+      request,
+      reportSessionRequestSchema,
+      messageResponseSchema,
+    );
+  }
+
+  public confirmEmailChange(token: string): Observable<EmailChangeConfirmResponse> {
+    const request: EmailChangeConfirmRequest = { token };
+
+    return this.post(
+      '/auth/email-change/confirm',
+      request,
+      emailChangeConfirmRequestSchema,
+      emailChangeConfirmResponseSchema,
+    );
+  }
+
   public refreshToken(refreshToken: string): Observable<RefreshTokenResponse> {
     const request: RefreshTokenRequest = {
       refresh_token: refreshToken,
@@ -0,0 +1,74 @@
+import { HttpParams } from '@angular/common/http';
+import { Injectable } from '@angular/core';
+import { catchError, map, type Observable } from 'rxjs';
+import { z } from 'zod';
+import {
+  type CustomField,
+  type CustomFieldEntity,
+  type CustomFieldRequest,
+  type CustomFieldResponse,
+  type TagSuggestionListResponse,
+  customFieldListResponseSchema,
+  customFieldRequestSchema,
+  customFieldResponseSchema,
+  tagSuggestionListResponseSchema,
+} from '../schemas/custom-fields.schema';
+import { BaseApiService } from './base-api.service';
+
+/** List filters shared by the candidates, applications and jobs lists and their exports */
+export type RecordFilters = Record<string, string | number | undefined>;
+
+const exportPaths: Record<CustomFieldEntity, string> = {
+  candidate: '/candidates/export',
+  application: '/applications/export',
+  job: '/jobs/export',
+};
+
+@Injectable({
+  providedIn: 'root',
+})
+export class CustomFieldsApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  public listCustomFields(entity?: CustomFieldEntity): Observable<CustomField[]> {
+    return this.get(`/custom-fields${entity ? `?entity=${entity}` : ''}`, customFieldListResponseSchema).pipe(
+      map((response) => response.custom_fields),
+    );
+  }
+
+  public createCustomField(request: CustomFieldRequest): Observable<CustomFieldResponse> {
+    return this.post('/admin/custom-fields', request, customFieldRequestSchema, customFieldResponseSchema);
+  }
+
+  public updateCustomField(id: number, request: CustomFieldRequest): Observable<CustomFieldResponse> {
+    return this.put(`/admin/custom-fields/${id}`, request, customFieldRequestSchema, customFieldResponseSchema);
+  }
+
+  /** Also removes the field's values from every record */
+  public deleteCustomField(id: number): Observable<{ message: string }> {
+    return this.delete(`/admin/custom-fields/${id}`, z.object({ message: z.string() }));
+  }
+
+  public suggestTags(entity: CustomFieldEntity, query = ''): Observable<TagSuggestionListResponse> {
+    const params = new HttpParams().set('entity', entity).set('q', query);
+    return this.get(`/tags?${params.toString()}`, tagSuggestionListResponseSchema);
+  }
+
+  /**
+   * Downloads a list as CSV. Filters are those of the list, e.g.
+   * { tag: 'frontend', 'cf.work_permit': 'Blue Card' }
+   */
+  public exportCsv(entity: CustomFieldEntity, filters: RecordFilters = {}): Observable<Blob> {
+    let params = new HttpParams();
+    for (const [key, value] of Object.entries(filters)) {
+      if (value !== undefined && value !== '') {
+        params = params.set(key, String(value));
+      }
+    }
+    return this.http
+      .get(`${this.apiConfig.baseUrl}${exportPaths[entity]}`, { params, responseType: 'blob' })
+      .pipe(catchError((error) => this.handleError(error)));
+  }
+}
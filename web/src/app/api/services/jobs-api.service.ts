@@ -0,0 +1,75 @@
+import { HttpParams } from '@angular/common/http';
+import { Injectable } from '@angular/core';
+import type { Observable } from 'rxjs';
+import { z } from 'zod';
+import {
+  type JobListResponse,
+  type JobRequest,
+  type JobResponse,
+  type JobStatsResponse,
+  type JobTransitionRequest,
+  jobListResponseSchema,
+  jobRequestSchema,
+  jobResponseSchema,
+  jobStatsResponseSchema,
+  jobTransitionRequestSchema,
+} from '../schemas/jobs.schema';
+import { BaseApiService } from './base-api.service';
+
+export interface JobListQuery {
+  status?: string;
+  department_id?: number;
+  location_id?: number;
+  workplace_type?: string;
+  type?: string;
+  q?: string;
+  mine?: boolean;
+  sort?: string;
+  page?: number;
+  perPage?: number;
+}
+
+@Injectable({
+  providedIn: 'root',
+})
+export class JobsApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  public listJobs(query: JobListQuery = {}): Observable<JobListResponse> {
+    let params = new HttpParams();
+    for (const [key, value] of Object.entries(query)) {
+      if (value !== undefined && value !== '') {
+        params = params.set(key === 'perPage' ? 'per_page' : key, String(value));
+      }
+    }
+
+    const queryString = params.toString();
+    return this.get(`/jobs${queryString ? `?${queryString}` : ''}`, jobListResponseSchema);
+  }
+
+  public getStats(): Observable<JobStatsResponse> {
+    return this.get('/jobs/stats', jobStatsResponseSchema);
+  }
+
+  public getJob(id: number): Observable<JobResponse> {
+    return this.get(`/jobs/${id}`, jobResponseSchema);
+  }
+
+  public createJob(job: JobRequest): Observable<JobResponse> {
+    return this.post('/jobs', job, jobRequestSchema, jobResponseSchema);
+  }
+
+  public updateJob(id: number, job: JobRequest): Observable<JobResponse> {
+    return this.put(`/jobs/${id}`, job, jobRequestSchema, jobResponseSchema);
+  }
+
+  public transitionJob(id: number, transition: JobTransitionRequest): Observable<JobResponse> {
+    return this.post(`/jobs/${id}/transitions`, transition, jobTransitionRequestSchema, jobResponseSchema);
+  }
+
+  public deleteJob(id: number): Observable<{ message: string }> {
+    return this.delete(`/jobs/${id}`, z.object({ message: z.string() }));
+  }
+}
@@ -0,0 +1,31 @@
+import { Injectable } from '@angular/core';
+import { map, type Observable } from 'rxjs';
+import {
+  type Department,
+  type Location,
+  departmentListResponseSchema,
+  locationListResponseSchema,
+} from '../schemas/organization.schema';
+import { BaseApiService } from './base-api.service';
+
+@Injectable({
+  providedIn: 'root',
+})
+export class OrganizationApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  // Departments come parents first, ready for an indented list
+  public listDepartments(): Observable<Department[]> {
+    return this.get('/departments', departmentListResponseSchema).pipe(
+      map((response) => response.departments),
+    );
+  }
+
+  public listLocations(): Observable<Location[]> {
+    return this.get('/locations', locationListResponseSchema).pipe(
+      map((response) => response.locations.map((entry) => entry.location)),
+    );
+  }
+}
@@ -0,0 +1,17 @@
+import { Injectable } from '@angular/core';
+import type { Observable } from 'rxjs';
+import { type PipelineTemplateListResponse, pipelineTemplateListResponseSchema } from '../schemas/pipeline.schema';
+import { BaseApiService } from './base-api.service';
+
+@Injectable({
+  providedIn: 'root',
+})
+export class PipelineApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  public listTemplates(): Observable<PipelineTemplateListResponse> {
+    return this.get('/pipeline-templates', pipelineTemplateListResponseSchema);
+  }
+}
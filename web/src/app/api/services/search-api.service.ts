@@ -0,0 +1,30 @@
+import { HttpParams } from '@angular/common/http';
+import { Injectable } from '@angular/core';
+import type { Observable } from 'rxjs';
+import {
+  type SearchKind,
+  type SearchSuggestionsResponse,
+  searchSuggestionsResponseSchema,
+} from '../schemas/search.schema';
+import { BaseApiService } from './base-api.service';
+
+@Injectable({
+  providedIn: 'root',
+})
+export class SearchApiService extends BaseApiService {
+  protected apiConfig = {
+    baseUrl: '/api',
+  };
+
+  // Suggestions for a partly typed query; limit is per kind
+  public suggest(query: string, types?: SearchKind[], limit?: number): Observable<SearchSuggestionsResponse> {
+    let params = new HttpParams().set('q', query);
+    if (types?.length) {
+      params = params.set('types', types.join(','));
+    }
+    if (limit !== undefined) {
+      params = params.set('limit', String(limit));
+    }
+    return this.get(`/search/suggest?${params.toString()}`, searchSuggestionsResponseSchema);
+  }
+}
@@ -21,6 +21,23 @@ export const routes: Routes = [
         loadComponent: () =>
           import('./routes/auth/register.component').then((m) => m.RegisterComponent),
       },
+      {
+        path: 'confirm-email-change',
+        loadComponent: () =>
+          import('./routes/auth/confirm-email-change.component').then(
+            (m) => m.ConfirmEmailChangeComponent,
+          ),
+      },
+      {
+        path: 'magic-link',
+        loadComponent: () =>
+          import('./routes/auth/magic-link.component').then((m) => m.MagicLinkComponent),
+      },
+      {
+        path: 'report-session',
+        loadComponent: () =>
+          import('./routes/auth/report-session.component').then((m) => m.ReportSessionComponent),
+      },
       {
         path: '',
         redirectTo: 'login',
@@ -0,0 +1,65 @@
+import { Component, inject, signal } from '@angular/core';
+import { ActivatedRoute, RouterLink } from '@angular/router';
+import { StatusMessageComponent } from '../../components/status-message.component';
+import { AuthService } from '../../services/auth.service';
+
+// Opened from the link sent to a new email address to confirm the change
+@Component({
+  selector: 'app-confirm-email-change',
+  standalone: true,
+  imports: [RouterLink, StatusMessageComponent],
+  template: `
+    <div class="space-y-6">
+      @if (result(); as result) {
+        <app-status-message [type]="result.type" [title]="result.title" [message]="result.message" />
+      } @else {
+        <div class="flex items-center justify-center gap-2 text-text-secondary">
+          <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
+          Confirming your new email address...
+        </div>
+      }
+
+      <div class="text-center">
+        <a
+          routerLink="/auth/login"
+          class="text-sm text-primary-400 hover:text-primary-300 transition-colors"
+        >
+          Go to sign in
+        </a>
+      </div>
+    </div>
+  `,
+})
+export class ConfirmEmailChangeComponent {
+  private authService = inject(AuthService);
+  private route = inject(ActivatedRoute);
+
+  public result = signal<{ type: 'success' | 'error'; title: string; message: string } | null>(
+    null,
+  );
+
+  constructor() {
+    const token = this.route.snapshot.queryParamMap.get('token');
+    if (!token) {
+      this.result.set({
+        type: 'error',
+        title: 'Link not valid',
+        message: 'Open the link from the confirmation email again.',
+      });
+      return;
+    }
+
+    this.authService.confirmEmailChange(token).subscribe({
+      next: (response) => {
+        this.result.set({ type: 'success', title: 'Email address changed', message: response.message });
+      },
+      error: (error) => {
+        this.result.set({
+          type: 'error',
+          title: 'Link not valid',
+          message: error.message || 'The link has expired or was already used.',
+        });
+      },
+    });
+  }
+}
@@ -79,6 +79,15 @@ import { ToastService } from '../../services/toast.service';
         </app-button>
       </form>
 
+      <div class="text-center">
+        <a
+          routerLink="/auth/magic-link"
+          class="text-sm text-primary-400 hover:text-primary-300 transition-colors"
+        >
+          Email me a sign-in link instead
+        </a>
+      </div>
+
       <div class="text-center">
         <p class="text-text-secondary text-sm">
           Don't have an account?
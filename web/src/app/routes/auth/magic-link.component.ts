@@ -0,0 +1,146 @@
+import { Component, inject, signal } from '@angular/core';
+import { FormsModule } from '@angular/forms';
+import { ActivatedRoute, Router, RouterLink } from '@angular/router';
+import { AuthApiService } from '../../api/services/auth-api.service';
+import { ButtonComponent } from '../../components/button.component';
+import { InputComponent, type InputError } from '../../components/input.component';
+import { StatusMessageComponent } from '../../components/status-message.component';
+import { AuthService } from '../../services/auth.service';
+import { ToastService } from '../../services/toast.service';
+
+// Requests a sign-in link by email, and signs in with the token when opened
+// from the link in that email
+@Component({
+  selector: 'app-magic-link',
+  standalone: true,
+  imports: [RouterLink, ButtonComponent, InputComponent, StatusMessageComponent, FormsModule],
+  template: `
+    <div class="space-y-6">
+      @if (token) {
+        @if (signInError(); as error) {
+          <app-status-message type="error" title="Sign-in link not valid" [message]="error" />
+        } @else {
+          <div class="flex items-center justify-center gap-2 text-text-secondary">
+            <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
+            Signing you in...
+          </div>
+        }
+      } @else if (sentMessage(); as message) {
+        <app-status-message type="success" title="Check your email" [message]="message" />
+      } @else {
+        <div class="text-center">
+          <h2 class="text-2xl font-bold text-text-primary mb-2">Sign In by Email</h2>
+          <p class="text-text-secondary text-sm">
+            We'll email you a link that signs you in without a password
+          </p>
+        </div>
+
+        <form (ngSubmit)="onSubmit()" class="flex flex-col gap-y-4">
+          <app-input
+            id="email"
+            name="email"
+            label="Email Address"
+            type="email"
+            placeholder="you@example.com"
+            icon="mail"
+            [required]="true"
+            [error]="emailError()"
+            [(value)]="email"
+          />
+
+          <app-button
+            type="submit"
+            variant="primary"
+            size="md"
+            [fullWidth]="true"
+            [disabled]="isLoading()"
+          >
+            @if (isLoading()) {
+              <span class="flex items-center justify-center gap-2">
+                <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
+                Sending...
+              </span>
+            } @else {
+              Send Sign-In Link
+            }
+          </app-button>
+        </form>
+      }
+
+      <div class="text-center">
+        <a
+          routerLink="/auth/login"
+          class="text-sm text-primary-400 hover:text-primary-300 transition-colors"
+        >
+          Sign in with a password
+        </a>
+      </div>
+    </div>
+  `,
+})
+export class MagicLinkComponent {
+  private authService = inject(AuthService);
+  private authApi = inject(AuthApiService);
+  private toast = inject(ToastService);
+  private router = inject(Router);
+  private route = inject(ActivatedRoute);
+
+  public token: string | null = this.route.snapshot.queryParamMap.get('token');
+
+  public email = signal('');
+  public isLoading = signal(false);
+  public emailError = signal<InputError | null>(null);
+  public sentMessage = signal<string | null>(null);
+  public signInError = signal<string | null>(null);
+
+  constructor() {
+    if (this.token) {
+      this.signIn(this.token);
+    }
+  }
+
+  private signIn(token: string) {
+    this.authService.loginWithMagicLink(token).subscribe({
+      next: (response) => {
+        this.toast.success('Login successful!', `Welcome back, ${response.user.name}!`);
+        this.router.navigate(['/dashboard']);
+      },
+      error: (error) => {
+        this.signInError.set(error.message || 'The link has expired or was already used.');
+      },
+    });
+  }
+
+  private validateEmail(): boolean {
+    if (!this.email().trim()) {
+      this.emailError.set({ message: 'Email address is required' });
+      return false;
+    }
+    const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
+    if (!emailRegex.test(this.email())) {
+      this.emailError.set({ message: 'Please enter a valid email address' });
+      return false;
+    }
+    this.emailError.set(null);
+    return true;
+  }
+
+  public onSubmit() {
+    if (!this.validateEmail()) {
+      return;
+    }
+
+    this.isLoading.set(true);
+
+    this.authApi.requestMagicLink(this.email()).subscribe({
+      next: (response) => {
+        this.isLoading.set(false);
+        this.sentMessage.set(response.message);
+      },
+      error: (error) => {
+        this.isLoading.set(false);
+        this.toast.error('Request failed', error.message || 'Please try again later.');
+      },
+    });
+  }
+}
@@ -1,16 +1,26 @@
-import { Component, inject, signal } from '@angular/core';
+import { Component, computed, inject, signal } from '@angular/core';
 import { FormsModule } from '@angular/forms';
 import { Router, RouterLink } from '@angular/router';
+import type { RegistrationPolicyResponse } from '../../api/schemas/auth.schema';
+import { AuthApiService } from '../../api/services/auth-api.service';
 import { ButtonComponent } from '../../components/button.component';
 import { InputComponent, type InputError } from '../../components/input.component';
 import { PasswordInputComponent } from '../../components/password-input.component';
+import { StatusMessageComponent } from '../../components/status-message.component';
 import { AuthService } from '../../services/auth.service';
 import { ToastService } from '../../services/toast.service';
 
 @Component({
   selector: 'app-register',
   standalone: true,
-  imports: [RouterLink, InputComponent, FormsModule, PasswordInputComponent, ButtonComponent],
+  imports: [
+    RouterLink,
+    InputComponent,
+    FormsModule,
+    PasswordInputComponent,
+    ButtonComponent,
+    StatusMessageComponent,
+  ],
   template: `
     <div class="space-y-6">
       <div class="text-center">
@@ -20,95 +30,109 @@ import { ToastService } from '../../services/toast.service';
         </p>
       </div>
 
-      <form (ngSubmit)="onSubmit()" class="flex flex-col gap-y-4">
-        <app-input
-          id="name"
-          name="name"
-          label="Full Name"
-          placeholder="John Doe"
-          icon="person"
-          [required]="true"
-          [error]="nameError()"
-          [(value)]="name"
-        />
-
-        <app-input
-          id="email"
-          name="email"
-          label="Email Address"
-          type="email"
-          placeholder="you@example.com"
-          icon="mail"
-          [required]="true"
-          [error]="emailError()"
-          [(value)]="email"
+      @if (registrationPolicy()?.enabled === false) {
+        <app-status-message
+          type="info"
+          title="Registration is invite-only"
+          message="Ask an administrator at your company to invite you to Kandy."
         />
+      } @else {
+        @if (registrationPolicy()?.mode === 'domain_allowlist') {
+          <p class="text-text-secondary text-sm text-center">
+            Sign up with your work email ({{ allowedDomainsLabel() }}).
+          </p>
+        }
 
-        <app-password-input
-          id="password"
-          name="password"
-          label="Password"
-          placeholder="••••••••"
-          hint="Must be at least 8 characters with uppercase, lowercase, and numbers"
-          [required]="true"
-          [error]="passwordError()"
-          [(value)]="password"
-        />
+        <form (ngSubmit)="onSubmit()" class="flex flex-col gap-y-4">
+          <app-input
+            id="name"
+            name="name"
+            label="Full Name"
+            placeholder="John Doe"
+            icon="person"
+            [required]="true"
+            [error]="nameError()"
+            [(value)]="name"
+          />
 
-        <app-password-input
-          id="confirmPassword"
-          name="confirmPassword"
-          label="Confirm Password"
-          placeholder="••••••••"
-          [required]="true"
-          [error]="confirmPasswordError()"
-          [(value)]="confirmPassword"
-        />
+          <app-input
+            id="email"
+            name="email"
+            label="Email Address"
+            type="email"
+            placeholder="you@example.com"
+            icon="mail"
+            [required]="true"
+            [error]="emailError()"
+            [(value)]="email"
+          />
 
-        <label class="flex items-start gap-2 cursor-pointer">
-          <input
-            type="checkbox"
-            [(ngModel)]="acceptTerms"
-            name="acceptTerms"
-            required
-            class="w-4 h-4 mt-0.5 rounded border-2 border-border bg-surface text-primary-600 focus:ring-2 focus:ring-primary-500 focus:ring-offset-0 cursor-pointer"
+          <app-password-input
+            id="password"
+            name="password"
+            label="Password"
+            placeholder="••••••••"
+            hint="Must be at least 8 characters with uppercase, lowercase, and numbers"
+            [required]="true"
+            [error]="passwordError()"
+            [(value)]="password"
           />
-          <span class="text-sm text-text-secondary">
-            I agree to the
-            <a href="#" class="text-primary-400 hover:text-primary-300 transition-colors">
-              Terms of Service
-            </a>
-            and
-            <a href="#" class="text-primary-400 hover:text-primary-300 transition-colors">
-              Privacy Policy
-            </a>
-          </span>
-        </label>
 
-        @if (termsError()) {
-          <p class="text-sm text-error flex items-center gap-1 -mt-2">
-            <span class="material-symbols-outlined text-base">error</span>
-            {{ termsError()!.message }}
-          </p>
-        }
+          <app-password-input
+            id="confirmPassword"
+            name="confirmPassword"
+            label="Confirm Password"
+            placeholder="••••••••"
+            [required]="true"
+            [error]="confirmPasswordError()"
+            [(value)]="confirmPassword"
+          />
 
-        <app-button
-          type="submit"
-          variant="primary"
-          size="md"
-          [fullWidth]="true"
-          [disabled]="isLoading()"
-        >
-          @if (isLoading()) {
-            <span class="flex items-center justify-center gap-2">
-              <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
-              Creating account...
+          <label class="flex items-start gap-2 cursor-pointer">
+            <input
+              type="checkbox"
+              [(ngModel)]="acceptTerms"
+              name="acceptTerms"
+              required
+              class="w-4 h-4 mt-0.5 rounded border-2 border-border bg-surface text-primary-600 focus:ring-2 focus:ring-primary-500 focus:ring-offset-0 cursor-pointer"
+            />
+            <span class="text-sm text-text-secondary">
+              I agree to the
+              <a href="#" class="text-primary-400 hover:text-primary-300 transition-colors">
+                Terms of Service
+              </a>
+              and
+              <a href="#" class="text-primary-400 hover:text-primary-300 transition-colors">
+                Privacy Policy
+              </a>
             </span>
-          } @else {
-            Create Account
+          </label>
+
+          @if (termsError()) {
+            <p class="text-sm text-error flex items-center gap-1 -mt-2">
+              <span class="material-symbols-outlined text-base">error</span>
+              {{ termsError()!.message }}
+            </p>
           }
-        </app-button>
-      </form>
+
+          <app-button
+            type="submit"
+            variant="primary"
+            size="md"
+            [fullWidth]="true"
+            [disabled]="isLoading()"
+          >
+            @if (isLoading()) {
+              <span class="flex items-center justify-center gap-2">
+                <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
+                Creating account...
+              </span>
+            } @else {
+              Create Account
+            }
+          </app-button>
+        </form>
+      }
 
       <div class="text-center">
         <p class="text-text-secondary text-sm">
@@ -126,9 +150,15 @@ import { ToastService } from '../../services/toast.service';
 })
 export class RegisterComponent {
   private authService = inject(AuthService);
+  private authApi = inject(AuthApiService);
   private toast = inject(ToastService);
   private router = inject(Router);
 
+  public registrationPolicy = signal<RegistrationPolicyResponse | null>(null);
+  public allowedDomainsLabel = computed(() =>
+    (this.registrationPolicy()?.allowed_domains ?? []).map((domain) => `@${domain}`).join(', '),
+  );
+
   public name = signal('');
   public email = signal('');
   public password = signal('');
@@ -142,6 +172,14 @@ export class RegisterComponent {
   public confirmPasswordError = signal<InputError | null>(null);
   public termsError = signal<InputError | null>(null);
 
+  constructor() {
+    // Without a policy the form stays visible and the backend still enforces it
+    this.authApi.getRegistrationPolicy().subscribe({
+      next: (policy) => this.registrationPolicy.set(policy),
+      error: () => this.registrationPolicy.set(null),
+    });
+  }
+
   private validateName(): boolean {
     if (!this.name().trim()) {
       this.nameError.set({ message: 'Full name is required' });
@@ -239,6 +277,11 @@ export class RegisterComponent {
     this.authService.register(this.name(), this.email(), this.password()).subscribe({
       next: (response) => {
         this.isLoading.set(false);
+        if (response.user.approval_status === 'pending') {
+          this.toast.info('Registration received', response.message);
+          this.router.navigate(['/auth/login']);
+          return;
+        }
         this.toast.success('Registration successful!', `Welcome, ${response.user.name}!`);
         this.router.navigate(['/dashboard']);
       },
@@ -0,0 +1,87 @@
+import { Component, inject, signal } from '@angular/core';
+import { ActivatedRoute, RouterLink } from '@angular/router';
+import { AuthApiService } from '../../api/services/auth-api.service';
+import { ButtonComponent } from '../../components/button.component';
+import { StatusMessageComponent } from '../../components/status-message.component';
+
+// Opened from the "this wasn't me" link in a new sign-in email. Signing the
+// session out needs a click, so link previews in mail clients don't do it
+@Component({
+  selector: 'app-report-session',
+  standalone: true,
+  imports: [RouterLink, ButtonComponent, StatusMessageComponent],
+  template: `
+    <div class="space-y-6">
+      @if (result(); as result) {
+        <app-status-message [type]="result.type" [title]="result.title" [message]="result.message" />
+      } @else {
+        <div class="text-center">
+          <h2 class="text-2xl font-bold text-text-primary mb-2">Wasn't You?</h2>
+          <p class="text-text-secondary text-sm">
+            Sign out the session from the email right away. You can then change your password.
+          </p>
+        </div>
+
+        <app-button
+          variant="error"
+          size="md"
+          [fullWidth]="true"
+          [disabled]="isLoading() || !token"
+          (clicked)="onReport()"
+        >
+          @if (isLoading()) {
+            <span class="flex items-center justify-center gap-2">
+              <span class="material-symbols-outlined text-lg animate-spin">progress_activity</span>
+              Signing out...
+            </span>
+          } @else {
+            Sign Out That Session
+          }
+        </app-button>
+      }
+
+      <div class="text-center">
+        <a
+          routerLink="/auth/login"
+          class="text-sm text-primary-400 hover:text-primary-300 transition-colors"
+        >
+          Go to sign in
+        </a>
+      </div>
+    </div>
+  `,
+})
+export class ReportSessionComponent {
+  private authApi = inject(AuthApiService);
+  private route = inject(ActivatedRoute);
+
+  public token: string | null = this.route.snapshot.queryParamMap.get('token');
+
+  public isLoading = signal(false);
+  public result = signal<{ type: 'success' | 'error'; title: string; message: string } | null>(
+    null,
+  );
+
+  public onReport() {
+    if (!this.token) {
+      return;
+    }
+
+    this.isLoading.set(true);
+
+    this.authApi.reportSession(this.token).subscribe({
+      next: (response) => {
+        this.isLoading.set(false);
+        this.result.set({ type: 'success', title: 'Session signed out', message: response.message });
+      },
+      error: (error) => {
+        this.isLoading.set(false);
+        this.result.set({
+          type: 'error',
+          title: 'Link not valid',
+          message: error.message || 'The link has expired or was already used.',
+        });
+      },
+    });
+  }
+}
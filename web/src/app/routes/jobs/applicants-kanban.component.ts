@@ -1,30 +1,36 @@
 import { CommonModule } from '@angular/common';
-import {Component, inject, signal} from '@angular/core';
+import { Component, computed, inject, type OnInit, signal } from '@angular/core';
 import { ActivatedRoute, RouterLink } from '@angular/router';
+import { ApplicationsApiService } from '../../api/services/applications-api.service';
+import type {
+  Application,
+  PipelineColumnKey,
+  RejectionReason,
+  StageType,
+} from '../../api/schemas/applications.schema';
+import { ToastService } from '../../services/toast.service';
 import { CardComponent } from '../../components/card.component';
 import { ButtonComponent } from '../../components/button.component';
 import { IconComponent } from '../../components/icon.component';
 
-interface Applicant {
-  id: number;
-  name: string;
-  email: string;
-  phone: string;
-  appliedAt: string;
-  source: string;
-  status: 'new' | 'screening' | 'interview' | 'offer' | 'hired' | 'rejected';
-  resumeUrl?: string;
-  coverLetter?: string;
-}
-
 interface KanbanColumn {
-  id: string;
+  id: PipelineColumnKey;
   title: string;
-  status: Applicant['status'];
   color: string;
-  applicants: Applicant[];
+  applicants: Application[];
 }
 
+const stageColors: Record<StageType, string> = {
+  applied: '#3b82f6',
+  screen: '#f59e0b',
+  interview: '#8b5cf6',
+  assessment: '#06b6d4',
+  offer: '#ec4899',
+  hired: '#10b981',
+};
+
+const rejectedColor = '#ef4444';
+
 @Component({
   selector: 'app-applicants-kanban',
   standalone: true,
@@ -48,11 +54,43 @@ interface KanbanColumn {
         </app-button>
       </div>
 
+      @if (rejecting(); as applicant) {
+        <app-card class="p-4">
+          <div class="flex flex-wrap items-end gap-3">
+            <div class="flex-1 min-w-[200px]">
+              <p class="text-sm font-medium text-text-primary mb-2">Reject {{ applicant.candidate?.name }}</p>
+              <select
+                #reason
+                class="w-full px-4 py-2 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+              >
+                @for (option of rejectionReasons; track option.value) {
+                  <option [value]="option.value">{{ option.label }}</option>
+                }
+              </select>
+            </div>
+            <input
+              #note
+              type="text"
+              placeholder="Note (optional)"
+              class="flex-1 min-w-[200px] px-4 py-2 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
+            />
+            <app-button variant="ghost" size="sm" (click)="rejecting.set(null)">Cancel</app-button>
+            <app-button variant="primary" size="sm" (click)="reject(applicant, $any(reason.value), note.value)">
+              Reject
+            </app-button>
+          </div>
+        </app-card>
+      }
+
       <!-- Kanban Board -->
       <div class="overflow-x-auto pb-4">
         <div class="flex gap-4 min-w-max">
           @for (column of columns(); track column.id) {
-            <div class="w-80 flex-shrink-0">
+            <div
+              class="w-80 flex-shrink-0"
+              (dragover)="canManage() && $event.preventDefault()"
+              (drop)="onDrop(column.id)"
+            >
               <!-- Column Header -->
               <div class="mb-4">
                 <div class="flex items-center justify-between p-4 bg-surface rounded-xl border-2 border-border">
@@ -74,14 +112,17 @@ interface KanbanColumn {
                 @for (applicant of column.applicants; track applicant.id) {
                   <app-card
                     class="p-4 cursor-pointer hover:border-primary-500/50 transition-all hover:shadow-lg"
+                    [attr.draggable]="canManage()"
+                    (dragstart)="dragged.set(applicant)"
+                    (dragend)="dragged.set(null)"
                     (click)="openApplicantDetails(applicant)"
                   >
                     <div class="space-y-3">
                       <!-- Applicant Info -->
                       <div class="flex items-start justify-between">
                         <div class="flex-1">
-                          <h4 class="font-semibold text-text-primary">{{ applicant.name }}</h4>
-                          <p class="text-sm text-text-secondary mt-1">{{ applicant.email }}</p>
+                          <h4 class="font-semibold text-text-primary">{{ applicant.candidate?.name }}</h4>
+                          <p class="text-sm text-text-secondary mt-1">{{ applicant.candidate?.email }}</p>
                         </div>
                         <button
                           class="p-1.5 rounded-lg hover:bg-surface-elevated transition-colors"
@@ -98,18 +139,21 @@ interface KanbanColumn {
                           {{ formatDate(applicant.appliedAt) }}
                         </span>
                         <span class="w-1 h-1 rounded-full bg-text-tertiary"></span>
-                        <span class="px-2 py-1 bg-surface-elevated rounded">{{ applicant.source }}</span>
+                        <span class="px-2 py-1 bg-surface-elevated rounded">{{ sourceLabel(applicant) }}</span>
+                        @if (applicant.status === 'withdrawn') {
+                          <span class="px-2 py-1 bg-surface-elevated rounded">Withdrawn</span>
+                        }
                       </div>
 
                       <!-- Actions -->
                       <div class="flex gap-2 pt-2 border-t border-border">
-                        @if (applicant.resumeUrl) {
+                        @if (column.id === 'rejected' && canManage()) {
                           <button
                             class="flex-1 px-3 py-2 bg-surface-elevated hover:bg-surface-hover rounded-lg text-xs font-medium text-text-primary transition-colors flex items-center justify-center gap-1"
-                            (click)="$event.stopPropagation(); viewResume(applicant)"
+                            (click)="$event.stopPropagation(); reopen(applicant)"
                           >
-                            <app-icon name="description" size="sm" />
-                            Resume
+                            <app-icon name="undo" size="sm" />
+                            Reopen
                           </button>
                         }
                         <button
@@ -136,101 +180,113 @@ interface KanbanColumn {
     </div>
   `,
 })
-export class ApplicantsKanbanComponent {
+export class ApplicantsKanbanComponent implements OnInit {
   private route = inject(ActivatedRoute);
+  private applicationsApi = inject(ApplicationsApiService);
+  private toast = inject(ToastService);
 
-  jobId = signal<number>(1);
-  jobTitle = signal<string>('Senior Frontend Developer');
+  jobId = signal<number>(0);
+  jobTitle = signal<string>('');
+  canManage = signal(false);
+  columns = signal<KanbanColumn[]>([]);
 
-  columns = signal<KanbanColumn[]>([
-    {
-      id: 'new',
-      title: 'New',
-      status: 'new',
-      color: '#3b82f6',
-      applicants: [
-        {
-          id: 1,
-          name: 'John Doe',
-          email: 'john@example.com',
-          phone: '+1234567890',
-          appliedAt: '2025-10-07T10:30:00',
-          source: 'LinkedIn',
-          status: 'new',
-          resumeUrl: '/resumes/1.pdf',
-        },
-        {
-          id: 2,
-          name: 'Jane Smith',
-          email: 'jane@example.com',
-          phone: '+1234567891',
-          appliedAt: '2025-10-07T14:20:00',
-          source: 'Indeed',
-          status: 'new',
-          resumeUrl: '/resumes/2.pdf',
-        },
-      ],
-    },
-    {
-      id: 'screening',
-      title: 'Screening',
-      status: 'screening',
-      color: '#f59e0b',
-      applicants: [
-        {
-          id: 3,
-          name: 'Bob Johnson',
-          email: 'bob@example.com',
-          phone: '+1234567892',
-          appliedAt: '2025-10-06T09:15:00',
-          source: 'LinkedIn',
-          status: 'screening',
-          resumeUrl: '/resumes/3.pdf',
-        },
-      ],
-    },
-    {
-      id: 'interview',
-      title: 'Interview',
-      status: 'interview',
-      color: '#8b5cf6',
-      applicants: [
-        {
-          id: 4,
-          name: 'Alice Williams',
-          email: 'alice@example.com',
-          phone: '+1234567893',
-          appliedAt: '2025-10-05T16:45:00',
-          source: 'Internal',
-          status: 'interview',
-          resumeUrl: '/resumes/4.pdf',
-        },
-      ],
-    },
-    {
-      id: 'offer',
-      title: 'Offer',
-      status: 'offer',
-      color: '#ec4899',
-      applicants: [],
-    },
-    {
-      id: 'hired',
-      title: 'Hired',
-      status: 'hired',
-      color: '#10b981',
-      applicants: [],
-    },
-    {
-      id: 'rejected',
-      title: 'Rejected',
-      status: 'rejected',
-      color: '#ef4444',
-      applicants: [],
-    },
-  ]);
+  dragged = signal<Application | null>(null);
+  rejecting = signal<Application | null>(null);
 
-  totalApplicants = signal<number>(4);
+  totalApplicants = computed(() =>
+    this.columns().reduce((total, column) => total + column.applicants.length, 0),
+  );
+
+  rejectionReasons: { value: RejectionReason; label: string }[] = [
+    { value: 'unqualified', label: 'Not qualified' },
+    { value: 'better_candidate', label: 'Stronger candidate chosen' },
+    { value: 'position_filled', label: 'Position filled' },
+    { value: 'compensation', label: 'Compensation mismatch' },
+    { value: 'no_show', label: 'No show' },
+    { value: 'unresponsive', label: 'Unresponsive' },
+    { value: 'other', label: 'Other' },
+  ];
+
+  ngOnInit(): void {
+    this.jobId.set(Number(this.route.snapshot.paramMap.get('id')));
+    this.load();
+  }
+
+  load(): void {
+    this.applicationsApi.getPipeline(this.jobId()).subscribe({
+      next: (pipeline) => {
+        this.jobTitle.set(pipeline.job.title);
+        this.canManage.set(pipeline.can_manage);
+        this.columns.set([
+          ...pipeline.columns.map((column) => ({
+            id: column.stage.id,
+            title: column.stage.name,
+            color: stageColors[column.stage.type],
+            applicants: column.applications,
+          })),
+          {
+            id: 'rejected' as const,
+            title: 'Rejected',
+            color: rejectedColor,
+            applicants: pipeline.rejected.applications,
+          },
+        ]);
+      },
+      error: (error) => this.toast.error('Could not load applicants', error.message),
+    });
+  }
+
+  onDrop(target: PipelineColumnKey): void {
+    const applicant = this.dragged();
+    this.dragged.set(null);
+    if (!applicant || this.columnOf(applicant) === target) {
+      return;
+    }
+
+    if (target === 'rejected') {
+      this.rejecting.set(applicant);
+      return;
+    }
+    if (applicant.status === 'rejected' || applicant.status === 'withdrawn') {
+      this.toast.error('Reopen first', 'Reopen the application before moving it');
+      return;
+    }
+    this.move(applicant, target);
+  }
+
+  reject(applicant: Application, reason: RejectionReason, note: string): void {
+    this.rejecting.set(null);
+    this.applicationsApi.setStatus(applicant.id, { status: 'rejected', rejection_reason: reason, note }).subscribe({
+      next: () => this.load(),
+      error: (error) => this.toast.error('Could not reject', error.message),
+    });
+  }
+
+  reopen(applicant: Application): void {
+    this.applicationsApi.setStatus(applicant.id, { status: 'active' }).subscribe({
+      next: () => this.load(),
+      error: (error) => this.toast.error('Could not reopen', error.message),
+    });
+  }
+
+  private move(applicant: Application, stageId: number): void {
+    this.applicationsApi.moveApplication(applicant.id, stageId).subscribe({
+      next: () => this.load(),
+      error: (error) => this.toast.error('Could not move applicant', error.message),
+    });
+  }
+
+  private columnOf(applicant: Application): PipelineColumnKey {
+    return applicant.status === 'rejected' || applicant.status === 'withdrawn' ? 'rejected' : applicant.stageId;
+  }
+
+  sourceLabel(applicant: Application): string {
+    const candidate = applicant.candidate;
+    if (!candidate) {
+      return '';
+    }
+    return candidate.sourceDetail || candidate.source.replace('_', ' ');
+  }
 
   formatDate(dateString: string): string {
     const date = new Date(dateString);
@@ -243,24 +299,19 @@ export class ApplicantsKanbanComponent {
     return date.toLocaleDateString();
   }
 
-  openApplicantDetails(applicant: Applicant): void {
+  openApplicantDetails(applicant: Application): void {
     console.log('Open applicant details', applicant);
     // TODO: Open modal or navigate to applicant detail page
   }
 
-  openMenu(applicant: Applicant): void {
+  openMenu(applicant: Application): void {
     console.log('Open menu', applicant);
     // TODO: Show context menu for actions
   }
 
-  viewResume(applicant: Applicant): void {
-    console.log('View resume', applicant);
-    // TODO: Open resume in new tab or modal
-  }
-
-  sendEmail(applicant: Applicant): void {
-    console.log('Send email', applicant);
-    // TODO: Open email compose modal
+  sendEmail(applicant: Application): void {
+    if (applicant.candidate?.email) {
+      window.location.href = `mailto:${applicant.candidate.email}`;
+    }
   }
 }
-
@@ -1,7 +1,14 @@
 import { CommonModule } from '@angular/common';
-import {Component, inject, signal} from '@angular/core';
+import { Component, inject, type OnInit, signal } from '@angular/core';
 import { FormBuilder, FormGroup, ReactiveFormsModule, Validators } from '@angular/forms';
-import { Router, RouterLink } from '@angular/router';
+import { ActivatedRoute, Router, RouterLink } from '@angular/router';
+import { JobsApiService } from '../../api/services/jobs-api.service';
+import { OrganizationApiService } from '../../api/services/organization-api.service';
+import { PipelineApiService } from '../../api/services/pipeline-api.service';
+import type { Job, JobRequest, JobStatus } from '../../api/schemas/jobs.schema';
+import type { Department, Location } from '../../api/schemas/organization.schema';
+import type { PipelineTemplate } from '../../api/schemas/pipeline.schema';
+import { ToastService } from '../../services/toast.service';
 import { CardComponent } from '../../components/card.component';
 import { ButtonComponent } from '../../components/button.component';
 import { IconComponent } from '../../components/icon.component';
@@ -27,8 +34,10 @@ import { IconComponent } from '../../components/icon.component';
           </app-button>
         </a>
         <div>
-          <h1 class="text-3xl font-bold text-text-primary">Create Job</h1>
-          <p class="text-text-secondary mt-1">Fill in the details to create a new job posting</p>
+          <h1 class="text-3xl font-bold text-text-primary">{{ jobId() ? 'Edit Job' : 'Create Job' }}</h1>
+          <p class="text-text-secondary mt-1">
+            {{ jobId() ? 'Update the details of this job posting' : 'Fill in the details to create a new job posting' }}
+          </p>
         </div>
       </div>
 
@@ -57,27 +66,54 @@ import { IconComponent } from '../../components/icon.component';
                 <label class="block text-sm font-medium text-text-primary mb-2">
                   Department <span class="text-error">*</span>
                 </label>
-                <input
-                  type="text"
-                  formControlName="department"
-                  placeholder="e.g. Engineering"
-                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
-                />
+                <select
+                  formControlName="departmentId"
+                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                >
+                  <option [ngValue]="null">
+                    {{ legacyDepartment() || 'Select a department' }}
+                  </option>
+                  @for (department of departments(); track department.id) {
+                    <option [ngValue]="department.id">{{ indent(department.depth) }}{{ department.name }}</option>
+                  }
+                </select>
               </div>
 
               <div>
                 <label class="block text-sm font-medium text-text-primary mb-2">
                   Location <span class="text-error">*</span>
                 </label>
-                <input
-                  type="text"
-                  formControlName="location"
-                  placeholder="e.g. Remote, New York, NY"
-                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
-                />
+                <select
+                  formControlName="locationId"
+                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                >
+                  <option [ngValue]="null">
+                    {{ legacyLocation() || 'Select a location' }}
+                  </option>
+                  @for (location of locations(); track location.id) {
+                    <option [ngValue]="location.id">
+                      {{ location.name }}{{ location.workplaceType === 'onsite' ? '' : ' (' + location.workplaceType + ')' }}
+                    </option>
+                  }
+                </select>
               </div>
             </div>
 
+            @if (jobId() === null) {
+              <div>
+                <label class="block text-sm font-medium text-text-primary mb-2">Hiring Pipeline</label>
+                <select
+                  formControlName="pipelineTemplateId"
+                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                >
+                  <option [ngValue]="null">Default ({{ defaultStages() }})</option>
+                  @for (template of pipelineTemplates(); track template.id) {
+                    <option [ngValue]="template.id">{{ template.name }}</option>
+                  }
+                </select>
+              </div>
+            }
+
             <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
               <div>
                 <label class="block text-sm font-medium text-text-primary mb-2">
@@ -96,14 +132,32 @@ import { IconComponent } from '../../components/icon.component';
 
               <div>
                 <label class="block text-sm font-medium text-text-primary mb-2">
-                  Salary Range
+                  Salary Range (per year)
                 </label>
-                <input
-                  type="text"
-                  formControlName="salary"
-                  placeholder="e.g. $80,000 - $120,000"
-                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
-                />
+                <div class="flex gap-2">
+                  <input
+                    type="number"
+                    min="0"
+                    formControlName="salaryMin"
+                    placeholder="Min"
+                    class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
+                  />
+                  <input
+                    type="number"
+                    min="0"
+                    formControlName="salaryMax"
+                    placeholder="Max"
+                    class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary placeholder:text-text-tertiary focus:outline-none focus:border-primary-500 transition-colors"
+                  />
+                  <select
+                    formControlName="salaryCurrency"
+                    class="px-3 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                  >
+                    @for (currency of currencies; track currency) {
+                      <option [value]="currency">{{ currency }}</option>
+                    }
+                  </select>
+                </div>
               </div>
             </div>
 
@@ -164,6 +218,26 @@ import { IconComponent } from '../../components/icon.component';
               }
             </div>
 
+            <div class="grid grid-cols-1 md:grid-cols-2 gap-4">
+              <div>
+                <label class="block text-sm font-medium text-text-primary mb-2">Publish on</label>
+                <input
+                  type="datetime-local"
+                  formControlName="publishAt"
+                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                />
+                <p class="text-xs text-text-tertiary mt-1">Leave empty to publish as soon as the job is approved</p>
+              </div>
+              <div>
+                <label class="block text-sm font-medium text-text-primary mb-2">Close on</label>
+                <input
+                  type="datetime-local"
+                  formControlName="closeAt"
+                  class="w-full px-4 py-3 bg-surface border-2 border-border rounded-xl text-text-primary focus:outline-none focus:border-primary-500 transition-colors"
+                />
+              </div>
+            </div>
+
             <div class="p-4 bg-primary-500/10 border-2 border-primary-500/20 rounded-xl">
               <div class="flex gap-3">
                 <app-icon name="info" size="sm" class="text-primary-500 mt-0.5" />
@@ -190,7 +264,7 @@ import { IconComponent } from '../../components/icon.component';
             <app-icon name="save" size="sm" />
             Save as Draft
           </app-button>
-          <app-button variant="primary" size="md" type="submit" [disabled]="!jobForm.valid">
+          <app-button variant="primary" size="md" type="submit" [disabled]="!jobForm.valid || saving()">
             <app-icon name="publish" size="sm" />
             Publish Job
           </app-button>
@@ -199,9 +273,29 @@ import { IconComponent } from '../../components/icon.component';
     </div>
   `,
 })
-export class JobFormComponent {
+export class JobFormComponent implements OnInit {
   private fb = inject(FormBuilder);
   private router = inject(Router);
+  private route = inject(ActivatedRoute);
+  private jobsApi = inject(JobsApiService);
+  private organizationApi = inject(OrganizationApiService);
+  private pipelineApi = inject(PipelineApiService);
+  private toast = inject(ToastService);
+
+  jobId = signal<number | null>(null);
+  departments = signal<Department[]>([]);
+  locations = signal<Location[]>([]);
+  pipelineTemplates = signal<PipelineTemplate[]>([]);
+  defaultStages = signal('');
+
+  // Jobs created before departments and locations were managed may only
+  // have a name; it is kept until another one is picked
+  legacyDepartment = signal('');
+  legacyLocation = signal('');
+  currentStatus = signal<JobStatus | null>(null);
+  saving = signal(false);
+
+  currencies = ['USD', 'EUR', 'GBP', 'CHF', 'CAD', 'AUD'];
 
   selectedPlatforms = signal<string[]>([]);
 
@@ -234,14 +328,56 @@ export class JobFormComponent {
 
   jobForm: FormGroup = this.fb.group({
     title: ['', [Validators.required]],
-    department: ['', [Validators.required]],
-    location: ['', [Validators.required]],
+    departmentId: [null as number | null, [Validators.required]],
+    locationId: [null as number | null, [Validators.required]],
+    pipelineTemplateId: [null as number | null],
     type: ['full-time', [Validators.required]],
-    salary: [''],
+    salaryMin: [null as number | null],
+    salaryMax: [null as number | null],
+    salaryCurrency: ['USD'],
+    publishAt: [''],
+    closeAt: [''],
     description: ['', [Validators.required]],
     requirements: ['', [Validators.required]],
   });
 
+  ngOnInit(): void {
+    this.organizationApi.listDepartments().subscribe({
+      next: (departments) => this.departments.set(departments),
+      error: (error) => this.toast.error('Could not load departments', error.message),
+    });
+    this.organizationApi.listLocations().subscribe({
+      next: (locations) => this.locations.set(locations),
+      error: (error) => this.toast.error('Could not load locations', error.message),
+    });
+
+    const id = Number(this.route.snapshot.paramMap.get('id'));
+    if (!id) {
+      // The pipeline is picked once; existing jobs edit their stages directly
+      this.pipelineApi.listTemplates().subscribe({
+        next: (response) => {
+          this.pipelineTemplates.set(response.templates);
+          this.defaultStages.set(response.default_stages.map((stage) => stage.name).join(' → '));
+        },
+        error: (error) => this.toast.error('Could not load pipeline templates', error.message),
+      });
+      return;
+    }
+
+    this.jobId.set(id);
+    this.jobsApi.getJob(id).subscribe({
+      next: (response) => this.fillForm(response.job),
+      error: (error) => {
+        this.toast.error('Could not load job', error.message);
+        this.router.navigate(['/jobs']);
+      },
+    });
+  }
+
+  indent(depth: number): string {
+    return '\u00a0\u00a0'.repeat(depth);
+  }
+
   togglePlatform(platformId: string): void {
     const current = this.selectedPlatforms();
     if (current.includes(platformId)) {
@@ -252,16 +388,96 @@ export class JobFormComponent {
   }
 
   saveDraft(): void {
-    // TODO: Implement save as draft
-    console.log('Save draft', this.jobForm.value, this.selectedPlatforms());
-    this.router.navigate(['/jobs']);
+    if (!this.jobForm.value.title) {
+      this.toast.error('Title required', 'Give the job a title before saving it');
+      return;
+    }
+    this.save('draft');
   }
 
   onSubmit(): void {
     if (this.jobForm.valid) {
-      // TODO: Implement job creation with API
-      console.log('Create job', this.jobForm.value, this.selectedPlatforms());
-      this.router.navigate(['/jobs']);
+      this.save('published');
+    }
+  }
+
+  private save(status: NonNullable<JobRequest['status']>): void {
+    const request = this.buildRequest(status);
+    const id = this.jobId();
+    const call = id ? this.jobsApi.updateJob(id, request) : this.jobsApi.createJob(request);
+
+    this.saving.set(true);
+    call.subscribe({
+      next: (response) => {
+        this.saving.set(false);
+        this.toast.success(response.message ?? 'Job saved', request.title);
+        this.router.navigate(['/jobs']);
+      },
+      error: (error) => {
+        this.saving.set(false);
+        this.toast.error('Could not save job', error.message);
+      },
+    });
+  }
+
+  private buildRequest(status: NonNullable<JobRequest['status']>): JobRequest {
+    // Only drafts change status through the form, other changes are transitions
+    const current = this.currentStatus();
+    const requestedStatus = current === null || current === 'draft' ? status : undefined;
+
+    const form = this.jobForm.value;
+    const salaryMin = form.salaryMin === null || form.salaryMin === '' ? null : Number(form.salaryMin);
+    const salaryMax = form.salaryMax === null || form.salaryMax === '' ? null : Number(form.salaryMax);
+    const hasSalary = salaryMin !== null || salaryMax !== null;
+
+    return {
+      title: form.title,
+      department_id: form.departmentId,
+      department: form.departmentId === null ? this.legacyDepartment() : undefined,
+      location_id: form.locationId,
+      location: form.locationId === null ? this.legacyLocation() : undefined,
+      type: form.type,
+      status: requestedStatus,
+      description: form.description,
+      requirements: form.requirements,
+      salary_min: salaryMin,
+      salary_max: salaryMax,
+      salary_currency: hasSalary ? form.salaryCurrency : '',
+      salary_period: hasSalary ? 'year' : '',
+      published_to: this.selectedPlatforms() as JobRequest['published_to'],
+      publish_at: form.publishAt ? new Date(form.publishAt).toISOString() : null,
+      close_at: form.closeAt ? new Date(form.closeAt).toISOString() : null,
+      pipeline_template_id: this.jobId() === null ? form.pipelineTemplateId : undefined,
+    };
+  }
+
+  private fillForm(job: Job): void {
+    this.jobForm.patchValue({
+      title: job.title,
+      departmentId: job.departmentId,
+      locationId: job.locationId,
+      type: job.type,
+      salaryMin: job.salaryMin,
+      salaryMax: job.salaryMax,
+      salaryCurrency: job.salaryCurrency || 'USD',
+      description: job.description,
+      requirements: job.requirements,
+      publishAt: this.toLocalInput(job.publishAt),
+      closeAt: this.toLocalInput(job.closeAt),
+    });
+    this.legacyDepartment.set(job.departmentId === null ? job.department : '');
+    this.legacyLocation.set(job.locationId === null ? job.location : '');
+    this.selectedPlatforms.set(job.publishedTo);
+    this.currentStatus.set(job.status);
+  }
+
+  // datetime-local inputs take local time without a zone
+  private toLocalInput(value: string | null): string {
+    if (!value) {
+      return '';
     }
+    const date = new Date(value);
+    const offset = date.getTimezoneOffset() * 60000;
+    return new Date(date.getTime() - offset).toISOString().slice(0, 16);
   }
 }
@@ -1,22 +1,13 @@
 import { CommonModule } from '@angular/common';
-import { Component, signal } from '@angular/core';
+import { Component, inject, type OnInit, signal } from '@angular/core';
 import { RouterLink } from '@angular/router';
+import { JobsApiService } from '../../api/services/jobs-api.service';
+import type { Job, JobStatsResponse } from '../../api/schemas/jobs.schema';
+import { ToastService } from '../../services/toast.service';
 import { CardComponent } from '../../components/card.component';
 import { ButtonComponent } from '../../components/button.component';
 import { IconComponent } from '../../components/icon.component';
 
-interface Job {
-  id: number;
-  title: string;
-  department: string;
-  location: string;
-  type: 'full-time' | 'part-time' | 'contract' | 'internship';
-  status: 'draft' | 'published' | 'closed';
-  applicants: number;
-  publishedTo: string[];
-  createdAt: string;
-}
-
 @Component({
   selector: 'app-jobs-list',
   standalone: true,
@@ -120,7 +111,7 @@ interface Job {
                         [class]="getStatusClass(job.status)"
                         class="px-3 py-1 rounded-full text-xs font-medium"
                       >
-                        {{ job.status }}
+                        {{ job.status.replace('_', ' ') }}
                       </span>
                     </div>
                     <div class="flex flex-wrap items-center gap-4 mt-2 text-sm text-text-secondary">
@@ -147,7 +138,7 @@ interface Job {
                         <div class="flex gap-2">
                           @for (platform of job.publishedTo; track platform) {
                             <span class="px-2 py-1 bg-surface-elevated rounded text-xs text-text-secondary">
-                              {{ platform }}
+                              {{ channelName(platform) }}
                             </span>
                           }
                         </div>
@@ -193,42 +184,9 @@ interface Job {
     </div>
   `,
 })
-export class JobsListComponent {
-  jobs = signal<Job[]>([
-    {
-      id: 1,
-      title: 'Senior Frontend Developer',
-      department: 'Engineering',
-      location: 'Remote',
-      type: 'full-time',
-      status: 'published',
-      applicants: 24,
-      publishedTo: ['LinkedIn', 'Indeed'],
-      createdAt: '2025-10-01',
-    },
-    {
-      id: 2,
-      title: 'Product Designer',
-      department: 'Design',
-      location: 'New York, NY',
-      type: 'full-time',
-      status: 'published',
-      applicants: 15,
-      publishedTo: ['LinkedIn'],
-      createdAt: '2025-10-03',
-    },
-    {
-      id: 3,
-      title: 'Marketing Manager',
-      department: 'Marketing',
-      location: 'San Francisco, CA',
-      type: 'full-time',
-      status: 'draft',
-      applicants: 0,
-      publishedTo: [],
-      createdAt: '2025-10-05',
-    },
-  ]);
+export class JobsListComponent implements OnInit {
+  private jobsApi = inject(JobsApiService);
+  private toast = inject(ToastService);
 
   activeFilter = signal<string>('all');
 
@@ -236,39 +194,82 @@ export class JobsListComponent {
     { label: 'All Jobs', value: 'all' },
     { label: 'Published', value: 'published' },
     { label: 'Draft', value: 'draft' },
+    { label: 'Pending Approval', value: 'pending_approval' },
+    { label: 'Paused', value: 'paused' },
     { label: 'Closed', value: 'closed' },
   ];
 
-  stats = signal({
-    total: 3,
-    published: 2,
-    applicants: 39,
-    drafts: 1,
+  stats = signal<JobStatsResponse>({
+    total: 0,
+    published: 0,
+    drafts: 0,
+    pending_approval: 0,
+    paused: 0,
+    closed: 0,
+    applicants: 0,
   });
 
-  filteredJobs = signal<Job[]>(this.jobs());
+  filteredJobs = signal<Job[]>([]);
+
+  private channelNames: Record<string, string> = {
+    internal: 'Internal Portal',
+    linkedin: 'LinkedIn',
+    indeed: 'Indeed',
+    glassdoor: 'Glassdoor',
+  };
+
+  ngOnInit(): void {
+    this.loadJobs();
+    this.loadStats();
+  }
 
   setActiveFilter(filter: string): void {
     this.activeFilter.set(filter);
-    if (filter === 'all') {
-      this.filteredJobs.set(this.jobs());
-    } else {
-      this.filteredJobs.set(this.jobs().filter(job => job.status === filter));
-    }
+    this.loadJobs();
   }
 
   getStatusClass(status: string): string {
     const classes: Record<string, string> = {
       published: 'bg-success/10 text-success',
       draft: 'bg-warning/10 text-warning',
+      pending_approval: 'bg-primary-500/10 text-primary-500',
+      paused: 'bg-surface-elevated text-text-secondary',
       closed: 'bg-error/10 text-error',
     };
     return classes[status] || '';
   }
 
+  channelName(channel: string): string {
+    return this.channelNames[channel] ?? channel;
+  }
+
   deleteJob(id: number): void {
-    // TODO: Implement delete confirmation and API call
-    console.log('Delete job', id);
+    const job = this.filteredJobs().find((j) => j.id === id);
+    if (!confirm(`Delete "${job?.title ?? 'this job'}"? This cannot be undone.`)) {
+      return;
+    }
+
+    this.jobsApi.deleteJob(id).subscribe({
+      next: () => {
+        this.filteredJobs.update((jobs) => jobs.filter((j) => j.id !== id));
+        this.loadStats();
+        this.toast.success('Job deleted', job?.title ?? '');
+      },
+      error: (error) => this.toast.error('Could not delete job', error.message),
+    });
   }
-}
 
+  private loadJobs(): void {
+    this.jobsApi.listJobs({ status: this.activeFilter(), perPage: 100 }).subscribe({
+      next: (response) => this.filteredJobs.set(response.jobs),
+      error: (error) => this.toast.error('Could not load jobs', error.message),
+    });
+  }
+
+  private loadStats(): void {
+    this.jobsApi.getStats().subscribe({
+      next: (stats) => this.stats.set(stats),
+      error: (error) => console.error('Failed to load job stats', error),
+    });
+  }
+}
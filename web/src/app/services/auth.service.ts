@@ -1,7 +1,11 @@
 import { Injectable, inject, signal } from '@angular/core';
 import { Router } from '@angular/router';
 import { catchError, type Observable, tap, throwError } from 'rxjs';
-import type { LoginResponse, RegisterResponse } from '../api/schemas/auth.schema';
+import type {
+  EmailChangeConfirmResponse,
+  LoginResponse,
+  RegisterResponse,
+} from '../api/schemas/auth.schema';
 import { AuthApiService } from '../api/services/auth-api.service';
 
 export interface User {
@@ -51,7 +55,7 @@ export class AuthService {
   ): Observable<LoginResponse> {
     return this.authApi.login(email, password, rememberMe).pipe(
       tap((response) => {
-        this.storeAuthData(response);
+        this.storeAuthData(response.token, response.refresh_token, response.user);
       }),
       catchError((error) => {
         console.error('Login failed:', error);
@@ -60,10 +64,25 @@ export class AuthService {
     );
   }
 
+  public loginWithMagicLink(token: string): Observable<LoginResponse> {
+    return this.authApi.consumeMagicLink(token).pipe(
+      tap((response) => {
+        this.storeAuthData(response.token, response.refresh_token, response.user);
+      }),
+      catchError((error) => {
+        console.error('Magic link login failed:', error);
+        return throwError(() => error);
+      }),
+    );
+  }
+
   public register(name: string, email: string, password: string): Observable<RegisterResponse> {
     return this.authApi.register(name, email, password).pipe(
       tap((response) => {
-        this.storeAuthData(response);
+        // Accounts awaiting approval get no tokens until an admin approves them
+        if (response.token && response.refresh_token) {
+          this.storeAuthData(response.token, response.refresh_token, response.user);
+        }
       }),
       catchError((error) => {
         console.error('Registration failed:', error);
@@ -72,6 +91,17 @@ export class AuthService {
     );
   }
 
+  // Changing the email signs the account out everywhere, this browser included
+  public confirmEmailChange(token: string): Observable<EmailChangeConfirmResponse> {
+    return this.authApi.confirmEmailChange(token).pipe(
+      tap(() => this.clearAuthState()),
+      catchError((error) => {
+        console.error('Email change confirmation failed:', error);
+        return throwError(() => error);
+      }),
+    );
+  }
+
   public logout(): void {
     this.authApi.logout().subscribe({
       next: () => {
@@ -86,13 +116,13 @@ export class AuthService {
     });
   }
 
-  private storeAuthData(response: LoginResponse | RegisterResponse): void {
-    localStorage.setItem('access_token', response.token);
-    localStorage.setItem('refresh_token', response.refresh_token);
-    localStorage.setItem('user', JSON.stringify(response.user));
+  private storeAuthData(token: string, refreshToken: string, user: User): void {
+    localStorage.setItem('access_token', token);
+    localStorage.setItem('refresh_token', refreshToken);
+    localStorage.setItem('user', JSON.stringify(user));
 
-    this.accessToken.set(response.token);
-    this.currentUser.set(response.user);
+    this.accessToken.set(token);
+    this.currentUser.set(user);
     this.isAuthenticated.set(true);
   }
 